@@ -38,6 +38,21 @@ func IsOwner(userID int) (bool) {
 	return false
 }
 
+// Helper function to allow for commands by server admins (anyone with Administrator or Manage Server)
+// The owner of the bot counts as an admin everywhere
+func IsAdmin(session *discordgo.Session, message *discordgo.MessageCreate, userID int) (bool) {
+	if IsOwner(userID) {
+		return true
+	}
+
+	permissions, err := session.UserChannelPermissions(message.Author.ID, message.ChannelID)
+	if err != nil {
+		fmt.Printf("Error occurred while getting user permissions! %s\n", err)
+		return false
+	}
+	return permissions&discordgo.PermissionAdministrator != 0 || permissions&discordgo.PermissionManageServer != 0
+}
+
 func DeleteMessages(session *discordgo.Session, message *discordgo.MessageCreate, userID int, amount int) (string) {
 	// Check if user is owner
 	if !IsOwner(userID) {
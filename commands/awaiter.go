@@ -0,0 +1,184 @@
+package commands
+
+import (
+	"fmt"
+	"sync"
+	"time"
+	"github.com/bwmarrin/discordgo"
+)
+
+// The awaiter lets a command wait for messages, reactions or button presses without adding its own handler
+// It only adds three handlers to the session when Mary starts, and every wait removes itself when it times out or is cancelled
+
+// Which events a wait is interested in
+// Empty fields match anything, so WaitKey{UserID: "1", ChannelID: "2"} matches every message from user 1 in channel 2
+type WaitKey struct {
+	UserID    string
+	ChannelID string
+	MessageID string // Reactions and button presses on this message
+	CustomID  string // Button presses with this custom ID
+}
+
+// Something that happened that a wait was interested in
+// Only one of Message, Reaction or Interaction is set
+type AwaitEvent struct {
+	Message     *discordgo.MessageCreate
+	Reaction    *discordgo.MessageReactionAdd
+	Interaction *discordgo.InteractionCreate
+	UserID      string
+	Elapsed     time.Duration // Time between the wait starting and the event
+}
+
+// A registered wait
+// Call Next to get each event, and Cancel when you're done with it
+type Wait struct {
+	id      uint64
+	key     WaitKey
+	started time.Time
+	events  chan AwaitEvent
+	done    chan struct{}
+	timer   *time.Timer
+	once    sync.Once
+}
+
+var waits = map[uint64]*Wait{}
+var waitsMutex sync.Mutex
+var nextWaitID uint64
+
+// Adds the handlers that feed events to the registered waits
+// Call this once, before opening the Discord connection
+func StartAwaiter(session *discordgo.Session) {
+	session.AddHandler(func(s *discordgo.Session, m *discordgo.MessageCreate) {
+		if m.Author == nil || m.Author.Bot {
+			return
+		}
+		dispatch(WaitKey{UserID: m.Author.ID, ChannelID: m.ChannelID}, AwaitEvent{Message: m, UserID: m.Author.ID})
+	})
+	session.AddHandler(func(s *discordgo.Session, r *discordgo.MessageReactionAdd) {
+		if r.UserID == s.State.User.ID {
+			return
+		}
+		dispatch(WaitKey{UserID: r.UserID, ChannelID: r.ChannelID, MessageID: r.MessageID}, AwaitEvent{Reaction: r, UserID: r.UserID})
+	})
+	session.AddHandler(func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+		if i.Type != discordgo.InteractionMessageComponent {
+			return
+		}
+		userID := ""
+		if i.Member != nil {
+			userID = i.Member.User.ID
+		} else if i.User != nil {
+			userID = i.User.ID
+		}
+		key := WaitKey{UserID: userID, ChannelID: i.ChannelID, CustomID: i.MessageComponentData().CustomID}
+		if i.Message != nil {
+			key.MessageID = i.Message.ID
+		}
+		if dispatch(key, AwaitEvent{Interaction: i, UserID: userID}) {
+			// Acknowledge the button press so Discord doesn't show "This interaction failed"
+			err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{Type: discordgo.InteractionResponseDeferredMessageUpdate})
+			if err != nil {
+				fmt.Printf("Error occurred while acknowledging interaction! %s\n", err)
+			}
+		}
+	})
+}
+
+// Not a command
+// Sends an event to every wait whose key matches, returning whether any did
+func dispatch(key WaitKey, event AwaitEvent) (bool) {
+	waitsMutex.Lock()
+	defer waitsMutex.Unlock()
+	matched := false
+	for _, wait := range waits {
+		if !wait.matches(key) {
+			continue
+		}
+		matched = true
+		event.Elapsed = time.Since(wait.started)
+		// Never block the event loop: if the waiting command is too far behind, the event is dropped
+		select {
+		case wait.events <- event:
+		default:
+		}
+	}
+	return matched
+}
+
+// Not a command
+// Checks if every field set in the wait's key matches the event
+func (w *Wait) matches(key WaitKey) (bool) {
+	return (w.key.UserID == "" || w.key.UserID == key.UserID) &&
+		(w.key.ChannelID == "" || w.key.ChannelID == key.ChannelID) &&
+		(w.key.MessageID == "" || w.key.MessageID == key.MessageID) &&
+		(w.key.CustomID == "" || w.key.CustomID == key.CustomID)
+}
+
+// Registers a wait for events matching the key, which is cancelled automatically after the timeout
+func Listen(key WaitKey, timeout time.Duration) (*Wait) {
+	waitsMutex.Lock()
+	defer waitsMutex.Unlock()
+	nextWaitID++
+	wait := &Wait{
+		id:      nextWaitID,
+		key:     key,
+		started: time.Now(),
+		events:  make(chan AwaitEvent, 32),
+		done:    make(chan struct{}),
+	}
+	// The timer can fire before this returns, but Cancel waits for the lock so it always sees the timer
+	wait.timer = time.AfterFunc(timeout, wait.Cancel)
+	waits[wait.id] = wait
+	return wait
+}
+
+// Waits for the next event, returning false once the wait has timed out or been cancelled
+func (w *Wait) Next() (AwaitEvent, bool) {
+	// Hand out events that already arrived before checking if the wait is over
+	select {
+	case event := <-w.events:
+		return event, true
+	default:
+	}
+	select {
+	case event := <-w.events:
+		return event, true
+	case <-w.done:
+		return AwaitEvent{}, false
+	}
+}
+
+// Stops the wait and removes it from the awaiter
+// It is safe to call this more than once
+func (w *Wait) Cancel() {
+	w.once.Do(func() {
+		waitsMutex.Lock()
+		w.timer.Stop()
+		delete(waits, w.id)
+		waitsMutex.Unlock()
+		close(w.done)
+	})
+}
+
+// Cancels every wait with exactly this key, e.g. when a trade is cancelled from another command
+func CancelWaits(key WaitKey) {
+	waitsMutex.Lock()
+	matching := []*Wait{}
+	for _, wait := range waits {
+		if wait.key == key {
+			matching = append(matching, wait)
+		}
+	}
+	waitsMutex.Unlock()
+	for _, wait := range matching {
+		wait.Cancel()
+	}
+}
+
+// Waits for a single event matching the key
+// Returns false if nothing happened before the timeout
+func Await(key WaitKey, timeout time.Duration) (AwaitEvent, bool) {
+	wait := Listen(key, timeout)
+	defer wait.Cancel()
+	return wait.Next()
+}
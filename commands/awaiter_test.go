@@ -0,0 +1,37 @@
+package commands
+
+import (
+	"testing"
+	"time"
+)
+
+func TestListenTimesOutStraightAway(t *testing.T) {
+	// A timer that fires before Listen returns used to race with the timer being saved
+	for i := 0; i < 100; i++ {
+		wait := Listen(WaitKey{ChannelID: "test"}, 0)
+		if _, ok := wait.Next(); ok {
+			t.Fatalf("got an event from a wait that should have timed out")
+		}
+		wait.Cancel()
+	}
+	waitsMutex.Lock()
+	defer waitsMutex.Unlock()
+	if len(waits) != 0 {
+		t.Errorf("%d waits were left registered after timing out", len(waits))
+	}
+}
+
+func TestDispatchReachesMatchingWait(t *testing.T) {
+	wait := Listen(WaitKey{ChannelID: "test", CustomID: "button"}, time.Minute)
+	defer wait.Cancel()
+	if dispatch(WaitKey{ChannelID: "other", CustomID: "button"}, AwaitEvent{UserID: "1"}) {
+		t.Errorf("an event from another channel matched the wait")
+	}
+	if !dispatch(WaitKey{UserID: "1", ChannelID: "test", CustomID: "button"}, AwaitEvent{UserID: "1"}) {
+		t.Fatalf("an event matching the key didn't reach the wait")
+	}
+	event, ok := wait.Next()
+	if !ok || event.UserID != "1" {
+		t.Errorf("got %+v, %v, want the event from user 1", event, ok)
+	}
+}
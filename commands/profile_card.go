@@ -0,0 +1,487 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // Animated avatars are GIFs
+	_ "image/jpeg"
+	"image/png"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Profile cards are PNGs drawn from scratch with the standard image packages
+// Cards are cached by everything shown on them, so asking for the same profile twice only draws it once
+
+// Everything shown on a profile card
+type ProfileCard struct {
+	UserName     string
+	Title        string
+	AvatarURL    string
+	Balance      int64
+	Spouse       string
+	Level        int
+	LevelXP      int64 // XP into the current level
+	LevelNeeded  int64 // XP the current level needs
+	TriviaStreak int
+	BestStreak   int
+	Badges       []string // Names of achievements and season badges
+	Background   string   // Background item from the shop, or empty for the default
+	Health       string   // e.g. "85/100 HP" or "Knocked out for 12m"
+}
+
+const cardWidth = 900
+const cardHeight = 300
+const avatarSize = 180
+
+// How long a card stays cached, and how many are kept
+const cardCacheTime = 10 * time.Minute
+const cardCacheSize = 200
+
+// Colours and decorations for each background
+type cardTheme struct {
+	Top      color.RGBA
+	Bottom   color.RGBA
+	Accent   color.RGBA // Level bar
+	Decorate func(img *image.RGBA, random *rand.Rand)
+}
+
+var cardThemes = map[string]cardTheme{
+	"": {
+		color.RGBA{0xff, 0xc0, 0xcb, 0xff}, color.RGBA{0xff, 0x8f, 0xab, 0xff}, color.RGBA{0xff, 0x4f, 0x8b, 0xff}, nil,
+	},
+	"galaxy": {
+		color.RGBA{0x2a, 0x14, 0x5a, 0xff}, color.RGBA{0x05, 0x02, 0x10, 0xff}, color.RGBA{0x9d, 0x7b, 0xff, 0xff},
+		func(img *image.RGBA, random *rand.Rand) {
+			for i := 0; i < 250; i++ {
+				brightness := uint8(120 + random.Intn(136))
+				size := 1 + random.Intn(2)
+				fillCircle(img, random.Intn(cardWidth), random.Intn(cardHeight), size, color.RGBA{brightness, brightness, brightness, 0xff})
+			}
+		},
+	},
+	"sakura": {
+		color.RGBA{0xff, 0xe4, 0xec, 0xff}, color.RGBA{0xff, 0xb7, 0xc5, 0xff}, color.RGBA{0xff, 0x6f, 0x91, 0xff},
+		func(img *image.RGBA, random *rand.Rand) {
+			for i := 0; i < 60; i++ {
+				fillCircle(img, random.Intn(cardWidth), random.Intn(cardHeight), 4+random.Intn(6), color.NRGBA{0xff, 0x9e, 0xb5, 0xc0})
+			}
+		},
+	},
+	"ocean": {
+		color.RGBA{0x4f, 0xc3, 0xf7, 0xff}, color.RGBA{0x01, 0x57, 0x9b, 0xff}, color.RGBA{0x00, 0xe5, 0xff, 0xff},
+		func(img *image.RGBA, random *rand.Rand) {
+			for wave := 0; wave < 6; wave++ {
+				baseY := 40 + wave*45
+				phase := random.Float64() * math.Pi * 2
+				for x := 0; x < cardWidth; x++ {
+					y := baseY + int(8*math.Sin(float64(x)/40+phase))
+					fillCircle(img, x, y, 1, color.NRGBA{0xff, 0xff, 0xff, 0x50})
+				}
+			}
+		},
+	},
+}
+
+// The fonts are parsed once and shared, but faces keep state while drawing, so every card gets its own
+var cardFonts struct {
+	once    sync.Once
+	err     error
+	regular *opentype.Font
+	bold    *opentype.Font
+}
+
+type cardFaces struct {
+	name  font.Face
+	title font.Face
+	label font.Face
+	value font.Face
+}
+
+var cardCache = struct {
+	sync.Mutex
+	cards   map[uint64]cachedCard
+	avatars map[string]image.Image
+	themes  map[string]*image.RGBA // Backgrounds are the same every time, so they are only drawn once
+}{cards: map[uint64]cachedCard{}, avatars: map[string]image.Image{}, themes: map[string]*image.RGBA{}}
+
+type cachedCard struct {
+	PNG     []byte
+	Created time.Time
+}
+
+// Kept well under the time the profile command waits for a card, so a slow avatar still leaves time to draw
+// If it runs out, the card shows their first letter instead
+var avatarClient = &http.Client{Timeout: time.Second}
+
+// Draws a profile card as a PNG
+// If drawing takes longer than the timeout an error is returned so the caller can fall back to an embed,
+// but drawing carries on in the background and the card is cached for next time
+func RenderProfileCard(card ProfileCard, timeout time.Duration) ([]byte, error) {
+	key := cardKey(card)
+	cardCache.Lock()
+	cached, ok := cardCache.cards[key]
+	cardCache.Unlock()
+	if ok && time.Since(cached.Created) < cardCacheTime {
+		return cached.PNG, nil
+	}
+
+	type result struct {
+		png []byte
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		data, err := renderProfileCard(card)
+		if err == nil {
+			cacheCard(key, data)
+		}
+		done <- result{data, err}
+	}()
+	select {
+	case res := <-done:
+		return res.png, res.err
+	case <-time.After(timeout):
+		return nil, errors.New("drawing the profile card took too long")
+	}
+}
+
+// Not a command
+// Hashes everything on a card so that a change to any of it draws a new one
+func cardKey(card ProfileCard) (uint64) {
+	hash := fnv.New64a()
+	fmt.Fprintf(hash, "%#v", card)
+	return hash.Sum64()
+}
+
+// Not a command
+// Stores a card, throwing out the oldest one if the cache is full
+func cacheCard(key uint64, data []byte) {
+	cardCache.Lock()
+	defer cardCache.Unlock()
+	if len(cardCache.cards) >= cardCacheSize {
+		var oldestKey uint64
+		oldest := time.Now()
+		for k, cached := range cardCache.cards {
+			if cached.Created.Before(oldest) {
+				oldestKey, oldest = k, cached.Created
+			}
+		}
+		delete(cardCache.cards, oldestKey)
+	}
+	cardCache.cards[key] = cachedCard{PNG: data, Created: time.Now()}
+}
+
+// Not a command
+// Draws the card and encodes it
+func renderProfileCard(card ProfileCard) ([]byte, error) {
+	faces, err := newCardFaces()
+	if err != nil {
+		return nil, err
+	}
+	defer faces.Close()
+	theme, ok := cardThemes[card.Background]
+	if !ok {
+		theme = cardThemes[""]
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, cardWidth, cardHeight))
+	draw.Draw(img, img.Bounds(), themeBackground(card.Background, theme), image.Point{}, draw.Src)
+
+	// Dark panel so the text can be read on any background
+	panel := image.Rect(20, 20, cardWidth-20, cardHeight-20)
+	draw.DrawMask(img, panel, image.NewUniform(color.NRGBA{0, 0, 0, 0x8c}), image.Point{}, &roundedRect{panel, 24}, panel.Min, draw.Over)
+
+	// Avatar in a white circle
+	avatarRect := image.Rect(50, (cardHeight-avatarSize)/2, 50+avatarSize, (cardHeight+avatarSize)/2)
+	fillCircle(img, avatarRect.Min.X+avatarSize/2, avatarRect.Min.Y+avatarSize/2, avatarSize/2+5, color.RGBA{0xff, 0xff, 0xff, 0xff})
+	avatar := image.NewRGBA(image.Rect(0, 0, avatarSize, avatarSize))
+	if source := fetchAvatar(card.AvatarURL); source != nil {
+		xdraw.CatmullRom.Scale(avatar, avatar.Bounds(), source, source.Bounds(), xdraw.Src, nil)
+	} else {
+		// No avatar, so use their first letter instead
+		draw.Draw(avatar, avatar.Bounds(), image.NewUniform(theme.Accent), image.Point{}, draw.Src)
+		initial := strings.ToUpper(string([]rune(card.UserName + "?")[0]))
+		drawText(avatar, faces.name, initial, (avatarSize-font.MeasureString(faces.name, initial).Round())/2, avatarSize/2+14, color.White)
+	}
+	circle := &circleMask{image.Point{avatarSize / 2, avatarSize / 2}, avatarSize / 2}
+	draw.DrawMask(img, avatarRect, avatar, image.Point{}, circle, image.Point{}, draw.Over)
+
+	white := color.RGBA{0xff, 0xff, 0xff, 0xff}
+	grey := color.RGBA{0xcc, 0xcc, 0xcc, 0xff}
+	left := 270
+	right := cardWidth - 50
+
+	// Name and title, with the level on the right
+	level := "LEVEL " + strconv.Itoa(card.Level)
+	levelWidth := font.MeasureString(faces.name, level).Round()
+	drawText(img, faces.name, level, right-levelWidth, 80, theme.Accent)
+	drawText(img, faces.name, fitText(faces.name, card.UserName, right-levelWidth-left-20), left, 80, white)
+	if card.Title != "" {
+		drawText(img, faces.title, fitText(faces.title, card.Title, right-left), left, 110, grey)
+	}
+
+	// Level bar
+	bar := image.Rect(left, 130, right, 156)
+	draw.DrawMask(img, bar, image.NewUniform(color.NRGBA{0xff, 0xff, 0xff, 0x40}), image.Point{}, &roundedRect{bar, 13}, bar.Min, draw.Over)
+	if card.LevelNeeded > 0 && card.LevelXP > 0 {
+		filled := bar
+		filled.Max.X = bar.Min.X + int(float64(bar.Dx())*math.Min(1, float64(card.LevelXP)/float64(card.LevelNeeded)))
+		if filled.Dx() < 26 {
+			filled.Max.X = bar.Min.X + 26
+		}
+		draw.DrawMask(img, filled, image.NewUniform(theme.Accent), image.Point{}, &roundedRect{filled, 13}, filled.Min, draw.Over)
+	}
+	xp := fmt.Sprintf("%d / %d XP", card.LevelXP, card.LevelNeeded)
+	drawText(img, faces.label, xp, right-font.MeasureString(faces.label, xp).Round(), 176, grey)
+
+	// Stats
+	stats := []struct{ Label, Value string }{
+		{"WALLET", strconv.FormatInt(card.Balance, 10) + " coins"},
+		{"MARRIED TO", card.Spouse},
+		{"TRIVIA STREAK", fmt.Sprintf("%d (best %d)", card.TriviaStreak, card.BestStreak)},
+	}
+	if card.Health != "" {
+		stats = append(stats, struct{ Label, Value string }{"HEALTH", card.Health})
+	}
+	columnWidth := (right - left) / len(stats)
+	for i, stat := range stats {
+		x := left + i*columnWidth
+		drawText(img, faces.label, stat.Label, x, 200, grey)
+		drawText(img, faces.value, fitText(faces.value, stat.Value, columnWidth-10), x, 226, white)
+	}
+
+	badges := "No badges yet"
+	if len(card.Badges) > 0 {
+		badges = "Badges: " + strings.Join(card.Badges, ", ")
+	}
+	drawText(img, faces.label, fitText(faces.label, badges, right-left), left, 262, grey)
+
+	var buffer bytes.Buffer
+	encoder := png.Encoder{CompressionLevel: png.BestSpeed}
+	err = encoder.Encode(&buffer, img)
+	if err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// Not a command
+// Makes the faces for drawing one card, loading the Go fonts the first time a card is drawn
+func newCardFaces() (*cardFaces, error) {
+	cardFonts.once.Do(func() {
+		cardFonts.regular, cardFonts.err = opentype.Parse(goregular.TTF)
+		if cardFonts.err != nil {
+			return
+		}
+		cardFonts.bold, cardFonts.err = opentype.Parse(gobold.TTF)
+	})
+	if cardFonts.err != nil {
+		return nil, cardFonts.err
+	}
+
+	faces := &cardFaces{}
+	sizes := []struct {
+		face *font.Face
+		font *opentype.Font
+		size float64
+	}{
+		{&faces.name, cardFonts.bold, 36},
+		{&faces.title, cardFonts.regular, 20},
+		{&faces.label, cardFonts.regular, 16},
+		{&faces.value, cardFonts.bold, 20},
+	}
+	for _, f := range sizes {
+		var err error
+		*f.face, err = opentype.NewFace(f.font, &opentype.FaceOptions{Size: f.size, DPI: 72, Hinting: font.HintingFull})
+		if err != nil {
+			faces.Close()
+			return nil, err
+		}
+	}
+	return faces, nil
+}
+
+// Not a command
+// Frees the faces once the card is drawn
+func (faces *cardFaces) Close() {
+	for _, face := range []font.Face{faces.name, faces.title, faces.label, faces.value} {
+		if face != nil {
+			face.Close()
+		}
+	}
+}
+
+// Not a command
+// Gets a background, drawing it the first time it is used
+func themeBackground(name string, theme cardTheme) (*image.RGBA) {
+	cardCache.Lock()
+	defer cardCache.Unlock()
+	if background, ok := cardCache.themes[name]; ok {
+		return background
+	}
+	background := image.NewRGBA(image.Rect(0, 0, cardWidth, cardHeight))
+	for y := 0; y < cardHeight; y++ {
+		t := float64(y) / float64(cardHeight-1)
+		row := color.RGBA{
+			uint8(float64(theme.Top.R)*(1-t) + float64(theme.Bottom.R)*t),
+			uint8(float64(theme.Top.G)*(1-t) + float64(theme.Bottom.G)*t),
+			uint8(float64(theme.Top.B)*(1-t) + float64(theme.Bottom.B)*t),
+			0xff,
+		}
+		draw.Draw(background, image.Rect(0, y, cardWidth, y+1), image.NewUniform(row), image.Point{}, draw.Src)
+	}
+	if theme.Decorate != nil {
+		// A fixed seed keeps the decorations in the same place on every card
+		theme.Decorate(background, rand.New(rand.NewSource(int64(len(name)))))
+	}
+	cardCache.themes[name] = background
+	return background
+}
+
+// Not a command
+// Downloads an avatar, keeping it so that it is only downloaded once
+func fetchAvatar(url string) (image.Image) {
+	if url == "" {
+		return nil
+	}
+	cardCache.Lock()
+	avatar, ok := cardCache.avatars[url]
+	cardCache.Unlock()
+	if ok {
+		return avatar
+	}
+
+	response, err := avatarClient.Get(url)
+	if err != nil {
+		fmt.Printf("Error occurred while downloading avatar! %s\n", err)
+		return nil
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		fmt.Printf("Error occurred while downloading avatar! Status %d\n", response.StatusCode)
+		return nil
+	}
+	avatar, _, err = image.Decode(response.Body)
+	if err != nil {
+		fmt.Printf("Error occurred while reading avatar! %s\n", err)
+		return nil
+	}
+
+	cardCache.Lock()
+	if len(cardCache.avatars) >= cardCacheSize {
+		// Avatar URLs change when the avatar does, so old ones can just be dropped
+		cardCache.avatars = map[string]image.Image{}
+	}
+	cardCache.avatars[url] = avatar
+	cardCache.Unlock()
+	return avatar
+}
+
+// Not a command
+// Draws text with its baseline at y
+func drawText(img draw.Image, face font.Face, text string, x int, y int, colour color.Color) {
+	drawer := font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(colour),
+		Face: face,
+		Dot:  fixed.P(x, y),
+	}
+	drawer.DrawString(text)
+}
+
+// Not a command
+// Shortens text with "..." until it fits in a width
+func fitText(face font.Face, text string, width int) (string) {
+	if font.MeasureString(face, text).Round() <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		shortened := strings.TrimSpace(string(runes)) + "..."
+		if font.MeasureString(face, shortened).Round() <= width {
+			return shortened
+		}
+	}
+	return ""
+}
+
+// Not a command
+// Fills a circle directly on an image
+func fillCircle(img *image.RGBA, cx int, cy int, radius int, colour color.Color) {
+	rect := image.Rect(cx-radius, cy-radius, cx+radius+1, cy+radius+1)
+	draw.DrawMask(img, rect, image.NewUniform(colour), image.Point{}, &circleMask{image.Point{cx, cy}, radius}, rect.Min, draw.Over)
+}
+
+// A mask that is opaque inside a circle
+type circleMask struct {
+	Center image.Point
+	Radius int
+}
+
+func (c *circleMask) ColorModel() color.Model {
+	return color.AlphaModel
+}
+
+func (c *circleMask) Bounds() image.Rectangle {
+	return image.Rect(c.Center.X-c.Radius, c.Center.Y-c.Radius, c.Center.X+c.Radius+1, c.Center.Y+c.Radius+1)
+}
+
+func (c *circleMask) At(x int, y int) color.Color {
+	dx, dy := float64(x-c.Center.X)+0.5, float64(y-c.Center.Y)+0.5
+	return color.Alpha{edgeAlpha(float64(c.Radius) - math.Sqrt(dx*dx+dy*dy))}
+}
+
+// A mask that is opaque inside a rectangle with rounded corners
+type roundedRect struct {
+	Rect   image.Rectangle
+	Radius int
+}
+
+func (r *roundedRect) ColorModel() color.Model {
+	return color.AlphaModel
+}
+
+func (r *roundedRect) Bounds() image.Rectangle {
+	return r.Rect
+}
+
+func (r *roundedRect) At(x int, y int) color.Color {
+	radius := r.Radius
+	if radius*2 > r.Rect.Dy() {
+		radius = r.Rect.Dy() / 2
+	}
+	// Only the corners need checking, everything else is inside
+	cx := math.Max(float64(r.Rect.Min.X+radius), math.Min(float64(x)+0.5, float64(r.Rect.Max.X-radius)))
+	cy := math.Max(float64(r.Rect.Min.Y+radius), math.Min(float64(y)+0.5, float64(r.Rect.Max.Y-radius)))
+	dx, dy := float64(x)+0.5-cx, float64(y)+0.5-cy
+	return color.Alpha{edgeAlpha(float64(radius) - math.Sqrt(dx*dx+dy*dy))}
+}
+
+// Not a command
+// Turns the distance inside an edge into an alpha, so edges are smoothed over one pixel
+func edgeAlpha(distance float64) (uint8) {
+	if distance >= 0.5 {
+		return 0xff
+	} else if distance <= -0.5 {
+		return 0
+	}
+	return uint8((distance + 0.5) * 0xff)
+}
@@ -0,0 +1,357 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"github.com/bwmarrin/discordgo"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Something to unlock by playing
+// An achievement either counts the events it listens for, or checks a stat on the user after each one
+// The defaults can be replaced with a JSON file of these, pointed to by the ACHIEVEMENTS_CONFIG env var
+type Achievement struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Badge       string `json:"badge"` // Emoji shown on the profile
+	Description string `json:"description"`
+	Event       string `json:"event"` // The event type that can unlock it
+	Item        string `json:"item"`  // Only count events for this item, e.g. "car"
+	Stat        string `json:"stat"`  // Check this field on the user instead of counting events, e.g. "trivia_streak"
+	Goal        int64  `json:"goal"`  // How many events, or how high the stat has to be
+	Reward      int64  `json:"reward"` // Coins given on unlock
+	Title       string `json:"title"`  // Title unlocked for the profile, if any
+}
+
+// An achievement a user has, stored in their achievements array
+type UnlockedAchievement struct {
+	ID         string    `bson:"id"`
+	UnlockedAt time.Time `bson:"unlocked_at"`
+}
+
+var defaultAchievements = []Achievement{
+	{"first_marriage", "Just Married", "💍", "Get married for the first time", "marry", "", "", 1, 500, ""},
+	{"first_car", "Road Trip", "🚗", "Buy a car", "buy", "car", "", 1, 0, ""},
+	{"bulletproof", "Bulletproof", "🛡️", "Survive an attack thanks to a shield", "shield_block", "shield", "", 1, 1000, ""},
+	{"robber", "Career Criminal", "🦹", "Rob someone 10 times", "rob", "", "", 10, 0, "the Thief"},
+	{"golden_ticket", "Golden Ticket", "🍫", "Find a golden ticket in a chocolate bar", "golden_ticket", "", "", 1, 0, "the Lucky"},
+	{"trivia_streak", "Know-It-All", "🧠", "Answer 10 trivia questions in a row correctly", "trivia_answer", "", "trivia_streak", 10, 1000, "the Wise"},
+	{"trivia_100", "Quiz Master", "📚", "Answer 100 trivia questions correctly", "trivia_answer", "", "trivia_correct", 100, 2000, ""},
+	{"dedicated", "Dedicated", "📅", "Claim your daily 30 times", "daily", "", "", 30, 1000, ""},
+	{"investor", "Investor", "📈", "Buy your first stock", "stock_buy", "", "", 1, 0, ""},
+	{"level_10", "Regular", "⭐", "Reach level 10", "level_up", "", "level", 10, 0, ""},
+	{"level_25", "Veteran", "🌟", "Reach level 25", "level_up", "", "level", 25, 5000, "the Veteran"},
+}
+
+var achievements []Achievement
+var achievementsOnce sync.Once
+
+func init() {
+	onEvent(achievementsForEvent)
+}
+
+// Not a command
+// Gets every achievement, from the ACHIEVEMENTS_CONFIG file if there is one
+func getAchievements() ([]Achievement) {
+	achievementsOnce.Do(func() {
+		achievements = defaultAchievements
+		path := os.Getenv("ACHIEVEMENTS_CONFIG")
+		if path == "" {
+			return
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Printf("Error reading achievements config! %s\n", err)
+			return
+		}
+		var configs []Achievement
+		err = json.Unmarshal(data, &configs)
+		if err != nil || len(configs) == 0 {
+			fmt.Printf("Error parsing achievements config! %v\n", err)
+			return
+		}
+		for i := range configs {
+			if configs[i].Goal < 1 {
+				configs[i].Goal = 1
+			}
+		}
+		achievements = configs
+	})
+	return achievements
+}
+
+// Not a command
+// Counts the event towards every achievement listening for it, and unlocks any that are complete
+func achievementsForEvent(ctx context.Context, client *mongo.Client, event GameEvent) {
+	matching := []Achievement{}
+	for _, achievement := range getAchievements() {
+		if achievement.Event == event.Type && (achievement.Item == "" || achievement.Item == event.Item) {
+			matching = append(matching, achievement)
+		}
+	}
+	if len(matching) == 0 {
+		return
+	}
+
+	userCollection := client.Database(strconv.Itoa(event.GuildID)).Collection("Users")
+	filter := bson.D{
+		{Key: "user_id", Value: event.UserID},
+		{Key: "guild_id", Value: event.GuildID},
+	}
+	counters := bson.D{}
+	for _, achievement := range matching {
+		if achievement.Stat == "" {
+			counters = append(counters, bson.E{Key: "achievement_progress." + achievement.ID, Value: 1})
+		}
+	}
+
+	// Count the event and get the user's stats in one go
+	var user bson.Raw
+	var err error
+	if len(counters) > 0 {
+		err = userCollection.FindOneAndUpdate(
+			ctx,
+			filter,
+			bson.D{{Key: "$inc", Value: counters}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&user)
+	} else {
+		err = userCollection.FindOne(ctx, filter).Decode(&user)
+	}
+	if err == mongo.ErrNoDocuments {
+		return
+	} else if err != nil {
+		fmt.Printf("Error occurred while updating achievements! %s\n", err)
+		return
+	}
+
+	for _, achievement := range matching {
+		if achievementProgress(user, achievement) < achievement.Goal {
+			continue
+		}
+		err = unlockAchievement(ctx, client, event, achievement)
+		if err != nil {
+			fmt.Printf("Error occurred while unlocking achievement! %s\n", err)
+		}
+	}
+}
+
+// Not a command
+// Gets how far a user is towards an achievement from their user document
+func achievementProgress(user bson.Raw, achievement Achievement) (int64) {
+	var value bson.RawValue
+	var err error
+	if achievement.Stat == "" {
+		value, err = user.LookupErr("achievement_progress", achievement.ID)
+	} else {
+		value, err = user.LookupErr(achievement.Stat)
+	}
+	if err != nil {
+		return 0
+	}
+	progress, ok := value.AsInt64OK()
+	if !ok {
+		return 0
+	}
+	return progress
+}
+
+// Not a command
+// Gives a user an achievement along with its rewards, and tells them about it
+// Nothing happens if they already have it, so it is only ever rewarded once
+func unlockAchievement(ctx context.Context, client *mongo.Client, event GameEvent, achievement Achievement) (error) {
+	userCollection := client.Database(strconv.Itoa(event.GuildID)).Collection("Users")
+	update := bson.D{
+		{Key: "$push", Value: bson.D{
+			{Key: "achievements", Value: UnlockedAchievement{ID: achievement.ID, UnlockedAt: time.Now()}},
+		}},
+		{Key: "$inc", Value: bson.D{
+			{Key: "balance", Value: achievement.Reward},
+		}},
+	}
+	if achievement.Title != "" {
+		update = append(update, bson.E{Key: "$addToSet", Value: bson.D{
+			{Key: "titles", Value: achievement.Title},
+		}})
+	}
+	result, err := userCollection.UpdateOne(
+		ctx,
+		bson.D{
+			{Key: "user_id", Value: event.UserID},
+			{Key: "guild_id", Value: event.GuildID},
+			{Key: "achievements.id", Value: bson.D{{Key: "$ne", Value: achievement.ID}}},
+		},
+		update,
+	)
+	if err != nil || result.ModifiedCount == 0 {
+		return err
+	}
+	addSeasonEarnings(ctx, userCollection, event.GuildID, event.UserID, int64(achievement.Reward))
+
+	content := fmt.Sprintf("%s Achievement unlocked: **%s** - %s!", achievement.Badge, achievement.Name, achievement.Description)
+	if achievement.Reward > 0 {
+		content += fmt.Sprintf(" You earned %d coins.", achievement.Reward)
+	}
+	if achievement.Title != "" {
+		content += fmt.Sprintf(" You can now use the title \"%s\" with `mary title`.", achievement.Title)
+	}
+	notifyEvent(event, content)
+	return nil
+}
+
+// mary achievements [optional: @user] -> shows every achievement, which ones a user has and their progress on the rest
+func Achievements(mongoURI string, guildID int, guildName string, userID int, userName string) (string, *discordgo.MessageEmbed) {
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error()), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error()), nil
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	// Check if user exists in database
+	res := IsPlaying(ctx, client, guildID, guildName, userID, userName)
+	if res != "" {
+		return res, nil
+	}
+
+	var raw bson.Raw
+	err = client.Database(strconv.Itoa(guildID)).Collection("Users").FindOne(ctx, bson.M{"guild_id": guildID, "user_id": userID}).Decode(&raw)
+	if err != nil {
+		fmt.Printf("Error occurred while finding user in database! %s\n", err)
+		return "Error occurred while finding user in database! " + strings.Title(err.Error()), nil
+	}
+	var user User
+	err = bson.Unmarshal(raw, &user)
+	if err != nil {
+		fmt.Printf("Error occurred while reading user! %s\n", err)
+		return "Error occurred while reading user! " + strings.Title(err.Error()), nil
+	}
+	unlocked := map[string]time.Time{}
+	for _, achievement := range user.Achievements {
+		unlocked[achievement.ID] = achievement.UnlockedAt
+	}
+
+	all := getAchievements()
+	embed := &discordgo.MessageEmbed{
+		Title: fmt.Sprintf("%s's Achievements (%d/%d)", userName, len(unlocked), len(all)),
+		Color: 0xffc0cb,
+	}
+	for _, achievement := range all {
+		rewards := []string{}
+		if achievement.Reward > 0 {
+			rewards = append(rewards, fmt.Sprintf("%d coins", achievement.Reward))
+		}
+		if achievement.Title != "" {
+			rewards = append(rewards, "title \""+achievement.Title+"\"")
+		}
+		value := achievement.Description
+		if len(rewards) > 0 {
+			value += "\nReward: " + strings.Join(rewards, ", ")
+		}
+
+		name := "🔒 " + achievement.Name
+		if unlockedAt, ok := unlocked[achievement.ID]; ok {
+			name = achievement.Badge + " " + achievement.Name
+			value += "\nUnlocked " + unlockedAt.Format("Jan 2, 2006")
+		} else if achievement.Goal > 1 {
+			progress := achievementProgress(raw, achievement)
+			if progress > achievement.Goal {
+				progress = achievement.Goal
+			}
+			value += fmt.Sprintf("\n%s %d/%d", ProgressBar(progress, achievement.Goal), progress, achievement.Goal)
+		}
+		// Embeds can only have 25 fields
+		if len(embed.Fields) == 25 {
+			break
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: name, Value: value, Inline: true})
+	}
+	return "", embed
+}
+
+// mary title [optional: title/none] -> shows the titles a user has unlocked, or picks one to show on their profile
+func SetTitle(mongoURI string, guildID int, guildName string, userID int, userName string, title string) (string) {
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error())
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	// Check if user exists in database
+	res := IsPlaying(ctx, client, guildID, guildName, userID, userName)
+	if res != "" {
+		return res
+	}
+
+	userCollection := client.Database(strconv.Itoa(guildID)).Collection("Users")
+	filter := bson.M{"guild_id": guildID, "user_id": userID}
+	var user User
+	err = userCollection.FindOne(ctx, filter).Decode(&user)
+	if err != nil {
+		fmt.Printf("Error occurred while finding user in database! %s\n", err)
+		return "Error occurred while finding user in database! " + strings.Title(err.Error())
+	}
+
+	if title == "" {
+		if len(user.Titles) == 0 {
+			return "You haven't unlocked any titles yet! Some achievements give you one. Check them with `mary achievements`."
+		}
+		return "Your titles: " + strings.Join(user.Titles, ", ") + "\nUse `mary title [title]` to show one on your profile."
+	}
+
+	newTitle := ""
+	if strings.ToLower(title) != "none" {
+		for _, unlocked := range user.Titles {
+			if strings.EqualFold(unlocked, title) {
+				newTitle = unlocked
+			}
+		}
+		if newTitle == "" {
+			return "You haven't unlocked that title!"
+		}
+	}
+	_, err = userCollection.UpdateOne(ctx, filter, bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "title", Value: newTitle},
+		}},
+	})
+	if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+		return "Error occurred while updating database! " + strings.Title(err.Error())
+	}
+	if newTitle == "" {
+		return "Your title has been removed."
+	}
+	return "You are now " + userName + " " + newTitle + "!"
+}
@@ -0,0 +1,618 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"github.com/bwmarrin/discordgo"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Items a user is auctioning off to the highest bidder
+// The items and the highest bid are both held by the auction until it's settled
+// Auctions for every server are kept in the Auctions collection of the global database so the scheduler can settle them in one go
+type Auction struct {
+	AuctionID      int       `bson:"auction_id"`
+	GuildID        int       `bson:"guild_id"`
+	ChannelID      string    `bson:"channel_id"` // Where the auction was started and where the result is posted
+	SellerID       int       `bson:"seller_id"`
+	SellerName     string    `bson:"seller_name"`
+	Item           string    `bson:"item"`
+	Quantity       int       `bson:"quantity"`
+	Wear           int       `bson:"wear,omitempty"` // Uses taken off one of the items, which goes along with them
+	Reserve        int64     `bson:"reserve"` // The lowest bid that will be accepted
+	HighBid        int64     `bson:"high_bid"`
+	HighBidderID   int       `bson:"high_bidder_id"` // 0 until someone bids
+	HighBidderName string    `bson:"high_bidder_name"`
+	Bids           int       `bson:"bids"`
+	Status         string    `bson:"status"` // open, settling, sold, unsold or cancelled
+	ItemsDelivered bool      `bson:"items_delivered"` // Whether the items have gone to the winner (or back to the seller) while settling
+	CoinsDelivered bool      `bson:"coins_delivered"` // Whether the winning bid has gone to the seller while settling
+	CreatedAt      time.Time `bson:"created_at"`
+	EndsAt         time.Time `bson:"ends_at"`
+	ClosedAt       time.Time `bson:"closed_at"`
+}
+
+const minAuctionDuration = 5 * time.Minute
+const maxAuctionDuration = 7 * 24 * time.Hour
+const maxAuctions = 5
+
+// Bids this close to the end push the end back, so nobody can win by bidding in the last second
+const antiSnipeWindow = 2 * time.Minute
+
+// Not a command
+// Parses how long an auction lasts, e.g. "30m", "12h" or "2d"
+func ParseAuctionDuration(arg string) (time.Duration, string) {
+	duration, err := parseDays(arg)
+	if err != nil || duration < minAuctionDuration {
+		return 0, "Please enter a valid duration of at least 5 minutes, like 30m, 12h or 2d!"
+	}
+	if duration > maxAuctionDuration {
+		return 0, "Auctions can't last longer than 7 days!"
+	}
+	return duration, ""
+}
+
+// Not a command
+// Gets the lowest bid that would beat the current one
+// Each bid has to be at least 5% higher than the last, so bids can't creep up one coin at a time
+func minimumBid(auction Auction) (int64) {
+	if auction.HighBidderID == 0 {
+		return auction.Reserve
+	}
+	step := int64(math.Ceil(float64(auction.HighBid) * 0.05))
+	if step < 1 {
+		step = 1
+	}
+	return auction.HighBid + step
+}
+
+// Not a command
+// Builds the embed that shows an auction
+func auctionEmbed(auction Auction) (*discordgo.MessageEmbed) {
+	name := strings.Title(auction.Item)
+	highBid := "No bids yet"
+	if auction.HighBidderID != 0 {
+		highBid = fmt.Sprintf("%d coins from %s", auction.HighBid, auction.HighBidderName)
+	}
+	ends := fmt.Sprintf("<t:%d:R>", auction.EndsAt.Unix())
+	if auction.Status != "open" {
+		ends = "Ended (" + auction.Status + ")"
+	}
+	return &discordgo.MessageEmbed{
+		Title: fmt.Sprintf("Auction #%d: %s %dX %s%s", auction.AuctionID, itemEmoji(name), auction.Quantity, name, describeWear(auction.Item, auction.Wear)),
+		Color: 0xffc0cb,
+		Fields: []*discordgo.MessageEmbedField{
+			{
+				Name: "Seller",
+				Value: auction.SellerName,
+				Inline: true,
+			},
+			{
+				Name: "Reserve",
+				Value: fmt.Sprintf("%d coins", auction.Reserve),
+				Inline: true,
+			},
+			{
+				Name: "Highest Bid",
+				Value: highBid,
+				Inline: true,
+			},
+			{
+				Name: "Ends",
+				Value: ends,
+				Inline: true,
+			},
+		},
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: fmt.Sprintf("mary auction bid %d [amount] • Minimum bid: %d coins", auction.AuctionID, minimumBid(auction)),
+		},
+	}
+}
+
+// mary auction start [item] [amount] [reserve] [duration] -> auctions items off to the highest bidder
+func StartAuction(mongoURI string, guildID int, guildName string, userID int, userName string, channelID string, item string, quantity int, reserve int64, duration time.Duration) (string, *discordgo.MessageEmbed) {
+	item = knownItemName(item)
+	if item == "" {
+		return "That item doesn't exist!", nil
+	}
+	if quantity < 1 {
+		return "Please specify a positive amount!", nil
+	}
+	if reserve < 1 || reserve > maxListingPrice {
+		return fmt.Sprintf("Please specify a reserve between 1 and %d coins!", maxListingPrice), nil
+	}
+
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error()), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error()), nil
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	// Check if user exists in database
+	res := IsPlaying(ctx, client, guildID, guildName, userID, userName)
+	if res != "" {
+		return res, nil
+	}
+
+	auctionCollection := client.Database(globalDatabase).Collection("Auctions")
+	open, err := auctionCollection.CountDocuments(ctx, bson.M{"guild_id": guildID, "seller_id": userID, "status": "open"})
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return "Error occurred while selecting from database! " + strings.Title(err.Error()), nil
+	}
+	if open >= maxAuctions {
+		return fmt.Sprintf("You can only run %d auctions at a time!", maxAuctions), nil
+	}
+
+	userCollection := client.Database(strconv.Itoa(guildID)).Collection("Users")
+	taken, wear, err := takeItemLot(ctx, userCollection, guildID, userID, item, quantity)
+	if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+		return "Error occurred while updating database! " + strings.Title(err.Error()), nil
+	}
+	if !taken {
+		return "You don't have enough of that item to auction!", nil
+	}
+
+	now := time.Now()
+	auction := Auction{
+		GuildID:    guildID,
+		ChannelID:  channelID,
+		SellerID:   userID,
+		SellerName: userName,
+		Item:       item,
+		Quantity:   quantity,
+		Wear:       wear,
+		Reserve:    reserve,
+		Status:     "open",
+		CreatedAt:  now,
+		EndsAt:     now.Add(duration),
+	}
+	auction.AuctionID, err = nextSequence(ctx, client, "auctions")
+	if err == nil {
+		_, err = auctionCollection.InsertOne(ctx, auction)
+	}
+	if err != nil {
+		fmt.Printf("Error occurred while inserting into database! %s\n", err)
+		if err := addItemLot(ctx, userCollection, guildID, userID, item, quantity, wear); err != nil {
+			fmt.Printf("Error occurred while returning %dX %s to user %d! %s\n", quantity, item, userID, err)
+		}
+		return "Error occurred while inserting into database! " + strings.Title(err.Error()), nil
+	}
+	return "", auctionEmbed(auction)
+}
+
+// mary auction [auction number] -> shows an auction
+func ShowAuction(mongoURI string, guildID int, auctionID int) (string, *discordgo.MessageEmbed) {
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error()), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error()), nil
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	var auction Auction
+	err = client.Database(globalDatabase).Collection("Auctions").FindOne(ctx, bson.M{"guild_id": guildID, "auction_id": auctionID}).Decode(&auction)
+	if err == mongo.ErrNoDocuments {
+		return fmt.Sprintf("There's no auction #%d!", auctionID), nil
+	} else if err != nil {
+		fmt.Printf("Error occurred while finding auction in database! %s\n", err)
+		return "Error occurred while finding auction in database! " + strings.Title(err.Error()), nil
+	}
+	return "", auctionEmbed(auction)
+}
+
+// mary auctions -> shows the auctions running in the server, ending soonest first
+func ListAuctions(mongoURI string, guildID int, guildName string) (string, *discordgo.MessageEmbed) {
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error()), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error()), nil
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	cursor, err := client.Database(globalDatabase).Collection("Auctions").Find(
+		ctx,
+		bson.M{"guild_id": guildID, "status": "open"},
+		options.Find().SetSort(bson.D{{Key: "ends_at", Value: 1}}).SetLimit(10),
+	)
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return "Error occurred while selecting from database! " + strings.Title(err.Error()), nil
+	}
+	var auctions []Auction
+	err = cursor.All(ctx, &auctions)
+	if err != nil {
+		fmt.Printf("Error occurred while decoding result! %s\n", err)
+		return "Error occurred while decoding result! " + strings.Title(err.Error()), nil
+	}
+	if len(auctions) == 0 {
+		return "There are no auctions running! Start one with `mary auction start [item] [amount] [reserve] [duration]`.", nil
+	}
+
+	lines := []string{}
+	for _, auction := range auctions {
+		name := strings.Title(auction.Item)
+		bid := fmt.Sprintf("reserve %d coins", auction.Reserve)
+		if auction.HighBidderID != 0 {
+			bid = fmt.Sprintf("highest bid %d coins", auction.HighBid)
+		}
+		lines = append(lines, fmt.Sprintf("**#%d** %s %dX %s from %s, %s, ends <t:%d:R>", auction.AuctionID, itemEmoji(name), auction.Quantity, name, auction.SellerName, bid, auction.EndsAt.Unix()))
+	}
+	embed := &discordgo.MessageEmbed{
+		Title: guildName + "'s Auctions",
+		Description: strings.Join(lines, "\n"),
+		Color: 0xffc0cb,
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "mary auction [auction number] for details • mary auction bid [auction number] [amount]",
+		},
+	}
+	return "", embed
+}
+
+// mary auction bid [auction number] [amount] -> bids on an auction, holding your coins until you're outbid or it ends
+func BidAuction(mongoURI string, guildID int, guildName string, userID int, userName string, auctionID int, amount int64) (string) {
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error())
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	// Check if user exists in database
+	res := IsPlaying(ctx, client, guildID, guildName, userID, userName)
+	if res != "" {
+		return res
+	}
+
+	auctionCollection := client.Database(globalDatabase).Collection("Auctions")
+	userCollection := client.Database(strconv.Itoa(guildID)).Collection("Users")
+	var auction Auction
+	err = auctionCollection.FindOne(ctx, bson.M{"guild_id": guildID, "auction_id": auctionID, "status": "open"}).Decode(&auction)
+	if err == mongo.ErrNoDocuments {
+		return fmt.Sprintf("There's no auction #%d running!", auctionID)
+	} else if err != nil {
+		fmt.Printf("Error occurred while finding auction in database! %s\n", err)
+		return "Error occurred while finding auction in database! " + strings.Title(err.Error())
+	}
+	now := time.Now()
+	if !now.Before(auction.EndsAt) {
+		return fmt.Sprintf("Auction #%d has ended!", auctionID)
+	}
+	if auction.SellerID == userID {
+		return "You can't bid on your own auction!"
+	}
+	if auction.HighBidderID == userID {
+		return "You are already the highest bidder!"
+	}
+	if minimum := minimumBid(auction); amount < minimum {
+		return fmt.Sprintf("Your bid has to be at least %d coins!", minimum)
+	}
+
+	// Hold the bid, then try to replace the highest bid, giving the coins back if someone else bid first
+	paid, err := takeCoins(ctx, userCollection, guildID, userID, amount)
+	if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+		return "Error occurred while updating database! " + strings.Title(err.Error())
+	}
+	if !paid {
+		return "You don't have enough coins for that bid!"
+	}
+
+	endsAt := auction.EndsAt
+	extended := false
+	if endsAt.Sub(now) < antiSnipeWindow {
+		endsAt = now.Add(antiSnipeWindow)
+		extended = true
+	}
+	result, err := auctionCollection.UpdateOne(
+		ctx,
+		bson.D{
+			{Key: "auction_id", Value: auctionID},
+			{Key: "status", Value: "open"},
+			{Key: "high_bid", Value: auction.HighBid},
+			{Key: "high_bidder_id", Value: auction.HighBidderID},
+			{Key: "ends_at", Value: bson.D{{Key: "$gt", Value: now}}},
+		},
+		bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "high_bid", Value: amount},
+				{Key: "high_bidder_id", Value: userID},
+				{Key: "high_bidder_name", Value: userName},
+				{Key: "ends_at", Value: endsAt},
+			}},
+			{Key: "$inc", Value: bson.D{
+				{Key: "bids", Value: 1},
+			}},
+		},
+	)
+	if err != nil || result.ModifiedCount == 0 {
+		if err != nil {
+			fmt.Printf("Error occurred while updating database! %s\n", err)
+		}
+		if err := addCoins(ctx, userCollection, guildID, userID, amount); err != nil {
+			fmt.Printf("Error occurred while refunding %d coins to user %d! %s\n", amount, userID, err)
+		}
+		return "Someone else bid first or the auction ended! Your coins have been refunded."
+	}
+
+	// The last highest bidder gets their coins back straight away
+	if auction.HighBidderID != 0 {
+		err = addCoins(ctx, userCollection, guildID, auction.HighBidderID, auction.HighBid)
+		if err != nil {
+			fmt.Printf("Error occurred while refunding %d coins to user %d! %s\n", auction.HighBid, auction.HighBidderID, err)
+		}
+		notifyUser(auction.HighBidderID, auction.ChannelID, fmt.Sprintf("You were outbid on auction #%d (%dX %s) in %s! Your %d coins have been refunded.", auctionID, auction.Quantity, auction.Item, guildName, auction.HighBid))
+	}
+
+	content := fmt.Sprintf("You are the highest bidder on auction #%d with %d coins!", auctionID, amount)
+	if extended {
+		content += fmt.Sprintf(" The auction has been extended to end <t:%d:R>.", endsAt.Unix())
+	}
+	return content
+}
+
+// mary auction cancel [auction number] -> cancels one of your auctions, if nobody has bid yet
+func CancelAuction(mongoURI string, guildID int, userID int, auctionID int) (string) {
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error())
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	var auction Auction
+	err = client.Database(globalDatabase).Collection("Auctions").FindOneAndUpdate(
+		ctx,
+		bson.D{
+			{Key: "auction_id", Value: auctionID},
+			{Key: "guild_id", Value: guildID},
+			{Key: "seller_id", Value: userID},
+			{Key: "status", Value: "open"},
+			{Key: "high_bidder_id", Value: 0},
+		},
+		bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "status", Value: "cancelled"},
+				{Key: "closed_at", Value: time.Now()},
+			}},
+		},
+	).Decode(&auction)
+	if err == mongo.ErrNoDocuments {
+		return fmt.Sprintf("You don't have an auction #%d without bids!", auctionID)
+	} else if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+		return "Error occurred while updating database! " + strings.Title(err.Error())
+	}
+	err = addItemLot(ctx, client.Database(strconv.Itoa(guildID)).Collection("Users"), guildID, userID, auction.Item, auction.Quantity, auction.Wear)
+	if err != nil {
+		fmt.Printf("Error occurred while returning %dX %s to user %d! %s\n", auction.Quantity, auction.Item, userID, err)
+		return "Error occurred while returning your items! " + strings.Title(err.Error())
+	}
+	return fmt.Sprintf("Cancelled auction #%d and gave you back %dX %s.", auctionID, auction.Quantity, auction.Item)
+}
+
+// Not a command
+// Settles every auction that has ended, and finishes any that Mary stopped in the middle of settling
+// Auctions are stored in the database, so ones that ended while Mary was offline are settled when she starts again
+func SettleAuctions(mongoURI string) {
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx)
+
+	auctionCollection := client.Database(globalDatabase).Collection("Auctions")
+	cursor, err := auctionCollection.Find(ctx, bson.D{
+		{Key: "$or", Value: bson.A{
+			bson.D{
+				{Key: "status", Value: "open"},
+				{Key: "ends_at", Value: bson.D{{Key: "$lte", Value: time.Now()}}},
+			},
+			bson.D{
+				{Key: "status", Value: "settling"},
+			},
+		}},
+	})
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return
+	}
+	var auctions []Auction
+	err = cursor.All(ctx, &auctions)
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return
+	}
+
+	for _, auction := range auctions {
+		if auction.Status == "open" {
+			// Claim the auction so a late bid can't land while it's being settled
+			result, err := auctionCollection.UpdateOne(
+				ctx,
+				bson.D{
+					{Key: "auction_id", Value: auction.AuctionID},
+					{Key: "status", Value: "open"},
+					{Key: "ends_at", Value: bson.D{{Key: "$lte", Value: time.Now()}}},
+				},
+				bson.D{
+					{Key: "$set", Value: bson.D{
+						{Key: "status", Value: "settling"},
+					}},
+				},
+			)
+			if err != nil {
+				fmt.Printf("Error occurred while updating auction #%d! %s\n", auction.AuctionID, err)
+				continue
+			}
+			if result.ModifiedCount == 0 {
+				continue
+			}
+			// Re-read it in case a bid landed between finding and claiming it
+			err = auctionCollection.FindOne(ctx, bson.M{"auction_id": auction.AuctionID}).Decode(&auction)
+			if err != nil {
+				fmt.Printf("Error occurred while finding auction #%d! %s\n", auction.AuctionID, err)
+				continue
+			}
+		}
+		settleAuction(ctx, client, auction)
+	}
+}
+
+// Not a command
+// Hands the items to the winner and the winning bid to the seller, or gives the items back if nobody bid
+// Each handover is marked as done before it happens, so settling twice never pays out twice
+func settleAuction(ctx context.Context, client *mongo.Client, auction Auction) {
+	auctionCollection := client.Database(globalDatabase).Collection("Auctions")
+	userCollection := client.Database(strconv.Itoa(auction.GuildID)).Collection("Users")
+	claim := func(field string) (bool) {
+		result, err := auctionCollection.UpdateOne(
+			ctx,
+			bson.D{
+				{Key: "auction_id", Value: auction.AuctionID},
+				{Key: "status", Value: "settling"},
+				{Key: field, Value: false},
+			},
+			bson.D{
+				{Key: "$set", Value: bson.D{
+					{Key: field, Value: true},
+				}},
+			},
+		)
+		if err != nil {
+			fmt.Printf("Error occurred while updating auction #%d! %s\n", auction.AuctionID, err)
+			return false
+		}
+		return result.ModifiedCount == 1
+	}
+
+	sold := auction.HighBidderID != 0
+	var taxed int64
+	if claim("items_delivered") {
+		recipient := auction.SellerID
+		if sold {
+			recipient = auction.HighBidderID
+		}
+		err := addItemLot(ctx, userCollection, auction.GuildID, recipient, auction.Item, auction.Quantity, auction.Wear)
+		if err != nil {
+			fmt.Printf("Error occurred while handing over %dX %s from auction #%d to user %d! %s\n", auction.Quantity, auction.Item, auction.AuctionID, recipient, err)
+		}
+	}
+	// The winning bid is taxed the same as a market sale
+	if sold {
+		_, tax := marketRates(getSettings(ctx, client, auction.GuildID))
+		taxed = int64(math.Floor(float64(auction.HighBid) * tax / 100))
+	}
+	if sold && claim("coins_delivered") {
+		err := addCoins(ctx, userCollection, auction.GuildID, auction.SellerID, auction.HighBid - taxed)
+		if err != nil {
+			fmt.Printf("Error occurred while paying %d coins from auction #%d to user %d! %s\n", auction.HighBid - taxed, auction.AuctionID, auction.SellerID, err)
+		} else {
+			addSeasonEarnings(ctx, userCollection, auction.GuildID, auction.SellerID, auction.HighBid - taxed)
+		}
+		addToTreasury(ctx, client, auction.GuildID, taxed, "auction_tax")
+	}
+
+	status := "unsold"
+	if sold {
+		status = "sold"
+	}
+	result, err := auctionCollection.UpdateOne(
+		ctx,
+		bson.M{"auction_id": auction.AuctionID, "status": "settling"},
+		bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "status", Value: status},
+				{Key: "closed_at", Value: time.Now()},
+			}},
+		},
+	)
+	if err != nil {
+		fmt.Printf("Error occurred while updating auction #%d! %s\n", auction.AuctionID, err)
+		return
+	}
+	if result.ModifiedCount == 0 {
+		return
+	}
+
+	if sold {
+		postChannel(auction.ChannelID, fmt.Sprintf("🔨 Auction #%d is over! <@%d> won %dX %s for %d coins. <@%d> got %d coins after %d coins of tax.", auction.AuctionID, auction.HighBidderID, auction.Quantity, auction.Item, auction.HighBid, auction.SellerID, auction.HighBid - taxed, taxed))
+		recordEvent(ctx, client, GameEvent{GuildID: auction.GuildID, UserID: auction.HighBidderID, ChannelID: auction.ChannelID, Type: "auction_win", Item: auction.Item, TargetID: auction.SellerID, Amount: int64(auction.Quantity)})
+	} else {
+		postChannel(auction.ChannelID, fmt.Sprintf("🔨 Auction #%d ended without any bids. <@%d>, your %dX %s has been given back.", auction.AuctionID, auction.SellerID, auction.Quantity, auction.Item))
+	}
+}
@@ -0,0 +1,412 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// How an item helps in a fight, keyed by the name used in the inventory
+// Items without a slot can still be used to attack, but can't be equipped
+type Gear struct {
+	Slot    string // weapon, armor or accessory
+	Attack  int
+	Defense int
+}
+
+var equipmentSlots = []string{"weapon", "armor", "accessory"}
+
+var gear = map[string]Gear{
+	"knife":    {"weapon", 15, 0},
+	"bow":      {"weapon", 25, 0},
+	"gun":      {"weapon", 40, 0},
+	"crossbow": {"weapon", 55, 0},
+	"car":      {"", 80, 0},
+	"vest":     {"armor", 0, 30},
+	"shield":   {"armor", 0, 50},
+	"charm":    {"accessory", 5, 15},
+}
+
+// How hard someone hits with nothing but their fists
+const fistAttack = 10
+
+// The outcome of one attack, worked out the same way for every offensive action
+type Attack struct {
+	Weapon     string  // What the attacker used, empty for their fists
+	Power      int     // Attack of the weapon plus any equipped bonuses
+	Defense    int     // Defense of everything the defender has equipped
+	Blocked    bool    // The defender's gear stopped the attack completely
+	Blocker    string  // The piece of gear that blocked it
+	Broke      bool    // The blocker broke doing it
+	Countered  bool    // The defender fought back with a stronger weapon
+	Counter    string  // The weapon they fought back with
+	Multiplier float64 // How much of the attack got through, from 0 to 1
+}
+
+// Not a command
+// Gets the gear a user has equipped in each slot, leaving out anything they no longer own
+// Players who have never equipped or taken off anything fight with the best gear they own, like before gear had to be equipped
+func equippedGear(user User) (map[string]string) {
+	owned := map[string]bool{}
+	for _, item := range user.Inventory {
+		if item.Quantity > 0 {
+			owned[item.Name] = true
+		}
+	}
+	equipped := map[string]string{}
+	if user.Equipment == nil {
+		for item := range owned {
+			stats, ok := gear[item]
+			if !ok || stats.Slot == "" {
+				continue
+			}
+			best, ok := equipped[stats.Slot]
+			if !ok || stats.Attack + stats.Defense > gear[best].Attack + gear[best].Defense {
+				equipped[stats.Slot] = item
+			}
+		}
+		return equipped
+	}
+	for slot, item := range user.Equipment {
+		if owned[item] && gear[item].Slot == slot {
+			equipped[slot] = item
+		}
+	}
+	return equipped
+}
+
+// Not a command
+// Gets the weapon a user fights with when they aren't using an item, empty for their fists
+func equippedWeapon(user User) (string) {
+	return equippedGear(user)["weapon"]
+}
+
+// Not a command
+// Saves the gear a user is falling back on as what they have equipped, before they equip or take off anything themselves
+// Otherwise equipping one piece would take off the rest of the gear they were fighting with
+func saveDefaultEquipment(ctx context.Context, userCollection *mongo.Collection, guildID int, userID int) (error) {
+	var user User
+	err := userCollection.FindOne(ctx, bson.M{"user_id": userID, "guild_id": guildID}).Decode(&user)
+	if err != nil || user.Equipment != nil {
+		return err
+	}
+	_, err = userCollection.UpdateOne(
+		ctx,
+		bson.M{"user_id": userID, "guild_id": guildID, "equipment": nil}, // Matches a missing field too
+		bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "equipment", Value: equippedGear(user)},
+			}},
+		},
+	)
+	return err
+}
+
+// Not a command
+// Works out how hard a user hits with a weapon, including the bonuses from their other gear
+func attackPower(attacker User, weapon string) (int) {
+	power := fistAttack
+	if stats, ok := gear[weapon]; ok && weapon != "" {
+		power = stats.Attack
+	}
+	for slot, item := range equippedGear(attacker) {
+		if slot != "weapon" {
+			power += gear[item].Attack
+		}
+	}
+	return power
+}
+
+// Not a command
+// Works out a user's total defense, and which piece of their gear takes the hit
+func defenseOf(defender User) (int, string) {
+	defense := 0
+	blocker := ""
+	best := 0
+	for _, item := range equippedGear(defender) {
+		defense += gear[item].Defense
+		if gear[item].Defense > best {
+			best = gear[item].Defense
+			blocker = item
+		}
+	}
+	return defense, blocker
+}
+
+// Not a command
+// Works out what happens when one user attacks another with a weapon
+// Equipped accessories add to the attack, and the defender's equipped gear can block it, soften it or fight back
+// Any gear that blocks or fights back wears down
+func resolveAttack(ctx context.Context, userCollection *mongo.Collection, guildID int, attacker User, defender User, weapon string) (Attack, error) {
+	attack := Attack{Weapon: weapon, Power: attackPower(attacker, weapon), Multiplier: 1}
+	attack.Defense, attack.Blocker = defenseOf(defender)
+	defenderGear := equippedGear(defender)
+
+	// A defender with a stronger weapon equipped wins the fight
+	if counter, ok := defenderGear["weapon"]; ok && gear[counter].Attack > attack.Power {
+		attack.Countered = true
+		attack.Counter = counter
+		attack.Blocker = ""
+		_, _, err := wearItem(ctx, userCollection, guildID, defender.UserID, counter)
+		return attack, err
+	}
+	if attack.Defense == 0 {
+		attack.Blocker = ""
+		return attack, nil
+	}
+
+	// The more defense compared to attack, the more likely the attack is blocked and the less gets through if it isn't
+	blockChance := float64(attack.Defense) / float64(attack.Power + attack.Defense)
+	if rand.Float64() < blockChance {
+		attack.Blocked = true
+		attack.Multiplier = 0
+		if getItemStats(attack.Blocker).Durability > 0 {
+			_, broke, err := wearItem(ctx, userCollection, guildID, defender.UserID, attack.Blocker)
+			attack.Broke = broke
+			return attack, err
+		}
+		return attack, nil
+	}
+	attack.Blocker = ""
+	attack.Multiplier = float64(attack.Power) / float64(attack.Power + attack.Defense)
+	return attack, nil
+}
+
+// Not a command
+// Scales an amount by how much of an attack got through, never less than 1 unless it was stopped
+func scaleByAttack(amount int64, attack Attack) (int64) {
+	if attack.Multiplier <= 0 || amount <= 0 {
+		return 0
+	}
+	scaled := int64(float64(amount) * attack.Multiplier)
+	if scaled < 1 {
+		scaled = 1
+	}
+	return scaled
+}
+
+// Not a command
+// Describes why an attack didn't land, e.g. "their shield blocked it"
+func describeDefense(attack Attack) (string) {
+	switch {
+	case attack.Countered:
+		return "they fought back with their " + attack.Counter
+	case attack.Blocked:
+		res := "their " + attack.Blocker + " blocked it"
+		if attack.Broke {
+			res += ", but it broke"
+		}
+		return res
+	}
+	return ""
+}
+
+// mary equip [item] -> puts on a piece of gear from your inventory
+func Equip(mongoURI string, guildID int, guildName string, userID int, userName string, item string) (string) {
+	pattern := regexp.MustCompile("[^a-zA-Z0-9]+")
+	item = strings.ToLower(pattern.ReplaceAllString(item, ""))
+	stats, ok := gear[item]
+	if !ok || stats.Slot == "" {
+		return "You can't equip that! Weapons, armor and accessories can be equipped."
+	}
+
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error())
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	// Check if user exists in database
+	res := IsPlaying(ctx, client, guildID, guildName, userID, userName)
+	if res != "" {
+		return res
+	}
+
+	userCollection := client.Database(strconv.Itoa(guildID)).Collection("Users")
+	err = saveDefaultEquipment(ctx, userCollection, guildID, userID)
+	if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+		return "Error occurred while updating database! " + strings.Title(err.Error())
+	}
+
+	// Only equip it if they actually have one
+	result, err := userCollection.UpdateOne(
+		ctx,
+		bson.D{
+			{Key: "user_id", Value: userID},
+			{Key: "guild_id", Value: guildID},
+			{Key: "inventory", Value: bson.D{
+				{Key: "$elemMatch", Value: bson.D{
+					{Key: "name", Value: item},
+					{Key: "quantity", Value: bson.D{{Key: "$gte", Value: 1}}},
+				}},
+			}},
+		},
+		bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "equipment." + stats.Slot, Value: item},
+			}},
+		},
+	)
+	if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+		return "Error occurred while updating database! " + strings.Title(err.Error())
+	}
+	if result.MatchedCount == 0 {
+		return "You don't have a " + item + " to equip!"
+	}
+
+	bonus := []string{}
+	if stats.Attack > 0 {
+		bonus = append(bonus, fmt.Sprintf("+%d attack", stats.Attack))
+	}
+	if stats.Defense > 0 {
+		bonus = append(bonus, fmt.Sprintf("+%d defense", stats.Defense))
+	}
+	return fmt.Sprintf("You equipped your %s %s as your %s (%s)!", itemEmoji(item), strings.Title(item), stats.Slot, strings.Join(bonus, ", "))
+}
+
+// mary unequip [optional: slot or item] -> takes off a piece of gear, or all of it
+func Unequip(mongoURI string, guildID int, guildName string, userID int, userName string, target string) (string) {
+	pattern := regexp.MustCompile("[^a-zA-Z0-9]+")
+	target = strings.ToLower(pattern.ReplaceAllString(target, ""))
+	slots := []string{}
+	if target == "" {
+		slots = equipmentSlots
+	} else if stats, ok := gear[target]; ok && stats.Slot != "" {
+		slots = append(slots, stats.Slot)
+	} else {
+		for _, slot := range equipmentSlots {
+			if slot == target {
+				slots = append(slots, slot)
+			}
+		}
+	}
+	if len(slots) == 0 {
+		return "Please specify a slot (" + strings.Join(equipmentSlots, ", ") + ") or an item to unequip!"
+	}
+
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error())
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	// Check if user exists in database
+	res := IsPlaying(ctx, client, guildID, guildName, userID, userName)
+	if res != "" {
+		return res
+	}
+
+	userCollection := client.Database(strconv.Itoa(guildID)).Collection("Users")
+	err = saveDefaultEquipment(ctx, userCollection, guildID, userID)
+	if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+		return "Error occurred while updating database! " + strings.Title(err.Error())
+	}
+
+	filter := bson.D{
+		{Key: "user_id", Value: userID},
+		{Key: "guild_id", Value: guildID},
+	}
+	// Unequipping an item only takes it off if it's the one in that slot
+	if _, ok := gear[target]; ok {
+		filter = append(filter, bson.E{Key: "equipment." + slots[0], Value: target})
+	}
+	unset := bson.D{}
+	for _, slot := range slots {
+		unset = append(unset, bson.E{Key: "equipment." + slot, Value: ""})
+	}
+	result, err := userCollection.UpdateOne(
+		ctx,
+		filter,
+		bson.D{
+			{Key: "$unset", Value: unset},
+		},
+	)
+	if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+		return "Error occurred while updating database! " + strings.Title(err.Error())
+	}
+	if result.ModifiedCount == 0 {
+		if target == "" {
+			return "You don't have anything equipped!"
+		}
+		return "You don't have that equipped!"
+	}
+	if target == "" {
+		return "You took off all of your gear."
+	}
+	return "You took off your " + target + "."
+}
+
+// Not a command
+// Describes what a user has equipped for their inventory, e.g. "Weapon: 🔫 Gun"
+func describeEquipment(user User) (string) {
+	equipped := equippedGear(user)
+	lines := []string{}
+	for _, slot := range equipmentSlots {
+		if item, ok := equipped[slot]; ok {
+			lines = append(lines, fmt.Sprintf("%s: %s %s", strings.Title(slot), itemEmoji(item), strings.Title(item)))
+		}
+	}
+	if len(lines) == 0 {
+		return "Nothing equipped. Use `mary equip [item]` to put on a weapon, armor or an accessory."
+	}
+	attack := attackPower(user, equipped["weapon"])
+	defense, _ := defenseOf(user)
+	return strings.Join(lines, "\n") + fmt.Sprintf("\n⚔️ %d attack • 🛡️ %d defense", attack, defense)
+}
+
+// Not a command
+// Mentions the defender's gear when it softened an attack that still got through
+func softened(attack Attack) (string) {
+	if attack.Defense == 0 || attack.Multiplier >= 1 {
+		return ""
+	}
+	return fmt.Sprintf(" Their gear softened the blow, so only %.0f%% got through.", attack.Multiplier * 100)
+}
+
+// Not a command
+// Moves coins from one user to another, returning false if the first doesn't have enough
+func stealCoins(ctx context.Context, userCollection *mongo.Collection, guildID int, fromID int, toID int, amount int64) (bool, error) {
+	if amount <= 0 {
+		return false, nil
+	}
+	taken, err := takeCoins(ctx, userCollection, guildID, fromID, amount)
+	if err != nil || !taken {
+		return false, err
+	}
+	return true, addCoins(ctx, userCollection, guildID, toID, amount)
+}
@@ -0,0 +1,30 @@
+package database
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Not a command
+// Gets the next number in a sequence, e.g. for order numbers that are short enough to type
+// Sequences are shared by every server and kept in the Counters collection of the global database
+func nextSequence(ctx context.Context, client *mongo.Client, name string) (int, error) {
+	var counter struct {
+		Value int `bson:"value"`
+	}
+	err := client.Database(globalDatabase).Collection("Counters").FindOneAndUpdate(
+		ctx,
+		bson.D{
+			{Key: "name", Value: name},
+		},
+		bson.D{
+			{Key: "$inc", Value: bson.D{
+				{Key: "value", Value: 1},
+			}},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&counter)
+	return counter.Value, err
+}
@@ -0,0 +1,367 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"github.com/bwmarrin/discordgo"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// A way of turning some inventory items into another
+// The defaults can be replaced with a JSON file of these, pointed to by the RECIPES_CONFIG env var
+type Recipe struct {
+	Output      string         `json:"output"` // Inventory name of the item made, e.g. "crossbow"
+	Emoji       string         `json:"emoji"`
+	Quantity    int            `json:"quantity"` // How many are made each time
+	Inputs      map[string]int `json:"inputs"` // Inventory names and how many of each are used up
+	Level       int            `json:"level"` // Level needed to craft it, if any
+	Description string         `json:"description"`
+}
+
+var defaultRecipes = []Recipe{
+	{"crossbow", "🎯", 1, map[string]int{"bow": 1, "gun": 1}, 0, "A bow with a trigger. Twice the fun, twice the danger!"},
+	{"giftbox", "🎁", 1, map[string]int{"chocolate": 5}, 0, "A box of chocolates, nicely wrapped. Eat the lot with mary use giftbox!"},
+}
+
+var recipes []Recipe
+var recipesOnce sync.Once
+
+// Not a command
+// Gets every recipe, from the RECIPES_CONFIG file if there is one
+func getRecipes() ([]Recipe) {
+	recipesOnce.Do(func() {
+		recipes = defaultRecipes
+		path := os.Getenv("RECIPES_CONFIG")
+		if path == "" {
+			return
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Printf("Error reading recipes config! %s\n", err)
+			return
+		}
+		var configs []Recipe
+		err = json.Unmarshal(data, &configs)
+		if err != nil || len(configs) == 0 {
+			fmt.Printf("Error parsing recipes config! %v\n", err)
+			return
+		}
+		valid := []Recipe{}
+		for _, recipe := range configs {
+			recipe.Output = strings.ToLower(recipe.Output)
+			if recipe.Quantity < 1 {
+				recipe.Quantity = 1
+			}
+			// A recipe that uses up its own output can't be done in one update
+			if _, ok := recipe.Inputs[recipe.Output]; ok || recipe.Output == "" || len(recipe.Inputs) == 0 {
+				fmt.Printf("Skipping invalid recipe for %s!\n", recipe.Output)
+				continue
+			}
+			valid = append(valid, recipe)
+		}
+		recipes = valid
+	})
+	return recipes
+}
+
+// Not a command
+// Finds the inventory name of any item, from the shop or from a recipe, from what the user typed
+// Returns an empty string if there is no such item
+func knownItemName(item string) (string) {
+	if name := shopItemName(item); name != "" {
+		return name
+	}
+	pattern := regexp.MustCompile("[^a-zA-Z0-9]+")
+	item = strings.ToLower(pattern.ReplaceAllString(item, ""))
+	for _, recipe := range getRecipes() {
+		if recipe.Output == item {
+			return item
+		}
+	}
+	return ""
+}
+
+// Not a command
+// Gets what an item is worth before the sell rate: its shop price, or what a crafted item's inputs are worth
+// Returns 0 if there is no such item
+func itemValue(item string) (int) {
+	return inputsValue(item, map[string]bool{})
+}
+
+// Not a command
+// Works out itemValue, skipping any recipe that is already being worked out so a config loop can't recurse forever
+func inputsValue(item string, seen map[string]bool) (int) {
+	pattern := regexp.MustCompile("[^a-zA-Z0-9]+")
+	for i := range items {
+		if strings.ToLower(pattern.ReplaceAllString(items[i].Name, "")) == item {
+			return items[i].Price
+		}
+	}
+	if seen[item] {
+		return 0
+	}
+	seen[item] = true
+	defer delete(seen, item)
+	for _, recipe := range getRecipes() {
+		if recipe.Output != item {
+			continue
+		}
+		total := 0
+		for input, quantity := range recipe.Inputs {
+			total += quantity * inputsValue(input, seen)
+		}
+		return total / recipe.Quantity
+	}
+	return 0
+}
+
+// Not a command
+// Gets the emoji for an item from the shop or from a recipe
+func itemEmoji(item string) (string) {
+	if emoji, ok := emojiLookup[strings.Title(item)]; ok {
+		return emoji
+	}
+	for _, recipe := range getRecipes() {
+		if recipe.Output == strings.ToLower(item) {
+			return recipe.Emoji
+		}
+	}
+	return ""
+}
+
+// Not a command
+// Describes what a recipe uses up, e.g. "1X Bow, 1X Gun"
+func describeInputs(recipe Recipe) (string) {
+	names := []string{}
+	for name := range recipe.Inputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	inputs := []string{}
+	for _, name := range names {
+		inputs = append(inputs, fmt.Sprintf("%s %dX %s", itemEmoji(name), recipe.Inputs[name], strings.Title(name)))
+	}
+	return strings.Join(inputs, ", ")
+}
+
+// Not a command
+// Works out how many times a user could craft a recipe with what's in their inventory
+func timesCraftable(recipe Recipe, inventory []Item) (int) {
+	owned := map[string]int{}
+	for _, item := range inventory {
+		owned[item.Name] += item.Quantity
+	}
+	times := -1
+	for name, needed := range recipe.Inputs {
+		if needed < 1 {
+			continue
+		}
+		if possible := owned[name] / needed; times == -1 || possible < times {
+			times = possible
+		}
+	}
+	if times < 0 {
+		return 0
+	}
+	return times
+}
+
+// mary recipes -> shows every recipe and which ones you can craft right now
+func Recipes(mongoURI string, guildID int, guildName string, userID int, userName string) (string, *discordgo.MessageEmbed) {
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error()), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error()), nil
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	// Check if user exists in database
+	res := IsPlaying(ctx, client, guildID, guildName, userID, userName)
+	if res != "" {
+		return res, nil
+	}
+
+	var user User
+	err = client.Database(strconv.Itoa(guildID)).Collection("Users").FindOne(ctx, bson.M{"guild_id": guildID, "user_id": userID}).Decode(&user)
+	if err != nil {
+		fmt.Printf("Error occurred while finding user in database! %s\n", err)
+		return "Error occurred while finding user in database! " + strings.Title(err.Error()), nil
+	}
+	level, _, _ := levelFromXP(user.XP)
+
+	embed := &discordgo.MessageEmbed{
+		Title: userName + "'s Recipes",
+		Color: 0xffc0cb,
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "mary craft [item] [optional: amount] to craft something",
+		},
+	}
+	craftable := 0
+	for _, recipe := range getRecipes() {
+		status := "❌ You don't have everything for this yet"
+		if recipe.Level > level {
+			status = fmt.Sprintf("🔒 Requires level %d", recipe.Level)
+		} else if times := timesCraftable(recipe, user.Inventory); times > 0 {
+			status = fmt.Sprintf("✅ You can craft this %d times", times)
+			craftable++
+		}
+		output := strings.Title(recipe.Output)
+		if recipe.Quantity > 1 {
+			output = fmt.Sprintf("%dX %s", recipe.Quantity, output)
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name: recipe.Emoji + " " + output,
+			Value: recipe.Description + "\nNeeds " + describeInputs(recipe) + "\n" + status,
+		})
+	}
+	embed.Description = fmt.Sprintf("You can craft %d of %d recipes right now.", craftable, len(getRecipes()))
+	return "", embed
+}
+
+// mary craft [item] [optional: amount] -> uses up items from your inventory to make another
+func Craft(mongoURI string, guildID int, guildName string, userID int, userName string, channelID string, item string, times int) (string) {
+	if times < 1 {
+		return "Please specify a positive amount!"
+	}
+	pattern := regexp.MustCompile("[^a-zA-Z0-9]+")
+	item = strings.ToLower(pattern.ReplaceAllString(item, ""))
+	var recipe Recipe
+	found := false
+	for _, r := range getRecipes() {
+		if r.Output == item {
+			recipe = r
+			found = true
+			break
+		}
+	}
+	if !found {
+		return "There's no recipe for that! Check `mary recipes` for what you can craft."
+	}
+
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error())
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	// Check if user exists in database
+	res := IsPlaying(ctx, client, guildID, guildName, userID, userName)
+	if res != "" {
+		return res
+	}
+
+	userCollection := client.Database(strconv.Itoa(guildID)).Collection("Users")
+	if recipe.Level > 0 {
+		var user User
+		err = userCollection.FindOne(ctx, bson.M{"guild_id": guildID, "user_id": userID}).Decode(&user)
+		if err != nil {
+			fmt.Printf("Error occurred while finding user in database! %s\n", err)
+			return "Error occurred while finding user in database! " + strings.Title(err.Error())
+		}
+		if level, _, _ := levelFromXP(user.XP); level < recipe.Level {
+			return fmt.Sprintf("You need to be level %d to craft this! You are level %d.", recipe.Level, level)
+		}
+	}
+
+	// Make sure the output has a place in the inventory, so the craft itself can be a single update
+	_, err = userCollection.UpdateOne(
+		ctx,
+		bson.D{
+			{Key: "user_id", Value: userID},
+			{Key: "guild_id", Value: guildID},
+			{Key: "inventory.name", Value: bson.D{{Key: "$ne", Value: recipe.Output}}},
+		},
+		bson.D{
+			{Key: "$push", Value: bson.D{
+				{Key: "inventory", Value: Item{Name: recipe.Output, Quantity: 0}},
+			}},
+		},
+	)
+	if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+		return "Error occurred while updating database! " + strings.Title(err.Error())
+	}
+
+	// Every input is checked and used up in the same update as the output is added, so it all happens or none of it does
+	names := []string{}
+	for name := range recipe.Inputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	required := bson.A{}
+	inc := bson.D{{Key: "inventory.$[output].quantity", Value: recipe.Quantity * times}}
+	arrayFilters := []interface{}{bson.M{"output.name": recipe.Output}}
+	for i, name := range names {
+		needed := recipe.Inputs[name] * times
+		identifier := "input" + strconv.Itoa(i)
+		required = append(required, bson.D{
+			{Key: "$elemMatch", Value: bson.D{
+				{Key: "name", Value: name},
+				{Key: "quantity", Value: bson.D{{Key: "$gte", Value: needed}}},
+			}},
+		})
+		inc = append(inc, bson.E{Key: "inventory.$[" + identifier + "].quantity", Value: -needed})
+		arrayFilters = append(arrayFilters, bson.M{identifier + ".name": name})
+	}
+	result, err := userCollection.UpdateOne(
+		ctx,
+		bson.D{
+			{Key: "user_id", Value: userID},
+			{Key: "guild_id", Value: guildID},
+			{Key: "inventory", Value: bson.D{{Key: "$all", Value: required}}},
+		},
+		bson.D{
+			{Key: "$inc", Value: inc},
+		},
+		options.Update().SetArrayFilters(options.ArrayFilters{Filters: arrayFilters}),
+	)
+	if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+		return "Error occurred while updating database! " + strings.Title(err.Error())
+	}
+	if result.ModifiedCount == 0 {
+		return "You don't have enough items to craft that! You need " + describeInputs(recipe) + " for each one."
+	}
+
+	// Inputs that were worn down forget their wear once they're all used up, so the next one isn't born worn
+	for _, name := range names {
+		clearWear(ctx, userCollection, guildID, userID, name)
+	}
+
+	made := recipe.Quantity * times
+	recordEvent(ctx, client, GameEvent{GuildID: guildID, UserID: userID, ChannelID: channelID, Type: "craft", Item: recipe.Output, Amount: int64(made)})
+	return fmt.Sprintf("You crafted %s %dX %s!", recipe.Emoji, made, strings.Title(recipe.Output))
+}
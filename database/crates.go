@@ -0,0 +1,448 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"github.com/bwmarrin/discordgo"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Something that can come out of a crate
+// Exactly one of Coins, Item or Title should be set
+type Drop struct {
+	Tier     string `json:"tier"` // common, rare or epic
+	Weight   int    `json:"weight"` // The chance of this drop is its weight out of the total weight of the crate
+	Coins    int64  `json:"coins"`
+	Item     string `json:"item"`
+	Quantity int    `json:"quantity"`
+	Title    string `json:"title"` // A profile title, for cosmetic drops
+}
+
+// A loot box and what can come out of it
+// The defaults can be replaced with a JSON file of these, pointed to by the CRATES_CONFIG env var
+type Crate struct {
+	Name  string `json:"name"` // Inventory name, e.g. "crate"
+	Pity  int    `json:"pity"` // Opening this many in a row without a rare or better guarantees the next one, 0 for no pity
+	Drops []Drop `json:"drops"`
+}
+
+// Tiers from worst to best, with the colour shown when one is opened
+var crateTiers = []string{"common", "rare", "epic"}
+var tierColours = map[string]int{
+	"common": 0xb0b0b0,
+	"rare":   0x3498db,
+	"epic":   0x9b59b6,
+}
+
+var defaultCrates = []Crate{
+	{"crate", 10, []Drop{
+		{"common", 30, 200, "", 0, ""},
+		{"common", 25, 0, "chocolate", 2, ""},
+		{"common", 15, 0, "bow", 1, ""},
+		{"common", 10, 400, "", 0, ""},
+		{"rare", 8, 0, "gun", 1, ""},
+		{"rare", 6, 1500, "", 0, ""},
+		{"rare", 3, 0, "ring", 1, ""},
+		{"epic", 2, 0, "galaxy", 1, ""},
+		{"epic", 1, 0, "", 0, "the Lucky Looter"},
+	}},
+	{"chest", 5, []Drop{
+		{"common", 35, 1000, "", 0, ""},
+		{"common", 20, 0, "gun", 1, ""},
+		{"common", 15, 0, "chocolate", 5, ""},
+		{"rare", 12, 0, "shield", 1, ""},
+		{"rare", 8, 5000, "", 0, ""},
+		{"rare", 4, 0, "sakura", 1, ""},
+		{"epic", 3, 0, "car", 1, ""},
+		{"epic", 2, 0, "ocean", 1, ""},
+		{"epic", 1, 20000, "", 0, ""},
+	}},
+	{"vault", 3, []Drop{
+		{"common", 40, 4000, "", 0, ""},
+		{"common", 20, 0, "shield", 2, ""},
+		{"rare", 20, 0, "car", 1, ""},
+		{"rare", 10, 15000, "", 0, ""},
+		{"epic", 6, 40000, "", 0, ""},
+		{"epic", 4, 0, "", 0, "the Vault Breaker"},
+	}},
+}
+
+var crates []Crate
+var cratesOnce sync.Once
+
+// Not a command
+// Gets every crate, from the CRATES_CONFIG file if there is one
+func getCrates() ([]Crate) {
+	cratesOnce.Do(func() {
+		crates = defaultCrates
+		path := os.Getenv("CRATES_CONFIG")
+		if path == "" {
+			return
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Printf("Error reading crates config! %s\n", err)
+			return
+		}
+		var configs []Crate
+		err = json.Unmarshal(data, &configs)
+		if err != nil || len(configs) == 0 {
+			fmt.Printf("Error parsing crates config! %v\n", err)
+			return
+		}
+		valid := []Crate{}
+		for _, crate := range configs {
+			crate.Name = strings.ToLower(crate.Name)
+			if problem := crateProblem(crate); problem != "" {
+				fmt.Printf("Skipping invalid crate %s! %s\n", crate.Name, problem)
+				continue
+			}
+			valid = append(valid, crate)
+		}
+		if len(valid) == 0 {
+			fmt.Printf("No valid crates in crates config, using the defaults!\n")
+			return
+		}
+		crates = valid
+	})
+	return crates
+}
+
+// Not a command
+// Checks a crate from the config can be opened, returning what is wrong with it if it can't
+func crateProblem(crate Crate) (string) {
+	if crate.Name == "" || len(crate.Drops) == 0 {
+		return "It needs a name and at least one drop."
+	}
+	hasRare := false
+	for _, drop := range crate.Drops {
+		known := false
+		for _, tier := range crateTiers {
+			known = known || drop.Tier == tier
+		}
+		switch {
+		case !known:
+			return fmt.Sprintf("%q isn't a tier.", drop.Tier)
+		case drop.Weight < 1:
+			return "Every drop needs a weight of at least 1."
+		case drop.Item != "" && drop.Quantity < 1:
+			return "Item drops need a quantity of at least 1."
+		case drop.Item == "" && drop.Title == "" && drop.Coins < 1:
+			return "Coin drops need at least 1 coin."
+		}
+		if tierRank(drop.Tier) >= tierRank("rare") {
+			hasRare = true
+		}
+	}
+	// Pity guarantees a rare or better, so there has to be one to give
+	if crate.Pity > 0 && !hasRare {
+		return "Crates with pity need a rare or better drop."
+	}
+	return ""
+}
+
+// Not a command
+// Finds a crate from what the user typed
+func findCrate(name string) (Crate, bool) {
+	pattern := regexp.MustCompile("[^a-zA-Z0-9]+")
+	name = strings.ToLower(pattern.ReplaceAllString(name, ""))
+	for _, crate := range getCrates() {
+		if crate.Name == name {
+			return crate, true
+		}
+	}
+	return Crate{}, false
+}
+
+// Not a command
+// Gets how good a tier is, so "rare or better" can be checked
+func tierRank(tier string) (int) {
+	for i, name := range crateTiers {
+		if name == tier {
+			return i
+		}
+	}
+	return 0
+}
+
+// Not a command
+// Describes what a drop gives, e.g. "1X Gun" or "500 coins"
+func describeDrop(drop Drop) (string) {
+	switch {
+	case drop.Item != "":
+		return fmt.Sprintf("%s %dX %s", itemEmoji(drop.Item), drop.Quantity, strings.Title(drop.Item))
+	case drop.Title != "":
+		return fmt.Sprintf("🏷️ Title \"%s\"", drop.Title)
+	}
+	return fmt.Sprintf("💰 %d coins", drop.Coins)
+}
+
+// Not a command
+// Picks a drop at random by weight, only from drops of at least minTier
+// Returns the drop along with the number rolled and the total weight, so the roll can be checked later
+func rollDrop(crate Crate, minTier string) (Drop, int, int) {
+	total := 0
+	for _, drop := range crate.Drops {
+		if tierRank(drop.Tier) >= tierRank(minTier) {
+			total += drop.Weight
+		}
+	}
+	if total <= 0 {
+		return crate.Drops[0], 0, 0
+	}
+	roll := rand.Intn(total)
+	position := roll
+	for _, drop := range crate.Drops {
+		if tierRank(drop.Tier) < tierRank(minTier) {
+			continue
+		}
+		if position < drop.Weight {
+			return drop, roll, total
+		}
+		position -= drop.Weight
+	}
+	return crate.Drops[len(crate.Drops)-1], roll, total
+}
+
+// mary droprates [crate] -> shows the exact odds of everything that can come out of a crate
+func DropRates(name string) (string, *discordgo.MessageEmbed) {
+	crate, ok := findCrate(name)
+	if !ok {
+		names := []string{}
+		for _, crate := range getCrates() {
+			names = append(names, crate.Name)
+		}
+		return "Please choose a crate of " + strings.Join(names, ", ") + "!", nil
+	}
+
+	total := 0
+	tierTotals := map[string]int{}
+	for _, drop := range crate.Drops {
+		total += drop.Weight
+		tierTotals[drop.Tier] += drop.Weight
+	}
+	if total <= 0 {
+		return "That crate is empty!", nil
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title: fmt.Sprintf("%s %s Drop Rates", itemEmoji(crate.Name), strings.Title(crate.Name)),
+		Color: 0xffc0cb,
+	}
+	if crate.Pity > 0 {
+		embed.Description = fmt.Sprintf("If you open %d in a row without a rare or better, the next one is guaranteed to be rare or better.", crate.Pity)
+	}
+	for _, tier := range crateTiers {
+		if tierTotals[tier] == 0 {
+			continue
+		}
+		lines := []string{}
+		for _, drop := range crate.Drops {
+			if drop.Tier == tier {
+				lines = append(lines, fmt.Sprintf("%s: %.2f%% (%d/%d)", describeDrop(drop), float64(drop.Weight) * 100 / float64(total), drop.Weight, total))
+			}
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name: fmt.Sprintf("%s (%.2f%%)", strings.Title(tier), float64(tierTotals[tier]) * 100 / float64(total)),
+			Value: strings.Join(lines, "\n"),
+		})
+	}
+	return "", embed
+}
+
+// mary open [crate] -> opens a crate from your inventory
+// Returns each step of the opening so it can be shown one after the other
+func OpenCrate(mongoURI string, guildID int, guildName string, userID int, userName string, channelID string, name string) (string, []*discordgo.MessageEmbed) {
+	crate, ok := findCrate(name)
+	if !ok {
+		return "That isn't a crate! Check `mary droprates [crate]` for what's inside each one.", nil
+	}
+	if len(crate.Drops) == 0 {
+		return "That crate is empty!", nil
+	}
+
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error()), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error()), nil
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	// Check if user exists in database
+	res := IsPlaying(ctx, client, guildID, guildName, userID, userName)
+	if res != "" {
+		return res, nil
+	}
+
+	userCollection := client.Database(strconv.Itoa(guildID)).Collection("Users")
+	taken, err := takeItem(ctx, userCollection, guildID, userID, crate.Name, 1)
+	if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+		return "Error occurred while updating database! " + strings.Title(err.Error()), nil
+	}
+	if !taken {
+		return "You don't have a " + crate.Name + "! You can buy one from the shop or get one from weekly quests.", nil
+	}
+
+	// Count this open towards the pity before rolling, so two opens at once can't both miss the guarantee
+	pityField := "crate_pity." + crate.Name
+	var counters struct {
+		Pity map[string]int `bson:"crate_pity"`
+	}
+	err = userCollection.FindOneAndUpdate(
+		ctx,
+		bson.D{
+			{Key: "user_id", Value: userID},
+			{Key: "guild_id", Value: guildID},
+		},
+		bson.D{
+			{Key: "$inc", Value: bson.D{
+				{Key: pityField, Value: 1},
+			}},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&counters)
+	if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+	}
+	// This open is counted already, so the ones before it all missed
+	opens := counters.Pity[crate.Name]
+	guaranteed := crate.Pity > 0 && opens - 1 >= crate.Pity
+
+	minTier := "common"
+	if guaranteed {
+		minTier = "rare"
+	}
+	drop, roll, total := rollDrop(crate, minTier)
+
+	// A rare or better resets the pity
+	if tierRank(drop.Tier) >= tierRank("rare") {
+		_, err = userCollection.UpdateOne(
+			ctx,
+			bson.D{
+				{Key: "user_id", Value: userID},
+				{Key: "guild_id", Value: guildID},
+			},
+			bson.D{
+				{Key: "$set", Value: bson.D{
+					{Key: pityField, Value: 0},
+				}},
+			},
+		)
+		if err != nil {
+			fmt.Printf("Error occurred while updating database! %s\n", err)
+		}
+	}
+
+	// Hand out the drop
+	switch {
+	case drop.Item != "":
+		err = addItem(ctx, userCollection, guildID, userID, drop.Item, drop.Quantity)
+	case drop.Title != "":
+		_, err = userCollection.UpdateOne(
+			ctx,
+			bson.D{
+				{Key: "user_id", Value: userID},
+				{Key: "guild_id", Value: guildID},
+			},
+			bson.D{
+				{Key: "$addToSet", Value: bson.D{
+					{Key: "titles", Value: drop.Title},
+				}},
+			},
+		)
+	default:
+		err = addCoins(ctx, userCollection, guildID, userID, drop.Coins)
+	}
+	if err != nil {
+		fmt.Printf("Error occurred while giving %s from a %s to user %d! %s\n", describeDrop(drop), crate.Name, userID, err)
+	}
+
+	// Save the roll so it can be checked against the published drop rates
+	details := fmt.Sprintf("Opened a %s and got %s (%s). Rolled %d of %d", crate.Name, describeDrop(drop), drop.Tier, roll, total)
+	if guaranteed {
+		details += fmt.Sprintf(", guaranteed %s or better after %d opens", minTier, opens)
+	}
+	recordTransaction(ctx, client, Transaction{
+		GuildID: guildID,
+		UserID:  userID,
+		Type:    "crate_open",
+		Item:    crate.Name,
+		Details: details + ".",
+		Data: map[string]interface{}{
+			"tier":       drop.Tier,
+			"coins":      drop.Coins,
+			"reward":     drop.Item,
+			"quantity":   drop.Quantity,
+			"title":      drop.Title,
+			"roll":       roll,
+			"total":      total,
+			"pity":       opens,
+			"guaranteed": guaranteed,
+		},
+	})
+	recordEvent(ctx, client, GameEvent{GuildID: guildID, UserID: userID, ChannelID: channelID, Type: "crate_open", Item: crate.Name, Amount: 1})
+
+	// Build up to the reveal
+	emoji := itemEmoji(crate.Name)
+	title := fmt.Sprintf("%s %s opens a %s", emoji, userName, strings.Title(crate.Name))
+	frames := []*discordgo.MessageEmbed{
+		{
+			Title: title,
+			Description: "The " + crate.Name + " is shaking... 🫨",
+			Color: 0xffc0cb,
+		},
+		{
+			Title: title,
+			Description: "It's starting to glow... ✨",
+			Color: tierColours[drop.Tier],
+		},
+	}
+	if tierRank(drop.Tier) >= tierRank("epic") {
+		frames = append(frames, &discordgo.MessageEmbed{
+			Title: title,
+			Description: "Something **very** shiny is inside... 🌟🌟🌟",
+			Color: tierColours[drop.Tier],
+		})
+	}
+	reveal := &discordgo.MessageEmbed{
+		Title: title,
+		Description: fmt.Sprintf("**%s!** You got %s!", strings.ToUpper(drop.Tier), describeDrop(drop)),
+		Color: tierColours[drop.Tier],
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "mary droprates " + crate.Name + " for the odds • mary history to see your rolls",
+		},
+	}
+	if guaranteed {
+		reveal.Description += "\nYour pity kicked in, so this one was guaranteed to be rare or better."
+	} else if crate.Pity > 0 && tierRank(drop.Tier) < tierRank("rare") {
+		if remaining := crate.Pity - opens; remaining > 0 {
+			reveal.Description += fmt.Sprintf("\n%d more without a rare and the one after that is guaranteed.", remaining)
+		} else {
+			reveal.Description += "\nYour next one is guaranteed to be rare or better."
+		}
+	}
+	frames = append(frames, reveal)
+	return "", frames
+}
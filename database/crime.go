@@ -0,0 +1,415 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+	"github.com/bwmarrin/discordgo"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// A kind of crime, keyed by how it's committed: "rob" for mary rob, or the item used
+type Crime struct {
+	Name   string
+	Chance float64       // Chance of getting away with it before gear, level and defenses
+	Fine   int64         // Paid to the victim when caught
+	Jail   time.Duration // Time in jail when caught and not fined
+}
+
+var crimes = map[string]Crime{
+	"rob": {"Robbery", 0.6, 100, 10 * time.Minute},
+	"bow": {"Armed robbery", 0.55, 300, 15 * time.Minute},
+	"crossbow": {"Armed robbery", 0.5, 400, 15 * time.Minute},
+	"gun": {"Hold-up", 0.5, 500, 20 * time.Minute},
+	"car": {"Hit and run", 0.45, 1000, 30 * time.Minute},
+}
+
+// Each level makes you a little better at crime, up to a point
+const crimeLevelBonus = 0.01
+const maxCrimeLevelBonus = 0.15
+
+// Chances never go below or above these, so there's always some risk and some hope
+const minCrimeChance = 0.1
+const maxCrimeChance = 0.9
+
+// When caught, the chance of a fine rather than jail, as long as you can pay it
+const fineChance = 0.6
+
+// Bail costs this many times the fine, and goes to the server's treasury
+const bailMultiplier = 2
+
+const crimesPageSize = 10
+
+// Commands that can't be used while in jail
+// Some are only blocked for one subcommand, e.g. you can still look at your quests but not claim them
+var jailCommands = map[string]bool{
+	"auction":      true,
+	"beg":          true,
+	"buy":          true,
+	"craft":        true,
+	"daily":        true,
+	"eat":          true,
+	"gamble":       true,
+	"give":         true,
+	"kill":         true,
+	"lottery":      true,
+	"market":       true,
+	"marry":        true,
+	"open":         true,
+	"order":        true,
+	"pay":          true,
+	"quest claim":  true,
+	"quests claim": true,
+	"quiz":         true,
+	"rob":          true,
+	"run":          true,
+	"runover":      true,
+	"sell":         true,
+	"shoot":        true,
+	"slots":        true,
+	"stock":        true,
+	"trade":        true,
+	"triv":         true,
+	"trivia":       true,
+	"use":          true,
+}
+
+// One attempt at a crime, stored in the Crimes collection of the server's database
+type CrimeRecord struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty"`
+	GuildID  int                `bson:"guild_id"`
+	UserID   int                `bson:"user_id"`
+	VictimID int                `bson:"victim_id"`
+	Crime    string             `bson:"crime"`
+	Chance   float64            `bson:"chance"`
+	Caught   bool               `bson:"caught"`
+	Loot     int64              `bson:"loot"` // Coins taken, if they got away with it
+	Fine     int64              `bson:"fine"`
+	Jail     time.Duration      `bson:"jail"`
+	At       time.Time          `bson:"at"`
+}
+
+// Not a command
+// Works out the chance of getting away with a crime against someone
+// Better gear, a higher level and getting revenge help, and the victim's defenses make it harder
+func crimeChance(criminal User, victim User, crime string) (float64) {
+	weapon := crime
+	if crime == "rob" {
+		weapon = equippedWeapon(criminal)
+	}
+	level, _, _ := levelFromXP(criminal.XP)
+	levelBonus := float64(level) * crimeLevelBonus
+	if levelBonus > maxCrimeLevelBonus {
+		levelBonus = maxCrimeLevelBonus
+	}
+	defense, _ := defenseOf(victim)
+	chance := crimes[crime].Chance + levelBonus + float64(attackPower(criminal, weapon) - defense) / 200
+	if hasRevenge(criminal, victim.UserID) {
+		chance += revengeChanceBonus
+	}
+	if chance < minCrimeChance {
+		chance = minCrimeChance
+	}
+	if chance > maxCrimeChance {
+		chance = maxCrimeChance
+	}
+	return chance
+}
+
+// Not a command
+// Rolls whether a crime succeeds, and deals with the criminal if they're caught
+// Returns the record of the crime so any loot can be added to it, and a message if they were caught
+func attemptCrime(ctx context.Context, client *mongo.Client, guildID int, criminal User, victim User, crime string) (primitive.ObjectID, string, error) {
+	userCollection := client.Database(strconv.Itoa(guildID)).Collection("Users")
+	record := CrimeRecord{
+		GuildID:  guildID,
+		UserID:   criminal.UserID,
+		VictimID: victim.UserID,
+		Crime:    crime,
+		Chance:   crimeChance(criminal, victim, crime),
+		At:       time.Now(),
+	}
+	res := ""
+	details := crimes[crime]
+	if rand.Float64() >= record.Chance {
+		record.Caught = true
+
+		// Pay the victim if they can afford it, otherwise it's off to jail
+		fined := false
+		if rand.Float64() < fineChance {
+			var err error
+			fined, err = stealCoins(ctx, userCollection, guildID, criminal.UserID, victim.UserID, details.Fine)
+			if err != nil {
+				return primitive.NilObjectID, "", err
+			}
+		}
+		if fined {
+			record.Fine = details.Fine
+			addSeasonEarnings(ctx, userCollection, guildID, criminal.UserID, -details.Fine)
+			res = fmt.Sprintf("🚨 You were caught! You had to pay <@%d> a fine of %d coins.", victim.UserID, details.Fine)
+		} else {
+			record.Jail = details.Jail
+			_, err := userCollection.UpdateOne(
+				ctx,
+				bson.D{
+					{Key: "user_id", Value: criminal.UserID},
+					{Key: "guild_id", Value: guildID},
+				},
+				bson.D{
+					{Key: "$set", Value: bson.D{
+						{Key: "jailed_until", Value: time.Now().Add(details.Jail)},
+						{Key: "bail", Value: details.Fine * bailMultiplier},
+					}},
+				},
+			)
+			if err != nil {
+				return primitive.NilObjectID, "", err
+			}
+			res = fmt.Sprintf("🚨 You were caught and thrown in jail for %s! Someone can bail you out for %d coins with `mary bail @you`.", formatCooldown(details.Jail), details.Fine * bailMultiplier)
+		}
+	}
+
+	result, err := client.Database(strconv.Itoa(guildID)).Collection("Crimes").InsertOne(ctx, record)
+	if err != nil {
+		fmt.Printf("Error occurred while saving crime for user %d! %s\n", criminal.UserID, err)
+		return primitive.NilObjectID, res, nil
+	}
+	return result.InsertedID.(primitive.ObjectID), res, nil
+}
+
+// Not a command
+// Adds the coins taken to the record of a crime that was got away with
+func addCrimeLoot(ctx context.Context, client *mongo.Client, guildID int, crimeID primitive.ObjectID, loot int64) {
+	if crimeID.IsZero() {
+		return
+	}
+	_, err := client.Database(strconv.Itoa(guildID)).Collection("Crimes").UpdateOne(
+		ctx,
+		bson.M{"_id": crimeID},
+		bson.D{
+			{Key: "$inc", Value: bson.D{
+				{Key: "loot", Value: loot},
+			}},
+		},
+	)
+	if err != nil {
+		fmt.Printf("Error occurred while updating crime %s! %s\n", crimeID.Hex(), err)
+	}
+}
+
+// Not a command
+// Checks whether a user can use a command right now, returning a message if they can't
+// Knocked out players can only look around, and players in jail can't touch the economy
+// Commands are checked on every message, so this uses the shared client rather than connecting each time
+func Restricted(mongoURI string, guildID int, userID int, command string, subcommand string) (string) {
+	command = strings.ToLower(command)
+	subcommand = strings.ToLower(subcommand)
+	if knockoutCommands[command] {
+		return ""
+	}
+	client, err := getSharedClient(mongoURI)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var user User
+	err = client.Database(strconv.Itoa(guildID)).Collection("Users").FindOne(ctx, bson.M{"guild_id": guildID, "user_id": userID}).Decode(&user)
+	if err != nil {
+		// Not playing yet, so nothing can be stopping them
+		return ""
+	}
+	if _, knockedOut := currentHealth(user); knockedOut > 0 {
+		return "You're knocked out! 💫 You'll come round in " + formatCooldown(knockedOut) + ", or you can get patched up with `mary hospital`."
+	}
+	if time.Now().Before(user.JailedUntil) && (jailCommands[command] || jailCommands[command + " " + subcommand]) {
+		return fmt.Sprintf("You're in jail! 🔒 You'll be let out in %s, unless someone bails you out for %d coins with `mary bail @you`.", formatCooldown(time.Until(user.JailedUntil)), user.Bail)
+	}
+	return ""
+}
+
+// mary bail @user -> pays to get someone else out of jail
+func Bail(mongoURI string, guildID int, guildName string, userID int, userName string, pingedUserID int) (string) {
+	if userID == pingedUserID {
+		return "You can't bail yourself out! Someone else has to do it for you."
+	}
+
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error())
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	// Check if user exists in database
+	res := IsPlaying(ctx, client, guildID, guildName, userID, userName)
+	if res != "" {
+		return res
+	}
+
+	userCollection := client.Database(strconv.Itoa(guildID)).Collection("Users")
+	var prisoner User
+	err = userCollection.FindOne(ctx, bson.M{"guild_id": guildID, "user_id": pingedUserID}).Decode(&prisoner)
+	if err != nil {
+		return "That user is not currently playing the game!"
+	}
+	if !time.Now().Before(prisoner.JailedUntil) {
+		return "<@" + strconv.Itoa(pingedUserID) + "> isn't in jail!"
+	}
+
+	paid, err := takeCoins(ctx, userCollection, guildID, userID, prisoner.Bail)
+	if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+		return "Error occurred while updating database! " + strings.Title(err.Error())
+	}
+	if !paid {
+		return fmt.Sprintf("You don't have enough coins to pay the %d coin bail!", prisoner.Bail)
+	}
+
+	// Only let them out if they're still in for the same crime, otherwise give the bail back
+	result, err := userCollection.UpdateOne(
+		ctx,
+		bson.D{
+			{Key: "user_id", Value: pingedUserID},
+			{Key: "guild_id", Value: guildID},
+			{Key: "jailed_until", Value: prisoner.JailedUntil},
+		},
+		bson.D{
+			{Key: "$unset", Value: bson.D{
+				{Key: "jailed_until", Value: ""},
+				{Key: "bail", Value: ""},
+			}},
+		},
+	)
+	if err != nil || result.ModifiedCount == 0 {
+		if err != nil {
+			fmt.Printf("Error occurred while updating database! %s\n", err)
+		}
+		err = addCoins(ctx, userCollection, guildID, userID, prisoner.Bail)
+		if err != nil {
+			fmt.Printf("Error occurred while refunding bail to user %d! %s\n", userID, err)
+		}
+		return "<@" + strconv.Itoa(pingedUserID) + "> was let out before you could pay. Your coins have been returned."
+	}
+	addToTreasury(ctx, client, guildID, prisoner.Bail, "bail")
+	return fmt.Sprintf("🔓 You paid %d coins to bail <@%d> out of jail!", prisoner.Bail, pingedUserID)
+}
+
+// mary crimes [optional: @user] [optional: page] -> shows the crimes someone has committed
+func Crimes(mongoURI string, guildID int, guildName string, targetID int, targetName string, page int) (string, *discordgo.MessageEmbed) {
+	if page < 1 {
+		return "Please enter a valid page number!", nil
+	}
+
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error()), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error()), nil
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	crimeCollection := client.Database(strconv.Itoa(guildID)).Collection("Crimes")
+	filter := bson.M{"guild_id": guildID, "user_id": targetID}
+	total, err := crimeCollection.CountDocuments(ctx, filter)
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return "Error occurred while selecting from database! " + strings.Title(err.Error()), nil
+	}
+	if total == 0 {
+		return targetName + " has a clean record!", nil
+	}
+	caught, err := crimeCollection.CountDocuments(ctx, bson.M{"guild_id": guildID, "user_id": targetID, "caught": true})
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return "Error occurred while selecting from database! " + strings.Title(err.Error()), nil
+	}
+
+	cursor, err := crimeCollection.Find(
+		ctx,
+		filter,
+		options.Find().
+			SetSort(bson.D{{Key: "at", Value: -1}}).
+			SetSkip(int64((page - 1) * crimesPageSize)).
+			SetLimit(crimesPageSize),
+	)
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return "Error occurred while selecting from database! " + strings.Title(err.Error()), nil
+	}
+	var records []CrimeRecord
+	err = cursor.All(ctx, &records)
+	if err != nil {
+		fmt.Printf("Error occurred while decoding result! %s\n", err)
+		return "Error occurred while decoding result! " + strings.Title(err.Error()), nil
+	}
+	if len(records) == 0 {
+		return "There's nothing on that page!", nil
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title: targetName + "'s Criminal Record",
+		Color: 0xffc0cb,
+		Description: fmt.Sprintf("%d crimes, caught %d times", total, caught),
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: fmt.Sprintf("Page %d • mary crimes [optional: @user] [page] for older crimes", page),
+		},
+	}
+	for _, record := range records {
+		name := record.Crime
+		if crime, ok := crimes[record.Crime]; ok {
+			name = crime.Name
+		}
+		outcome := fmt.Sprintf("Got away with %d coins", record.Loot)
+		if record.Caught && record.Jail > 0 {
+			outcome = "🔒 Caught and jailed for " + formatCooldown(record.Jail)
+		} else if record.Caught {
+			outcome = fmt.Sprintf("🚨 Caught and fined %d coins", record.Fine)
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name: fmt.Sprintf("%s against %s", name, userNameOf(ctx, client, guildID, record.VictimID)),
+			Value: fmt.Sprintf("<t:%d:R> • %.0f%% chance\n%s", record.At.Unix(), record.Chance * 100, outcome),
+		})
+	}
+	return "", embed
+}
+
+// Not a command
+// Gets the name a user plays under, falling back to a mention
+func userNameOf(ctx context.Context, client *mongo.Client, guildID int, userID int) (string) {
+	var user User
+	err := client.Database(strconv.Itoa(guildID)).Collection("Users").FindOne(ctx, bson.M{"guild_id": guildID, "user_id": userID}).Decode(&user)
+	if err != nil || user.UserName == "" {
+		return "<@" + strconv.Itoa(userID) + ">"
+	}
+	return user.UserName
+}
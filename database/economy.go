@@ -184,6 +184,12 @@ func daily(ctx context.Context, userCollection *mongo.Collection, guildID int, u
 		return "<@" + strconv.Itoa(userID) + ">, you have already claimed your daily! Please wait " + strconv.Itoa(hours) + " hours, " + strconv.Itoa(minutes) + " minutes, and " + strconv.Itoa(seconds) + " seconds before claiming again."
 	}
 	
+	// Higher levels get a bigger daily
+	xp, _ := collectionResult.Lookup("xp").AsInt64OK()
+	level, _, _ := levelFromXP(xp)
+	bonus := dailyLevelBonus(level)
+	balance += bonus
+
 	result := userCollection.FindOneAndUpdate(
 		ctx,
 		bson.D{
@@ -203,6 +209,11 @@ func daily(ctx context.Context, userCollection *mongo.Collection, guildID int, u
 		fmt.Printf("Error occurred while inserting to database! %s\n", result.Err().Error())
 		return "Error occurred while inserting to database! " + strings.Title(result.Err().Error())
 	} 
+	addSeasonEarnings(ctx, userCollection, guildID, userID, int64(balance))
+	recordEvent(ctx, userCollection.Database().Client(), GameEvent{GuildID: guildID, UserID: userID, Type: "daily", Amount: int64(balance)})
+	if bonus > 0 {
+		return "<@" + strconv.Itoa(userID) + ">, you have received your daily " + strconv.Itoa(balance) + " coins! (+" + strconv.Itoa(bonus) + " level " + strconv.Itoa(level) + " bonus)"
+	}
 	return "<@" + strconv.Itoa(userID) + ">, you have received your daily " + strconv.Itoa(balance) + " coins!"
 	}
 
@@ -246,6 +257,8 @@ func beg(ctx context.Context, userCollection *mongo.Collection, guildID int, use
 		fmt.Printf("Error occurred while inserting to database! %s\n", result.Err().Error())
 		return "Error occurred while inserting to database! " + strings.Title(result.Err().Error())
 	} 
+	addSeasonEarnings(ctx, userCollection, guildID, userID, int64(balance))
+	recordEvent(ctx, userCollection.Database().Client(), GameEvent{GuildID: guildID, UserID: userID, Type: "beg", Amount: int64(balance)})
 	return "<@" + strconv.Itoa(userID) + ">, you have received " + strconv.Itoa(balance) + " coins!"
 }
 
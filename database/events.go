@@ -0,0 +1,88 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Something a user did that other features react to, like earning XP
+// Events are recorded in the same code paths that do the thing, so nothing can be done without counting
+type GameEvent struct {
+	GuildID   int
+	UserID    int
+	ChannelID string // Where it happened, if it happened in a channel
+	Type      string // message, command, trivia_answer, buy, rob, marry...
+	Content   string // The message, for message events
+	Correct   bool   // For trivia answers
+	Item      string // The item bought or used, if there was one
+	TargetID  int    // The other user, for things done to someone
+	Amount    int64  // How many were bought, coins taken, etc.
+}
+
+// Every function that reacts to events
+// Features add theirs in an init function
+var eventHandlers []func(ctx context.Context, client *mongo.Client, event GameEvent)
+
+// Not a command
+// Adds a function that is called with every event
+func onEvent(handler func(ctx context.Context, client *mongo.Client, event GameEvent)) {
+	eventHandlers = append(eventHandlers, handler)
+}
+
+// Not a command
+// Passes an event to every handler
+// Handlers log their own errors, since the thing the user did has already happened
+func recordEvent(ctx context.Context, client *mongo.Client, event GameEvent) {
+	for _, handler := range eventHandlers {
+		handler(ctx, client, event)
+	}
+}
+
+// Chat messages arrive far too often to open a new connection for each one like commands do,
+// so they share one client that stays connected
+var sharedClient *mongo.Client
+var sharedClientMutex sync.Mutex
+
+// Not a command
+// Gets the shared client, connecting it the first time
+func getSharedClient(mongoURI string) (*mongo.Client, error) {
+	sharedClientMutex.Lock()
+	defer sharedClientMutex.Unlock()
+	if sharedClient != nil {
+		return sharedClient, nil
+	}
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err = client.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sharedClient = client
+	return sharedClient, nil
+}
+
+// Records a chat message or command from a user who is already playing
+// Call this for every message Mary sees, before handling any command in it
+func RecordMessage(mongoURI string, guildID int, userID int, channelID string, content string, isCommand bool) {
+	client, err := getSharedClient(mongoURI)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	eventType := "message"
+	if isCommand {
+		eventType = "command"
+	}
+	recordEvent(ctx, client, GameEvent{GuildID: guildID, UserID: userID, ChannelID: channelID, Type: eventType, Content: content})
+}
@@ -50,6 +50,7 @@ func Gamble(ctx context.Context, userCollection *mongo.Collection, guildID int,
 		bson.D{
 			{Key: "$inc", Value: bson.D{
 				{Key: "balance", Value: -balance},
+				{Key: "gamble_profit", Value: -balance},
 			}},
 		},
 		options.FindOneAndUpdate().SetUpsert(true),
@@ -58,6 +59,8 @@ func Gamble(ctx context.Context, userCollection *mongo.Collection, guildID int,
 		fmt.Printf("Error occurred while updating database! %s\n", result.Err())
 		return "Error occurred while updating database! " + strings.Title(result.Err().Error())
 	}
+	addSeasonEarnings(ctx, userCollection, guildID, userID, -int64(balance))
+	recordEvent(ctx, userCollection.Database().Client(), GameEvent{GuildID: guildID, UserID: userID, Type: "gamble", Item: "gamble", Amount: int64(balance)})
 
 	// Roll dice 
 	dice := rand.Intn(100) + 1
@@ -75,6 +78,7 @@ func Gamble(ctx context.Context, userCollection *mongo.Collection, guildID int,
 			bson.D{
 				{Key: "$inc", Value: bson.D{
 					{Key: "balance", Value: balance * 2},
+					{Key: "gamble_profit", Value: balance * 2},
 				}},
 			},
 			options.FindOneAndUpdate().SetUpsert(true),
@@ -83,6 +87,8 @@ func Gamble(ctx context.Context, userCollection *mongo.Collection, guildID int,
 			fmt.Printf("Error occurred while updating database! %s\n", result.Err())
 			return "Error occurred while updating database! " + strings.Title(result.Err().Error())
 		}
+		addSeasonEarnings(ctx, userCollection, guildID, userID, int64(balance * 2))
+		recordEvent(ctx, userCollection.Database().Client(), GameEvent{GuildID: guildID, UserID: userID, Type: "gamble_win", Item: "gamble", Amount: int64(balance * 2)})
 		return "<@" + strconv.Itoa(userID) + ">, you win! +" + strconv.Itoa(balance * 2) + " coins!"
 	} else {
 		// Lose
@@ -126,6 +132,7 @@ func Lottery(ctx context.Context, userCollection *mongo.Collection, guildID int,
 		bson.D{
 			{Key: "$inc", Value: bson.D{
 				{Key: "balance", Value: -balance},
+				{Key: "gamble_profit", Value: -balance},
 			}},
 		},
 		options.FindOneAndUpdate().SetUpsert(true),
@@ -134,6 +141,8 @@ func Lottery(ctx context.Context, userCollection *mongo.Collection, guildID int,
 		fmt.Printf("Error occurred while updating database! %s\n", result.Err())
 		return "Error occurred while updating database! " + strings.Title(result.Err().Error())
 	}
+	addSeasonEarnings(ctx, userCollection, guildID, userID, -int64(balance))
+	recordEvent(ctx, userCollection.Database().Client(), GameEvent{GuildID: guildID, UserID: userID, Type: "gamble", Item: "lottery", Amount: int64(balance)})
 
 	// Roll dice 
 	dice := rand.Intn(100) + 1
@@ -151,6 +160,7 @@ func Lottery(ctx context.Context, userCollection *mongo.Collection, guildID int,
 			bson.D{
 				{Key: "$inc", Value: bson.D{
 					{Key: "balance", Value: balance * 5},
+					{Key: "gamble_profit", Value: balance * 5},
 				}},
 			},
 			options.FindOneAndUpdate().SetUpsert(true),
@@ -159,6 +169,8 @@ func Lottery(ctx context.Context, userCollection *mongo.Collection, guildID int,
 			fmt.Printf("Error occurred while updating database! %s\n", result.Err())
 			return "Error occurred while updating database! " + strings.Title(result.Err().Error())
 		}
+		addSeasonEarnings(ctx, userCollection, guildID, userID, int64(balance * 5))
+		recordEvent(ctx, userCollection.Database().Client(), GameEvent{GuildID: guildID, UserID: userID, Type: "gamble_win", Item: "lottery", Amount: int64(balance * 5)})
 		return "<@" + strconv.Itoa(userID) + ">, you win! +" + strconv.Itoa(balance * 5) + " coins!"
 	} else {
 		// Lose
@@ -202,6 +214,7 @@ func Slots(ctx context.Context, userCollection *mongo.Collection, guildID int, u
 		bson.D{
 			{Key: "$inc", Value: bson.D{
 				{Key: "balance", Value: -balance},
+				{Key: "gamble_profit", Value: -balance},
 			}},
 		},
 		options.FindOneAndUpdate().SetUpsert(true),
@@ -210,6 +223,8 @@ func Slots(ctx context.Context, userCollection *mongo.Collection, guildID int, u
 		fmt.Printf("Error occurred while updating database! %s\n", result.Err())
 		return "Error occurred while updating database! " + strings.Title(result.Err().Error())
 	}
+	addSeasonEarnings(ctx, userCollection, guildID, userID, -int64(balance))
+	recordEvent(ctx, userCollection.Database().Client(), GameEvent{GuildID: guildID, UserID: userID, Type: "gamble", Item: "slots", Amount: int64(balance)})
 
 	// Roll dice 
 	dice := rand.Intn(100) + 1
@@ -227,6 +242,7 @@ func Slots(ctx context.Context, userCollection *mongo.Collection, guildID int, u
 			bson.D{
 				{Key: "$inc", Value: bson.D{
 					{Key: "balance", Value: balance * 2},
+					{Key: "gamble_profit", Value: balance * 2},
 				}},
 			},
 			options.FindOneAndUpdate().SetUpsert(true),
@@ -235,6 +251,8 @@ func Slots(ctx context.Context, userCollection *mongo.Collection, guildID int, u
 			fmt.Printf("Error occurred while updating database! %s\n", result.Err())
 			return "Error occurred while updating database! " + strings.Title(result.Err().Error())
 		}
+		addSeasonEarnings(ctx, userCollection, guildID, userID, int64(balance * 2))
+		recordEvent(ctx, userCollection.Database().Client(), GameEvent{GuildID: guildID, UserID: userID, Type: "gamble_win", Item: "slots", Amount: int64(balance * 2)})
 		return "<@" + strconv.Itoa(userID) + ">, you win! +" + strconv.Itoa(balance * 2) + " coins!"
 	} else {
 		// Lose
@@ -0,0 +1,482 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"github.com/bwmarrin/discordgo"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Every server has its own database, so the global leaderboard works from copies of each player's stats
+// Only users who opt in with mary global join are copied, and never from servers that have opted out
+// The copies live in the GlobalStats collection of the global database, one per user per server
+
+// A user who has opted in, kept in the GlobalPlayers collection of the global database
+type GlobalPlayer struct {
+	UserID   int       `bson:"user_id"`
+	UserName string    `bson:"user_name"`
+	JoinedAt time.Time `bson:"joined_at"`
+}
+
+// A copy of a user's stats in one server
+type GlobalStats struct {
+	UserID           int       `bson:"user_id"`
+	UserName         string    `bson:"user_name"`
+	GuildID          int       `bson:"guild_id"`
+	GuildName        string    `bson:"guild_name"`
+	Balance          int64     `bson:"balance"`
+	NetWorth         float64   `bson:"net_worth"`
+	TriviaCorrect    int       `bson:"trivia_correct"`
+	TriviaBestStreak int       `bson:"trivia_best_streak"`
+	GambleProfit     int64     `bson:"gamble_profit"`
+	UpdatedAt        time.Time `bson:"updated_at"`
+}
+
+// How each leaderboard metric is combined across servers
+// Best streak is the best in any server, everything else is added up
+var globalMetrics = map[string]struct {
+	Field string
+	Op    string
+}{
+	"coins":    {"balance", "$sum"},
+	"networth": {"net_worth", "$sum"},
+	"trivia":   {"trivia_correct", "$sum"},
+	"streak":   {"trivia_best_streak", "$max"},
+	"gambling": {"gamble_profit", "$sum"},
+}
+
+const GlobalSyncInterval = 15 * time.Minute
+
+// Not a command
+// Copies the stats of the given opted-in users from one server into GlobalStats
+// If the server has opted out, its stats are removed instead
+func syncGlobalGuild(ctx context.Context, client *mongo.Client, guildID int, players []int, stocks map[string]Stock) (error) {
+	statsCollection := client.Database(globalDatabase).Collection("GlobalStats")
+	if getSettings(ctx, client, guildID).GlobalOptOut {
+		_, err := statsCollection.DeleteMany(ctx, bson.M{"guild_id": guildID})
+		return err
+	}
+
+	cursor, err := client.Database(strconv.Itoa(guildID)).Collection("Users").Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"guild_id": guildID, "user_id": bson.M{"$in": players}}}},
+		{{Key: "$project", Value: bson.M{
+			"user_id":            1,
+			"user_name":          1,
+			"guild_id":           1,
+			"guild_name":         1,
+			"balance":            bson.M{"$ifNull": bson.A{"$balance", 0}},
+			"net_worth":          netWorthExpression(stocks),
+			"trivia_correct":     bson.M{"$ifNull": bson.A{"$trivia_correct", 0}},
+			"trivia_best_streak": bson.M{"$ifNull": bson.A{"$trivia_best_streak", 0}},
+			"gamble_profit":      bson.M{"$ifNull": bson.A{"$gamble_profit", 0}},
+		}}},
+	})
+	if err != nil {
+		return err
+	}
+	var stats []GlobalStats
+	err = cursor.All(ctx, &stats)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, stat := range stats {
+		stat.UpdatedAt = now
+		_, err = statsCollection.ReplaceOne(
+			ctx,
+			bson.M{"user_id": stat.UserID, "guild_id": guildID},
+			stat,
+			options.Replace().SetUpsert(true),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Not a command
+// Gets the ID of every server that has a database
+func getGuildIDs(ctx context.Context, client *mongo.Client) ([]int, error) {
+	names, err := client.ListDatabaseNames(ctx, bson.D{})
+	if err != nil {
+		return nil, err
+	}
+	guildIDs := []int{}
+	for _, name := range names {
+		// Server databases are named by their ID, which leaves out Global, admin and local
+		guildID, err := strconv.Atoi(name)
+		if err == nil {
+			guildIDs = append(guildIDs, guildID)
+		}
+	}
+	return guildIDs, nil
+}
+
+// Not a command
+// Copies one user's stats from every server they play in
+func syncGlobalUser(ctx context.Context, client *mongo.Client, userID int) (error) {
+	guildIDs, err := getGuildIDs(ctx, client)
+	if err != nil {
+		return err
+	}
+	stocks, err := getStocks(ctx, client)
+	if err != nil {
+		return err
+	}
+	for _, guildID := range guildIDs {
+		err = syncGlobalGuild(ctx, client, guildID, []int{userID}, stocks)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Copies every opted-in user's stats into GlobalStats
+// Run by the scheduler every GlobalSyncInterval
+func SyncGlobalStats(mongoURI string) {
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx)
+
+	cursor, err := client.Database(globalDatabase).Collection("GlobalPlayers").Find(ctx, bson.D{})
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return
+	}
+	var players []GlobalPlayer
+	err = cursor.All(ctx, &players)
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return
+	}
+	if len(players) == 0 {
+		return
+	}
+	playerIDs := []int{}
+	for _, player := range players {
+		playerIDs = append(playerIDs, player.UserID)
+	}
+
+	guildIDs, err := getGuildIDs(ctx, client)
+	if err != nil {
+		fmt.Printf("Error occurred while listing server databases! %s\n", err)
+		return
+	}
+	stocks, err := getStocks(ctx, client)
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return
+	}
+	for _, guildID := range guildIDs {
+		err = syncGlobalGuild(ctx, client, guildID, playerIDs, stocks)
+		if err != nil {
+			fmt.Printf("Error occurred while syncing global stats for server %d! %s\n", guildID, err)
+		}
+	}
+}
+
+// mary global join/leave -> opts in or out of the global leaderboard and profile
+func SetGlobalOptIn(mongoURI string, guildID int, guildName string, userID int, userName string, join bool) (string) {
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error())
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	playerCollection := client.Database(globalDatabase).Collection("GlobalPlayers")
+	if !join {
+		_, err = playerCollection.DeleteOne(ctx, bson.M{"user_id": userID})
+		if err == nil {
+			_, err = client.Database(globalDatabase).Collection("GlobalStats").DeleteMany(ctx, bson.M{"user_id": userID})
+		}
+		if err != nil {
+			fmt.Printf("Error occurred while updating database! %s\n", err)
+			return "Error occurred while updating database! " + strings.Title(err.Error())
+		}
+		return "<@" + strconv.Itoa(userID) + ">, you have left the global leaderboard. Your stats from every server have been removed."
+	}
+
+	// Check if user exists in database
+	res := IsPlaying(ctx, client, guildID, guildName, userID, userName)
+	if res != "" {
+		return res
+	}
+
+	_, err = playerCollection.UpdateOne(
+		ctx,
+		bson.M{"user_id": userID},
+		bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "user_name", Value: userName},
+			}},
+			{Key: "$setOnInsert", Value: bson.D{
+				{Key: "joined_at", Value: time.Now()},
+			}},
+		},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+		return "Error occurred while updating database! " + strings.Title(err.Error())
+	}
+
+	// Copy their stats straight away so they don't have to wait for the next sync
+	err = syncGlobalUser(ctx, client, userID)
+	if err != nil {
+		fmt.Printf("Error occurred while syncing global stats! %s\n", err)
+	}
+	message := "<@" + strconv.Itoa(userID) + ">, you have joined the global leaderboard! Stats from every server you play in will be combined."
+	if getSettings(ctx, client, guildID).GlobalOptOut {
+		message += " This server has opted out, so your stats here won't be included."
+	}
+	return message
+}
+
+// mary global enable/disable (admin only) -> lets a server keep its players' stats out of the global leaderboard
+func SetGuildGlobalOptOut(mongoURI string, guildID int, optOut bool) (string) {
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error())
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	err = updateSettings(ctx, client, guildID, bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "global_opt_out", Value: optOut},
+		}},
+	})
+	if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+		return "Error occurred while updating database! " + strings.Title(err.Error())
+	}
+
+	if !optOut {
+		return "This server is now part of the global leaderboard. Stats of players who have joined will show up after the next sync."
+	}
+	// Remove the server's stats straight away rather than waiting for the next sync
+	_, err = client.Database(globalDatabase).Collection("GlobalStats").DeleteMany(ctx, bson.M{"guild_id": guildID})
+	if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+		return "Error occurred while updating database! " + strings.Title(err.Error())
+	}
+	return "This server has opted out of the global leaderboard. Nobody's stats from here will be shared."
+}
+
+// mary global top [optional: metric] [optional: page] -> ranks opted-in users by their stats from every server
+func GlobalLeaderboard(mongoURI string, metricName string, page int) (string, *discordgo.MessageEmbed) {
+	metricName, ok := FindLeaderboardMetric(metricName)
+	if !ok {
+		return "Please choose a leaderboard of coins, networth, trivia, streak or gambling!", nil
+	}
+	metric, ok := globalMetrics[metricName]
+	if !ok {
+		// Levels are earned separately in each server, so adding them up wouldn't mean anything
+		return "That leaderboard is only available per server! Please use `mary top " + metricName + "`.", nil
+	}
+	if page < 1 {
+		return "Please enter a valid page number!", nil
+	}
+
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error()), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error()), nil
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	statsCollection := client.Database(globalDatabase).Collection("GlobalStats")
+	cursor, err := statsCollection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{
+			"_id":       "$user_id",
+			"user_name": bson.M{"$last": "$user_name"},
+			"score":     bson.M{metric.Op: "$" + metric.Field},
+			"servers":   bson.M{"$sum": 1},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "score", Value: -1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$facet", Value: bson.M{
+			"total": bson.A{bson.M{"$count": "count"}},
+			"page":  bson.A{bson.M{"$skip": (page - 1) * leaderboardPageSize}, bson.M{"$limit": leaderboardPageSize}},
+		}}},
+	})
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return "Error occurred while selecting from database! " + strings.Title(err.Error()), nil
+	}
+	var results []struct {
+		Total []struct {
+			Count int `bson:"count"`
+		} `bson:"total"`
+		Page []struct {
+			UserName string  `bson:"user_name"`
+			Score    float64 `bson:"score"`
+			Servers  int     `bson:"servers"`
+		} `bson:"page"`
+	}
+	err = cursor.All(ctx, &results)
+	if err != nil {
+		fmt.Printf("Error occurred while decoding result! %s\n", err)
+		return "Error occurred while decoding result! " + strings.Title(err.Error()), nil
+	}
+	if len(results) == 0 || len(results[0].Total) == 0 {
+		return "Nobody has joined the global leaderboard yet! Use `mary global join` to be the first.", nil
+	}
+	total := results[0].Total[0].Count
+	pages := (total + leaderboardPageSize - 1) / leaderboardPageSize
+	if page > pages {
+		return fmt.Sprintf("There are only %d pages!", pages), nil
+	}
+
+	lines := []string{}
+	for i, entry := range results[0].Page {
+		lines = append(lines, fmt.Sprintf("**%d.** %s: %s %s (%d servers)", (page-1)*leaderboardPageSize+i+1, entry.UserName, formatScore(entry.Score), leaderboardMetrics[metricName].Unit, entry.Servers))
+	}
+	embed := &discordgo.MessageEmbed{
+		Title:       "Global " + leaderboardMetrics[metricName].Title,
+		Description: strings.Join(lines, "\n"),
+		Color:       0xffc0cb,
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: leaderboardFooter(fmt.Sprintf("Page %d/%d - Only players who used mary global join are shown", page, pages), leaderboardMetrics[metricName]),
+		},
+	}
+	return "", embed
+}
+
+// mary global profile [optional: @user] -> shows the servers a user plays in and their combined stats
+func GlobalProfile(mongoURI string, userID int, userName string) (string, *discordgo.MessageEmbed) {
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error()), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error()), nil
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	count, err := client.Database(globalDatabase).Collection("GlobalPlayers").CountDocuments(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return "Error occurred while selecting from database! " + strings.Title(err.Error()), nil
+	}
+	if count == 0 {
+		return userName + " hasn't joined the global leaderboard! Use `mary global join` to share your stats from every server.", nil
+	}
+
+	cursor, err := client.Database(globalDatabase).Collection("GlobalStats").Find(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return "Error occurred while selecting from database! " + strings.Title(err.Error()), nil
+	}
+	var stats []GlobalStats
+	err = cursor.All(ctx, &stats)
+	if err != nil {
+		fmt.Printf("Error occurred while decoding result! %s\n", err)
+		return "Error occurred while decoding result! " + strings.Title(err.Error()), nil
+	}
+	if len(stats) == 0 {
+		return userName + " doesn't have any stats to share yet! Servers that have opted out aren't included.", nil
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].NetWorth > stats[j].NetWorth
+	})
+
+	var combined GlobalStats
+	servers := []string{}
+	for _, stat := range stats {
+		combined.Balance += stat.Balance
+		combined.NetWorth += stat.NetWorth
+		combined.TriviaCorrect += stat.TriviaCorrect
+		combined.GambleProfit += stat.GambleProfit
+		if stat.TriviaBestStreak > combined.TriviaBestStreak {
+			combined.TriviaBestStreak = stat.TriviaBestStreak
+		}
+		// Keep the list short enough for one embed field
+		if len(servers) < 15 {
+			servers = append(servers, fmt.Sprintf("**%s**: %d coins, %.0f net worth", stat.GuildName, stat.Balance, stat.NetWorth))
+		}
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title: userName + "'s Global Profile",
+		Color: 0xffc0cb,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Coins", Value: strconv.FormatInt(combined.Balance, 10), Inline: true},
+			{Name: "Net Worth", Value: fmt.Sprintf("%.0f", combined.NetWorth), Inline: true},
+			{Name: "Trivia Wins", Value: strconv.Itoa(combined.TriviaCorrect), Inline: true},
+			{Name: "Best Trivia Streak", Value: strconv.Itoa(combined.TriviaBestStreak), Inline: true},
+			{Name: "Gambling Profit", Value: strconv.FormatInt(combined.GambleProfit, 10), Inline: true},
+			{Name: fmt.Sprintf("Servers (%d)", len(stats)), Value: strings.Join(servers, "\n")},
+		},
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "Updated",
+		},
+		Timestamp: stats[0].UpdatedAt.Format(time.RFC3339),
+	}
+	return "", embed
+}
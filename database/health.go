@@ -0,0 +1,286 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Health is stored as the damage taken, so players who have never been hurt are at full health
+const maxHealth = 100
+const healthRegenPerMinute = 1
+
+// Players at 0 HP are knocked out for a while, then wake up with a little health
+const knockoutTime = 30 * time.Minute
+const wakeHealth = 25
+
+// The hospital heals you fully for a base fee plus a little for each HP missing
+const hospitalBaseFee = 100
+const hospitalFeePerHP = 5
+
+// How much HP each healing item restores, keyed by the name used in the inventory
+var healingItems = map[string]int{
+	"chocolate":   5,
+	"bandage":     20,
+	"painkillers": 40,
+	"medkit":      maxHealth,
+	"giftbox":     25, // Five chocolates' worth
+}
+
+// Commands that can still be used while knocked out
+var knockoutCommands = map[string]bool{
+	"help":      true,
+	"profile":   true,
+	"bal":       true,
+	"inventory": true,
+	"inv":       true,
+	"history":   true,
+	"hospital":  true,
+	"crimes":    true,
+	"notify":    true,
+}
+
+// The damage one attack did to someone
+type Hit struct {
+	UserID     int // Who got hurt, the attacker if the defender fought back
+	Damage     int
+	Health     int // Health left afterwards
+	KnockedOut bool
+}
+
+// Not a command
+// Works out a user's health right now, and how long they are knocked out for if they are
+func currentHealth(user User) (int, time.Duration) {
+	if time.Now().Before(user.KnockedOutUntil) {
+		return 0, time.Until(user.KnockedOutUntil)
+	}
+	health := maxHealth - user.Damage
+	if health < maxHealth && !user.LastHurt.IsZero() && time.Now().After(user.LastHurt) {
+		health += int(time.Since(user.LastHurt).Minutes()) * healthRegenPerMinute
+	}
+	if health > maxHealth {
+		health = maxHealth
+	}
+	if health < 0 {
+		health = 0
+	}
+	return health, 0
+}
+
+// Not a command
+// Sets a user's health, knocking them out if it reaches 0
+// The filter checks nobody else changed their health since it was read, so two hits at once can't undo each other
+func setHealth(ctx context.Context, userCollection *mongo.Collection, guildID int, user User, health int) (bool, error) {
+	now := time.Now()
+	set := bson.D{
+		{Key: "damage", Value: maxHealth - health},
+		{Key: "last_hurt", Value: now},
+	}
+	if health <= 0 {
+		// They start healing from when they wake up
+		wake := now.Add(knockoutTime)
+		set = bson.D{
+			{Key: "damage", Value: maxHealth - wakeHealth},
+			{Key: "last_hurt", Value: wake},
+			{Key: "knocked_out_until", Value: wake},
+		}
+	}
+	result, err := userCollection.UpdateOne(
+		ctx,
+		bson.D{
+			{Key: "user_id", Value: user.UserID},
+			{Key: "guild_id", Value: guildID},
+			{Key: "damage", Value: bson.D{{Key: "$in", Value: bson.A{user.Damage, nil}}}},
+			{Key: "last_hurt", Value: bson.D{{Key: "$in", Value: bson.A{user.LastHurt, nil}}}},
+		},
+		bson.D{
+			{Key: "$set", Value: set},
+		},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+// Not a command
+// Takes health away from a user, reading their health again if it changed in the meantime
+func hurt(ctx context.Context, userCollection *mongo.Collection, guildID int, user User, damage int) (Hit, error) {
+	for attempt := 0; attempt < 3; attempt++ {
+		health, knockedOut := currentHealth(user)
+		if knockedOut > 0 {
+			// You can't hurt someone who is already out cold
+			return Hit{UserID: user.UserID}, nil
+		}
+		health -= damage
+		if health < 0 {
+			health = 0
+		}
+		updated, err := setHealth(ctx, userCollection, guildID, user, health)
+		if err != nil {
+			return Hit{}, err
+		}
+		if updated {
+			return Hit{UserID: user.UserID, Damage: damage, Health: health, KnockedOut: health == 0}, nil
+		}
+		err = userCollection.FindOne(ctx, bson.M{"guild_id": guildID, "user_id": user.UserID}).Decode(&user)
+		if err != nil {
+			return Hit{}, err
+		}
+	}
+	return Hit{}, fmt.Errorf("couldn't update the health of user %d", user.UserID)
+}
+
+// Not a command
+// Restores health to a user, returning their health afterwards
+func heal(ctx context.Context, userCollection *mongo.Collection, guildID int, user User, amount int) (int, error) {
+	for attempt := 0; attempt < 3; attempt++ {
+		health, _ := currentHealth(user)
+		health += amount
+		if health > maxHealth {
+			health = maxHealth
+		}
+		updated, err := setHealth(ctx, userCollection, guildID, user, health)
+		if err != nil {
+			return 0, err
+		}
+		if updated {
+			return health, nil
+		}
+		err = userCollection.FindOne(ctx, bson.M{"guild_id": guildID, "user_id": user.UserID}).Decode(&user)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return 0, fmt.Errorf("couldn't update the health of user %d", user.UserID)
+}
+
+// Not a command
+// Deals the damage from an attack, the one pipeline every attack goes through
+// A blocked attack does no damage, and a defender who fought back hurts the attacker instead
+func damageFromAttack(ctx context.Context, userCollection *mongo.Collection, guildID int, attacker User, defender User, attack Attack) (Hit, error) {
+	roll := func(power float64) (int) {
+		damage := int(power * (0.8 + rand.Float64() * 0.4)) // Between 80% and 120% of the attack
+		if damage < 1 {
+			damage = 1
+		}
+		return damage
+	}
+	switch {
+	case attack.Countered:
+		return hurt(ctx, userCollection, guildID, attacker, roll(float64(gear[attack.Counter].Attack)))
+	case attack.Blocked:
+		health, _ := currentHealth(defender)
+		return Hit{UserID: defender.UserID, Health: health}, nil
+	}
+	return hurt(ctx, userCollection, guildID, defender, roll(float64(attack.Power) * attack.Multiplier))
+}
+
+// Not a command
+// Describes the damage from an attack, from the point of view of the attacker
+func describeHit(hit Hit, attackerID int) (string) {
+	if hit.Damage == 0 {
+		return ""
+	}
+	who := "They"
+	if hit.UserID == attackerID {
+		who = "You"
+	}
+	if hit.KnockedOut {
+		return fmt.Sprintf(" %s took %d damage and got knocked out! 💫", who, hit.Damage)
+	}
+	return fmt.Sprintf(" %s took %d damage (%d/%d HP).", who, hit.Damage, hit.Health, maxHealth)
+}
+
+// Not a command
+// Describes a user's health for their profile, e.g. "85/100 HP" or "Knocked out for 12m"
+func describeHealth(user User) (string) {
+	health, knockedOut := currentHealth(user)
+	if knockedOut > 0 {
+		return "Knocked out for " + formatCooldown(knockedOut)
+	}
+	return fmt.Sprintf("%d/%d HP", health, maxHealth)
+}
+
+// mary hospital -> pays to be healed to full health straight away, even if you're knocked out
+func Hospital(mongoURI string, guildID int, guildName string, userID int, userName string) (string) {
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error())
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	// Check if user exists in database
+	res := IsPlaying(ctx, client, guildID, guildName, userID, userName)
+	if res != "" {
+		return res
+	}
+
+	userCollection := client.Database(strconv.Itoa(guildID)).Collection("Users")
+	var user User
+	err = userCollection.FindOne(ctx, bson.M{"guild_id": guildID, "user_id": userID}).Decode(&user)
+	if err != nil {
+		fmt.Printf("Error occurred while finding user in database! %s\n", err)
+		return "Error occurred while finding user in database! " + strings.Title(err.Error())
+	}
+	health, knockedOut := currentHealth(user)
+	if health == maxHealth {
+		return "You're already at full health! The hospital has nothing to do for you."
+	}
+	fee := int64(hospitalBaseFee + (maxHealth - health) * hospitalFeePerHP)
+
+	paid, err := takeCoins(ctx, userCollection, guildID, userID, fee)
+	if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+		return "Error occurred while updating database! " + strings.Title(err.Error())
+	}
+	if !paid {
+		if knockedOut > 0 {
+			return fmt.Sprintf("The hospital costs %d coins and you can't afford it! You'll come round on your own in %s.", fee, formatCooldown(knockedOut))
+		}
+		return fmt.Sprintf("The hospital costs %d coins and you can't afford it!", fee)
+	}
+	_, err = userCollection.UpdateOne(
+		ctx,
+		bson.D{
+			{Key: "user_id", Value: userID},
+			{Key: "guild_id", Value: guildID},
+		},
+		bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "damage", Value: 0},
+				{Key: "last_hurt", Value: time.Now()},
+			}},
+			{Key: "$unset", Value: bson.D{
+				{Key: "knocked_out_until", Value: ""},
+			}},
+		},
+	)
+	if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+		return "Error occurred while updating database! " + strings.Title(err.Error())
+	}
+	addToTreasury(ctx, client, guildID, fee, "hospital")
+	if knockedOut > 0 {
+		return fmt.Sprintf("🏥 The doctors brought you round and patched you up for %d coins. You're back to %d/%d HP!", fee, maxHealth, maxHealth)
+	}
+	return fmt.Sprintf("🏥 The doctors patched you up for %d coins. You're back to %d/%d HP!", fee, maxHealth, maxHealth)
+}
@@ -0,0 +1,375 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"github.com/bwmarrin/discordgo"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// A standing offer to buy items on a server's market, filled when someone lists the item at or below the price
+// The coins for the whole order are taken from the buyer when it's placed and held until it fills or is cancelled
+// Buy orders are numbered with listings, so a number always means one or the other
+type ItemOrder struct {
+	OrderID   int       `bson:"order_id"`
+	GuildID   int       `bson:"guild_id"`
+	BuyerID   int       `bson:"buyer_id"`
+	BuyerName string    `bson:"buyer_name"`
+	ChannelID string    `bson:"channel_id"` // Where the order was placed, for when the buyer's DMs are closed
+	Item      string    `bson:"item"`
+	Quantity  int       `bson:"quantity"` // How many are still wanted
+	Filled    int       `bson:"filled"`
+	Price     int64     `bson:"price"` // For each item
+	Status    string    `bson:"status"` // open, filled or cancelled
+	CreatedAt time.Time `bson:"created_at"`
+	ClosedAt  time.Time `bson:"closed_at"`
+}
+
+const maxItemOrders = 20
+
+// Not a command
+// Describes a buy order in one line
+func describeItemOrder(order ItemOrder) (string) {
+	name := strings.Title(order.Item)
+	return fmt.Sprintf("**#%d** %s %dX %s at %d coins each", order.OrderID, itemEmoji(name), order.Quantity, name, order.Price)
+}
+
+// mary market order [item] [amount] [price] -> offers to buy items at your own price, holding the coins until someone sells
+func PlaceItemOrder(mongoURI string, guildID int, guildName string, userID int, userName string, channelID string, item string, quantity int, price int64) (string) {
+	item = knownItemName(item)
+	if item == "" {
+		return "That item doesn't exist!"
+	}
+	if quantity < 1 {
+		return "Please specify a positive amount!"
+	}
+	if price < 1 || price > maxListingPrice {
+		return fmt.Sprintf("Please specify a price between 1 and %d coins!", maxListingPrice)
+	}
+
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error())
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	// Check if user exists in database
+	res := IsPlaying(ctx, client, guildID, guildName, userID, userName)
+	if res != "" {
+		return res
+	}
+
+	orderCollection := client.Database(strconv.Itoa(guildID)).Collection("ItemOrders")
+	open, err := orderCollection.CountDocuments(ctx, bson.M{"guild_id": guildID, "buyer_id": userID, "status": "open"})
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return "Error occurred while selecting from database! " + strings.Title(err.Error())
+	}
+	if open >= maxItemOrders {
+		return fmt.Sprintf("You can only have %d buy orders at a time! Cancel one with `mary market cancel [order number]`.", maxItemOrders)
+	}
+
+	// If someone is already selling at that price there's nothing to wait for
+	var listing Listing
+	err = client.Database(strconv.Itoa(guildID)).Collection("Listings").FindOne(
+		ctx,
+		bson.D{
+			{Key: "guild_id", Value: guildID},
+			{Key: "item", Value: item},
+			{Key: "status", Value: "open"},
+			{Key: "seller_id", Value: bson.D{{Key: "$ne", Value: userID}}},
+			{Key: "price", Value: bson.D{{Key: "$lte", Value: price}}},
+		},
+		options.FindOne().SetSort(bson.D{{Key: "price", Value: 1}, {Key: "listing_id", Value: 1}}),
+	).Decode(&listing)
+	if err == nil {
+		return fmt.Sprintf("Listing #%d is already selling %s for %d coins each! Use `mary market buy %d [amount]` instead.", listing.ListingID, item, listing.Price, listing.ListingID)
+	} else if err != mongo.ErrNoDocuments {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return "Error occurred while selecting from database! " + strings.Title(err.Error())
+	}
+
+	// Hold the coins for the whole order
+	cost := price * int64(quantity)
+	userCollection := client.Database(strconv.Itoa(guildID)).Collection("Users")
+	paid, err := takeCoins(ctx, userCollection, guildID, userID, cost)
+	if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+		return "Error occurred while updating database! " + strings.Title(err.Error())
+	}
+	if !paid {
+		return fmt.Sprintf("You don't have enough coins! Buying %dX %s at %d coins each needs %d coins.", quantity, item, price, cost)
+	}
+
+	orderID, err := nextSequence(ctx, client, "listings")
+	if err == nil {
+		_, err = orderCollection.InsertOne(ctx, ItemOrder{
+			OrderID:   orderID,
+			GuildID:   guildID,
+			BuyerID:   userID,
+			BuyerName: userName,
+			ChannelID: channelID,
+			Item:      item,
+			Quantity:  quantity,
+			Price:     price,
+			Status:    "open",
+			CreatedAt: time.Now(),
+		})
+	}
+	if err != nil {
+		fmt.Printf("Error occurred while inserting into database! %s\n", err)
+		if err := addCoins(ctx, userCollection, guildID, userID, cost); err != nil {
+			fmt.Printf("Error occurred while refunding %d coins to user %d! %s\n", cost, userID, err)
+		}
+		return "Error occurred while inserting into database! " + strings.Title(err.Error())
+	}
+	return fmt.Sprintf("Placed buy order #%d for %dX %s at %d coins each! %d coins are held until someone sells to you or you cancel it.", orderID, quantity, item, price, cost)
+}
+
+// Not a command
+// Sells items that have just been listed to the best buy orders at or above the asking price, highest price first
+// The items must already be out of the seller's inventory, and the seller is paid each order's price minus the sales tax
+// The wear on the items goes with the last of them, so it only leaves with an order that takes them all
+// Returns how many were sold and how many coins the seller got
+func fillItemOrders(ctx context.Context, client *mongo.Client, guildID int, guildName string, sellerID int, sellerName string, channelID string, item string, quantity int, wear int, price int64) (int, int64) {
+	orderCollection := client.Database(strconv.Itoa(guildID)).Collection("ItemOrders")
+	userCollection := client.Database(strconv.Itoa(guildID)).Collection("Users")
+	_, tax := marketRates(getSettings(ctx, client, guildID))
+	sold, earned := 0, int64(0)
+
+	// Each pass fills one order, and a pass that loses a race just looks again
+	for attempt := 0; sold < quantity && attempt < 50; attempt++ {
+		var order ItemOrder
+		err := orderCollection.FindOne(
+			ctx,
+			bson.D{
+				{Key: "guild_id", Value: guildID},
+				{Key: "item", Value: item},
+				{Key: "status", Value: "open"},
+				{Key: "buyer_id", Value: bson.D{{Key: "$ne", Value: sellerID}}},
+				{Key: "price", Value: bson.D{{Key: "$gte", Value: price}}},
+			},
+			options.FindOne().SetSort(bson.D{{Key: "price", Value: -1}, {Key: "order_id", Value: 1}}),
+		).Decode(&order)
+		if err != nil {
+			if err != mongo.ErrNoDocuments {
+				fmt.Printf("Error occurred while selecting from database! %s\n", err)
+			}
+			break
+		}
+
+		amount := quantity - sold
+		if amount > order.Quantity {
+			amount = order.Quantity
+		}
+		result, err := orderCollection.UpdateOne(
+			ctx,
+			bson.D{
+				{Key: "order_id", Value: order.OrderID},
+				{Key: "status", Value: "open"},
+				{Key: "quantity", Value: bson.D{{Key: "$gte", Value: amount}}},
+			},
+			bson.D{
+				{Key: "$inc", Value: bson.D{
+					{Key: "quantity", Value: -amount},
+					{Key: "filled", Value: amount},
+				}},
+			},
+		)
+		if err != nil {
+			fmt.Printf("Error occurred while updating database! %s\n", err)
+			break
+		}
+		if result.ModifiedCount == 0 {
+			continue
+		}
+
+		// Close the order if that was the last of it
+		_, err = orderCollection.UpdateOne(
+			ctx,
+			bson.M{"order_id": order.OrderID, "status": "open", "quantity": 0},
+			bson.D{
+				{Key: "$set", Value: bson.D{
+					{Key: "status", Value: "filled"},
+					{Key: "closed_at", Value: time.Now()},
+				}},
+			},
+		)
+		if err != nil {
+			fmt.Printf("Error occurred while updating database! %s\n", err)
+		}
+
+		// The buyer's coins were already taken, so the seller is paid out of them
+		lotWear := 0
+		if sold + amount == quantity {
+			lotWear = wear
+		}
+		err = addItemLot(ctx, userCollection, guildID, order.BuyerID, item, amount, lotWear)
+		if err != nil {
+			fmt.Printf("Error occurred while giving %dX %s to user %d! %s\n", amount, item, order.BuyerID, err)
+		}
+		cost := order.Price * int64(amount)
+		taxed := int64(math.Floor(float64(cost) * tax / 100))
+		err = addCoins(ctx, userCollection, guildID, sellerID, cost - taxed)
+		if err != nil {
+			fmt.Printf("Error occurred while paying %d coins to user %d! %s\n", cost - taxed, sellerID, err)
+		} else {
+			addSeasonEarnings(ctx, userCollection, guildID, sellerID, cost - taxed)
+		}
+		addToTreasury(ctx, client, guildID, taxed, "market_tax")
+		sold += amount
+		earned += cost - taxed
+
+		notifyUser(order.BuyerID, order.ChannelID, fmt.Sprintf("🛒 %s sold you %dX %s%s for your buy order #%d in %s, for %d coins!", sellerName, amount, item, describeWear(item, lotWear), order.OrderID, guildName, cost))
+		recordEvent(ctx, client, GameEvent{GuildID: guildID, UserID: order.BuyerID, ChannelID: order.ChannelID, Type: "market_buy", Item: item, TargetID: sellerID, Amount: int64(amount)})
+		recordEvent(ctx, client, GameEvent{GuildID: guildID, UserID: sellerID, ChannelID: channelID, Type: "market_sell", Item: item, TargetID: order.BuyerID, Amount: int64(amount)})
+	}
+	return sold, earned
+}
+
+// Not a command
+// Cancels one of a user's buy orders and gives back the coins held for what hasn't been filled
+// Returns false if they don't have an open buy order with that number
+func cancelItemOrder(ctx context.Context, client *mongo.Client, guildID int, userID int, orderID int) (string, bool) {
+	var order ItemOrder
+	err := client.Database(strconv.Itoa(guildID)).Collection("ItemOrders").FindOneAndUpdate(
+		ctx,
+		bson.D{
+			{Key: "order_id", Value: orderID},
+			{Key: "guild_id", Value: guildID},
+			{Key: "buyer_id", Value: userID},
+			{Key: "status", Value: "open"},
+		},
+		bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "status", Value: "cancelled"},
+				{Key: "closed_at", Value: time.Now()},
+			}},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&order)
+	if err == mongo.ErrNoDocuments {
+		return "", false
+	} else if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+		return "Error occurred while updating database! " + strings.Title(err.Error()), true
+	}
+	refund := order.Price * int64(order.Quantity)
+	if refund > 0 {
+		err = addCoins(ctx, client.Database(strconv.Itoa(guildID)).Collection("Users"), guildID, userID, refund)
+		if err != nil {
+			fmt.Printf("Error occurred while refunding %d coins to user %d! %s\n", refund, userID, err)
+			return "Error occurred while refunding your coins! " + strings.Title(err.Error()), true
+		}
+	}
+	return fmt.Sprintf("Cancelled buy order #%d and gave you back %d coins for the %dX %s that weren't filled.", orderID, refund, order.Quantity, order.Item), true
+}
+
+// mary market orders [optional: item] [optional: page] -> shows the best buy orders on the market, optionally only for one item
+func BrowseItemOrders(mongoURI string, guildID int, guildName string, item string, page int) (string, *discordgo.MessageEmbed) {
+	if item != "" {
+		item = knownItemName(item)
+		if item == "" {
+			return "That item doesn't exist!", nil
+		}
+	}
+	if page < 1 {
+		return "Please enter a valid page number!", nil
+	}
+
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error()), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error()), nil
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	filter := bson.M{"guild_id": guildID, "status": "open"}
+	if item != "" {
+		filter["item"] = item
+	}
+	orderCollection := client.Database(strconv.Itoa(guildID)).Collection("ItemOrders")
+	total, err := orderCollection.CountDocuments(ctx, filter)
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return "Error occurred while selecting from database! " + strings.Title(err.Error()), nil
+	}
+	if total == 0 {
+		if item != "" {
+			return "Nobody wants to buy " + item + " right now!", nil
+		}
+		return "Nobody wants to buy anything right now! Place a buy order with `mary market order [item] [amount] [price]`.", nil
+	}
+	pages := int((total + marketPageSize - 1) / marketPageSize)
+	if page > pages {
+		return fmt.Sprintf("There are only %d pages!", pages), nil
+	}
+
+	// Best price first, then oldest first, which is the order they fill in
+	cursor, err := orderCollection.Find(
+		ctx,
+		filter,
+		options.Find().
+			SetSort(bson.D{{Key: "price", Value: -1}, {Key: "order_id", Value: 1}}).
+			SetSkip(int64((page - 1) * marketPageSize)).
+			SetLimit(marketPageSize),
+	)
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return "Error occurred while selecting from database! " + strings.Title(err.Error()), nil
+	}
+	var orders []ItemOrder
+	err = cursor.All(ctx, &orders)
+	if err != nil {
+		fmt.Printf("Error occurred while decoding result! %s\n", err)
+		return "Error occurred while decoding result! " + strings.Title(err.Error()), nil
+	}
+
+	lines := []string{}
+	for _, order := range orders {
+		lines = append(lines, describeItemOrder(order) + " from " + order.BuyerName)
+	}
+	_, tax := marketRates(getSettings(ctx, client, guildID))
+	title := guildName + "'s Buy Orders"
+	if item != "" {
+		title += ": " + strings.Title(item)
+	}
+	embed := &discordgo.MessageEmbed{
+		Title: title,
+		Description: strings.Join(lines, "\n"),
+		Color: 0xffc0cb,
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: fmt.Sprintf("Page %d/%d • mary market sell [item] [amount] [price] at or below a price to sell to it • Sales tax %g%%", page, pages, tax),
+		},
+	}
+	return "", embed
+}
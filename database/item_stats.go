@@ -0,0 +1,268 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// How an item behaves when it's used or sold, keyed by the name used in the inventory
+type ItemStats struct {
+	Durability int           // Uses before one breaks, 0 if it's used up straight away
+	Cooldown   time.Duration // How long to wait between uses of this item
+	Rarity     string        // common, rare or epic, the same tiers as crate drops
+}
+
+// Items that aren't in here are common, get used up straight away and have the default cooldown
+var itemStats = map[string]ItemStats{
+	"gun":         {5, time.Minute, "common"},
+	"bow":         {3, 30 * time.Second, "common"},
+	"crossbow":    {4, 45 * time.Second, "rare"},
+	"car":         {10, 5 * time.Minute, "epic"},
+	"shield":      {3, 0, "rare"}, // Wears down each time it blocks a shot
+	"chocolate":   {0, 10 * time.Second, "common"},
+	"ring":        {0, time.Minute, "rare"},
+	"galaxy":      {0, 0, "epic"},
+	"sakura":      {0, 0, "epic"},
+	"ocean":       {0, 0, "epic"},
+	"crate":       {0, 0, "common"},
+	"chest":       {0, 0, "rare"},
+	"vault":       {0, 0, "epic"},
+	"knife":       {0, 0, "common"},
+	"vest":        {5, 0, "rare"}, // Wears down each time it blocks an attack
+	"charm":       {0, 0, "rare"},
+	"bandage":     {0, 30 * time.Second, "common"},
+	"painkillers": {0, time.Minute, "common"},
+	"medkit":      {0, 2 * time.Minute, "rare"},
+	"giftbox":     {0, 30 * time.Second, "common"},
+}
+
+const defaultItemCooldown = time.Minute
+
+// How much of the shop price an item sells back for, by rarity
+var raritySellRates = map[string]float64{
+	"common": 0.5,
+	"rare":   0.6,
+	"epic":   0.75,
+}
+
+// Not a command
+// Gets the stats of an item, filling in the defaults for items that aren't listed
+func getItemStats(item string) (ItemStats) {
+	stats, ok := itemStats[item]
+	if !ok {
+		return ItemStats{0, defaultItemCooldown, "common"}
+	}
+	if stats.Rarity == "" {
+		stats.Rarity = "common"
+	}
+	return stats
+}
+
+// Not a command
+// Works out how much an item sells back to the shop for, based on its rarity
+func sellPrice(item string, price int) (int) {
+	rate, ok := raritySellRates[getItemStats(item).Rarity]
+	if !ok {
+		rate = raritySellRates["common"]
+	}
+	return int(float64(price) * rate)
+}
+
+// Not a command
+// Starts an item's cooldown, returning how long is left instead if it's still cooling down
+// The check is part of the filter so two uses at once can't both get through
+func startCooldown(ctx context.Context, userCollection *mongo.Collection, guildID int, userID int, item string) (time.Duration, error) {
+	cooldown := getItemStats(item).Cooldown
+	if cooldown <= 0 {
+		return 0, nil
+	}
+	field := "cooldowns." + item
+	now := time.Now()
+	result, err := userCollection.UpdateOne(
+		ctx,
+		bson.D{
+			{Key: "user_id", Value: userID},
+			{Key: "guild_id", Value: guildID},
+			{Key: "$or", Value: bson.A{
+				bson.D{{Key: field, Value: bson.D{{Key: "$exists", Value: false}}}},
+				bson.D{{Key: field, Value: bson.D{{Key: "$lte", Value: now.Add(-cooldown)}}}},
+			}},
+		},
+		bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: field, Value: now},
+			}},
+		},
+	)
+	if err != nil {
+		return 0, err
+	}
+	if result.ModifiedCount == 1 {
+		return 0, nil
+	}
+
+	// Someone else got there first, so work out how long is left
+	var user User
+	err = userCollection.FindOne(ctx, bson.M{"guild_id": guildID, "user_id": userID}).Decode(&user)
+	if err != nil {
+		return 0, err
+	}
+	remaining := time.Until(user.Cooldowns[item].Add(cooldown))
+	if remaining < time.Second {
+		remaining = time.Second
+	}
+	return remaining, nil
+}
+
+// Not a command
+// Gets how long is left on an item's cooldown, 0 if it's ready to use
+func cooldownLeft(user User, item string) (time.Duration) {
+	lastUse, ok := user.Cooldowns[item]
+	if !ok {
+		return 0
+	}
+	remaining := time.Until(lastUse.Add(getItemStats(item).Cooldown))
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// Not a command
+// Wears down one of an item in a user's inventory
+// Items without durability are used up straight away; the rest only go once their durability runs out
+// Returns whether the user had one to use, and whether it was used up or broke
+func wearItem(ctx context.Context, userCollection *mongo.Collection, guildID int, userID int, item string) (bool, bool, error) {
+	durability := getItemStats(item).Durability
+	if durability <= 0 {
+		taken, err := takeItem(ctx, userCollection, guildID, userID, item, 1)
+		return taken, taken, err
+	}
+
+	// Add a use to the one being worn down
+	result, err := userCollection.UpdateOne(
+		ctx,
+		bson.D{
+			{Key: "user_id", Value: userID},
+			{Key: "guild_id", Value: guildID},
+			{Key: "inventory", Value: bson.D{
+				{Key: "$elemMatch", Value: bson.D{
+					{Key: "name", Value: item},
+					{Key: "quantity", Value: bson.D{{Key: "$gte", Value: 1}}},
+				}},
+			}},
+		},
+		bson.D{
+			{Key: "$inc", Value: bson.D{
+				{Key: "inventory.$.wear", Value: 1},
+			}},
+		},
+	)
+	if err != nil {
+		return false, false, err
+	}
+	if result.ModifiedCount == 0 {
+		return false, false, nil
+	}
+
+	// If that was its last use, it breaks and the next one in the stack takes its place
+	broke, err := breakWornItem(ctx, userCollection, guildID, userID, item, durability)
+	if err != nil {
+		return true, false, err
+	}
+	if broke {
+		clearWear(ctx, userCollection, guildID, userID, item)
+	}
+	return true, broke, nil
+}
+
+// Not a command
+// Uses up the item being worn down if its durability has run out, taking that much wear off the stack
+// Returns whether one broke
+func breakWornItem(ctx context.Context, userCollection *mongo.Collection, guildID int, userID int, item string, durability int) (bool, error) {
+	result, err := userCollection.UpdateOne(
+		ctx,
+		bson.D{
+			{Key: "user_id", Value: userID},
+			{Key: "guild_id", Value: guildID},
+			{Key: "inventory", Value: bson.D{
+				{Key: "$elemMatch", Value: bson.D{
+					{Key: "name", Value: item},
+					{Key: "wear", Value: bson.D{{Key: "$gte", Value: durability}}},
+				}},
+			}},
+		},
+		bson.D{
+			{Key: "$inc", Value: bson.D{
+				{Key: "inventory.$.quantity", Value: -1},
+				{Key: "inventory.$.wear", Value: -durability},
+			}},
+		},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+// Not a command
+// Describes the wear carried by items being moved around, e.g. " (one has 2/5 uses left)"
+// Returns an empty string if they're all good as new
+func describeWear(item string, wear int) (string) {
+	durability := getItemStats(item).Durability
+	if wear <= 0 || durability <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (one has %d/%d uses left)", durability - wear, durability)
+}
+
+// Not a command
+// Forgets the wear on an item once a user has none of it left, so the next one they get is good as new
+func clearWear(ctx context.Context, userCollection *mongo.Collection, guildID int, userID int, item string) {
+	if getItemStats(item).Durability <= 0 {
+		return
+	}
+	_, err := userCollection.UpdateOne(
+		ctx,
+		bson.D{
+			{Key: "user_id", Value: userID},
+			{Key: "guild_id", Value: guildID},
+			{Key: "inventory", Value: bson.D{
+				{Key: "$elemMatch", Value: bson.D{
+					{Key: "name", Value: item},
+					{Key: "quantity", Value: bson.D{{Key: "$lte", Value: 0}}},
+				}},
+			}},
+		},
+		bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "inventory.$.wear", Value: 0},
+			}},
+		},
+	)
+	if err != nil {
+		fmt.Printf("Error occurred while resetting wear on %s for user %d! %s\n", item, userID, err)
+	}
+}
+
+// Not a command
+// Describes how long is left on a cooldown, e.g. "1m 30s"
+func formatCooldown(remaining time.Duration) (string) {
+	remaining = remaining.Round(time.Second)
+	minutes := int(remaining.Minutes())
+	seconds := int(remaining.Seconds()) % 60
+	if minutes == 0 {
+		return fmt.Sprintf("%ds", seconds)
+	}
+	return fmt.Sprintf("%dm %ds", minutes, seconds)
+}
+
+// Not a command
+// Describes an item's rarity, e.g. "Rare"
+func rarityName(item string) (string) {
+	return strings.Title(getItemStats(item).Rarity)
+}
@@ -28,6 +28,18 @@ var items = []ShopItem{ // Global variables don't use :=, they use =
 	{"💍 Ring", 1000, "Congratulations! Who's the lucky person?"},
 	{"🏹 Bow", 400, "It might not be as strong as a gun, but it's cheaper!"},
 	{"🛡️ Shield", 5000, "Protect yourself from the attackers!"},
+	{"🌌 Galaxy", 3000, "A starry background for your profile card. Put it on with mary background galaxy!"},
+	{"🌸 Sakura", 3000, "A cherry blossom background for your profile card. Put it on with mary background sakura!"},
+	{"🌊 Ocean", 3000, "A wavy background for your profile card. Put it on with mary background ocean!"},
+	{"📦 Crate", 500, "A loot box full of surprises. Open it with mary open crate!"},
+	{"🧰 Chest", 2500, "A sturdier loot box with better odds. Open it with mary open chest!"},
+	{"🗝️ Vault", 10000, "The best loot box money can buy. Open it with mary open vault!"},
+	{"🔪 Knife", 300, "A small weapon to equip for robberies. Better than your fists!"},
+	{"🦺 Vest", 3000, "Armor to equip that can stop an attack, or at least soften it."},
+	{"🧿 Charm", 1500, "An accessory to equip that adds a little attack and defense."},
+	{"🩹 Bandage", 150, "Patches up a scrape. Restores 20 HP."},
+	{"💊 Painkillers", 400, "Takes the edge off. Restores 40 HP."},
+	{"⛑️ Medkit", 1000, "Everything you need after a bad day. Restores you to full health."},
 }
 
 // Lookup table for emojis
@@ -38,20 +50,53 @@ var emojiLookup = map[string]string{
 	"Ring": "💍",
 	"Bow": "🏹",
 	"Shield": "🛡️",
+	"Galaxy": "🌌",
+	"Sakura": "🌸",
+	"Ocean": "🌊",
+	"Crate": "📦",
+	"Chest": "🧰",
+	"Vault": "🗝️",
+	"Knife": "🔪",
+	"Vest": "🦺",
+	"Charm": "🧿",
+	"Bandage": "🩹",
+	"Painkillers": "💊",
+	"Medkit": "⛑️",
 }
 
 type User struct {
 	UserID   int    `bson:"user_id"`
 	GuildID  int    `bson:"guild_id"`
+	UserName string `bson:"user_name"`
 	Balance  int64  `bson:"balance"`
-	LastUse  time.Time `bson:"last_use"`
+	Cooldowns map[string]time.Time `bson:"cooldowns"` // When each item was last used
+	Equipment map[string]string `bson:"equipment"` // The item equipped in each slot
+	LastRob time.Time `bson:"last_rob"`
+	Damage int `bson:"damage"` // Health lost, see health.go
+	LastHurt time.Time `bson:"last_hurt"` // Health comes back over time from here
+	KnockedOutUntil time.Time `bson:"knocked_out_until"`
+	JailedUntil time.Time `bson:"jailed_until"`
+	Bail int64 `bson:"bail"` // What it costs to bail them out of jail
+	Revenge map[string]time.Time `bson:"revenge"` // When each robber's revenge window closes, keyed by their user ID
+	NotifyRobbery bool `bson:"notify_robbery"` // DM them when they get robbed here, from before the setting was global and only used until they pick one
+	NotifyRobberyHere *bool `bson:"notify_robbery_here,omitempty"` // Overrides their global robbery DM setting in this server, nil to follow it
 	MarriedTo int `bson:"married_to"`
 	Inventory []Item `bson:"inventory"`
+	Portfolio []Holding `bson:"portfolio"`
+	StockProfit int64 `bson:"stock_profit"` // Profit from stocks that have been sold
+	StockInvested int64 `bson:"stock_invested"` // Total coins ever spent on stocks, for working out returns
+	Badges []string `bson:"badges"`
+	XP int64 `bson:"xp"`
+	Level int `bson:"level"` // Highest level announced, so each level up is only handled once
+	Achievements []UnlockedAchievement `bson:"achievements"`
+	Titles []string `bson:"titles"` // Titles unlocked by achievements
+	Title string `bson:"title"` // The title shown on the profile
 }
 
 type Item struct {
 	Name     string `bson:"name"`
 	Quantity int    `bson:"quantity"`
+	Wear     int    `bson:"wear,omitempty"` // Uses taken off the one being worn down, the rest of the stack is good as new
 }
 
 // No return value because we are using the session to add reactions to the message
@@ -98,6 +143,16 @@ func Shop(session *discordgo.Session, message *discordgo.MessageCreate, pageSize
 			Value: fmt.Sprintf("Price: %d coins\n%s", item.Price, item.Description),
             Inline: false,
         }
+		pattern := regexp.MustCompile("[^a-zA-Z0-9]+")
+		name := strings.ToLower(pattern.ReplaceAllString(item.Name, ""))
+		field.Value += "\nRarity: " + rarityName(name)
+		if durability := getItemStats(name).Durability; durability > 0 {
+			field.Value += fmt.Sprintf(" • Lasts %d uses", durability)
+		}
+		// Show the level needed to buy it, if any
+		if required, ok := itemLevelRequirements[name]; ok {
+			field.Value += fmt.Sprintf("\n🔒 Requires level %d", required)
+		}
         embed.Fields = append(embed.Fields, field)
     }
 
@@ -174,6 +229,15 @@ func Buy(mongoURI string, guildID int, guildName string, userID int, userName st
 		return "That item doesn't exist!"
 	}
 
+	// Check if user is a high enough level for the item
+	if required, ok := itemLevelRequirements[item]; ok {
+		xp, _ := collectionResult.Lookup("xp").AsInt64OK()
+		level, _, _ := levelFromXP(xp)
+		if level < required {
+			return fmt.Sprintf("You need to be level %d to buy this item! You are level %d.", required, level)
+		}
+	}
+
 	// Check if user has enough money
 	if balance.Int64() < int64(itemPrice) * int64(amount) {
 		return "You don't have enough money to buy this item!"
@@ -238,10 +302,15 @@ func Buy(mongoURI string, guildID int, guildName string, userID int, userName st
 		return "Error occurred while updating database! " + strings.Title(err.Error())
 	}
 
+	recordEvent(ctx, client, GameEvent{GuildID: guildID, UserID: userID, Type: "buy", Item: item, Amount: int64(amount)})
 	return "You have successfully bought " + strconv.Itoa(amount) + "X " + item + " for " + strconv.Itoa(itemPrice * amount) + " coins!"
 }
 
 func Sell(mongoURI string, guildID int, guildName string, userID int, userName string, item string, amount int) (string) {
+	if amount < 1 {
+		return "Please specify a positive amount!"
+	}
+
 	// Connect to MongoDB
 	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
 	if err != nil {
@@ -281,67 +350,40 @@ func Sell(mongoURI string, guildID int, guildName string, userID int, userName s
 		return "You do not have any items in your inventory!"
 	}
 
-	// Get price of item specified from items
-	itemPrice := 0
-	for i := range items {
-		// Ignore the first character of the item name because it is a unicode emoji
-		// Second character is a space
-		pattern := regexp.MustCompile(`^\p{So}|\s`)
-		if strings.ToLower(pattern.ReplaceAllString(items[i].Name, "")) == item {
-			itemPrice = items[i].Price
-			break
-		}
-	}
+	// Get price of item specified from items, or from what it was crafted from
+	itemPrice := itemValue(item)
 
 	// Check if item exists
 	if itemPrice == 0 {
 		return "That item doesn't exist!"
 	}
 
-	// Check the quantity of the item the user currently has
-	itemAmount := 0
-	for i := range user.Inventory {
-		if strings.ToLower(user.Inventory[i].Name) == item {
-			itemAmount = user.Inventory[i].Quantity
-			break
-		}
-	}
+	// Rarer items sell back for more of their price
+	itemPrice = sellPrice(item, itemPrice)
 
-	// If the user doesn't have enough, return
-	if itemAmount < amount {
+	// Take the items first so two sells at once can't sell the same ones
+	taken, wear, err := takeItemLot(ctx, userCollection, guildID, userID, item, amount)
+	if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+		return "Error occurred while updating database! " + strings.Title(err.Error())
+	}
+	if !taken {
 		return "You don't have enough of that item to sell!"
 	}
 
-	// Set the sell price to 50% of the item's price
-	itemPrice = itemPrice / 2
-
-	// Otherwise, let the user sell the item and update their balance
-	_, err = userCollection.UpdateOne(
-		ctx,
-		bson.D{
-			{Key: "user_id", Value: userID},
-			{Key: "guild_id", Value: guildID},
-			{Key: "inventory", Value: bson.D{
-				{Key: "$elemMatch", Value: bson.D{ // Check if the user has the item in their inventory
-					{Key: "name", Value: item}, // If they do, update the quantity
-				}},
-			}},
-		},
-		bson.D{
-			{Key: "$inc", Value: bson.D{ // Remember that $dec is not a thing
-				{Key: "inventory.$.quantity", Value: -amount}, // Decrement the quantity by the amount specified
-			}},
-			{Key: "$inc", Value: bson.D{
-				{Key: "balance", Value: itemPrice * amount}, // Increment the balance by the price of the item * the amount specified
-			}},
-		},
-	)
+	// A worn item only sells for the uses it has left
+	earned := itemPrice * amount
+	if durability := getItemStats(item).Durability; wear > 0 && durability > 0 {
+		earned -= itemPrice * wear / durability
+	}
+	err = addCoins(ctx, userCollection, guildID, userID, int64(earned))
 	if err != nil {
 		fmt.Printf("Error occurred while updating database! %s\n", err)
 		return "Error occurred while updating database! " + strings.Title(err.Error())
 	}
 
-	return "You have successfully sold " + strconv.Itoa(amount) + "X " + item + " for " + strconv.Itoa(itemPrice * amount) + " coins!"
+	recordEvent(ctx, client, GameEvent{GuildID: guildID, UserID: userID, Type: "sell", Item: item, Amount: int64(amount)})
+	return "You have successfully sold " + strconv.Itoa(amount) + "X " + item + " for " + strconv.Itoa(earned) + " coins!"
 }
 
 func Inventory(mongoURI string, guildID int, guildName string, userID int, userName string) (string, *discordgo.MessageEmbed) {
@@ -388,28 +430,49 @@ func Inventory(mongoURI string, guildID int, guildName string, userID int, userN
 	embed := &discordgo.MessageEmbed{
 		Title: userName + "'s Inventory",
 		Color: 0xffc0cb,
+		Description: describeEquipment(user),
 	}
 	
 	// Find the emoji for each item
 	// Add each item to the embed
 	for _, item := range user.Inventory {
-		// Capitalize each item.Name
-		item.Name = strings.Title(item.Name)
+		// Items that were all sold or traded away stay in the inventory with nothing left
+		if item.Quantity <= 0 {
+			continue
+		}
+		stats := getItemStats(item.Name)
+		value := fmt.Sprintf("Quantity: %d\nRarity: %s", item.Quantity, rarityName(item.Name))
+		if stats, ok := gear[item.Name]; ok && stats.Slot != "" {
+			value += "\nSlot: " + strings.Title(stats.Slot)
+		}
+		if stats.Durability > 0 {
+			value += fmt.Sprintf("\nDurability: %d/%d", stats.Durability - item.Wear, stats.Durability)
+		}
+		if remaining := cooldownLeft(user, item.Name); remaining > 0 {
+			value += "\nCooldown: " + formatCooldown(remaining)
+		}
 
-		// Get the emoji for the item using emojiLookup map[string]string
-		emoji := emojiLookup[item.Name]
+		// Get the emoji for the item from the shop or the recipe that makes it
+		emoji := itemEmoji(item.Name)
 		
 		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
-			Name: fmt.Sprintf("%s %s", emoji, item.Name),
-			Value: fmt.Sprintf("Quantity: %d", item.Quantity),
+			Name: fmt.Sprintf("%s %s", emoji, strings.Title(item.Name)),
+			Value: value,
 			Inline: true,
 		})
 	}
+	if len(embed.Fields) == 0 {
+		return "You do not have any items in your inventory!", nil
+	}
 
 	return "", embed
 }
 
 func Give(mongoURI string, guildID int, guildName string, userID int, userName string, item string, amount int, pingedUser int) (string) {
+	if amount < 1 {
+		return "Please specify a positive amount!"
+	}
+
 	// Connect to MongoDB
 	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
 	if err != nil {
@@ -443,102 +506,211 @@ func Give(mongoURI string, guildID int, guildName string, userID int, userName s
 		return "The user you are trying to give an item to is not playing the game!"
 	}
 
-	// Get user from database
-	filter := bson.M{"guild_id": guildID, "user_id": userID}
-	var user User // User struct defined in database.go
-	err = userCollection.FindOne(ctx, filter).Decode(&user)
-	if err != nil {
-		fmt.Printf("Error occurred while finding user in database! %s\n", err)
-		return "Error occurred while finding user in database! " + strings.Title(err.Error())
+	// Check if item exists, either in the shop or as something that can be crafted
+	if knownItemName(item) != item {
+		return "That item doesn't exist!"
 	}
 
-	// Check if item exists
-	itemPrice := 0
-	for i := range items {
-		pattern := regexp.MustCompile(`^\p{So}|\s`)
-		if strings.ToLower(pattern.ReplaceAllString(items[i].Name, "")) == item {
-			itemPrice = items[i].Price
-			break
-		}
+	// Move the items across, along with any wear they have, so a worn item isn't given away as new
+	taken, wear, err := takeItemLot(ctx, userCollection, guildID, userID, item, amount)
+	if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+		return "Error occurred while updating database! " + strings.Title(err.Error())
 	}
-	if itemPrice == 0 {
-		return "That item doesn't exist!"
+	if !taken {
+		return "You do not have enough of this item to give!"
 	}
-
-	// Check if the user has the item in their inventory
-	itemName := ""
-	itemIndex := -1
-	for i, inventoryItem := range user.Inventory {
-		if inventoryItem.Name == item {
-			itemName = inventoryItem.Name
-			itemIndex = i
-			if inventoryItem.Quantity < amount {
-				return "You do not have enough of this item to give!"
-			}
+	err = addItemLot(ctx, userCollection, guildID, pingedUser, item, amount, wear)
+	if err != nil {
+		fmt.Printf("Error occurred while updating pinged user's inventory! %s\n", err)
+		if err := addItemLot(ctx, userCollection, guildID, userID, item, amount, wear); err != nil {
+			fmt.Printf("Error occurred while returning %dX %s to user %d! %s\n", amount, item, userID, err)
 		}
+		return "Error occurred while updating pinged user's inventory! " + strings.Title(err.Error())
 	}
-	if itemName == "" {
-		return "You do not have this item in your inventory!"
+	return fmt.Sprintf("You gave %dX %s to <@%d>!", amount, item, pingedUser)
+}
+// Not a command
+// Finds the inventory name of a shop item from what the user typed, e.g. "Gun" -> "gun"
+// Returns an empty string if there is no such item
+func shopItemName(item string) (string) {
+	pattern := regexp.MustCompile("[^a-zA-Z0-9]+")
+	item = strings.ToLower(pattern.ReplaceAllString(item, ""))
+	for i := range items {
+		if strings.ToLower(pattern.ReplaceAllString(items[i].Name, "")) == item {
+			return item
+		}
 	}
+	return ""
+}
 
-	// Otherwise, update the user's inventory and the pinged user's inventory
-	user.Inventory[itemIndex].Quantity -= amount
-	if user.Inventory[itemIndex].Quantity == 0 {
-		user.Inventory = append(user.Inventory[:itemIndex], user.Inventory[itemIndex+1:]...)
+// Not a command
+// Takes items out of a user's inventory, returning false if they don't have enough
+// The quantity check is part of the filter so two commands at once can't take the same items
+func takeItem(ctx context.Context, userCollection *mongo.Collection, guildID int, userID int, item string, amount int) (bool, error) {
+	taken, _, err := takeItemLot(ctx, userCollection, guildID, userID, item, amount)
+	return taken, err
+}
+
+// Not a command
+// Takes items out of a user's inventory to move them somewhere else, like a listing, an auction or another user
+// Good as new ones are taken first, so the one being worn down only goes with them if the whole stack does
+// Returns whether they had enough, and the wear that went with the items
+func takeItemLot(ctx context.Context, userCollection *mongo.Collection, guildID int, userID int, item string, amount int) (bool, int, error) {
+	if amount < 1 {
+		return false, 0, fmt.Errorf("can't take %d of %s", amount, item)
 	}
-	_, err = userCollection.UpdateOne(
+	var before User
+	err := userCollection.FindOneAndUpdate(
 		ctx,
-		filter,
-		bson.M{
-			"$set": bson.M{
-				"inventory": user.Inventory,
-			},
+		bson.D{
+			{Key: "user_id", Value: userID},
+			{Key: "guild_id", Value: guildID},
+			{Key: "inventory", Value: bson.D{
+				{Key: "$elemMatch", Value: bson.D{
+					{Key: "name", Value: item},
+					{Key: "quantity", Value: bson.D{{Key: "$gte", Value: amount}}},
+				}},
+			}},
 		},
-	)
+		bson.D{
+			{Key: "$inc", Value: bson.D{
+				{Key: "inventory.$.quantity", Value: -amount},
+			}},
+		},
+		options.FindOneAndUpdate().SetProjection(bson.M{"inventory": 1}),
+	).Decode(&before)
+	if err == mongo.ErrNoDocuments {
+		return false, 0, nil
+	}
 	if err != nil {
-		fmt.Printf("Error occurred while updating database! %s\n", err)
-		return "Error occurred while updating database! " + strings.Title(err.Error())
+		return false, 0, err
+	}
+
+	wear := 0
+	for _, inventoryItem := range before.Inventory {
+		if inventoryItem.Name == item && inventoryItem.Quantity == amount {
+			wear = inventoryItem.Wear
+		}
 	}
+	clearWear(ctx, userCollection, guildID, userID, item)
+	return true, wear, nil
+}
 
-	// Update pinged user's inventory
-	pingedUserCollection := client.Database(strconv.Itoa(guildID)).Collection("Users")
-	filter = bson.M{"guild_id": guildID, "user_id": pingedUser}
+// Not a command
+// Puts items into a user's inventory, adding to the stack if they already have some
+func addItem(ctx context.Context, userCollection *mongo.Collection, guildID int, userID int, item string, amount int) (error) {
+	return addItemLot(ctx, userCollection, guildID, userID, item, amount, 0)
+}
 
-	// Check if pinged user has an inventory
-	var pingedUserStruct User
-	err = pingedUserCollection.FindOne(ctx, filter).Decode(&pingedUserStruct)
-	if err != nil {
-		// If the user doesn't have an inventory, create a new one with the given item and amount
-		update := bson.M{"$set": bson.M{"inventory": []bson.M{{"name": item, "quantity": amount}}}}
-		opts := options.Update().SetUpsert(true)
-		_, err = pingedUserCollection.UpdateOne(ctx, filter, update, opts)
+// Not a command
+// Puts items that carry wear back into a user's inventory, e.g. from a listing or another user
+// A stack only keeps the wear of the one being worn down, so if the wear adds up to a whole item that one is used up, just like wearing it down
+func addItemLot(ctx context.Context, userCollection *mongo.Collection, guildID int, userID int, item string, amount int, wear int) (error) {
+	if amount < 1 {
+		return fmt.Errorf("can't add %d of %s", amount, item)
+	}
+	durability := getItemStats(item).Durability
+	if durability <= 0 {
+		wear = 0
+	}
+	// Try twice in case the item was added to the inventory between the two updates
+	for attempt := 0; attempt < 2; attempt++ {
+		result, err := userCollection.UpdateOne(
+			ctx,
+			bson.D{
+				{Key: "user_id", Value: userID},
+				{Key: "guild_id", Value: guildID},
+				{Key: "inventory.name", Value: item},
+			},
+			bson.D{
+				{Key: "$inc", Value: bson.D{
+					{Key: "inventory.$.quantity", Value: amount},
+					{Key: "inventory.$.wear", Value: wear},
+				}},
+			},
+		)
 		if err != nil {
-			fmt.Printf("Error occurred while updating pinged user's inventory! %s\n", err)
-			return "Error occurred while updating pinged user's inventory! " + strings.Title(err.Error())
+			return err
 		}
-	} else {
-		// If the user already has an inventory, update the quantity of the given item
-		inventory := pingedUserStruct.Inventory
-		itemIndex := -1
-		for i, inventoryItem := range inventory {
-			if inventoryItem.Name == item {
-				itemIndex = i
-				break
-			}
+		if result.MatchedCount == 1 {
+			break
 		}
-		if itemIndex == -1 {
-			// If the user doesn't have the item, add it to their inventory
-			inventory = append(inventory, Item{Name: item, Quantity: amount})
-		} else {
-			// If the user already has the item, update the quantity
-			inventory[itemIndex].Quantity += amount
+		result, err = userCollection.UpdateOne(
+			ctx,
+			bson.D{
+				{Key: "user_id", Value: userID},
+				{Key: "guild_id", Value: guildID},
+				{Key: "inventory.name", Value: bson.D{{Key: "$ne", Value: item}}},
+			},
+			bson.D{
+				{Key: "$push", Value: bson.D{
+					{Key: "inventory", Value: Item{Name: item, Quantity: amount, Wear: wear}},
+				}},
+			},
+		)
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 1 {
+			break
+		}
+		if attempt == 1 {
+			return fmt.Errorf("couldn't add %s to the inventory of user %d", item, userID)
 		}
-		update := bson.M{"$set": bson.M{"inventory": inventory}}
-		_, err = pingedUserCollection.UpdateOne(ctx, filter, update)
+	}
+	if wear == 0 {
+		return nil
+	}
+
+	// Wear that adds up to a whole item can only come from an item that was already used up
+	for broke := true; broke; {
+		var err error
+		broke, err = breakWornItem(ctx, userCollection, guildID, userID, item, durability)
 		if err != nil {
-			fmt.Printf("Error occurred while updating pinged user's inventory! %s\n", err)
-			return "Error occurred while updating pinged user's inventory! " + strings.Title(err.Error())
+			return err
 		}
 	}
-	return fmt.Sprintf("You gave %dX %s to <@%d>!", amount, item, pingedUser)
-}
\ No newline at end of file
+	clearWear(ctx, userCollection, guildID, userID, item)
+	return nil
+}
+
+// Not a command
+// Takes coins from a user, returning false if they don't have enough
+func takeCoins(ctx context.Context, userCollection *mongo.Collection, guildID int, userID int, amount int64) (bool, error) {
+	result, err := userCollection.UpdateOne(
+		ctx,
+		bson.D{
+			{Key: "user_id", Value: userID},
+			{Key: "guild_id", Value: guildID},
+			{Key: "balance", Value: bson.D{{Key: "$gte", Value: amount}}},
+		},
+		bson.D{
+			{Key: "$inc", Value: bson.D{
+				{Key: "balance", Value: -amount},
+			}},
+		},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+// Not a command
+// Gives coins to a user
+func addCoins(ctx context.Context, userCollection *mongo.Collection, guildID int, userID int, amount int64) (error) {
+	_, err := userCollection.UpdateOne(
+		ctx,
+		bson.D{
+			{Key: "user_id", Value: userID},
+			{Key: "guild_id", Value: guildID},
+		},
+		bson.D{
+			{Key: "$inc", Value: bson.D{
+				{Key: "balance", Value: amount},
+			}},
+		},
+	)
+	return err
+}
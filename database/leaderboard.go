@@ -0,0 +1,370 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"github.com/bwmarrin/discordgo"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Something users can be ranked by
+// Metrics with a Field are stored on the user and sorted with an index
+// Net worth depends on current prices, so it is worked out in the query instead and can't use an index
+// A Note is shown under the leaderboard to explain what the score leaves out
+type leaderboardMetric struct {
+	Title string
+	Field string
+	Unit  string
+	Note  string
+}
+
+var leaderboardMetrics = map[string]leaderboardMetric{
+	"coins":    {"Leaderboard", "balance", "coins", ""},
+	"networth": {"Net Worth Leaderboard", "", "coins", netWorthNote},
+	"trivia":   {"Trivia Wins Leaderboard", "trivia_correct", "correct answers", ""},
+	"streak":   {"Trivia Streak Leaderboard", "trivia_best_streak", "in a row", ""},
+	"gambling": {"Gambling Profit Leaderboard", "gamble_profit", "coins", ""},
+	"xp":       {"Level Leaderboard", "xp", "XP", ""},
+}
+
+// Auctions and trades live in the global database, which a per-server query can't join, so nothing in escrow is counted
+const netWorthNote = "Items and coins held in listings, buy orders, auctions and trades aren't counted"
+
+const leaderboardPageSize = 10
+
+// Servers whose Users collection already has the leaderboard indexes
+var leaderboardIndexed sync.Map
+
+// A user's place on a leaderboard
+type LeaderboardEntry struct {
+	UserID   int     `bson:"user_id"`
+	UserName string  `bson:"user_name"`
+	Score    float64 `bson:"score"`
+}
+
+// Not a command
+// Finds a metric by name, with coins as the default
+func FindLeaderboardMetric(name string) (string, bool) {
+	name = strings.ToLower(name)
+	switch name {
+	case "", "balance", "bal":
+		return "coins", true
+	case "net", "worth":
+		return "networth", true
+	case "gamble", "gambling", "profit":
+		return "gambling", true
+	case "level", "levels":
+		return "xp", true
+	}
+	_, ok := leaderboardMetrics[name]
+	return name, ok
+}
+
+// Not a command
+// Makes sure every stored metric can be sorted with an index
+// Only runs once per server while Mary is running, since creating an index that exists does nothing
+func ensureLeaderboardIndexes(ctx context.Context, userCollection *mongo.Collection, guildID int) {
+	if _, ok := leaderboardIndexed.Load(guildID); ok {
+		return
+	}
+	models := []mongo.IndexModel{}
+	for _, metric := range leaderboardMetrics {
+		if metric.Field == "" {
+			continue
+		}
+		models = append(models, mongo.IndexModel{
+			Keys: bson.D{
+				{Key: "guild_id", Value: 1},
+				{Key: metric.Field, Value: -1},
+				{Key: "user_id", Value: 1},
+			},
+		})
+	}
+	_, err := userCollection.Indexes().CreateMany(ctx, models)
+	if err != nil {
+		fmt.Printf("Error occurred while creating leaderboard indexes! %s\n", err)
+		return
+	}
+	leaderboardIndexed.Store(guildID, true)
+}
+
+// Not a command
+// Builds an expression that looks up a key in a list of names and returns the matching value, or 0
+func priceLookup(key string, names bson.A, values bson.A) (bson.M) {
+	return bson.M{"$let": bson.M{
+		"vars": bson.M{"i": bson.M{"$indexOfArray": bson.A{names, key}}},
+		"in": bson.M{"$cond": bson.A{
+			bson.M{"$gte": bson.A{"$$i", 0}},
+			bson.M{"$arrayElemAt": bson.A{values, "$$i"}},
+			0,
+		}},
+	}}
+}
+
+// Not a command
+// Builds an expression for a user's net worth: coins, plus items at their sell price, crafted ones included, plus stocks at the market price
+// There is no bank yet, so coins are only the balance
+// Anything in escrow is left out, see netWorthNote
+func netWorthExpression(stocks map[string]Stock) (bson.M) {
+	pattern := regexp.MustCompile("[^a-zA-Z0-9]+")
+	itemNames, itemValues := bson.A{}, bson.A{}
+	for _, item := range items {
+		name := strings.ToLower(pattern.ReplaceAllString(item.Name, ""))
+		itemNames = append(itemNames, name)
+		itemValues = append(itemValues, sellPrice(name, item.Price)) // Same as Sell
+	}
+	for _, recipe := range getRecipes() {
+		itemNames = append(itemNames, recipe.Output)
+		itemValues = append(itemValues, sellPrice(recipe.Output, itemValue(recipe.Output)))
+	}
+	tickers, prices := bson.A{}, bson.A{}
+	for ticker, stock := range stocks {
+		tickers = append(tickers, ticker)
+		prices = append(prices, stock.Price)
+	}
+
+	return bson.M{"$add": bson.A{
+		0.0, // Makes the score a double for every user
+		bson.M{"$ifNull": bson.A{"$balance", 0}},
+		bson.M{"$reduce": bson.M{
+			"input":        bson.M{"$ifNull": bson.A{"$inventory", bson.A{}}},
+			"initialValue": 0,
+			"in": bson.M{"$add": bson.A{"$$value", bson.M{"$multiply": bson.A{"$$this.quantity", priceLookup("$$this.name", itemNames, itemValues)}}}},
+		}},
+		bson.M{"$reduce": bson.M{
+			"input":        bson.M{"$ifNull": bson.A{"$portfolio", bson.A{}}},
+			"initialValue": 0,
+			"in": bson.M{"$add": bson.A{"$$value", bson.M{"$multiply": bson.A{"$$this.quantity", priceLookup("$$this.ticker", tickers, prices)}}}},
+		}},
+	}}
+}
+
+// Not a command
+// Builds the expression for a metric's score
+func scoreExpression(ctx context.Context, client *mongo.Client, metric leaderboardMetric) (interface{}, error) {
+	if metric.Field != "" {
+		return bson.M{"$add": bson.A{0.0, bson.M{"$ifNull": bson.A{"$" + metric.Field, 0}}}}, nil
+	}
+	stocks, err := getStocks(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	return netWorthExpression(stocks), nil
+}
+
+// mary top [optional: metric] [optional: page] -> shows a page of the users with the highest score
+func Leaderboard(mongoURI string, guildID int, guildIconURL string, metricName string, page int) (string, *discordgo.MessageEmbed) {
+	metricName, ok := FindLeaderboardMetric(metricName)
+	if !ok {
+		return "Please choose a leaderboard of coins, networth, trivia, streak, gambling or xp!", nil
+	}
+	metric := leaderboardMetrics[metricName]
+	if page < 1 {
+		return "Please enter a valid page number!", nil
+	}
+
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error()), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error()), nil
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx)
+
+	userCollection := client.Database(strconv.Itoa(guildID)).Collection("Users")
+	ensureLeaderboardIndexes(ctx, userCollection, guildID)
+
+	total, err := userCollection.CountDocuments(ctx, bson.M{"guild_id": guildID})
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return "Error occurred while selecting from database! " + strings.Title(err.Error()), nil
+	}
+	pages := int((total + leaderboardPageSize - 1) / leaderboardPageSize)
+	if total == 0 {
+		return "Nobody in this server is playing yet!", nil
+	}
+	if page > pages {
+		return fmt.Sprintf("There are only %d pages!", pages), nil
+	}
+
+	score, err := scoreExpression(ctx, client, metric)
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return "Error occurred while selecting from database! " + strings.Title(err.Error()), nil
+	}
+
+	// Stored metrics are sorted before the score is added so that the index is used
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"guild_id": guildID}}},
+	}
+	if metric.Field != "" {
+		pipeline = append(pipeline,
+			bson.D{{Key: "$sort", Value: bson.D{{Key: metric.Field, Value: -1}, {Key: "user_id", Value: 1}}}},
+			bson.D{{Key: "$skip", Value: (page - 1) * leaderboardPageSize}},
+			bson.D{{Key: "$limit", Value: leaderboardPageSize}},
+			bson.D{{Key: "$project", Value: bson.M{"user_id": 1, "user_name": 1, "score": score}}},
+		)
+	} else {
+		pipeline = append(pipeline,
+			bson.D{{Key: "$project", Value: bson.M{"user_id": 1, "user_name": 1, "score": score}}},
+			bson.D{{Key: "$sort", Value: bson.D{{Key: "score", Value: -1}, {Key: "user_id", Value: 1}}}},
+			bson.D{{Key: "$skip", Value: (page - 1) * leaderboardPageSize}},
+			bson.D{{Key: "$limit", Value: leaderboardPageSize}},
+		)
+	}
+	cursor, err := userCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return "Error occurred while selecting from database! " + strings.Title(err.Error()), nil
+	}
+	var entries []LeaderboardEntry
+	err = cursor.All(ctx, &entries)
+	if err != nil {
+		fmt.Printf("Error occurred while decoding result! %s\n", err)
+		return "Error occurred while decoding result! " + strings.Title(err.Error()), nil
+	}
+
+	lines := []string{}
+	for i, entry := range entries {
+		lines = append(lines, fmt.Sprintf("**%d.** %s: %s %s", (page-1)*leaderboardPageSize+i+1, entry.UserName, formatScore(entry.Score), metric.Unit))
+	}
+	embed := &discordgo.MessageEmbed{
+		Title:       metric.Title,
+		Description: strings.Join(lines, "\n"),
+		Color:       0xffc0cb,
+		Thumbnail: &discordgo.MessageEmbedThumbnail{
+			URL: guildIconURL,
+		},
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: leaderboardFooter(fmt.Sprintf("Page %d/%d", page, pages), metric),
+		},
+	}
+	return "", embed
+}
+
+// Not a command
+// Adds the metric's note, if it has one, to a leaderboard footer
+func leaderboardFooter(text string, metric leaderboardMetric) (string) {
+	if metric.Note == "" {
+		return text
+	}
+	return text + " - " + metric.Note
+}
+
+// Not a command
+// Scores are whole numbers apart from net worth, which is rounded for display
+func formatScore(score float64) (string) {
+	return strconv.FormatInt(int64(score), 10)
+}
+
+// mary rank [optional: @user] [optional: metric] -> shows where a user is on a leaderboard
+func Rank(mongoURI string, guildID int, guildName string, userID int, userName string, metricName string) (string) {
+	metricName, ok := FindLeaderboardMetric(metricName)
+	if !ok {
+		return "Please choose a leaderboard of coins, networth, trivia, streak, gambling or xp!"
+	}
+	metric := leaderboardMetrics[metricName]
+
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error())
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx)
+
+	// Check if user exists in database
+	res := IsPlaying(ctx, client, guildID, guildName, userID, userName)
+	if res != "" {
+		return res
+	}
+
+	userCollection := client.Database(strconv.Itoa(guildID)).Collection("Users")
+	ensureLeaderboardIndexes(ctx, userCollection, guildID)
+
+	score, err := scoreExpression(ctx, client, metric)
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return "Error occurred while selecting from database! " + strings.Title(err.Error())
+	}
+
+	// Get the user's own score
+	cursor, err := userCollection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"guild_id": guildID, "user_id": userID}}},
+		{{Key: "$project", Value: bson.M{"user_id": 1, "user_name": 1, "score": score}}},
+	})
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return "Error occurred while selecting from database! " + strings.Title(err.Error())
+	}
+	var entries []LeaderboardEntry
+	err = cursor.All(ctx, &entries)
+	if err != nil || len(entries) == 0 {
+		fmt.Printf("Error occurred while decoding result! %v\n", err)
+		return "Error occurred while finding user in database!"
+	}
+	userScore := entries[0].Score
+
+	// Users with a higher score are ahead, and ties share a rank
+	var ahead int64
+	if metric.Field != "" {
+		filter := bson.M{"guild_id": guildID, metric.Field: bson.M{"$gt": userScore}}
+		ahead, err = userCollection.CountDocuments(ctx, filter)
+	} else {
+		cursor, err = userCollection.Aggregate(ctx, mongo.Pipeline{
+			{{Key: "$match", Value: bson.M{"guild_id": guildID}}},
+			{{Key: "$project", Value: bson.M{"score": score}}},
+			{{Key: "$match", Value: bson.M{"score": bson.M{"$gt": userScore}}}},
+			{{Key: "$count", Value: "ahead"}},
+		})
+		if err == nil {
+			var counts []struct {
+				Ahead int64 `bson:"ahead"`
+			}
+			err = cursor.All(ctx, &counts)
+			if len(counts) > 0 {
+				ahead = counts[0].Ahead
+			}
+		}
+	}
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return "Error occurred while selecting from database! " + strings.Title(err.Error())
+	}
+	total, err := userCollection.CountDocuments(ctx, bson.M{"guild_id": guildID})
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return "Error occurred while selecting from database! " + strings.Title(err.Error())
+	}
+
+	rank := ahead + 1
+	page := (rank-1)/leaderboardPageSize + 1
+	return fmt.Sprintf("<@%d> is ranked **#%d** of %d on the %s with %s %s. (Page %d)", userID, rank, total, strings.ToLower(metric.Title), formatScore(userScore), metric.Unit, page)
+}
@@ -0,0 +1,584 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"github.com/bwmarrin/discordgo"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Items a user has put up for sale on their server's market
+// The items are taken out of the seller's inventory while they're listed
+type Listing struct {
+	ListingID  int       `bson:"listing_id"`
+	GuildID    int       `bson:"guild_id"`
+	SellerID   int       `bson:"seller_id"`
+	SellerName string    `bson:"seller_name"`
+	ChannelID  string    `bson:"channel_id"` // Where the item was listed, for when the seller's DMs are closed
+	Item       string    `bson:"item"`
+	Quantity   int       `bson:"quantity"` // How many are still for sale
+	Wear       int       `bson:"wear,omitempty"` // Uses taken off one of the items, which goes with the last one sold
+	Sold       int       `bson:"sold"`
+	Price      int64     `bson:"price"` // For each item
+	Status     string    `bson:"status"` // open, sold or cancelled
+	CreatedAt  time.Time `bson:"created_at"`
+	ClosedAt   time.Time `bson:"closed_at"`
+}
+
+// Percentages used until a server's admins change them
+const defaultMarketFee = 2.0
+const defaultMarketTax = 5.0
+const maxMarketRate = 50.0
+
+const maxListings = 20
+const maxListingPrice = 10000000
+const marketPageSize = 5
+
+// Not a command
+// Gets the listing fee and sales tax for a server as percentages
+func marketRates(settings GuildSettings) (float64, float64) {
+	fee, tax := defaultMarketFee, defaultMarketTax
+	if settings.MarketFee != nil {
+		fee = *settings.MarketFee
+	}
+	if settings.MarketTax != nil {
+		tax = *settings.MarketTax
+	}
+	return fee, tax
+}
+
+// Not a command
+// Describes a listing in one line
+func describeListing(listing Listing) (string) {
+	name := strings.Title(listing.Item)
+	return fmt.Sprintf("**#%d** %s %dX %s%s at %d coins each", listing.ListingID, itemEmoji(name), listing.Quantity, name, describeWear(listing.Item, listing.Wear), listing.Price)
+}
+
+// mary market sell [item] [amount] [price] -> lists items on the market at your own price for each one
+// Buy orders at or above the price are filled first, and only the rest is listed
+func ListItem(mongoURI string, guildID int, guildName string, userID int, userName string, channelID string, item string, quantity int, price int64) (string) {
+	item = knownItemName(item)
+	if item == "" {
+		return "That item doesn't exist!"
+	}
+	if quantity < 1 {
+		return "Please specify a positive amount!"
+	}
+	if price < 1 || price > maxListingPrice {
+		return fmt.Sprintf("Please specify a price between 1 and %d coins!", maxListingPrice)
+	}
+
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error())
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	// Check if user exists in database
+	res := IsPlaying(ctx, client, guildID, guildName, userID, userName)
+	if res != "" {
+		return res
+	}
+
+	listingCollection := client.Database(strconv.Itoa(guildID)).Collection("Listings")
+	open, err := listingCollection.CountDocuments(ctx, bson.M{"guild_id": guildID, "seller_id": userID, "status": "open"})
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return "Error occurred while selecting from database! " + strings.Title(err.Error())
+	}
+	if open >= maxListings {
+		return fmt.Sprintf("You can only have %d listings at a time! Cancel one with `mary market cancel [listing number]`.", maxListings)
+	}
+
+	// Take the items first, then the fee, giving the items back if the fee can't be paid
+	userCollection := client.Database(strconv.Itoa(guildID)).Collection("Users")
+	taken, wear, err := takeItemLot(ctx, userCollection, guildID, userID, item, quantity)
+	if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+		return "Error occurred while updating database! " + strings.Title(err.Error())
+	}
+	if !taken {
+		return "You don't have enough of that item to list!"
+	}
+
+	// Sell to any buy orders paying the asking price or more, and only list what's left
+	sold, earned := fillItemOrders(ctx, client, guildID, guildName, userID, userName, channelID, item, quantity, wear, price)
+	quantity -= sold
+	soldMessage := ""
+	if sold > 0 {
+		soldMessage = fmt.Sprintf("Sold %dX %s to buy orders for %d coins after sales tax! ", sold, item, earned)
+	}
+	if quantity == 0 {
+		return strings.TrimSpace(soldMessage)
+	}
+
+	fee, _ := marketRates(getSettings(ctx, client, guildID))
+	listingFee := int64(math.Ceil(float64(price) * float64(quantity) * fee / 100))
+	if listingFee > 0 {
+		paid, err := takeCoins(ctx, userCollection, guildID, userID, listingFee)
+		if err != nil || !paid {
+			if err != nil {
+				fmt.Printf("Error occurred while updating database! %s\n", err)
+			}
+			err = addItemLot(ctx, userCollection, guildID, userID, item, quantity, wear)
+			if err != nil {
+				fmt.Printf("Error occurred while returning %dX %s to user %d! %s\n", quantity, item, userID, err)
+			}
+			return soldMessage + fmt.Sprintf("You don't have enough coins for the %d coin listing fee!", listingFee)
+		}
+		addToTreasury(ctx, client, guildID, listingFee, "market_fee")
+	}
+
+	listingID, err := nextSequence(ctx, client, "listings")
+	if err == nil {
+		_, err = listingCollection.InsertOne(ctx, Listing{
+			ListingID:  listingID,
+			GuildID:    guildID,
+			SellerID:   userID,
+			SellerName: userName,
+			ChannelID:  channelID,
+			Item:       item,
+			Quantity:   quantity,
+			Wear:       wear,
+			Price:      price,
+			Status:     "open",
+			CreatedAt:  time.Now(),
+		})
+	}
+	if err != nil {
+		// Give the items back, but the fee has already gone to the treasury
+		fmt.Printf("Error occurred while inserting into database! %s\n", err)
+		if err := addItemLot(ctx, userCollection, guildID, userID, item, quantity, wear); err != nil {
+			fmt.Printf("Error occurred while returning %dX %s to user %d! %s\n", quantity, item, userID, err)
+		}
+		return soldMessage + "Error occurred while inserting into database! " + strings.Title(err.Error())
+	}
+
+	content := soldMessage + fmt.Sprintf("Listed %dX %s for %d coins each as listing #%d!", quantity, item, price, listingID)
+	if listingFee > 0 {
+		content += fmt.Sprintf(" You paid a %d coin listing fee.", listingFee)
+	}
+	return content
+}
+
+// mary market [optional: item] [optional: page] -> shows the cheapest listings on the market, optionally only for one item
+func BrowseMarket(mongoURI string, guildID int, guildName string, item string, page int) (string, *discordgo.MessageEmbed) {
+	if item != "" {
+		item = knownItemName(item)
+		if item == "" {
+			return "That item doesn't exist!", nil
+		}
+	}
+	if page < 1 {
+		return "Please enter a valid page number!", nil
+	}
+
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error()), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error()), nil
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	filter := bson.M{"guild_id": guildID, "status": "open"}
+	if item != "" {
+		filter["item"] = item
+	}
+	listingCollection := client.Database(strconv.Itoa(guildID)).Collection("Listings")
+	total, err := listingCollection.CountDocuments(ctx, filter)
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return "Error occurred while selecting from database! " + strings.Title(err.Error()), nil
+	}
+	if total == 0 {
+		if item != "" {
+			return "Nobody is selling " + item + " right now!", nil
+		}
+		return "Nothing is for sale right now! List something with `mary market sell [item] [amount] [price]`.", nil
+	}
+	pages := int((total + marketPageSize - 1) / marketPageSize)
+	if page > pages {
+		return fmt.Sprintf("There are only %d pages!", pages), nil
+	}
+
+	// Cheapest first, then oldest first
+	cursor, err := listingCollection.Find(
+		ctx,
+		filter,
+		options.Find().
+			SetSort(bson.D{{Key: "price", Value: 1}, {Key: "listing_id", Value: 1}}).
+			SetSkip(int64((page - 1) * marketPageSize)).
+			SetLimit(marketPageSize),
+	)
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return "Error occurred while selecting from database! " + strings.Title(err.Error()), nil
+	}
+	var listings []Listing
+	err = cursor.All(ctx, &listings)
+	if err != nil {
+		fmt.Printf("Error occurred while decoding result! %s\n", err)
+		return "Error occurred while decoding result! " + strings.Title(err.Error()), nil
+	}
+
+	lines := []string{}
+	for _, listing := range listings {
+		lines = append(lines, describeListing(listing) + " from " + listing.SellerName)
+	}
+	fee, tax := marketRates(getSettings(ctx, client, guildID))
+	title := guildName + "'s Market"
+	if item != "" {
+		title += ": " + strings.Title(item)
+	}
+	embed := &discordgo.MessageEmbed{
+		Title: title,
+		Description: strings.Join(lines, "\n"),
+		Color: 0xffc0cb,
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: fmt.Sprintf("Page %d/%d • mary market buy [listing number] [amount] • mary market orders for buy orders • Listing fee %g%%, sales tax %g%%", page, pages, fee, tax),
+		},
+	}
+	return "", embed
+}
+
+// mary market mine -> shows your open listings and buy orders
+func MyListings(mongoURI string, guildID int, userID int, userName string) (string, *discordgo.MessageEmbed) {
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error()), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error()), nil
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	cursor, err := client.Database(strconv.Itoa(guildID)).Collection("Listings").Find(
+		ctx,
+		bson.M{"guild_id": guildID, "seller_id": userID, "status": "open"},
+		options.Find().SetSort(bson.D{{Key: "listing_id", Value: 1}}),
+	)
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return "Error occurred while selecting from database! " + strings.Title(err.Error()), nil
+	}
+	var listings []Listing
+	err = cursor.All(ctx, &listings)
+	if err != nil {
+		fmt.Printf("Error occurred while decoding result! %s\n", err)
+		return "Error occurred while decoding result! " + strings.Title(err.Error()), nil
+	}
+
+	cursor, err = client.Database(strconv.Itoa(guildID)).Collection("ItemOrders").Find(
+		ctx,
+		bson.M{"guild_id": guildID, "buyer_id": userID, "status": "open"},
+		options.Find().SetSort(bson.D{{Key: "order_id", Value: 1}}),
+	)
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return "Error occurred while selecting from database! " + strings.Title(err.Error()), nil
+	}
+	var orders []ItemOrder
+	err = cursor.All(ctx, &orders)
+	if err != nil {
+		fmt.Printf("Error occurred while decoding result! %s\n", err)
+		return "Error occurred while decoding result! " + strings.Title(err.Error()), nil
+	}
+	if len(listings) == 0 && len(orders) == 0 {
+		return "You don't have anything listed! List something with `mary market sell [item] [amount] [price]`, or offer to buy something with `mary market order [item] [amount] [price]`.", nil
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title: userName + "'s Listings",
+		Color: 0xffc0cb,
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "mary market cancel [number] to take a listing or buy order down",
+		},
+	}
+	if len(listings) > 0 {
+		lines := []string{}
+		for _, listing := range listings {
+			line := describeListing(listing)
+			if listing.Sold > 0 {
+				line += fmt.Sprintf(" (%d sold)", listing.Sold)
+			}
+			lines = append(lines, line)
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: "Selling", Value: strings.Join(lines, "\n")})
+	}
+	if len(orders) > 0 {
+		lines := []string{}
+		for _, order := range orders {
+			line := describeItemOrder(order)
+			if order.Filled > 0 {
+				line += fmt.Sprintf(" (%d bought)", order.Filled)
+			}
+			lines = append(lines, line)
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: "Buying", Value: strings.Join(lines, "\n")})
+	}
+	return "", embed
+}
+
+// mary market buy [listing number] [optional: amount] -> buys items from a listing
+func BuyListing(mongoURI string, guildID int, guildName string, userID int, userName string, listingID int, quantity int) (string) {
+	if quantity < 1 {
+		return "Please specify a positive amount!"
+	}
+
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error())
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	// Check if user exists in database
+	res := IsPlaying(ctx, client, guildID, guildName, userID, userName)
+	if res != "" {
+		return res
+	}
+
+	listingCollection := client.Database(strconv.Itoa(guildID)).Collection("Listings")
+	userCollection := client.Database(strconv.Itoa(guildID)).Collection("Users")
+	var listing Listing
+	err = listingCollection.FindOne(ctx, bson.M{"guild_id": guildID, "listing_id": listingID, "status": "open"}).Decode(&listing)
+	if err == mongo.ErrNoDocuments {
+		return fmt.Sprintf("There's no listing #%d for sale!", listingID)
+	} else if err != nil {
+		fmt.Printf("Error occurred while finding listing in database! %s\n", err)
+		return "Error occurred while finding listing in database! " + strings.Title(err.Error())
+	}
+	if listing.SellerID == userID {
+		return "You can't buy your own listing! Use `mary market cancel " + strconv.Itoa(listingID) + "` to take it down."
+	}
+	if quantity > listing.Quantity {
+		return fmt.Sprintf("Listing #%d only has %d left!", listingID, listing.Quantity)
+	}
+
+	// Take the buyer's coins first, then claim the items, refunding the coins if someone else got them first
+	cost := listing.Price * int64(quantity)
+	paid, err := takeCoins(ctx, userCollection, guildID, userID, cost)
+	if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+		return "Error occurred while updating database! " + strings.Title(err.Error())
+	}
+	if !paid {
+		return fmt.Sprintf("You don't have enough coins! %dX %s costs %d coins.", quantity, listing.Item, cost)
+	}
+	var updated Listing
+	err = listingCollection.FindOneAndUpdate(
+		ctx,
+		bson.D{
+			{Key: "listing_id", Value: listingID},
+			{Key: "status", Value: "open"},
+			{Key: "price", Value: listing.Price},
+			{Key: "quantity", Value: bson.D{{Key: "$gte", Value: quantity}}},
+		},
+		bson.D{
+			{Key: "$inc", Value: bson.D{
+				{Key: "quantity", Value: -quantity},
+				{Key: "sold", Value: quantity},
+			}},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			fmt.Printf("Error occurred while updating database! %s\n", err)
+		}
+		if err := addCoins(ctx, userCollection, guildID, userID, cost); err != nil {
+			fmt.Printf("Error occurred while refunding %d coins to user %d! %s\n", cost, userID, err)
+		}
+		return "Someone else bought those first! Your coins have been refunded."
+	}
+
+	// Close the listing if that was the last of it
+	_, err = listingCollection.UpdateOne(
+		ctx,
+		bson.M{"listing_id": listingID, "status": "open", "quantity": 0},
+		bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "status", Value: "sold"},
+				{Key: "closed_at", Value: time.Now()},
+			}},
+		},
+	)
+	if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+	}
+
+	// Whoever buys the last of the listing gets the worn one
+	wear := 0
+	if updated.Quantity == 0 {
+		wear = updated.Wear
+	}
+	err = addItemLot(ctx, userCollection, guildID, userID, listing.Item, quantity, wear)
+	if err != nil {
+		fmt.Printf("Error occurred while giving %dX %s to user %d! %s\n", quantity, listing.Item, userID, err)
+	}
+
+	// The seller gets the price minus the sales tax, which goes to the treasury
+	_, tax := marketRates(getSettings(ctx, client, guildID))
+	taxed := int64(math.Floor(float64(cost) * tax / 100))
+	err = addCoins(ctx, userCollection, guildID, listing.SellerID, cost - taxed)
+	if err != nil {
+		fmt.Printf("Error occurred while paying %d coins to user %d! %s\n", cost - taxed, listing.SellerID, err)
+	} else {
+		addSeasonEarnings(ctx, userCollection, guildID, listing.SellerID, cost - taxed)
+	}
+	addToTreasury(ctx, client, guildID, taxed, "market_tax")
+
+	notifyUser(listing.SellerID, listing.ChannelID, fmt.Sprintf("💸 %s bought %dX %s from your listing #%d in %s! You got %d coins after %d coins of sales tax.", userName, quantity, listing.Item, listingID, guildName, cost - taxed, taxed))
+	recordEvent(ctx, client, GameEvent{GuildID: guildID, UserID: userID, Type: "market_buy", Item: listing.Item, TargetID: listing.SellerID, Amount: int64(quantity)})
+	recordEvent(ctx, client, GameEvent{GuildID: guildID, UserID: listing.SellerID, ChannelID: listing.ChannelID, Type: "market_sell", Item: listing.Item, TargetID: userID, Amount: int64(quantity)})
+	return fmt.Sprintf("You bought %dX %s%s from %s for %d coins!", quantity, listing.Item, describeWear(listing.Item, wear), listing.SellerName, cost)
+}
+
+// mary market cancel [number] -> takes one of your listings down and gives back the items that haven't sold
+// The number can also be one of your buy orders, which gives back the coins held for it
+func CancelListing(mongoURI string, guildID int, userID int, listingID int) (string) {
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error())
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	// Closing the listing stops any more sales, so whatever is left in it now goes back to the seller
+	var listing Listing
+	err = client.Database(strconv.Itoa(guildID)).Collection("Listings").FindOneAndUpdate(
+		ctx,
+		bson.D{
+			{Key: "listing_id", Value: listingID},
+			{Key: "guild_id", Value: guildID},
+			{Key: "seller_id", Value: userID},
+			{Key: "status", Value: "open"},
+		},
+		bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "status", Value: "cancelled"},
+				{Key: "closed_at", Value: time.Now()},
+			}},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&listing)
+	if err == mongo.ErrNoDocuments {
+		if res, ok := cancelItemOrder(ctx, client, guildID, userID, listingID); ok {
+			return res
+		}
+		return fmt.Sprintf("You don't have an open listing or buy order #%d!", listingID)
+	} else if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+		return "Error occurred while updating database! " + strings.Title(err.Error())
+	}
+	if listing.Quantity > 0 {
+		err = addItemLot(ctx, client.Database(strconv.Itoa(guildID)).Collection("Users"), guildID, userID, listing.Item, listing.Quantity, listing.Wear)
+		if err != nil {
+			fmt.Printf("Error occurred while returning %dX %s to user %d! %s\n", listing.Quantity, listing.Item, userID, err)
+			return "Error occurred while returning your items! " + strings.Title(err.Error())
+		}
+	}
+	return fmt.Sprintf("Took down listing #%d and gave you back %dX %s. The listing fee isn't refunded.", listingID, listing.Quantity, listing.Item)
+}
+
+// mary market fee/tax [percent] (admin only) -> sets the listing fee or sales tax for the server
+func SetMarketRate(mongoURI string, guildID int, kind string, percent float64) (string) {
+	if percent < 0 || percent > maxMarketRate {
+		return fmt.Sprintf("Please enter a percentage between 0 and %g!", maxMarketRate)
+	}
+	field := "market_fee"
+	name := "listing fee"
+	if kind == "tax" {
+		field = "market_tax"
+		name = "sales tax"
+	}
+
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error())
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	err = updateSettings(ctx, client, guildID, bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: field, Value: percent},
+		}},
+	})
+	if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+		return "Error occurred while updating database! " + strings.Title(err.Error())
+	}
+	return fmt.Sprintf("The market %s is now %g%%.", name, percent)
+}
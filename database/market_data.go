@@ -0,0 +1,275 @@
+package database
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Where stock prices come from
+// Pick one with the STOCK_PROVIDER env var: "synthetic" (default), "replay" or "http"
+type MarketDataProvider interface {
+	// Name is saved with every quote so you can tell where a price came from
+	Name() string
+	// Quotes gets the latest price of each stock, given the last price that was saved for it (if any)
+	// Stocks the provider has no price for are left out, and keep their old (eventually stale) price
+	Quotes(stocks []StockConfig, last map[string]float64) ([]Quote, error)
+}
+
+type Quote struct {
+	Ticker string
+	Price  float64
+	Time   time.Time // When the price is from, which for real markets can be long before it was fetched
+}
+
+var marketDataProvider MarketDataProvider
+var marketDataProviderOnce sync.Once
+
+// Not a command
+// Gets the provider chosen in the env vars, creating it the first time
+// Falls back to the synthetic market if the chosen provider can't be set up
+func getMarketDataProvider() (MarketDataProvider) {
+	marketDataProviderOnce.Do(func() {
+		var err error
+		switch strings.ToLower(os.Getenv("STOCK_PROVIDER")) {
+		case "replay":
+			marketDataProvider, err = newReplayProvider(os.Getenv("STOCK_REPLAY_FILE"))
+		case "http":
+			marketDataProvider, err = newHTTPProvider(os.Getenv("STOCK_QUOTE_URL"))
+		case "", "synthetic":
+			marketDataProvider = syntheticProvider{}
+		default:
+			err = fmt.Errorf("unknown provider %q", os.Getenv("STOCK_PROVIDER"))
+		}
+		if err != nil {
+			fmt.Printf("Error setting up stock market data provider, using synthetic prices! %s\n", err)
+			marketDataProvider = syntheticProvider{}
+		}
+		fmt.Printf("Stock market using %s prices\n", marketDataProvider.Name())
+	})
+	return marketDataProvider
+}
+
+// How long a price can go without being fetched before trading on it is refused, from the STOCK_MAX_QUOTE_AGE env var (e.g. "15m")
+// Defaults to three ticks, so a single failed refresh doesn't stop trading
+func MaxQuoteAge() (time.Duration) {
+	age, err := time.ParseDuration(os.Getenv("STOCK_MAX_QUOTE_AGE"))
+	if err != nil || age <= 0 {
+		return 3 * MarketTickInterval()
+	}
+	return age
+}
+
+// Not a command
+// Checks if a stock's price is too old to trade on
+// This goes by when the price was last fetched, not when it's from, so real stocks can still be traded on the closing price while their market is shut
+func isStale(stock Stock) (bool) {
+	return time.Since(stock.UpdatedAt) > MaxQuoteAge()
+}
+
+// Generates prices with geometric Brownian motion, using each stock's drift and volatility
+type syntheticProvider struct{}
+
+// Each tick of the market is one simulated trading day
+const marketTickYears = 1.0 / 252
+
+// Every sector has this chance per tick of a shock that moves all of its stocks together
+const sectorShockChance = 0.05
+const sectorShockSize = 0.06
+
+func (p syntheticProvider) Name() (string) {
+	return "synthetic"
+}
+
+func (p syntheticProvider) Quotes(stocks []StockConfig, last map[string]float64) ([]Quote, error) {
+	// Roll the shocks for each sector first so that every stock in a sector moves together
+	shocks := map[string]float64{}
+	for _, config := range stocks {
+		if _, ok := shocks[config.Sector]; ok {
+			continue
+		}
+		shocks[config.Sector] = 0
+		if rand.Float64() < sectorShockChance {
+			shocks[config.Sector] = rand.NormFloat64() * sectorShockSize
+		}
+	}
+
+	now := time.Now()
+	quotes := []Quote{}
+	for _, config := range stocks {
+		price, ok := last[config.Ticker]
+		if !ok {
+			// New stocks start at their configured price
+			price = config.Price
+		} else {
+			price = nextPrice(price, config, shocks[config.Sector])
+		}
+		quotes = append(quotes, Quote{Ticker: config.Ticker, Price: price, Time: now})
+	}
+	return quotes, nil
+}
+
+// Not a command
+// Moves a price forward one tick with geometric Brownian motion plus the shock for the stock's sector
+func nextPrice(price float64, config StockConfig, shock float64) (float64) {
+	dt := marketTickYears
+	drift := (config.Drift - config.Volatility*config.Volatility/2) * dt
+	diffusion := config.Volatility * math.Sqrt(dt) * rand.NormFloat64()
+	next := price * math.Exp(drift+diffusion+shock)
+	// Keep prices from rounding down to nothing
+	return math.Max(math.Round(next*100)/100, 0.01)
+}
+
+// Replays historical prices from a CSV file, one row per tick, starting again from the top at the end
+// The first row is a header of "date" followed by tickers, e.g. "date,MARY,EVE", and the first column is ignored
+// Empty cells mean there is no new price for that stock on that row
+// The position in the file isn't saved, so the replay starts from the top again when Mary restarts
+type replayProvider struct {
+	tickers []string
+	rows    [][]string
+	next    int
+	mutex   sync.Mutex
+}
+
+// Not a command
+// Reads the whole CSV file up front so a bad file is caught when Mary starts
+func newReplayProvider(path string) (*replayProvider, error) {
+	if path == "" {
+		return nil, fmt.Errorf("STOCK_REPLAY_FILE not set")
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) < 2 || len(records[0]) < 2 {
+		return nil, fmt.Errorf("%s needs a header row and at least one row of prices", path)
+	}
+
+	tickers := []string{}
+	for _, ticker := range records[0][1:] {
+		tickers = append(tickers, strings.ToUpper(strings.TrimSpace(ticker)))
+	}
+	return &replayProvider{tickers: tickers, rows: records[1:]}, nil
+}
+
+func (p *replayProvider) Name() (string) {
+	return "replay"
+}
+
+func (p *replayProvider) Quotes(stocks []StockConfig, last map[string]float64) ([]Quote, error) {
+	p.mutex.Lock()
+	row := p.rows[p.next]
+	p.next = (p.next + 1) % len(p.rows)
+	p.mutex.Unlock()
+
+	configured := map[string]bool{}
+	for _, config := range stocks {
+		configured[config.Ticker] = true
+	}
+
+	now := time.Now()
+	quotes := []Quote{}
+	for i, ticker := range p.tickers {
+		if !configured[ticker] || i+1 >= len(row) || strings.TrimSpace(row[i+1]) == "" {
+			continue
+		}
+		price, err := strconv.ParseFloat(strings.TrimSpace(row[i+1]), 64)
+		if err != nil || price <= 0 {
+			fmt.Printf("Skipping bad replay price %q for %s!\n", row[i+1], ticker)
+			continue
+		}
+		quotes = append(quotes, Quote{Ticker: ticker, Price: price, Time: now})
+	}
+	return quotes, nil
+}
+
+// Fetches real quotes over HTTP from an endpoint that answers like the Yahoo Finance quote API
+// GET [url]?symbols=AAPL,MSFT -> {"quoteResponse": {"result": [{"symbol": "AAPL", "regularMarketPrice": 150.1, "regularMarketTime": 1700000000}]}}
+// Point STOCK_QUOTE_URL at a local server that answers the same way to try it without the real API
+type httpProvider struct {
+	url    string
+	client *http.Client
+}
+
+const defaultQuoteURL = "https://query1.finance.yahoo.com/v7/finance/quote"
+
+type httpQuoteResponse struct {
+	QuoteResponse struct {
+		Result []struct {
+			Symbol             string  `json:"symbol"`
+			RegularMarketPrice float64 `json:"regularMarketPrice"`
+			RegularMarketTime  int64   `json:"regularMarketTime"`
+		} `json:"result"`
+	} `json:"quoteResponse"`
+}
+
+// Not a command
+func newHTTPProvider(quoteURL string) (*httpProvider, error) {
+	if quoteURL == "" {
+		quoteURL = defaultQuoteURL
+	}
+	_, err := url.ParseRequestURI(quoteURL)
+	if err != nil {
+		return nil, err
+	}
+	return &httpProvider{url: quoteURL, client: &http.Client{Timeout: 10 * time.Second}}, nil
+}
+
+func (p *httpProvider) Name() (string) {
+	return "http"
+}
+
+func (p *httpProvider) Quotes(stocks []StockConfig, last map[string]float64) ([]Quote, error) {
+	tickers := []string{}
+	for _, config := range stocks {
+		tickers = append(tickers, config.Ticker)
+	}
+
+	requestURL, _ := url.Parse(p.url)
+	query := requestURL.Query()
+	query.Set("symbols", strings.Join(tickers, ","))
+	requestURL.RawQuery = query.Encode()
+
+	resp, err := p.client.Get(requestURL.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("quote server returned %s", resp.Status)
+	}
+
+	var body httpQuoteResponse
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	if err != nil {
+		return nil, err
+	}
+
+	quotes := []Quote{}
+	for _, result := range body.QuoteResponse.Result {
+		if result.RegularMarketPrice <= 0 {
+			continue
+		}
+		quoteTime := time.Now()
+		if result.RegularMarketTime > 0 {
+			quoteTime = time.Unix(result.RegularMarketTime, 0)
+		}
+		quotes = append(quotes, Quote{Ticker: strings.ToUpper(result.Symbol), Price: result.RegularMarketPrice, Time: quoteTime})
+	}
+	return quotes, nil
+}
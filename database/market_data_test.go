@@ -0,0 +1,145 @@
+package database
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Not a test
+// Starts a local quote server that answers every request with the given status and body
+// The symbols it was asked for are saved in requested
+func stubQuoteServer(t *testing.T, status int, body string, requested *string) (*httpProvider) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requested != nil {
+			*requested = r.URL.Query().Get("symbols")
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	provider, err := newHTTPProvider(server.URL)
+	if err != nil {
+		t.Fatalf("newHTTPProvider(%q) failed: %s", server.URL, err)
+	}
+	return provider
+}
+
+func TestHTTPProviderQuotes(t *testing.T) {
+	requested := ""
+	provider := stubQuoteServer(t, http.StatusOK, `{"quoteResponse": {"result": [
+		{"symbol": "aapl", "regularMarketPrice": 150.1, "regularMarketTime": 1700000000},
+		{"symbol": "MSFT", "regularMarketPrice": 320.5}
+	]}}`, &requested)
+
+	stocks := []StockConfig{{Ticker: "AAPL"}, {Ticker: "MSFT"}}
+	before := time.Now()
+	quotes, err := provider.Quotes(stocks, nil)
+	if err != nil {
+		t.Fatalf("Quotes failed: %s", err)
+	}
+	if requested != "AAPL,MSFT" {
+		t.Errorf("asked for symbols %q, want %q", requested, "AAPL,MSFT")
+	}
+	if len(quotes) != 2 {
+		t.Fatalf("got %d quotes, want 2", len(quotes))
+	}
+	if quotes[0].Ticker != "AAPL" || quotes[0].Price != 150.1 || !quotes[0].Time.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("got %+v, want AAPL at 150.1 from the market time", quotes[0])
+	}
+	// Without a market time the quote is taken to be from when it was fetched
+	if quotes[1].Ticker != "MSFT" || quotes[1].Price != 320.5 || quotes[1].Time.Before(before) {
+		t.Errorf("got %+v, want MSFT at 320.5 from now", quotes[1])
+	}
+}
+
+func TestHTTPProviderMissingTicker(t *testing.T) {
+	provider := stubQuoteServer(t, http.StatusOK, `{"quoteResponse": {"result": [
+		{"symbol": "AAPL", "regularMarketPrice": 150.1, "regularMarketTime": 1700000000},
+		{"symbol": "GONE", "regularMarketPrice": 0}
+	]}}`, nil)
+
+	quotes, err := provider.Quotes([]StockConfig{{Ticker: "AAPL"}, {Ticker: "MSFT"}, {Ticker: "GONE"}}, nil)
+	if err != nil {
+		t.Fatalf("Quotes failed: %s", err)
+	}
+	// Stocks without a price are left out so they keep their old one
+	if len(quotes) != 1 || quotes[0].Ticker != "AAPL" {
+		t.Errorf("got %+v, want only AAPL", quotes)
+	}
+}
+
+func TestHTTPProviderErrorStatus(t *testing.T) {
+	provider := stubQuoteServer(t, http.StatusServiceUnavailable, `{"quoteResponse": {"result": []}}`, nil)
+
+	quotes, err := provider.Quotes([]StockConfig{{Ticker: "AAPL"}}, nil)
+	if err == nil {
+		t.Fatalf("got quotes %+v, want an error", quotes)
+	}
+}
+
+func TestReplayProviderQuotes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prices.csv")
+	csv := "date,MARY,eve,BOB\n" +
+		"2024-01-01,100,50,10\n" +
+		"2024-01-02,101,,abc\n"
+	err := os.WriteFile(path, []byte(csv), 0644)
+	if err != nil {
+		t.Fatalf("couldn't write %s: %s", path, err)
+	}
+
+	provider, err := newReplayProvider(path)
+	if err != nil {
+		t.Fatalf("newReplayProvider failed: %s", err)
+	}
+	// BOB isn't configured, so it's never quoted
+	stocks := []StockConfig{{Ticker: "MARY"}, {Ticker: "EVE"}}
+
+	want := [][]Quote{
+		{{Ticker: "MARY", Price: 100}, {Ticker: "EVE", Price: 50}},
+		{{Ticker: "MARY", Price: 101}}, // An empty cell is no new price
+		{{Ticker: "MARY", Price: 100}, {Ticker: "EVE", Price: 50}}, // Back to the top at the end of the file
+	}
+	for tick, wantQuotes := range want {
+		quotes, err := provider.Quotes(stocks, nil)
+		if err != nil {
+			t.Fatalf("tick %d: Quotes failed: %s", tick, err)
+		}
+		if len(quotes) != len(wantQuotes) {
+			t.Fatalf("tick %d: got %+v, want %+v", tick, quotes, wantQuotes)
+		}
+		for i := range quotes {
+			if quotes[i].Ticker != wantQuotes[i].Ticker || quotes[i].Price != wantQuotes[i].Price {
+				t.Errorf("tick %d: got %+v, want %+v", tick, quotes[i], wantQuotes[i])
+			}
+		}
+	}
+}
+
+func TestReplayProviderBadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prices.csv")
+	err := os.WriteFile(path, []byte("date,MARY\n"), 0644)
+	if err != nil {
+		t.Fatalf("couldn't write %s: %s", path, err)
+	}
+	_, err = newReplayProvider(path)
+	if err == nil {
+		t.Errorf("want an error for a file without any prices")
+	}
+}
+
+func TestIsStaleUsesFetchTime(t *testing.T) {
+	// A closed market keeps sending yesterday's quote, which is fine to trade on as long as it's still being fetched
+	stock := Stock{QuoteTime: time.Now().Add(-24 * time.Hour), UpdatedAt: time.Now()}
+	if isStale(stock) {
+		t.Errorf("a price fetched just now shouldn't be stale")
+	}
+	stock.UpdatedAt = time.Now().Add(-MaxQuoteAge() - time.Minute)
+	if !isStale(stock) {
+		t.Errorf("a price that hasn't been fetched for longer than %s should be stale", MaxQuoteAge())
+	}
+}
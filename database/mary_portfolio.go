@@ -0,0 +1,521 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"github.com/bwmarrin/discordgo"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Mary trades on her own with simple rules instead of an external API
+// Every strategy looks at a stock's price history and votes to buy, sell or hold, and Mary goes with the majority
+type TradingStrategy interface {
+	Name() string
+	// Signal returns 1 to buy, -1 to sell and 0 to hold
+	Signal(history []PricePoint) int
+}
+
+// Every strategy Mary knows
+// Pick which ones she uses with the MARY_STRATEGIES env var, e.g. "crossover,momentum"
+var tradingStrategies = map[string]TradingStrategy{
+	"crossover":     movingAverageCrossover{Short: 5, Long: 20},
+	"momentum":      momentumStrategy{Lookback: 10, Threshold: 0.05},
+	"meanreversion": meanReversionStrategy{Window: 20, Threshold: 2},
+}
+
+// Mary's portfolio, the only document in the MaryPortfolio collection of the global database
+type MaryPortfolio struct {
+	Cash          int64     `bson:"cash"`
+	Portfolio     []Holding `bson:"portfolio"`
+	StartingValue int64     `bson:"starting_value"`
+	Realized      int64     `bson:"realized"` // Profit from stocks she has sold
+	CreatedAt     time.Time `bson:"created_at"`
+}
+
+// A trade Mary made, kept in the MaryTrades collection of the global database
+type MaryTrade struct {
+	Time       time.Time `bson:"time"`
+	Ticker     string    `bson:"ticker"`
+	Buy        bool      `bson:"buy"`
+	Quantity   int       `bson:"quantity"`
+	Price      float64   `bson:"price"`
+	Coins      int64     `bson:"coins"`
+	Strategies []string  `bson:"strategies"` // The strategies that voted for the trade
+}
+
+const maryStartingCash = 100000
+const maryTradeSize = 0.10 // Each buy spends up to this much of her starting cash
+const maryMaxPosition = 0.25 // She won't put more than this much of her net worth into one stock
+
+// Not a command
+// Gets the strategies chosen in the env vars, or all of them
+func getTradingStrategies() ([]TradingStrategy) {
+	names := []string{}
+	if os.Getenv("MARY_STRATEGIES") != "" {
+		names = strings.Split(strings.ToLower(os.Getenv("MARY_STRATEGIES")), ",")
+	} else {
+		for name := range tradingStrategies {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	strategies := []TradingStrategy{}
+	for _, name := range names {
+		strategy, ok := tradingStrategies[strings.TrimSpace(name)]
+		if !ok {
+			fmt.Printf("Unknown trading strategy %s!\n", name)
+			continue
+		}
+		strategies = append(strategies, strategy)
+	}
+	return strategies
+}
+
+// Not a command
+// Averages the last n prices
+func averagePrice(history []PricePoint, n int) (float64) {
+	total := 0.0
+	for _, point := range history[len(history)-n:] {
+		total += point.Price
+	}
+	return total / float64(n)
+}
+
+// Buys when the short moving average crosses above the long one, and sells when it crosses below
+type movingAverageCrossover struct {
+	Short int
+	Long  int
+}
+
+func (s movingAverageCrossover) Name() (string) {
+	return "crossover"
+}
+
+func (s movingAverageCrossover) Signal(history []PricePoint) (int) {
+	if len(history) < s.Long+1 {
+		return 0
+	}
+	previous := history[:len(history)-1]
+	wasAbove := averagePrice(previous, s.Short) > averagePrice(previous, s.Long)
+	isAbove := averagePrice(history, s.Short) > averagePrice(history, s.Long)
+	if isAbove && !wasAbove {
+		return 1
+	} else if !isAbove && wasAbove {
+		return -1
+	}
+	return 0
+}
+
+// Buys stocks that have gone up a lot recently, and sells ones that have gone down a lot
+type momentumStrategy struct {
+	Lookback  int
+	Threshold float64
+}
+
+func (s momentumStrategy) Name() (string) {
+	return "momentum"
+}
+
+func (s momentumStrategy) Signal(history []PricePoint) (int) {
+	if len(history) < s.Lookback+1 {
+		return 0
+	}
+	past := history[len(history)-s.Lookback-1].Price
+	change := (history[len(history)-1].Price - past) / past
+	if change > s.Threshold {
+		return 1
+	} else if change < -s.Threshold {
+		return -1
+	}
+	return 0
+}
+
+// Buys stocks that are far below their average, and sells ones that are far above it
+// Threshold is how many standard deviations away the price has to be
+type meanReversionStrategy struct {
+	Window    int
+	Threshold float64
+}
+
+func (s meanReversionStrategy) Name() (string) {
+	return "meanreversion"
+}
+
+func (s meanReversionStrategy) Signal(history []PricePoint) (int) {
+	if len(history) < s.Window {
+		return 0
+	}
+	mean := averagePrice(history, s.Window)
+	variance := 0.0
+	for _, point := range history[len(history)-s.Window:] {
+		variance += (point.Price - mean) * (point.Price - mean)
+	}
+	deviation := math.Sqrt(variance / float64(s.Window))
+	if deviation == 0 {
+		return 0
+	}
+	z := (history[len(history)-1].Price - mean) / deviation
+	if z < -s.Threshold {
+		return 1
+	} else if z > s.Threshold {
+		return -1
+	}
+	return 0
+}
+
+// Not a command
+// Gets Mary's portfolio, giving her starting cash the first time
+func getMaryPortfolio(ctx context.Context, client *mongo.Client) (MaryPortfolio, error) {
+	var portfolio MaryPortfolio
+	err := client.Database(globalDatabase).Collection("MaryPortfolio").FindOneAndUpdate(
+		ctx,
+		bson.D{},
+		bson.D{
+			{Key: "$setOnInsert", Value: MaryPortfolio{
+				Cash:          maryStartingCash,
+				Portfolio:     []Holding{},
+				StartingValue: maryStartingCash,
+				CreatedAt:     time.Now(),
+			}},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&portfolio)
+	return portfolio, err
+}
+
+// Not a command
+// Works out how much a portfolio is worth at the current prices
+func holdingsValue(holdings []Holding, stocks map[string]Stock) (float64) {
+	value := 0.0
+	for _, holding := range holdings {
+		value += stocks[holding.Ticker].Price * float64(holding.Quantity)
+	}
+	return value
+}
+
+// Not a command
+// Lets Mary's strategies trade on the latest prices
+// Called by TickMarket whenever prices update
+func runMaryStrategies(ctx context.Context, client *mongo.Client, stocks map[string]Stock) {
+	strategies := getTradingStrategies()
+	if len(strategies) == 0 {
+		return
+	}
+	portfolio, err := getMaryPortfolio(ctx, client)
+	if err != nil {
+		fmt.Printf("Error occurred while getting Mary's portfolio! %s\n", err)
+		return
+	}
+
+	tickers := []string{}
+	for ticker := range stocks {
+		tickers = append(tickers, ticker)
+	}
+	sort.Strings(tickers)
+
+	held := map[string]int{}
+	for _, holding := range portfolio.Portfolio {
+		held[holding.Ticker] = holding.Quantity
+	}
+	netWorth := float64(portfolio.Cash) + holdingsValue(portfolio.Portfolio, stocks)
+
+	trades := []MaryTrade{}
+	for _, ticker := range tickers {
+		stock := stocks[ticker]
+		if isStale(stock) {
+			continue
+		}
+
+		// Count the votes
+		votes := 0
+		buyers, sellers := []string{}, []string{}
+		for _, strategy := range strategies {
+			signal := strategy.Signal(stock.History)
+			votes += signal
+			if signal > 0 {
+				buyers = append(buyers, strategy.Name())
+			} else if signal < 0 {
+				sellers = append(sellers, strategy.Name())
+			}
+		}
+
+		if votes > 0 {
+			// Don't let one stock take over the portfolio
+			room := netWorth*maryMaxPosition - stock.Price*float64(held[ticker])
+			budget := math.Min(math.Min(maryTradeSize*float64(portfolio.StartingValue), room), float64(portfolio.Cash))
+			quantity := int(budget / stock.Price)
+			if quantity < 1 {
+				continue
+			}
+			cost := int64(math.Ceil(stock.Price * float64(quantity)))
+			portfolio.Cash -= cost
+			held[ticker] += quantity
+			portfolio.Portfolio = addToHolding(portfolio.Portfolio, ticker, quantity, cost)
+			trades = append(trades, MaryTrade{Time: time.Now(), Ticker: ticker, Buy: true, Quantity: quantity, Price: stock.Price, Coins: cost, Strategies: buyers})
+		} else if votes < 0 && held[ticker] > 0 {
+			// Sell the whole position
+			quantity := held[ticker]
+			proceeds := int64(math.Floor(stock.Price * float64(quantity)))
+			for i, holding := range portfolio.Portfolio {
+				if holding.Ticker == ticker {
+					portfolio.Realized += proceeds - holding.Cost
+					portfolio.Portfolio = append(portfolio.Portfolio[:i], portfolio.Portfolio[i+1:]...)
+					break
+				}
+			}
+			portfolio.Cash += proceeds
+			held[ticker] = 0
+			trades = append(trades, MaryTrade{Time: time.Now(), Ticker: ticker, Buy: false, Quantity: quantity, Price: stock.Price, Coins: proceeds, Strategies: sellers})
+		}
+	}
+	if len(trades) == 0 {
+		return
+	}
+
+	// Only the scheduler changes Mary's portfolio, so it can be saved in one go
+	_, err = client.Database(globalDatabase).Collection("MaryPortfolio").UpdateOne(
+		ctx,
+		bson.D{},
+		bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "cash", Value: portfolio.Cash},
+				{Key: "portfolio", Value: portfolio.Portfolio},
+				{Key: "realized", Value: portfolio.Realized},
+			}},
+		},
+	)
+	if err != nil {
+		fmt.Printf("Error occurred while updating Mary's portfolio! %s\n", err)
+		return
+	}
+	documents := []interface{}{}
+	for _, trade := range trades {
+		documents = append(documents, trade)
+	}
+	_, err = client.Database(globalDatabase).Collection("MaryTrades").InsertMany(ctx, documents)
+	if err != nil {
+		fmt.Printf("Error occurred while logging Mary's trades! %s\n", err)
+	}
+}
+
+// Not a command
+// Adds shares to a holding, or starts a new one
+func addToHolding(holdings []Holding, ticker string, quantity int, cost int64) ([]Holding) {
+	for i := range holdings {
+		if holdings[i].Ticker == ticker {
+			holdings[i].Quantity += quantity
+			holdings[i].Cost += cost
+			return holdings
+		}
+	}
+	return append(holdings, Holding{Ticker: ticker, Quantity: quantity, Cost: cost})
+}
+
+// Not a command
+// Works out Mary's return on her starting cash
+func maryReturn(portfolio MaryPortfolio, stocks map[string]Stock) (float64) {
+	netWorth := float64(portfolio.Cash) + holdingsValue(portfolio.Portfolio, stocks)
+	return (netWorth - float64(portfolio.StartingValue)) / float64(portfolio.StartingValue)
+}
+
+// Not a command
+// Works out a user's return on everything they have put into stocks
+func userStockReturn(user User, stocks map[string]Stock) (float64) {
+	if user.StockInvested <= 0 {
+		return 0
+	}
+	cost := int64(0)
+	for _, holding := range user.Portfolio {
+		cost += holding.Cost
+	}
+	profit := holdingsValue(user.Portfolio, stocks) - float64(cost) + float64(user.StockProfit)
+	return profit / float64(user.StockInvested)
+}
+
+// mary maryportfolio -> shows Mary's holdings, returns and latest trades
+func ShowMaryPortfolio(mongoURI string) (string, *discordgo.MessageEmbed) {
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error()), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error()), nil
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	portfolio, err := getMaryPortfolio(ctx, client)
+	if err != nil {
+		fmt.Printf("Error occurred while getting Mary's portfolio! %s\n", err)
+		return "Error occurred while getting Mary's portfolio! " + strings.Title(err.Error()), nil
+	}
+	stocks, err := getStocks(ctx, client)
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return "Error occurred while selecting from database! " + strings.Title(err.Error()), nil
+	}
+
+	strategyNames := []string{}
+	for _, strategy := range getTradingStrategies() {
+		strategyNames = append(strategyNames, strategy.Name())
+	}
+	value := holdingsValue(portfolio.Portfolio, stocks)
+	embed := &discordgo.MessageEmbed{
+		Title:       "Mary's Portfolio",
+		Description: "Strategies: " + strings.Join(strategyNames, ", "),
+		Color:       0xffc0cb,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Cash", Value: fmt.Sprintf("%d coins", portfolio.Cash), Inline: true},
+			{Name: "Stocks", Value: fmt.Sprintf("%.0f coins", value), Inline: true},
+			{Name: "Return", Value: fmt.Sprintf("%+.2f%% since <t:%d:D>", maryReturn(portfolio, stocks)*100, portfolio.CreatedAt.Unix()), Inline: true},
+		},
+	}
+	for _, holding := range portfolio.Portfolio {
+		holdingValue := stocks[holding.Ticker].Price * float64(holding.Quantity)
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:   fmt.Sprintf("%s x%d", holding.Ticker, holding.Quantity),
+			Value:  fmt.Sprintf("Cost: %d coins\nValue: %.0f coins\nP&L: %s", holding.Cost, holdingValue, formatPriceChange(float64(holding.Cost), holdingValue)),
+			Inline: true,
+		})
+	}
+
+	// Show her latest trades
+	cursor, err := client.Database(globalDatabase).Collection("MaryTrades").Find(
+		ctx,
+		bson.D{},
+		options.Find().SetSort(bson.D{{Key: "time", Value: -1}}).SetLimit(5),
+	)
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return "Error occurred while selecting from database! " + strings.Title(err.Error()), nil
+	}
+	var trades []MaryTrade
+	err = cursor.All(ctx, &trades)
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return "Error occurred while selecting from database! " + strings.Title(err.Error()), nil
+	}
+	if len(trades) > 0 {
+		lines := []string{}
+		for _, trade := range trades {
+			side := "Sold"
+			if trade.Buy {
+				side = "Bought"
+			}
+			lines = append(lines, fmt.Sprintf("<t:%d:R> %s %d %s at %.2f (%s)", trade.Time.Unix(), side, trade.Quantity, trade.Ticker, trade.Price, strings.Join(trade.Strategies, ", ")))
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  "Latest Trades",
+			Value: strings.Join(lines, "\n"),
+		})
+	}
+	return "", embed
+}
+
+// mary stock top -> ranks everyone's stock returns in the server against Mary's
+func StockLeaderboard(mongoURI string, guildID int) (string, *discordgo.MessageEmbed) {
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error()), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error()), nil
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	stocks, err := getStocks(ctx, client)
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return "Error occurred while selecting from database! " + strings.Title(err.Error()), nil
+	}
+	portfolio, err := getMaryPortfolio(ctx, client)
+	if err != nil {
+		fmt.Printf("Error occurred while getting Mary's portfolio! %s\n", err)
+		return "Error occurred while getting Mary's portfolio! " + strings.Title(err.Error()), nil
+	}
+
+	userCollection := client.Database(strconv.Itoa(guildID)).Collection("Users")
+	cursor, err := userCollection.Find(ctx, bson.M{"guild_id": guildID, "stock_invested": bson.M{"$gt": 0}})
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return "Error occurred while selecting from database! " + strings.Title(err.Error()), nil
+	}
+	var users []struct {
+		User     `bson:",inline"`
+		UserName string `bson:"user_name"`
+	}
+	err = cursor.All(ctx, &users)
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return "Error occurred while selecting from database! " + strings.Title(err.Error()), nil
+	}
+
+	type entry struct {
+		Name   string
+		Return float64
+		Mary   bool
+	}
+	entries := []entry{{Name: "Mary", Return: maryReturn(portfolio, stocks), Mary: true}}
+	for _, user := range users {
+		entries = append(entries, entry{Name: user.UserName, Return: userStockReturn(user.User, stocks)})
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Return > entries[j].Return
+	})
+
+	embed := &discordgo.MessageEmbed{
+		Title: "Stock Returns",
+		Color: 0xffc0cb,
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "Returns count sold and unsold stocks against everything put in",
+		},
+	}
+	// Show the top 10, and Mary wherever she is
+	lines := []string{}
+	maryRank := 0
+	for i, e := range entries {
+		if e.Mary {
+			maryRank = i
+		}
+		if i >= 10 && !e.Mary {
+			continue
+		}
+		name := e.Name
+		if e.Mary {
+			name = "**Mary**"
+		}
+		lines = append(lines, fmt.Sprintf("%d. %s: %+.2f%%", i+1, name, e.Return*100))
+	}
+	embed.Description = strings.Join(lines, "\n")
+	embed.Fields = []*discordgo.MessageEmbedField{{
+		Name:  "Beating Mary",
+		Value: fmt.Sprintf("%d of %d traders", maryRank, len(entries)-1),
+	}}
+	return "", embed
+}
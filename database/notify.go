@@ -0,0 +1,77 @@
+package database
+
+import (
+	"fmt"
+	"strconv"
+	"github.com/bwmarrin/discordgo"
+)
+
+// Background jobs don't have a message to reply to, so they use this session to reach users
+var notifySession *discordgo.Session
+
+// Lets background jobs like the order matcher message users
+// Call this once after opening the Discord connection
+func SetNotifySession(session *discordgo.Session) {
+	notifySession = session
+}
+
+// Not a command
+// Sends a user a DM, falling back to mentioning them in the channel if their DMs are closed
+func notifyUser(userID int, channelID string, content string) {
+	if notifySession == nil {
+		fmt.Printf("No session to notify user %d! %s\n", userID, content)
+		return
+	}
+	dm, err := notifySession.UserChannelCreate(strconv.Itoa(userID))
+	if err == nil {
+		_, err = notifySession.ChannelMessageSend(dm.ID, content)
+		if err == nil {
+			return
+		}
+	}
+	if channelID == "" {
+		fmt.Printf("Error occurred while notifying user %d! %s\n", userID, err)
+		return
+	}
+	_, err = notifySession.ChannelMessageSend(channelID, "<@" + strconv.Itoa(userID) + "> " + content)
+	if err != nil {
+		fmt.Printf("Error occurred while notifying user %d! %s\n", userID, err)
+	}
+}
+
+// Not a command
+// Tells a user about something that came from an event, in the channel it happened in if there was one, otherwise in a DM
+func notifyEvent(event GameEvent, content string) {
+	if event.ChannelID != "" && notifySession != nil {
+		_, err := notifySession.ChannelMessageSend(event.ChannelID, "<@" + strconv.Itoa(event.UserID) + "> " + content)
+		if err == nil {
+			return
+		}
+	}
+	notifyUser(event.UserID, event.ChannelID, content)
+}
+
+// Not a command
+// Posts an embed in a server's announcement channel, if it has one
+func announce(channelID string, embed *discordgo.MessageEmbed) {
+	if notifySession == nil || channelID == "" {
+		return
+	}
+	_, err := notifySession.ChannelMessageSendEmbed(channelID, embed)
+	if err != nil {
+		fmt.Printf("Error occurred while sending announcement! %s\n", err)
+	}
+}
+
+// Not a command
+// Posts a message in a channel, e.g. the result of something that finished in the background
+func postChannel(channelID string, content string) {
+	if notifySession == nil || channelID == "" {
+		fmt.Printf("No session to post in channel %s! %s\n", channelID, content)
+		return
+	}
+	_, err := notifySession.ChannelMessageSend(channelID, content)
+	if err != nil {
+		fmt.Printf("Error occurred while posting in channel %s! %s\n", channelID, err)
+	}
+}
@@ -0,0 +1,391 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"github.com/bwmarrin/discordgo"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// An order that trades a stock once its price crosses the order's price
+// Orders for every server are kept in the Orders collection of the global database so the matcher can check them in one go
+type StockOrder struct {
+	OrderID   int       `bson:"order_id"`
+	GuildID   int       `bson:"guild_id"`
+	UserID    int       `bson:"user_id"`
+	ChannelID string    `bson:"channel_id"` // Where the order was placed, for when the user's DMs are closed
+	Type      string    `bson:"type"` // One of the keys of orderTypes
+	Ticker    string    `bson:"ticker"`
+	Quantity  int       `bson:"quantity"`
+	Price     float64   `bson:"price"` // The price that triggers the order
+	Status    string    `bson:"status"` // open, filled, cancelled, expired or failed
+	CreatedAt time.Time `bson:"created_at"`
+	ExpiresAt time.Time `bson:"expires_at"`
+	FillPrice float64   `bson:"fill_price"`
+	FilledAt  time.Time `bson:"filled_at"`
+	Reason    string    `bson:"reason"` // Why a failed order couldn't be filled
+}
+
+type orderType struct {
+	Name string
+	Buy  bool // Otherwise the order sells
+	// Checks if the current price has reached the order's price
+	Triggered func(price float64, orderPrice float64) bool
+}
+
+var orderTypes = map[string]orderType{
+	"buy": {"Limit buy", true, func(price float64, orderPrice float64) bool {
+		return price <= orderPrice
+	}},
+	"sell": {"Limit sell", false, func(price float64, orderPrice float64) bool {
+		return price >= orderPrice
+	}},
+	"stop": {"Stop-loss", false, func(price float64, orderPrice float64) bool {
+		return price <= orderPrice
+	}},
+	"takeprofit": {"Take-profit", false, func(price float64, orderPrice float64) bool {
+		return price >= orderPrice
+	}},
+}
+
+const defaultOrderExpiry = 7 * 24 * time.Hour
+const maxOrderExpiry = 30 * 24 * time.Hour
+const maxOpenOrders = 20
+
+// Not a command
+// Parses how long an order lasts, e.g. "12h", "3d" or "90m"
+func ParseOrderExpiry(arg string) (time.Duration, string) {
+	expiry, err := parseDays(arg)
+	if err != nil || expiry < time.Minute {
+		return 0, "Please enter a valid expiry, like 90m, 12h or 3d!"
+	}
+	if expiry > maxOrderExpiry {
+		return 0, "Orders can't last longer than 30 days!"
+	}
+	return expiry, ""
+}
+
+// Not a command
+// Parses a duration that can also be given in days, e.g. "3d"
+func parseDays(arg string) (time.Duration, error) {
+	arg = strings.ToLower(arg)
+	if strings.HasSuffix(arg, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(arg, "d"))
+		return time.Duration(days) * 24 * time.Hour, err
+	}
+	return time.ParseDuration(arg)
+}
+
+// mary order [buy/sell/stop/takeprofit] [ticker] [amount] [price] [optional: expiry] -> places an order that trades when the price is reached
+func PlaceOrder(mongoURI string, guildID int, guildName string, userID int, userName string, channelID string, kind string, ticker string, quantity int, price float64, expiry time.Duration) (string) {
+	kind = strings.ToLower(kind)
+	order, ok := orderTypes[kind]
+	if !ok {
+		return "Please choose an order type of buy, sell, stop or takeprofit!"
+	}
+	if quantity < 1 {
+		return "Please specify a positive number of shares!"
+	}
+	if price <= 0 {
+		return "Please specify a positive price!"
+	}
+	if expiry == 0 {
+		expiry = defaultOrderExpiry
+	}
+
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error())
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	// Check if user exists in database
+	res := IsPlaying(ctx, client, guildID, guildName, userID, userName)
+	if res != "" {
+		return res
+	}
+
+	stock, errMsg := getStock(ctx, client, ticker)
+	if errMsg != "" {
+		return errMsg
+	}
+
+	// Selling orders need the shares to be there now, although they're only taken when the order fills
+	if !order.Buy {
+		var user User
+		err = client.Database(strconv.Itoa(guildID)).Collection("Users").FindOne(ctx, bson.M{"guild_id": guildID, "user_id": userID}).Decode(&user)
+		if err != nil {
+			fmt.Printf("Error occurred while finding user in database! %s\n", err)
+			return "Error occurred while finding user in database! " + strings.Title(err.Error())
+		}
+		owned := 0
+		for _, holding := range user.Portfolio {
+			if holding.Ticker == stock.Ticker {
+				owned = holding.Quantity
+			}
+		}
+		if owned < quantity {
+			return fmt.Sprintf("You only have %d shares of %s!", owned, stock.Ticker)
+		}
+	}
+
+	orderCollection := client.Database(globalDatabase).Collection("Orders")
+	open, err := orderCollection.CountDocuments(ctx, bson.M{"guild_id": guildID, "user_id": userID, "status": "open"})
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return "Error occurred while selecting from database! " + strings.Title(err.Error())
+	}
+	if open >= maxOpenOrders {
+		return fmt.Sprintf("You can only have %d open orders! Cancel one with `mary orders cancel [order number]`.", maxOpenOrders)
+	}
+
+	orderID, err := nextSequence(ctx, client, "orders")
+	if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+		return "Error occurred while updating database! " + strings.Title(err.Error())
+	}
+	now := time.Now()
+	_, err = orderCollection.InsertOne(ctx, StockOrder{
+		OrderID:   orderID,
+		GuildID:   guildID,
+		UserID:    userID,
+		ChannelID: channelID,
+		Type:      kind,
+		Ticker:    stock.Ticker,
+		Quantity:  quantity,
+		Price:     price,
+		Status:    "open",
+		CreatedAt: now,
+		ExpiresAt: now.Add(expiry),
+	})
+	if err != nil {
+		fmt.Printf("Error occurred while inserting to database! %s\n", err)
+		return "Error occurred while inserting to database! " + strings.Title(err.Error())
+	}
+	return fmt.Sprintf("Placed order #%d: %s. It expires <t:%d:R>. The current price is %.2f coins.", orderID, describeOrder(StockOrder{Type: kind, Ticker: stock.Ticker, Quantity: quantity, Price: price}), now.Add(expiry).Unix(), stock.Price)
+}
+
+// Not a command
+// Describes an order, e.g. "Stop-loss sell 10 MARY at 95.00"
+func describeOrder(order StockOrder) (string) {
+	kind := orderTypes[order.Type]
+	side := "sell"
+	if kind.Buy {
+		side = "buy"
+	}
+	if order.Type == "buy" || order.Type == "sell" {
+		return fmt.Sprintf("%s %d %s at %.2f", kind.Name, order.Quantity, order.Ticker, order.Price)
+	}
+	return fmt.Sprintf("%s %s %d %s at %.2f", kind.Name, side, order.Quantity, order.Ticker, order.Price)
+}
+
+// mary orders -> shows your open orders
+func ListOrders(mongoURI string, guildID int, userID int, userName string) (string, *discordgo.MessageEmbed) {
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error()), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error()), nil
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	cursor, err := client.Database(globalDatabase).Collection("Orders").Find(
+		ctx,
+		bson.M{"guild_id": guildID, "user_id": userID, "status": "open"},
+		options.Find().SetSort(bson.D{{Key: "order_id", Value: 1}}),
+	)
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return "Error occurred while selecting from database! " + strings.Title(err.Error()), nil
+	}
+	var orders []StockOrder
+	err = cursor.All(ctx, &orders)
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return "Error occurred while selecting from database! " + strings.Title(err.Error()), nil
+	}
+	if len(orders) == 0 {
+		return "You don't have any open orders! Place one with `mary order [buy/sell/stop/takeprofit] [ticker] [amount] [price]`.", nil
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title: userName + "'s Open Orders",
+		Color: 0xffc0cb,
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "mary orders cancel [order number] to cancel an order",
+		},
+	}
+	for _, order := range orders {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  fmt.Sprintf("#%d", order.OrderID),
+			Value: fmt.Sprintf("%s\nExpires <t:%d:R>", describeOrder(order), order.ExpiresAt.Unix()),
+		})
+	}
+	return "", embed
+}
+
+// mary orders cancel [order number] -> cancels one of your open orders
+func CancelOrder(mongoURI string, guildID int, userID int, orderID int) (string) {
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error())
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	result, err := client.Database(globalDatabase).Collection("Orders").UpdateOne(
+		ctx,
+		bson.D{
+			{Key: "order_id", Value: orderID},
+			{Key: "guild_id", Value: guildID},
+			{Key: "user_id", Value: userID},
+			{Key: "status", Value: "open"},
+		},
+		bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "status", Value: "cancelled"},
+			}},
+		},
+	)
+	if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+		return "Error occurred while updating database! " + strings.Title(err.Error())
+	}
+	if result.ModifiedCount == 0 {
+		return fmt.Sprintf("You don't have an open order #%d!", orderID)
+	}
+	return fmt.Sprintf("Cancelled order #%d!", orderID)
+}
+
+// Not a command
+// Fills every open order whose price has been reached, and expires old ones
+// Called by TickMarket whenever prices update
+func matchOrders(ctx context.Context, client *mongo.Client, stocks map[string]Stock) {
+	orderCollection := client.Database(globalDatabase).Collection("Orders")
+	now := time.Now()
+
+	cursor, err := orderCollection.Find(ctx, bson.M{"status": "open"}, options.Find().SetSort(bson.D{{Key: "order_id", Value: 1}}))
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return
+	}
+	var orders []StockOrder
+	err = cursor.All(ctx, &orders)
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return
+	}
+
+	for _, order := range orders {
+		if now.After(order.ExpiresAt) {
+			if claimOrder(ctx, orderCollection, order, bson.D{{Key: "status", Value: "expired"}}) {
+				notifyUser(order.UserID, order.ChannelID, fmt.Sprintf("Your order #%d (%s) expired without filling.", order.OrderID, describeOrder(order)))
+			}
+			continue
+		}
+
+		// Never fill on a price that's out of date
+		stock, ok := stocks[order.Ticker]
+		if !ok || isStale(stock) || !orderTypes[order.Type].Triggered(stock.Price, order.Price) {
+			continue
+		}
+		fillOrder(ctx, client, orderCollection, order, stock)
+	}
+}
+
+// Not a command
+// Moves an open order to its next status, returning false if something else got to it first
+func claimOrder(ctx context.Context, orderCollection *mongo.Collection, order StockOrder, set bson.D) (bool) {
+	result, err := orderCollection.UpdateOne(
+		ctx,
+		bson.D{
+			{Key: "order_id", Value: order.OrderID},
+			{Key: "status", Value: "open"},
+		},
+		bson.D{
+			{Key: "$set", Value: set},
+		},
+	)
+	if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+		return false
+	}
+	return result.ModifiedCount == 1
+}
+
+// Not a command
+// Trades a triggered order at the current price and tells the user how it went
+func fillOrder(ctx context.Context, client *mongo.Client, orderCollection *mongo.Collection, order StockOrder, stock Stock) {
+	// Claim the order first so a cancel at the same moment can't leave it half done
+	if !claimOrder(ctx, orderCollection, order, bson.D{{Key: "status", Value: "filling"}}) {
+		return
+	}
+
+	userCollection := client.Database(strconv.Itoa(order.GuildID)).Collection("Users")
+	var notice, errMsg string
+	if orderTypes[order.Type].Buy {
+		var cost int64
+		cost, errMsg = buyShares(ctx, userCollection, order.GuildID, order.UserID, stock, order.Quantity)
+		notice = fmt.Sprintf("Your order #%d filled! You bought %d shares of %s at %.2f coins each for %d coins.", order.OrderID, order.Quantity, stock.Ticker, stock.Price, cost)
+	} else {
+		var proceeds, profit int64
+		proceeds, profit, errMsg = sellShares(ctx, userCollection, order.GuildID, order.UserID, stock, order.Quantity)
+		notice = fmt.Sprintf("Your order #%d filled! You sold %d shares of %s at %.2f coins each for %d coins. Profit: %+d coins.", order.OrderID, order.Quantity, stock.Ticker, stock.Price, proceeds, profit)
+	}
+
+	set := bson.D{
+		{Key: "status", Value: "filled"},
+		{Key: "fill_price", Value: stock.Price},
+		{Key: "filled_at", Value: time.Now()},
+	}
+	if errMsg != "" {
+		set = bson.D{
+			{Key: "status", Value: "failed"},
+			{Key: "reason", Value: errMsg},
+		}
+		notice = fmt.Sprintf("Your order #%d (%s) was triggered but couldn't be filled: %s", order.OrderID, describeOrder(order), errMsg)
+	}
+	_, err := orderCollection.UpdateOne(ctx, bson.M{"order_id": order.OrderID}, bson.D{{Key: "$set", Value: set}})
+	if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+	}
+	notifyUser(order.UserID, order.ChannelID, notice)
+}
@@ -0,0 +1,161 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Shop items that change the background of the profile card instead of being used up
+var ProfileBackgrounds = []string{"galaxy", "sakura", "ocean"}
+
+// Everything on a profile that GetProfile doesn't return
+type ProfileExtras struct {
+	Level        int
+	LevelXP      int64 // XP into the current level
+	LevelNeeded  int64 // XP the current level needs
+	Title        string
+	Badges       string   // Achievement emojis, for the embed
+	BadgeNames   []string // Achievement names followed by season badges, for the card
+	SeasonBadges []string
+	TriviaStreak int
+	BestStreak   int
+	Background   string // Only set if the user still owns the background
+	Health       string // e.g. "85/100 HP" or "Knocked out for 12m"
+}
+
+// Not a command
+// Gets a user's level, title, badges, streak and background for showing on their profile
+// Profiles are drawn for every mention, so this uses the shared client rather than connecting each time
+func GetProfileExtras(mongoURI string, guildID int, userID int) (ProfileExtras) {
+	extras := ProfileExtras{LevelNeeded: xpToNextLevel(0)}
+	client, err := getSharedClient(mongoURI)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return extras
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var raw bson.Raw
+	err = client.Database(strconv.Itoa(guildID)).Collection("Users").FindOne(ctx, bson.M{"guild_id": guildID, "user_id": userID}).Decode(&raw)
+	if err != nil {
+		fmt.Printf("Error occurred while finding user in database! %s\n", err)
+		return extras
+	}
+	var user User
+	var stats triviaStats
+	err = bson.Unmarshal(raw, &user)
+	if err == nil {
+		err = bson.Unmarshal(raw, &stats)
+	}
+	if err != nil {
+		fmt.Printf("Error occurred while reading user! %s\n", err)
+		return extras
+	}
+
+	extras.Level, extras.LevelXP, extras.LevelNeeded = levelFromXP(user.XP)
+	extras.Title = user.Title
+	extras.TriviaStreak = stats.Streak
+	extras.BestStreak = stats.BestStreak
+	extras.Health = describeHealth(user)
+
+	unlocked := map[string]bool{}
+	for _, achievement := range user.Achievements {
+		unlocked[achievement.ID] = true
+	}
+	for _, achievement := range getAchievements() {
+		if unlocked[achievement.ID] {
+			extras.Badges += achievement.Badge
+			extras.BadgeNames = append(extras.BadgeNames, achievement.Name)
+		}
+	}
+	extras.SeasonBadges = user.Badges
+	extras.BadgeNames = append(extras.BadgeNames, user.Badges...)
+
+	// A background that has been sold or given away can't be shown anymore
+	if background, ok := raw.Lookup("profile_background").StringValueOK(); ok {
+		for _, item := range user.Inventory {
+			if item.Name == background && item.Quantity > 0 {
+				extras.Background = background
+			}
+		}
+	}
+	return extras
+}
+
+// mary background [name/none] -> sets the background of your profile card to one you have bought
+func SetBackground(mongoURI string, guildID int, guildName string, userID int, userName string, background string) (string) {
+	background = strings.ToLower(background)
+	valid := background == "none"
+	for _, name := range ProfileBackgrounds {
+		if name == background {
+			valid = true
+		}
+	}
+	if !valid {
+		return "Please choose a background of " + strings.Join(ProfileBackgrounds, ", ") + " or none!"
+	}
+
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error())
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	// Check if user exists in database
+	res := IsPlaying(ctx, client, guildID, guildName, userID, userName)
+	if res != "" {
+		return res
+	}
+
+	userCollection := client.Database(strconv.Itoa(guildID)).Collection("Users")
+	filter := bson.D{
+		{Key: "user_id", Value: userID},
+		{Key: "guild_id", Value: guildID},
+	}
+	if background == "none" {
+		background = ""
+	} else {
+		// Only set it if they own it
+		filter = append(filter, bson.E{Key: "inventory", Value: bson.D{
+			{Key: "$elemMatch", Value: bson.D{
+				{Key: "name", Value: background},
+				{Key: "quantity", Value: bson.D{{Key: "$gt", Value: 0}}},
+			}},
+		}})
+	}
+	result, err := userCollection.UpdateOne(ctx, filter, bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "profile_background", Value: background},
+		}},
+	})
+	if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+		return "Error occurred while updating database! " + strings.Title(err.Error())
+	}
+	if result.MatchedCount == 0 {
+		return "You don't own that background! You can buy it from the shop."
+	}
+	if background == "" {
+		return "Your profile card is back to the default background."
+	}
+	return "Your profile card now has the " + strings.Title(background) + " background!"
+}
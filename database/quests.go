@@ -0,0 +1,421 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"github.com/bwmarrin/discordgo"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// An objective that can be given to a user for a day or a week
+// Quests are picked from a pool for each period, which can be replaced with a JSON file pointed to by the QUESTS_CONFIG env var
+type Quest struct {
+	ID          string `json:"id"`
+	Period      string `json:"period"` // daily or weekly
+	Description string `json:"description"`
+	Event       string `json:"event"` // The event type that counts towards it
+	Item        string `json:"item"`  // Only count events for this item, e.g. "chocolate"
+	Correct     bool   `json:"correct"` // Only count correct trivia answers
+	ByAmount    bool   `json:"by_amount"` // Count the amount in each event (e.g. items sold) instead of the number of events
+	Goal        int64  `json:"goal"`
+	Reward      int64  `json:"reward"` // Coins given when claimed
+	Crate       string `json:"crate"` // Loot box given when claimed as well as the coins, if any
+}
+
+// How often a set of quests is given out
+type QuestPeriod struct {
+	Name  string
+	Count int // How many quests each user gets
+	Key   func(now time.Time) (string) // Names the current period, which changes when the quests rotate
+	Reset func(now time.Time) (time.Time) // When the current period ends
+}
+
+var questPeriods = []QuestPeriod{
+	{"daily", 3,
+		func(now time.Time) (string) {
+			return now.UTC().Format("2006-01-02")
+		},
+		func(now time.Time) (time.Time) {
+			year, month, day := now.UTC().Date()
+			return time.Date(year, month, day+1, 0, 0, 0, 0, time.UTC)
+		},
+	},
+	{"weekly", 3,
+		func(now time.Time) (string) {
+			year, week := now.UTC().ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		},
+		func(now time.Time) (time.Time) {
+			// Weeks start on Monday
+			year, month, day := now.UTC().Date()
+			daysLeft := (8 - int(now.UTC().Weekday())) % 7
+			if daysLeft == 0 {
+				daysLeft = 7
+			}
+			return time.Date(year, month, day+daysLeft, 0, 0, 0, 0, time.UTC)
+		},
+	},
+}
+
+var defaultQuests = []Quest{
+	{"trivia_3", "daily", "Answer 3 trivia questions correctly", "trivia_answer", "", true, false, 3, 300, ""},
+	{"eat_chocolate", "daily", "Eat a chocolate", "use", "chocolate", false, false, 1, 100, ""},
+	{"beg_5", "daily", "Beg 5 times", "beg", "", false, false, 5, 100, ""},
+	{"gamble_3", "daily", "Gamble 3 times", "gamble", "", false, false, 3, 200, ""},
+	{"claim_daily", "daily", "Claim your daily", "daily", "", false, false, 1, 100, ""},
+	{"buy_3", "daily", "Buy 3 items from the shop", "buy", "", false, true, 3, 200, ""},
+	{"sell_5", "weekly", "Sell 5 items", "sell", "", false, true, 5, 1000, "crate"},
+	{"trivia_20", "weekly", "Answer 20 trivia questions correctly", "trivia_answer", "", true, false, 20, 2000, "crate"},
+	{"win_gamble_5", "weekly", "Win 5 gambles", "gamble_win", "", false, false, 5, 1500, "crate"},
+	{"rob_3", "weekly", "Rob someone 3 times", "rob", "", false, false, 3, 1000, "crate"},
+	{"daily_5", "weekly", "Claim your daily 5 times", "daily", "", false, false, 5, 1000, "crate"},
+	{"use_gun", "weekly", "Use a gun on someone", "use", "gun", false, false, 1, 500, "crate"},
+	{"stock_buy_3", "weekly", "Buy stocks 3 times", "stock_buy", "", false, false, 3, 800, "crate"},
+}
+
+var quests []Quest
+var questsOnce sync.Once
+
+func init() {
+	onEvent(questsForEvent)
+}
+
+// Not a command
+// Gets every quest, from the QUESTS_CONFIG file if there is one
+func getQuests() ([]Quest) {
+	questsOnce.Do(func() {
+		quests = defaultQuests
+		path := os.Getenv("QUESTS_CONFIG")
+		if path == "" {
+			return
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Printf("Error reading quests config! %s\n", err)
+			return
+		}
+		var configs []Quest
+		err = json.Unmarshal(data, &configs)
+		if err != nil || len(configs) == 0 {
+			fmt.Printf("Error parsing quests config! %v\n", err)
+			return
+		}
+		for i := range configs {
+			configs[i].Period = strings.ToLower(configs[i].Period)
+			if configs[i].Goal < 1 {
+				configs[i].Goal = 1
+			}
+		}
+		quests = configs
+	})
+	return quests
+}
+
+// Not a command
+// Picks a user's quests for a period
+// The pick only depends on who they are and which period it is, so it never has to be stored and is the same every time
+func assignedQuests(guildID int, userID int, period QuestPeriod, key string) ([]Quest) {
+	pool := []Quest{}
+	for _, quest := range getQuests() {
+		if quest.Period == period.Name {
+			pool = append(pool, quest)
+		}
+	}
+	if len(pool) <= period.Count {
+		return pool
+	}
+	hash := fnv.New64a()
+	hash.Write([]byte(fmt.Sprintf("%d:%d:%s:%s", guildID, userID, period.Name, key)))
+	random := rand.New(rand.NewSource(int64(hash.Sum64())))
+	assigned := []Quest{}
+	for _, i := range random.Perm(len(pool))[:period.Count] {
+		assigned = append(assigned, pool[i])
+	}
+	return assigned
+}
+
+// Not a command
+// Works out how much an event counts towards a quest, which is 0 if it doesn't count at all
+func questAmount(quest Quest, event GameEvent) (int64) {
+	if quest.Event != event.Type || (quest.Item != "" && quest.Item != event.Item) || (quest.Correct && !event.Correct) {
+		return 0
+	}
+	if quest.ByAmount {
+		return event.Amount
+	}
+	return 1
+}
+
+// Not a command
+// Counts the event towards any of the user's current quests that it matches
+func questsForEvent(ctx context.Context, client *mongo.Client, event GameEvent) {
+	now := time.Now()
+	for _, period := range questPeriods {
+		key := period.Key(now)
+		for _, quest := range assignedQuests(event.GuildID, event.UserID, period, key) {
+			amount := questAmount(quest, event)
+			if amount <= 0 {
+				continue
+			}
+			err := progressQuest(ctx, client, event, period, key, quest, amount)
+			if err != nil {
+				fmt.Printf("Error occurred while updating quest progress! %s\n", err)
+			}
+		}
+	}
+}
+
+// Not a command
+// Adds to a user's progress on a quest, and tells them when it is done
+func progressQuest(ctx context.Context, client *mongo.Client, event GameEvent, period QuestPeriod, key string, quest Quest, amount int64) (error) {
+	userCollection := client.Database(strconv.Itoa(event.GuildID)).Collection("Users")
+	field := "quests." + period.Name
+
+	// Clear out last period's progress the first time something counts in a new one
+	_, err := userCollection.UpdateOne(
+		ctx,
+		bson.D{
+			{Key: "user_id", Value: event.UserID},
+			{Key: "guild_id", Value: event.GuildID},
+			{Key: field + ".key", Value: bson.D{{Key: "$ne", Value: key}}},
+		},
+		bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: field, Value: bson.D{
+					{Key: "key", Value: key},
+					{Key: "progress", Value: bson.D{}},
+					{Key: "claimed", Value: bson.A{}},
+				}},
+			}},
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	var user bson.Raw
+	err = userCollection.FindOneAndUpdate(
+		ctx,
+		bson.D{
+			{Key: "user_id", Value: event.UserID},
+			{Key: "guild_id", Value: event.GuildID},
+			{Key: field + ".key", Value: key},
+		},
+		bson.D{
+			{Key: "$inc", Value: bson.D{
+				{Key: field + ".progress." + quest.ID, Value: amount},
+			}},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		// Not playing
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// Only the event that finishes the quest tells the user
+	progress := questProgress(user, period, key, quest)
+	if progress >= quest.Goal && progress-amount < quest.Goal {
+		notifyEvent(event, fmt.Sprintf("📜 Quest complete: **%s**! Use `mary quests claim` to collect %d coins.", quest.Description, quest.Reward))
+	}
+	return nil
+}
+
+// Not a command
+// Gets a user's progress on a quest and whether they have claimed it, from their user document
+func questStatus(user bson.Raw, period QuestPeriod, key string, quest Quest) (int64, bool) {
+	storedKey, err := user.LookupErr("quests", period.Name, "key")
+	if storedKey, ok := storedKey.StringValueOK(); err != nil || !ok || storedKey != key {
+		return 0, false
+	}
+	claimed := false
+	claimedList, err := user.LookupErr("quests", period.Name, "claimed")
+	if err == nil {
+		values, _ := claimedList.Array().Values()
+		for _, value := range values {
+			if id, ok := value.StringValueOK(); ok && id == quest.ID {
+				claimed = true
+			}
+		}
+	}
+	value, err := user.LookupErr("quests", period.Name, "progress", quest.ID)
+	if err != nil {
+		return 0, claimed
+	}
+	progress, _ := value.AsInt64OK()
+	return progress, claimed
+}
+
+// Not a command
+// Gets a user's progress on a quest
+func questProgress(user bson.Raw, period QuestPeriod, key string, quest Quest) (int64) {
+	progress, _ := questStatus(user, period, key, quest)
+	return progress
+}
+
+// mary quests -> shows your daily and weekly quests and how far along they are
+func Quests(mongoURI string, guildID int, guildName string, userID int, userName string) (string, *discordgo.MessageEmbed) {
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error()), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error()), nil
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	// Check if user exists in database
+	res := IsPlaying(ctx, client, guildID, guildName, userID, userName)
+	if res != "" {
+		return res, nil
+	}
+
+	var user bson.Raw
+	err = client.Database(strconv.Itoa(guildID)).Collection("Users").FindOne(ctx, bson.M{"guild_id": guildID, "user_id": userID}).Decode(&user)
+	if err != nil {
+		fmt.Printf("Error occurred while finding user in database! %s\n", err)
+		return "Error occurred while finding user in database! " + strings.Title(err.Error()), nil
+	}
+
+	now := time.Now()
+	embed := &discordgo.MessageEmbed{
+		Title: userName + "'s Quests",
+		Color: 0xffc0cb,
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "Finished quests can be collected with mary quests claim",
+		},
+	}
+	for _, period := range questPeriods {
+		key := period.Key(now)
+		lines := []string{}
+		for _, quest := range assignedQuests(guildID, userID, period, key) {
+			progress, claimed := questStatus(user, period, key, quest)
+			if progress > quest.Goal {
+				progress = quest.Goal
+			}
+			status := "⬜"
+			if claimed {
+				status = "✅"
+			} else if progress >= quest.Goal {
+				status = "🎁"
+			}
+			reward := fmt.Sprintf("%d coins", quest.Reward)
+			if quest.Crate != "" {
+				reward += " and a " + itemEmoji(quest.Crate) + " " + strings.Title(quest.Crate)
+			}
+			lines = append(lines, fmt.Sprintf("%s **%s** - %s\n%s %d/%d", status, quest.Description, reward, ProgressBar(progress, quest.Goal), progress, quest.Goal))
+		}
+		if len(lines) == 0 {
+			lines = append(lines, "No quests right now!")
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  fmt.Sprintf("%s Quests (new quests <t:%d:R>)", strings.Title(period.Name), period.Reset(now).Unix()),
+			Value: strings.Join(lines, "\n"),
+		})
+	}
+	return "", embed
+}
+
+// mary quests claim -> collects the rewards for every finished quest
+func ClaimQuests(mongoURI string, guildID int, guildName string, userID int, userName string) (string) {
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error())
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	// Check if user exists in database
+	res := IsPlaying(ctx, client, guildID, guildName, userID, userName)
+	if res != "" {
+		return res
+	}
+
+	userCollection := client.Database(strconv.Itoa(guildID)).Collection("Users")
+	now := time.Now()
+	total := int64(0)
+	claimed := []string{}
+	crates := []string{}
+	for _, period := range questPeriods {
+		key := period.Key(now)
+		field := "quests." + period.Name
+		for _, quest := range assignedQuests(guildID, userID, period, key) {
+			// The filter makes sure the quest is finished and hasn't been claimed, so claiming twice at once only pays once
+			result, err := userCollection.UpdateOne(
+				ctx,
+				bson.D{
+					{Key: "user_id", Value: userID},
+					{Key: "guild_id", Value: guildID},
+					{Key: field + ".key", Value: key},
+					{Key: field + ".claimed", Value: bson.D{{Key: "$ne", Value: quest.ID}}},
+					{Key: field + ".progress." + quest.ID, Value: bson.D{{Key: "$gte", Value: quest.Goal}}},
+				},
+				bson.D{
+					{Key: "$addToSet", Value: bson.D{
+						{Key: field + ".claimed", Value: quest.ID},
+					}},
+					{Key: "$inc", Value: bson.D{
+						{Key: "balance", Value: quest.Reward},
+					}},
+				},
+			)
+			if err != nil {
+				fmt.Printf("Error occurred while updating database! %s\n", err)
+				return "Error occurred while updating database! " + strings.Title(err.Error())
+			}
+			if result.ModifiedCount > 0 {
+				total += quest.Reward
+				addSeasonEarnings(ctx, userCollection, guildID, userID, int64(quest.Reward))
+				claimed = append(claimed, quest.Description)
+				if quest.Crate != "" {
+					err = addItem(ctx, userCollection, guildID, userID, quest.Crate, 1)
+					if err != nil {
+						fmt.Printf("Error occurred while giving %s to user %d! %s\n", quest.Crate, userID, err)
+						continue
+					}
+					crates = append(crates, strings.Title(quest.Crate))
+				}
+			}
+		}
+	}
+	if len(claimed) == 0 {
+		return "You don't have any finished quests to claim! Check your progress with `mary quests`."
+	}
+	content := fmt.Sprintf("You claimed %d coins for finishing: %s!", total, strings.Join(claimed, ", "))
+	if len(crates) > 0 {
+		content += " You also got: " + strings.Join(crates, ", ") + ". Open them with `mary open [crate]`!"
+	}
+	return content
+}
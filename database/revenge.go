@@ -0,0 +1,222 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// After being robbed, the victim has this long to get their own back
+const revengeWindow = 15 * time.Minute
+
+// Getting revenge makes the crime more likely to work and the loot bigger, on top of skipping the cooldown
+const revengeChanceBonus = 0.15
+const revengeLootMultiplier = 1.5
+
+// Not a command
+// Checks whether a user can still get revenge on someone who robbed them
+func hasRevenge(user User, targetID int) (bool) {
+	return time.Now().Before(user.Revenge[strconv.Itoa(targetID)])
+}
+
+// Not a command
+// Uses up a user's revenge on someone, returning whether they had any left
+// Revenge only works once per robbery, so it can't be used to get round the cooldown over and over
+func takeRevenge(ctx context.Context, userCollection *mongo.Collection, guildID int, userID int, targetID int) (bool, error) {
+	field := "revenge." + strconv.Itoa(targetID)
+	result, err := userCollection.UpdateOne(
+		ctx,
+		bson.D{
+			{Key: "user_id", Value: userID},
+			{Key: "guild_id", Value: guildID},
+			{Key: field, Value: bson.D{{Key: "$gt", Value: time.Now()}}},
+		},
+		bson.D{
+			{Key: "$unset", Value: bson.D{
+				{Key: field, Value: ""},
+			}},
+		},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+// Not a command
+// Adds the revenge bonus to the loot from a robbery
+func revengeLoot(amount int64, revenge bool) (int64) {
+	if !revenge {
+		return amount
+	}
+	return int64(float64(amount) * revengeLootMultiplier)
+}
+
+// Not a command
+// Deals with the victim's side of a robbery: opens their revenge window and tells them about it if they've opted in
+// Getting revenge doesn't open a window the other way, otherwise two people could rob each other with no cooldown forever
+func reportRobbery(ctx context.Context, userCollection *mongo.Collection, guildID int, guildName string, channelID string, robber User, victim User, how string, amount int64, revenge bool) {
+	if revenge {
+		return
+	}
+	until := time.Now().Add(revengeWindow)
+	_, err := userCollection.UpdateOne(
+		ctx,
+		bson.D{
+			{Key: "user_id", Value: victim.UserID},
+			{Key: "guild_id", Value: guildID},
+		},
+		bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "revenge." + strconv.Itoa(robber.UserID), Value: until},
+			}},
+		},
+	)
+	if err != nil {
+		fmt.Printf("Error occurred while opening revenge window for user %d! %s\n", victim.UserID, err)
+	}
+	if !wantsRobberyNotifications(ctx, userCollection.Database().Client(), victim) {
+		return
+	}
+
+	where := "**" + guildName + "**"
+	if channelID != "" {
+		where = "<#" + channelID + "> in " + where
+	}
+	robberName := robber.UserName
+	if robberName == "" {
+		robberName = "<@" + strconv.Itoa(robber.UserID) + ">"
+	}
+	notifyUser(victim.UserID, channelID, fmt.Sprintf(
+		"🚨 **%s** %s and took %d coins from you in %s! For the next %s you can get revenge with `mary rob` or a weapon: it skips your cooldown and has a better chance of working. Turn these messages off with `mary notify off`, or `mary notify off here` for just this server.",
+		robberName, how, amount, where, formatCooldown(revengeWindow),
+	))
+}
+
+// Settings that follow a user across every server, kept in the UserSettings collection of the global database next to GlobalPlayers
+// Users only have one once they've changed something
+type UserSettings struct {
+	UserID        int  `bson:"user_id"`
+	NotifyRobbery bool `bson:"notify_robbery"` // DM them when they get robbed in any server
+}
+
+// Not a command
+// Gets a user's global settings, returning false if they haven't got any yet
+func getUserSettings(ctx context.Context, client *mongo.Client, userID int) (UserSettings, bool) {
+	var settings UserSettings
+	err := client.Database(globalDatabase).Collection("UserSettings").FindOne(ctx, bson.M{"user_id": userID}).Decode(&settings)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			fmt.Printf("Error occurred while finding settings for user %d! %s\n", userID, err)
+		}
+		return settings, false
+	}
+	return settings, true
+}
+
+// Not a command
+// Works out whether to DM a user when they get robbed in this server
+// A setting for this server wins, then their global setting, then the per-server one from before the setting was global
+func wantsRobberyNotifications(ctx context.Context, client *mongo.Client, user User) (bool) {
+	if user.NotifyRobberyHere != nil {
+		return *user.NotifyRobberyHere
+	}
+	if settings, ok := getUserSettings(ctx, client, user.UserID); ok {
+		return settings.NotifyRobbery
+	}
+	return user.NotifyRobbery
+}
+
+// mary notify [on/off] [optional: here] -> turns DMs about being robbed on or off everywhere, or just in this server, or shows whether they're on
+// mary notify default here -> goes back to the global setting in this server
+func SetRobberyNotifications(mongoURI string, guildID int, guildName string, userID int, userName string, setting string, here bool) (string) {
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error())
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	// Check if user exists in database
+	res := IsPlaying(ctx, client, guildID, guildName, userID, userName)
+	if res != "" {
+		return res
+	}
+
+	userCollection := client.Database(strconv.Itoa(guildID)).Collection("Users")
+	if setting == "" {
+		var user User
+		err = userCollection.FindOne(ctx, bson.M{"guild_id": guildID, "user_id": userID}).Decode(&user)
+		if err != nil {
+			fmt.Printf("Error occurred while finding user in database! %s\n", err)
+			return "Error occurred while finding user in database! " + strings.Title(err.Error())
+		}
+		if user.NotifyRobberyHere != nil && *user.NotifyRobberyHere {
+			return "Robbery notifications are on in this server, whatever your other servers are set to. Use `mary notify default here` to follow your global setting again."
+		} else if user.NotifyRobberyHere != nil {
+			return "Robbery notifications are off in this server, whatever your other servers are set to. Use `mary notify default here` to follow your global setting again."
+		}
+		if wantsRobberyNotifications(ctx, client, user) {
+			return "Robbery notifications are on. I'll DM you whenever someone robs you in any server. Use `mary notify off` to stop them, or `mary notify off here` to stop them in just this server."
+		}
+		return "Robbery notifications are off. Use `mary notify on` and I'll DM you whenever someone robs you in any server, or `mary notify on here` for just this server."
+	}
+	if setting != "on" && setting != "off" && !(setting == "default" && here) {
+		return "Please use `mary notify on`, `mary notify off`, or add `here` to only change this server!"
+	}
+
+	// A setting for this server is kept with the player in this server
+	if here {
+		update := bson.D{{Key: "$set", Value: bson.D{{Key: "notify_robbery_here", Value: setting == "on"}}}}
+		if setting == "default" {
+			update = bson.D{{Key: "$unset", Value: bson.D{{Key: "notify_robbery_here", Value: ""}}}}
+		}
+		_, err = userCollection.UpdateOne(ctx, bson.M{"guild_id": guildID, "user_id": userID}, update)
+		if err != nil {
+			fmt.Printf("Error occurred while updating database! %s\n", err)
+			return "Error occurred while updating database! " + strings.Title(err.Error())
+		}
+		switch setting {
+		case "on":
+			return "Robbery notifications are now on in this server. I'll DM you who robbed you, how much they took and where, so you can get revenge!"
+		case "off":
+			return "Robbery notifications are now off in this server."
+		}
+		return "This server now follows your global robbery notification setting."
+	}
+
+	_, err = client.Database(globalDatabase).Collection("UserSettings").UpdateOne(
+		ctx,
+		bson.M{"user_id": userID},
+		bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "notify_robbery", Value: setting == "on"},
+			}},
+		},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+		return "Error occurred while updating database! " + strings.Title(err.Error())
+	}
+	if setting == "on" {
+		return "Robbery notifications are now on in every server. I'll DM you who robbed you, how much they took and where, so you can get revenge!"
+	}
+	return "Robbery notifications are now off in every server."
+}
@@ -0,0 +1,46 @@
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// Jobs that run in the background on a fixed interval, like moving stock prices
+type scheduledJob struct {
+	name     string
+	interval time.Duration
+	run      func(mongoURI string)
+}
+
+var scheduledJobs []scheduledJob
+
+// Registers a job to run every interval once the scheduler starts
+func Schedule(name string, interval time.Duration, run func(mongoURI string)) {
+	scheduledJobs = append(scheduledJobs, scheduledJob{name: name, interval: interval, run: run})
+}
+
+// Starts every registered job in its own goroutine
+// Each job runs once straight away, then on every tick of its interval
+func StartScheduler(mongoURI string) {
+	for _, job := range scheduledJobs {
+		go func(job scheduledJob) {
+			ticker := time.NewTicker(job.interval)
+			defer ticker.Stop()
+			for {
+				runJob(job, mongoURI)
+				<-ticker.C
+			}
+		}(job)
+	}
+}
+
+// Not a command
+// Runs a job, making sure a panic in one job doesn't take down Mary
+func runJob(job scheduledJob, mongoURI string) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Printf("Scheduled job %s panicked! %v\n", job.name, r)
+		}
+	}()
+	job.run(mongoURI)
+}
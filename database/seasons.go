@@ -0,0 +1,625 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"github.com/bwmarrin/discordgo"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Seasons rank users by what they earned during a week or month instead of their whole balance
+// Coins earned by playing are counted in season_earned as they're paid out, and the count starts again at 0 each season
+// Coins that only move around (pay, trades, escrow and refunds) or come from selling things back or cashing out stocks at cost don't count
+// Seasons are kept in the Seasons collection of the server's database, and ended ones stay there as the archive
+
+type Season struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	GuildID   int                `bson:"guild_id"`
+	Period    string             `bson:"period"` // weekly, monthly or reset
+	Number    int                `bson:"number"`
+	Status    string             `bson:"status"` // active, ending while it is being rewarded, or ended
+	StartedAt time.Time          `bson:"started_at"`
+	EndsAt    time.Time          `bson:"ends_at"`
+	EndedAt   time.Time          `bson:"ended_at"`
+	Results   []SeasonResult     `bson:"results"` // The final top 10
+	Snapshot  []SeasonResult     `bson:"snapshot,omitempty"` // Every balance before an economy reset
+}
+
+type SeasonResult struct {
+	Rank     int    `bson:"rank"`
+	UserID   int    `bson:"user_id"`
+	UserName string `bson:"user_name"`
+	Earnings int64  `bson:"earnings"`
+	Reward   int64  `bson:"reward"`
+	Badge    string `bson:"badge,omitempty"`
+}
+
+type seasonPeriod struct {
+	Name    string
+	Rewards []int64 // Coins for 1st, 2nd and 3rd
+	Badges  []string
+}
+
+var seasonPeriods = map[string]seasonPeriod{
+	"weekly":  {"Weekly", []int64{1000, 500, 250}, []string{"🥇", "🥈", "🥉"}},
+	"monthly": {"Monthly", []int64{5000, 2500, 1000}, []string{"🏆", "🥈", "🥉"}},
+	// Nobody has coins right after a reset, so the top of the old economy only get badges
+	"reset": {"Economy", []int64{0, 0, 0}, []string{"👑", "💎", "⭐"}},
+}
+
+const seasonResultsKept = 10
+
+// The seasons that count earnings as they happen
+var earningSeasons = []string{"weekly", "monthly"}
+
+// Not a command
+// Counts coins a user earned by playing towards the current weekly and monthly seasons
+// Call it where income is paid: daily, beg, gambling, trivia, crime, quests, achievements, and sales and stock profits
+// Bets, entry fees and fines are passed as negative amounts, so gambling and crime count what was won minus what was lost
+func addSeasonEarnings(ctx context.Context, userCollection *mongo.Collection, guildID int, userID int, amount int64) {
+	if amount == 0 {
+		return
+	}
+	earned := bson.D{}
+	for _, period := range earningSeasons {
+		earned = append(earned, bson.E{Key: "season_earned." + period, Value: amount})
+	}
+	_, err := userCollection.UpdateOne(
+		ctx,
+		bson.M{"guild_id": guildID, "user_id": userID},
+		bson.D{{Key: "$inc", Value: earned}},
+	)
+	if err != nil {
+		fmt.Printf("Error occurred while counting season earnings for user %d! %s\n", userID, err)
+	}
+}
+
+// Not a command
+// Works out when a season that starts at the given time ends
+// Weeks end on Monday at midnight UTC and months end on the 1st
+func seasonEnd(period string, start time.Time) (time.Time) {
+	start = start.UTC()
+	midnight := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	if period == "monthly" {
+		return time.Date(start.Year(), start.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	days := (8 - int(midnight.Weekday())) % 7
+	if days == 0 {
+		days = 7
+	}
+	return midnight.AddDate(0, 0, days)
+}
+
+// Not a command
+// Finds a season period by name, with weekly as the default
+func FindSeasonPeriod(name string) (string, bool) {
+	name = strings.ToLower(name)
+	switch name {
+	case "", "week", "weekly":
+		return "weekly", true
+	case "month", "monthly":
+		return "monthly", true
+	case "reset", "economy":
+		return "reset", true
+	}
+	return name, false
+}
+
+// Not a command
+// Gets the active season for a period, starting the first one if there isn't one yet
+func currentSeason(ctx context.Context, client *mongo.Client, guildID int, period string) (Season, error) {
+	seasonCollection := client.Database(strconv.Itoa(guildID)).Collection("Seasons")
+	var season Season
+	err := seasonCollection.FindOne(ctx, bson.M{"guild_id": guildID, "period": period, "status": "active"}).Decode(&season)
+	if err != mongo.ErrNoDocuments {
+		return season, err
+	}
+
+	// Carry on the numbering from the last season, if there was one
+	number := 1
+	var last Season
+	err = seasonCollection.FindOne(
+		ctx,
+		bson.M{"guild_id": guildID, "period": period},
+		options.FindOne().SetSort(bson.D{{Key: "number", Value: -1}}),
+	).Decode(&last)
+	if err == nil {
+		number = last.Number + 1
+	} else if err != mongo.ErrNoDocuments {
+		return season, err
+	}
+	return startSeason(ctx, client, guildID, period, number)
+}
+
+// Not a command
+// Starts a new season, with everyone's earnings for it at 0
+func startSeason(ctx context.Context, client *mongo.Client, guildID int, period string, number int) (Season, error) {
+	now := time.Now()
+	season := Season{
+		GuildID:   guildID,
+		Period:    period,
+		Number:    number,
+		Status:    "active",
+		StartedAt: now,
+		EndsAt:    seasonEnd(period, now),
+		Results:   []SeasonResult{},
+	}
+
+	_, err := client.Database(strconv.Itoa(guildID)).Collection("Users").UpdateMany(
+		ctx,
+		bson.M{"guild_id": guildID},
+		bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "season_earned." + period, Value: int64(0)},
+			}},
+		},
+	)
+	if err != nil {
+		return season, err
+	}
+
+	result, err := client.Database(strconv.Itoa(guildID)).Collection("Seasons").InsertOne(ctx, season)
+	if err != nil {
+		return season, err
+	}
+	season.ID = result.InsertedID.(primitive.ObjectID)
+	return season, nil
+}
+
+// Not a command
+// Ranks users by their earnings this season
+// Users who haven't earned anything since the season started have no count yet, which is the same as 0
+func seasonStandings(ctx context.Context, client *mongo.Client, guildID int, period string, limit int) ([]SeasonResult, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"guild_id": guildID}}},
+		{{Key: "$project", Value: bson.M{
+			"user_id":   1,
+			"user_name": 1,
+			"earnings":  bson.M{"$ifNull": bson.A{"$season_earned." + period, 0}},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "earnings", Value: -1}, {Key: "user_id", Value: 1}}}},
+	}
+	if limit > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$limit", Value: limit}})
+	}
+	cursor, err := client.Database(strconv.Itoa(guildID)).Collection("Users").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var results []SeasonResult
+	err = cursor.All(ctx, &results)
+	for i := range results {
+		results[i].Rank = i + 1
+	}
+	return results, err
+}
+
+// Not a command
+// Gives the top 3 their coins and badges
+// Nobody is rewarded for a season where they didn't earn anything
+func rewardSeason(ctx context.Context, client *mongo.Client, guildID int, season Season, results []SeasonResult) ([]SeasonResult, error) {
+	period := seasonPeriods[season.Period]
+	userCollection := client.Database(strconv.Itoa(guildID)).Collection("Users")
+	for i := range results {
+		if i >= len(period.Rewards) || (season.Period != "reset" && results[i].Earnings <= 0) {
+			break
+		}
+		results[i].Reward = period.Rewards[i]
+		results[i].Badge = fmt.Sprintf("%s %s Season %d #%d", period.Badges[i], period.Name, season.Number, i+1)
+		_, err := userCollection.UpdateOne(
+			ctx,
+			bson.M{"guild_id": guildID, "user_id": results[i].UserID},
+			bson.D{
+				{Key: "$inc", Value: bson.D{
+					{Key: "balance", Value: results[i].Reward},
+				}},
+				{Key: "$push", Value: bson.D{
+					{Key: "badges", Value: results[i].Badge},
+				}},
+			},
+		)
+		if err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+// Not a command
+// Shows the final standings of a season
+func seasonResultsEmbed(season Season) (*discordgo.MessageEmbed) {
+	period := seasonPeriods[season.Period]
+	lines := []string{}
+	for _, result := range season.Results {
+		line := fmt.Sprintf("**%d.** %s: %+d coins", result.Rank, result.UserName, result.Earnings)
+		if season.Period == "reset" {
+			line = fmt.Sprintf("**%d.** %s: %d coins", result.Rank, result.UserName, result.Earnings)
+		}
+		if result.Badge != "" {
+			line += " - " + result.Badge
+		}
+		if result.Reward > 0 {
+			line += fmt.Sprintf(" (+%d coins)", result.Reward)
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "Nobody played this season!")
+	}
+	return &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("%s Season %d Results", period.Name, season.Number),
+		Description: strings.Join(lines, "\n"),
+		Color:       0xffc0cb,
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: fmt.Sprintf("%s to %s", season.StartedAt.UTC().Format("Jan 2, 2006"), season.EndedAt.UTC().Format("Jan 2, 2006")),
+		},
+	}
+}
+
+// Not a command
+// Ends a season: rewards the winners, archives the results, announces them and starts the next one
+func endSeason(ctx context.Context, client *mongo.Client, guildID int, season Season) (error) {
+	seasonCollection := client.Database(strconv.Itoa(guildID)).Collection("Seasons")
+
+	// Claim the season first so it can never be rewarded twice
+	result, err := seasonCollection.UpdateOne(
+		ctx,
+		bson.M{"_id": season.ID, "status": "active"},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "status", Value: "ending"}}}},
+	)
+	if err != nil || result.ModifiedCount == 0 {
+		return err
+	}
+
+	results, err := seasonStandings(ctx, client, guildID, season.Period, seasonResultsKept)
+	if err != nil {
+		return err
+	}
+	results, err = rewardSeason(ctx, client, guildID, season, results)
+	if err != nil {
+		return err
+	}
+
+	season.Status = "ended"
+	season.EndedAt = time.Now()
+	season.Results = results
+	_, err = seasonCollection.UpdateOne(
+		ctx,
+		bson.M{"_id": season.ID},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "status", Value: season.Status},
+			{Key: "ended_at", Value: season.EndedAt},
+			{Key: "results", Value: season.Results},
+		}}},
+	)
+	if err != nil {
+		return err
+	}
+	announce(getSettings(ctx, client, guildID).AnnounceChannel, seasonResultsEmbed(season))
+
+	// Rewards are paid straight into balances, so they don't count as anyone's earnings
+	_, err = startSeason(ctx, client, guildID, season.Period, season.Number+1)
+	return err
+}
+
+// Ends any weekly or monthly seasons that are over and starts the next ones, in every server
+// Run by the scheduler every minute
+func RolloverSeasons(mongoURI string) {
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx)
+
+	guildIDs, err := getGuildIDs(ctx, client)
+	if err != nil {
+		fmt.Printf("Error occurred while listing server databases! %s\n", err)
+		return
+	}
+	for _, guildID := range guildIDs {
+		for _, period := range []string{"weekly", "monthly"} {
+			season, err := currentSeason(ctx, client, guildID, period)
+			if err != nil {
+				fmt.Printf("Error occurred while getting season for server %d! %s\n", guildID, err)
+				continue
+			}
+			if time.Now().Before(season.EndsAt) {
+				continue
+			}
+			err = endSeason(ctx, client, guildID, season)
+			if err != nil {
+				fmt.Printf("Error occurred while ending season for server %d! %s\n", guildID, err)
+			}
+		}
+	}
+}
+
+// mary season [optional: weekly/monthly] -> shows who has earned the most this season
+func SeasonStandings(mongoURI string, guildID int, guildName string, userID int, userName string, periodName string) (string, *discordgo.MessageEmbed) {
+	period, ok := FindSeasonPeriod(periodName)
+	if !ok || period == "reset" {
+		return "Please choose a weekly or monthly season!", nil
+	}
+
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error()), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error()), nil
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	// Check if user exists in database
+	res := IsPlaying(ctx, client, guildID, guildName, userID, userName)
+	if res != "" {
+		return res, nil
+	}
+
+	season, err := currentSeason(ctx, client, guildID, period)
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return "Error occurred while selecting from database! " + strings.Title(err.Error()), nil
+	}
+	standings, err := seasonStandings(ctx, client, guildID, period, 0)
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return "Error occurred while selecting from database! " + strings.Title(err.Error()), nil
+	}
+
+	rewards := seasonPeriods[period].Rewards
+	lines := []string{}
+	var own SeasonResult
+	for _, result := range standings {
+		if result.UserID == userID {
+			own = result
+		}
+		if result.Rank <= seasonResultsKept {
+			lines = append(lines, fmt.Sprintf("**%d.** %s: %+d coins", result.Rank, result.UserName, result.Earnings))
+		}
+	}
+	embed := &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("%s Season %d", seasonPeriods[period].Name, season.Number),
+		Description: strings.Join(lines, "\n"),
+		Color:       0xffc0cb,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Ends", Value: fmt.Sprintf("<t:%d:R>", season.EndsAt.Unix()), Inline: true},
+			{Name: "Rewards", Value: fmt.Sprintf("%d / %d / %d coins and a badge", rewards[0], rewards[1], rewards[2]), Inline: true},
+			{Name: "You", Value: fmt.Sprintf("#%d with %+d coins", own.Rank, own.Earnings), Inline: true},
+		},
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "Earnings are coins earned by playing this season. Gambling and crime count what you won minus what you lost",
+		},
+	}
+	return "", embed
+}
+
+// mary season history [optional: weekly/monthly/reset] [optional: season number] -> shows the results of past seasons
+func SeasonHistory(mongoURI string, guildID int, periodName string, number int) (string, *discordgo.MessageEmbed) {
+	period, ok := FindSeasonPeriod(periodName)
+	if !ok {
+		return "Please choose weekly, monthly or reset seasons!", nil
+	}
+
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error()), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error()), nil
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	seasonCollection := client.Database(strconv.Itoa(guildID)).Collection("Seasons")
+	if number > 0 {
+		var season Season
+		err = seasonCollection.FindOne(ctx, bson.M{"guild_id": guildID, "period": period, "number": number, "status": "ended"}).Decode(&season)
+		if err == mongo.ErrNoDocuments {
+			return fmt.Sprintf("%s season %d hasn't ended yet!", seasonPeriods[period].Name, number), nil
+		} else if err != nil {
+			fmt.Printf("Error occurred while selecting from database! %s\n", err)
+			return "Error occurred while selecting from database! " + strings.Title(err.Error()), nil
+		}
+		return "", seasonResultsEmbed(season)
+	}
+
+	cursor, err := seasonCollection.Find(
+		ctx,
+		bson.M{"guild_id": guildID, "period": period, "status": "ended"},
+		options.Find().SetSort(bson.D{{Key: "number", Value: -1}}).SetLimit(10).SetProjection(bson.M{"snapshot": 0}),
+	)
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return "Error occurred while selecting from database! " + strings.Title(err.Error()), nil
+	}
+	var seasons []Season
+	err = cursor.All(ctx, &seasons)
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return "Error occurred while selecting from database! " + strings.Title(err.Error()), nil
+	}
+	if len(seasons) == 0 {
+		return fmt.Sprintf("No %s seasons have ended yet!", strings.ToLower(seasonPeriods[period].Name)), nil
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title: fmt.Sprintf("Past %s Seasons", seasonPeriods[period].Name),
+		Color: 0xffc0cb,
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "mary season history [weekly/monthly/reset] [season number] for the full results",
+		},
+	}
+	for _, season := range seasons {
+		winner := "Nobody"
+		if len(season.Results) > 0 {
+			winner = fmt.Sprintf("%s (%d coins)", season.Results[0].UserName, season.Results[0].Earnings)
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  fmt.Sprintf("Season %d - %s", season.Number, season.EndedAt.UTC().Format("Jan 2, 2006")),
+			Value: "Winner: " + winner,
+		})
+	}
+	return "", embed
+}
+
+// mary season channel #channel (admin only) -> sets where season results are announced
+func SetAnnounceChannel(mongoURI string, guildID int, channelID string) (string) {
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error())
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	err = updateSettings(ctx, client, guildID, bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "announce_channel", Value: channelID},
+		}},
+	})
+	if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+		return "Error occurred while updating database! " + strings.Title(err.Error())
+	}
+	if channelID == "" {
+		return "Season results won't be announced anymore."
+	}
+	return "Season results will be announced in <#" + channelID + ">!"
+}
+
+// mary season reset (admin only) -> archives everyone's balance and sets it back to 0
+func ResetEconomy(mongoURI string, guildID int) (string, *discordgo.MessageEmbed) {
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error()), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error()), nil
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	seasonCollection := client.Database(strconv.Itoa(guildID)).Collection("Seasons")
+	userCollection := client.Database(strconv.Itoa(guildID)).Collection("Users")
+
+	// The economy season that's ending started at the last reset, or at the beginning
+	season := Season{GuildID: guildID, Period: "reset", Number: 1, Status: "ended"}
+	var last Season
+	err = seasonCollection.FindOne(
+		ctx,
+		bson.M{"guild_id": guildID, "period": "reset"},
+		options.FindOne().SetSort(bson.D{{Key: "number", Value: -1}}).SetProjection(bson.M{"snapshot": 0}),
+	).Decode(&last)
+	if err == nil {
+		season.Number = last.Number + 1
+		season.StartedAt = last.EndedAt
+	} else if err != mongo.ErrNoDocuments {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return "Error occurred while selecting from database! " + strings.Title(err.Error()), nil
+	}
+
+	// Snapshot every balance before anything changes
+	snapshot, err := userCollection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"guild_id": guildID}}},
+		{{Key: "$project", Value: bson.M{"user_id": 1, "user_name": 1, "earnings": bson.M{"$ifNull": bson.A{"$balance", 0}}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "earnings", Value: -1}, {Key: "user_id", Value: 1}}}},
+	})
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return "Error occurred while selecting from database! " + strings.Title(err.Error()), nil
+	}
+	err = snapshot.All(ctx, &season.Snapshot)
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return "Error occurred while selecting from database! " + strings.Title(err.Error()), nil
+	}
+	for i := range season.Snapshot {
+		season.Snapshot[i].Rank = i + 1
+	}
+	season.EndedAt = time.Now()
+	season.Results = append([]SeasonResult{}, season.Snapshot...)
+	if len(season.Results) > seasonResultsKept {
+		season.Results = season.Results[:seasonResultsKept]
+	}
+
+	// Zero every balance, while weekly and monthly earnings carry on since they don't depend on it
+	_, err = userCollection.UpdateMany(
+		ctx,
+		bson.M{"guild_id": guildID},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "balance", Value: int64(0)},
+		}}},
+	)
+	if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+		return "Error occurred while updating database! " + strings.Title(err.Error()), nil
+	}
+
+	season.Results, err = rewardSeason(ctx, client, guildID, season, season.Results)
+	if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+	}
+	_, err = seasonCollection.InsertOne(ctx, season)
+	if err != nil {
+		fmt.Printf("Error occurred while inserting to database! %s\n", err)
+		return "Error occurred while inserting to database! " + strings.Title(err.Error()), nil
+	}
+
+	embed := seasonResultsEmbed(season)
+	announce(getSettings(ctx, client, guildID).AnnounceChannel, embed)
+	return "", embed
+}
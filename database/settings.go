@@ -0,0 +1,50 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Per-server settings that admins can change
+// Stored as a single document in the Settings collection of the server's database
+type GuildSettings struct {
+	GuildID          int    `bson:"guild_id"`
+	TriviaCategories []int  `bson:"trivia_categories"` // Empty means every category is allowed
+	GlobalOptOut     bool   `bson:"global_opt_out"` // Keeps this server's stats off the global leaderboard
+	AnnounceChannel  string `bson:"announce_channel"` // Where season winners and other news are posted
+	LevelAnnounce    string `bson:"level_announce"` // Empty announces level ups where they happen, "off" turns them off, otherwise a channel ID
+	LevelRoles       map[string]string `bson:"level_roles"` // Role IDs given out at each level
+	MarketFee        *float64 `bson:"market_fee"` // Percent of a listing's value charged to list it, nil for the default
+	MarketTax        *float64 `bson:"market_tax"` // Percent of each sale taken from the seller, nil for the default
+}
+
+// Not a command
+// Gets the settings for a server, returning the defaults if none have been saved yet
+func getSettings(ctx context.Context, client *mongo.Client, guildID int) (GuildSettings) {
+	settings := GuildSettings{GuildID: guildID}
+	settingsCollection := client.Database(strconv.Itoa(guildID)).Collection("Settings")
+	err := settingsCollection.FindOne(ctx, bson.M{"guild_id": guildID}).Decode(&settings)
+	if err != nil && err != mongo.ErrNoDocuments {
+		fmt.Printf("Error occurred while getting server settings! %s\n", err)
+	}
+	return settings
+}
+
+// Not a command
+// Applies an update to the settings document for a server, creating it if it doesn't exist
+func updateSettings(ctx context.Context, client *mongo.Client, guildID int, update bson.D) (error) {
+	settingsCollection := client.Database(strconv.Itoa(guildID)).Collection("Settings")
+	_, err := settingsCollection.UpdateOne(
+		ctx,
+		bson.D{
+			{Key: "guild_id", Value: guildID},
+		},
+		update,
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
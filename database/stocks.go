@@ -0,0 +1,682 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"github.com/bwmarrin/discordgo"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Data shared by every server, like the stock market, lives in this database
+const globalDatabase = "Global"
+
+// A ticker that can be traded on the simulated market
+// The defaults can be replaced with a JSON file of these, pointed to by the STOCK_CONFIG env var
+type StockConfig struct {
+	Ticker     string  `json:"ticker"`
+	Name       string  `json:"name"`
+	Sector     string  `json:"sector"`
+	Price      float64 `json:"price"`      // Starting price
+	Drift      float64 `json:"drift"`      // Expected return per simulated year, e.g. 0.08 for 8%
+	Volatility float64 `json:"volatility"` // Volatility per simulated year, e.g. 0.3 for 30%
+}
+
+var defaultStocks = []StockConfig{
+	{"MARY", "Mary Industries", "Tech", 100, 0.10, 0.35},
+	{"EVE", "Eve Robotics", "Tech", 80, 0.08, 0.45},
+	{"ELS", "Elsword Games", "Entertainment", 45, 0.06, 0.40},
+	{"CHOC", "Golden Ticket Chocolate", "Food", 25, 0.05, 0.20},
+	{"RING", "Forever Jewelers", "Retail", 60, 0.04, 0.25},
+	{"ZOOM", "Zoom Motors", "Auto", 150, 0.07, 0.30},
+	{"ARCH", "Archery Supply Co", "Retail", 20, 0.03, 0.30},
+	{"SHLD", "Shield Defense", "Defense", 120, 0.06, 0.20},
+}
+
+// A stock as it is stored in the Stocks collection of the global database
+// The collection is the quote cache: commands only read prices from here, and only TickMarket talks to the provider
+type Stock struct {
+	Ticker    string       `bson:"ticker"`
+	Name      string       `bson:"name"`
+	Sector    string       `bson:"sector"`
+	Price     float64      `bson:"price"`
+	History   []PricePoint `bson:"history"` // Most recent prices, oldest first
+	QuoteTime time.Time    `bson:"quote_time"` // When the price is from
+	Source    string       `bson:"source"` // Which provider the price came from
+	UpdatedAt time.Time    `bson:"updated_at"` // When the price was last fetched
+}
+
+type PricePoint struct {
+	Price float64   `bson:"price"`
+	Time  time.Time `bson:"time"`
+}
+
+// A user's position in a stock, stored in the portfolio array next to their inventory
+type Holding struct {
+	Ticker   string `bson:"ticker"`
+	Quantity int    `bson:"quantity"`
+	Cost     int64  `bson:"cost"` // Total coins paid for the shares still held
+}
+
+// How many prices are kept in each stock's history
+const stockHistoryLength = 200
+
+// Not a command
+// Gets the tradeable stocks, from the STOCK_CONFIG file if there is one
+func getStockConfigs() ([]StockConfig) {
+	path := os.Getenv("STOCK_CONFIG")
+	if path == "" {
+		return defaultStocks
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Printf("Error reading stock config! %s\n", err)
+		return defaultStocks
+	}
+	var configs []StockConfig
+	err = json.Unmarshal(data, &configs)
+	if err != nil || len(configs) == 0 {
+		fmt.Printf("Error parsing stock config! %v\n", err)
+		return defaultStocks
+	}
+	for i := range configs {
+		configs[i].Ticker = strings.ToUpper(configs[i].Ticker)
+	}
+	return configs
+}
+
+// How often the market moves, from the STOCK_TICK_INTERVAL env var (e.g. "5m")
+func MarketTickInterval() (time.Duration) {
+	interval, err := time.ParseDuration(os.Getenv("STOCK_TICK_INTERVAL"))
+	if err != nil || interval < time.Second {
+		return 5 * time.Minute
+	}
+	return interval
+}
+
+// Refreshes the price of every stock on the market from the market data provider
+// Run by the scheduler every MarketTickInterval
+func TickMarket(mongoURI string) {
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx)
+
+	stocks, err := getStocks(ctx, client)
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return
+	}
+	last := map[string]float64{}
+	for ticker, stock := range stocks {
+		last[ticker] = stock.Price
+	}
+
+	configs := getStockConfigs()
+	provider := getMarketDataProvider()
+	quotes, err := provider.Quotes(configs, last)
+	if err != nil {
+		// The old prices stay in place and become stale, which stops trading until the provider is back
+		fmt.Printf("Error occurred while getting stock quotes! %s\n", err)
+		return
+	}
+
+	configsByTicker := map[string]StockConfig{}
+	for _, config := range configs {
+		configsByTicker[config.Ticker] = config
+	}
+
+	stockCollection := client.Database(globalDatabase).Collection("Stocks")
+	now := time.Now()
+	for _, quote := range quotes {
+		config, ok := configsByTicker[quote.Ticker]
+		if !ok {
+			continue
+		}
+		update := bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "name", Value: config.Name},
+				{Key: "sector", Value: config.Sector},
+				{Key: "price", Value: quote.Price},
+				{Key: "quote_time", Value: quote.Time},
+				{Key: "source", Value: provider.Name()},
+				{Key: "updated_at", Value: now},
+			}},
+		}
+		// Real markets repeat the same quote while they're closed, which shouldn't fill up the history
+		if quote.Time.After(stocks[quote.Ticker].QuoteTime) {
+			update = append(update, bson.E{Key: "$push", Value: bson.D{
+				{Key: "history", Value: bson.D{
+					{Key: "$each", Value: bson.A{PricePoint{Price: quote.Price, Time: quote.Time}}},
+					{Key: "$slice", Value: -stockHistoryLength},
+				}},
+			}})
+		}
+
+		_, err = stockCollection.UpdateOne(
+			ctx,
+			bson.D{
+				{Key: "ticker", Value: quote.Ticker},
+			},
+			update,
+			options.Update().SetUpsert(true),
+		)
+		if err != nil {
+			fmt.Printf("Error occurred while updating database! %s\n", err)
+		}
+	}
+
+	// Fill any orders the new prices have reached
+	stocks, err = getStocks(ctx, client)
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return
+	}
+	matchOrders(ctx, client, stocks)
+	runMaryStrategies(ctx, client, stocks)
+}
+
+// Not a command
+// Gets every stock on the market, keyed by ticker
+func getStocks(ctx context.Context, client *mongo.Client) (map[string]Stock, error) {
+	cursor, err := client.Database(globalDatabase).Collection("Stocks").Find(ctx, bson.D{})
+	if err != nil {
+		return nil, err
+	}
+	var stocks []Stock
+	err = cursor.All(ctx, &stocks)
+	if err != nil {
+		return nil, err
+	}
+	ret := map[string]Stock{}
+	for _, stock := range stocks {
+		ret[stock.Ticker] = stock
+	}
+	return ret, nil
+}
+
+// Not a command
+// Gets a single stock by its ticker
+func getStock(ctx context.Context, client *mongo.Client, ticker string) (Stock, string) {
+	var stock Stock
+	err := client.Database(globalDatabase).Collection("Stocks").FindOne(ctx, bson.M{"ticker": strings.ToUpper(ticker)}).Decode(&stock)
+	if err == mongo.ErrNoDocuments {
+		return stock, "That stock doesn't exist! Use `mary stocks` to see the market."
+	} else if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return stock, "Error occurred while selecting from database! " + strings.Title(err.Error())
+	}
+	return stock, ""
+}
+
+// Not a command
+// Tells the user why they can't trade a stock right now
+func staleMessage(stock Stock) (string) {
+	if stock.QuoteTime.IsZero() {
+		return fmt.Sprintf("%s doesn't have a price yet! Please try again in a few minutes.", stock.Ticker)
+	}
+	return fmt.Sprintf("The price of %s is out of date (last updated <t:%d:R>), so it can't be traded right now! Please try again later.", stock.Ticker, stock.UpdatedAt.Unix())
+}
+
+// Not a command
+// Formats a change between two prices, e.g. "+1.50 (+2.31%)"
+func formatPriceChange(from float64, to float64) (string) {
+	if from == 0 {
+		return "0.00 (0.00%)"
+	}
+	return fmt.Sprintf("%+.2f (%+.2f%%)", to-from, (to-from)/from*100)
+}
+
+// mary stocks -> shows every stock on the market
+func StockList(mongoURI string) (string, *discordgo.MessageEmbed) {
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error()), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error()), nil
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	stocks, err := getStocks(ctx, client)
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return "Error occurred while selecting from database! " + strings.Title(err.Error()), nil
+	}
+	if len(stocks) == 0 {
+		return "The market hasn't opened yet! Try again in a few minutes.", nil
+	}
+
+	tickers := []string{}
+	for ticker := range stocks {
+		tickers = append(tickers, ticker)
+	}
+	sort.Strings(tickers)
+
+	embed := &discordgo.MessageEmbed{
+		Title: "Stock Market",
+		Color: 0xffc0cb,
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "mary stock info [ticker] for more details",
+		},
+	}
+	for _, ticker := range tickers {
+		stock := stocks[ticker]
+		change := "0.00 (0.00%)"
+		if len(stock.History) > 1 {
+			change = formatPriceChange(stock.History[len(stock.History)-2].Price, stock.Price)
+		}
+		if isStale(stock) {
+			change += "\nOut of date, trading paused"
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:   fmt.Sprintf("%s - %s", stock.Ticker, stock.Name),
+			Value:  fmt.Sprintf("%.2f coins\n%s", stock.Price, change),
+			Inline: true,
+		})
+	}
+	return "", embed
+}
+
+// mary stock info [ticker] -> shows the price and recent performance of a stock
+func StockInfo(mongoURI string, ticker string) (string, *discordgo.MessageEmbed) {
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error()), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error()), nil
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	stock, errMsg := getStock(ctx, client, ticker)
+	if errMsg != "" {
+		return errMsg, nil
+	}
+
+	// Work out the high and low over the kept history
+	high, low := stock.Price, stock.Price
+	for _, point := range stock.History {
+		high = math.Max(high, point.Price)
+		low = math.Min(low, point.Price)
+	}
+	lastChange, periodChange := "0.00 (0.00%)", "0.00 (0.00%)"
+	if len(stock.History) > 1 {
+		lastChange = formatPriceChange(stock.History[len(stock.History)-2].Price, stock.Price)
+		periodChange = formatPriceChange(stock.History[0].Price, stock.Price)
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("%s - %s", stock.Ticker, stock.Name),
+		Description: "Sector: " + stock.Sector,
+		Color:       0xffc0cb,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Price", Value: fmt.Sprintf("%.2f coins", stock.Price), Inline: true},
+			{Name: "Last Tick", Value: lastChange, Inline: true},
+			{Name: fmt.Sprintf("Last %d Ticks", len(stock.History)), Value: periodChange, Inline: true},
+			{Name: "High", Value: fmt.Sprintf("%.2f coins", high), Inline: true},
+			{Name: "Low", Value: fmt.Sprintf("%.2f coins", low), Inline: true},
+		},
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "Price from " + stock.Source,
+		},
+		Timestamp: stock.QuoteTime.Format(time.RFC3339),
+	}
+	if isStale(stock) {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  "Trading Paused",
+			Value: "This price is out of date, so the stock can't be bought or sold until it is updated.",
+		})
+	}
+	return "", embed
+}
+
+// mary stock buy [ticker] [amount] -> buys shares at the market price
+func BuyStock(mongoURI string, guildID int, guildName string, userID int, userName string, ticker string, quantity int) (string) {
+	if quantity < 1 {
+		return "Please specify a positive number of shares!"
+	}
+
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error())
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	// Check if user exists in database
+	res := IsPlaying(ctx, client, guildID, guildName, userID, userName)
+	if res != "" {
+		return res
+	}
+
+	stock, errMsg := getStock(ctx, client, ticker)
+	if errMsg != "" {
+		return errMsg
+	}
+	if isStale(stock) {
+		return staleMessage(stock)
+	}
+
+	userCollection := client.Database(strconv.Itoa(guildID)).Collection("Users")
+	cost, errMsg := buyShares(ctx, userCollection, guildID, userID, stock, quantity)
+	if errMsg != "" {
+		return errMsg
+	}
+	recordEvent(ctx, client, GameEvent{GuildID: guildID, UserID: userID, Type: "stock_buy", Item: stock.Ticker, Amount: int64(quantity)})
+	return fmt.Sprintf("You bought %d shares of %s at %.2f coins each for %d coins!", quantity, stock.Ticker, stock.Price, cost)
+}
+
+// Not a command
+// Takes the cost of the shares from the user's balance and adds them to their portfolio
+// Returns the cost, or an error message if the user can't afford it
+func buyShares(ctx context.Context, userCollection *mongo.Collection, guildID int, userID int, stock Stock, quantity int) (int64, string) {
+	// Round the cost up so that buying and selling straight away never makes money
+	cost := int64(math.Ceil(stock.Price * float64(quantity)))
+
+	// If the user already has shares of this stock, add to the position
+	// The balance check is part of the filter so the user can't spend the same coins twice
+	result, err := userCollection.UpdateOne(
+		ctx,
+		bson.D{
+			{Key: "user_id", Value: userID},
+			{Key: "guild_id", Value: guildID},
+			{Key: "balance", Value: bson.D{{Key: "$gte", Value: cost}}},
+			{Key: "portfolio", Value: bson.D{
+				{Key: "$elemMatch", Value: bson.D{
+					{Key: "ticker", Value: stock.Ticker},
+				}},
+			}},
+		},
+		bson.D{
+			{Key: "$inc", Value: bson.D{
+				{Key: "balance", Value: -cost},
+				{Key: "stock_invested", Value: cost},
+				{Key: "portfolio.$.quantity", Value: quantity},
+				{Key: "portfolio.$.cost", Value: cost},
+			}},
+		},
+	)
+	if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+		return 0, "Error occurred while updating database! " + strings.Title(err.Error())
+	}
+	if result.ModifiedCount == 1 {
+		return cost, ""
+	}
+
+	// Otherwise, open a new position
+	result, err = userCollection.UpdateOne(
+		ctx,
+		bson.D{
+			{Key: "user_id", Value: userID},
+			{Key: "guild_id", Value: guildID},
+			{Key: "balance", Value: bson.D{{Key: "$gte", Value: cost}}},
+			{Key: "portfolio", Value: bson.D{
+				{Key: "$not", Value: bson.D{
+					{Key: "$elemMatch", Value: bson.D{
+						{Key: "ticker", Value: stock.Ticker},
+					}},
+				}},
+			}},
+		},
+		bson.D{
+			{Key: "$inc", Value: bson.D{
+				{Key: "balance", Value: -cost},
+				{Key: "stock_invested", Value: cost},
+			}},
+			{Key: "$push", Value: bson.D{
+				{Key: "portfolio", Value: Holding{Ticker: stock.Ticker, Quantity: quantity, Cost: cost}},
+			}},
+		},
+	)
+	if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+		return 0, "Error occurred while updating database! " + strings.Title(err.Error())
+	}
+	if result.ModifiedCount == 0 {
+		return 0, fmt.Sprintf("You don't have enough coins! %d shares of %s cost %d coins.", quantity, stock.Ticker, cost)
+	}
+	return cost, ""
+}
+
+// mary stock sell [ticker] [amount] -> sells shares at the market price
+func SellStock(mongoURI string, guildID int, guildName string, userID int, userName string, ticker string, quantity int) (string) {
+	if quantity < 1 {
+		return "Please specify a positive number of shares!"
+	}
+
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error())
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	// Check if user exists in database
+	res := IsPlaying(ctx, client, guildID, guildName, userID, userName)
+	if res != "" {
+		return res
+	}
+
+	stock, errMsg := getStock(ctx, client, ticker)
+	if errMsg != "" {
+		return errMsg
+	}
+	if isStale(stock) {
+		return staleMessage(stock)
+	}
+
+	userCollection := client.Database(strconv.Itoa(guildID)).Collection("Users")
+	proceeds, profit, errMsg := sellShares(ctx, userCollection, guildID, userID, stock, quantity)
+	if errMsg != "" {
+		return errMsg
+	}
+	return fmt.Sprintf("You sold %d shares of %s at %.2f coins each for %d coins! Profit: %+d coins.", quantity, stock.Ticker, stock.Price, proceeds, profit)
+}
+
+// Not a command
+// Removes shares from the user's portfolio and pays them the market price
+// Returns the proceeds and the profit over what the shares cost, or an error message
+func sellShares(ctx context.Context, userCollection *mongo.Collection, guildID int, userID int, stock Stock, quantity int) (int64, int64, string) {
+	var user User
+	err := userCollection.FindOne(ctx, bson.M{"guild_id": guildID, "user_id": userID}).Decode(&user)
+	if err != nil {
+		fmt.Printf("Error occurred while finding user in database! %s\n", err)
+		return 0, 0, "Error occurred while finding user in database! " + strings.Title(err.Error())
+	}
+
+	var holding Holding
+	for _, h := range user.Portfolio {
+		if h.Ticker == stock.Ticker {
+			holding = h
+		}
+	}
+	if holding.Quantity < quantity {
+		return 0, 0, fmt.Sprintf("You only have %d shares of %s!", holding.Quantity, stock.Ticker)
+	}
+
+	// Take out the average cost of the shares being sold
+	proceeds := int64(math.Floor(stock.Price * float64(quantity)))
+	costSold := holding.Cost * int64(quantity) / int64(holding.Quantity)
+
+	// Match the exact position that was read so that two sells at once can't both go through
+	result, err := userCollection.UpdateOne(
+		ctx,
+		bson.D{
+			{Key: "user_id", Value: userID},
+			{Key: "guild_id", Value: guildID},
+			{Key: "portfolio", Value: bson.D{
+				{Key: "$elemMatch", Value: bson.D{
+					{Key: "ticker", Value: stock.Ticker},
+					{Key: "quantity", Value: holding.Quantity},
+				}},
+			}},
+		},
+		bson.D{
+			{Key: "$inc", Value: bson.D{
+				{Key: "balance", Value: proceeds},
+				{Key: "stock_profit", Value: proceeds - costSold},
+				{Key: "portfolio.$.quantity", Value: -quantity},
+				{Key: "portfolio.$.cost", Value: -costSold},
+			}},
+		},
+	)
+	if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+		return 0, 0, "Error occurred while updating database! " + strings.Title(err.Error())
+	}
+	if result.ModifiedCount == 0 {
+		return 0, 0, "Your portfolio changed while selling! Please try again."
+	}
+	// Only the profit counts as earned, since the rest is just the coins that were put in getting cashed out
+	addSeasonEarnings(ctx, userCollection, guildID, userID, proceeds - costSold)
+
+	// Remove positions that have been sold off completely
+	_, err = userCollection.UpdateOne(
+		ctx,
+		bson.D{
+			{Key: "user_id", Value: userID},
+			{Key: "guild_id", Value: guildID},
+		},
+		bson.D{
+			{Key: "$pull", Value: bson.D{
+				{Key: "portfolio", Value: bson.D{
+					{Key: "quantity", Value: bson.D{{Key: "$lte", Value: 0}}},
+				}},
+			}},
+		},
+	)
+	if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+	}
+	return proceeds, proceeds - costSold, ""
+}
+
+// mary portfolio [@user] -> shows a user's stocks with their cost, current value and profit
+func Portfolio(mongoURI string, guildID int, guildName string, userID int, userName string) (string, *discordgo.MessageEmbed) {
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error()), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error()), nil
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	// Check if user exists in database
+	res := IsPlaying(ctx, client, guildID, guildName, userID, userName)
+	if res != "" {
+		return res, nil
+	}
+
+	userCollection := client.Database(strconv.Itoa(guildID)).Collection("Users")
+	var user User
+	err = userCollection.FindOne(ctx, bson.M{"guild_id": guildID, "user_id": userID}).Decode(&user)
+	if err != nil {
+		fmt.Printf("Error occurred while finding user in database! %s\n", err)
+		return "Error occurred while finding user in database! " + strings.Title(err.Error()), nil
+	}
+	if len(user.Portfolio) == 0 {
+		return "<@" + strconv.Itoa(userID) + "> doesn't own any stocks!", nil
+	}
+
+	stocks, err := getStocks(ctx, client)
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return "Error occurred while selecting from database! " + strings.Title(err.Error()), nil
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title: userName + "'s Portfolio",
+		Color: 0xffc0cb,
+	}
+	var totalCost, totalValue float64
+	for _, holding := range user.Portfolio {
+		price := stocks[holding.Ticker].Price
+		value := price * float64(holding.Quantity)
+		totalCost += float64(holding.Cost)
+		totalValue += value
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name: fmt.Sprintf("%s x%d", holding.Ticker, holding.Quantity),
+			Value: fmt.Sprintf("Cost: %d coins (%.2f each)\nValue: %.0f coins (%.2f each)\nP&L: %s",
+				holding.Cost, float64(holding.Cost)/float64(holding.Quantity), value, price, formatPriceChange(float64(holding.Cost), value)),
+			Inline: true,
+		})
+	}
+	embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+		Name:  "Total",
+		Value: fmt.Sprintf("Cost: %.0f coins\nValue: %.0f coins\nUnrealized P&L: %s\nRealized P&L: %+d coins", totalCost, totalValue, formatPriceChange(totalCost, totalValue), user.StockProfit),
+	})
+	return "", embed
+}
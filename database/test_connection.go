@@ -3,14 +3,11 @@ package database
 import (
 	"context"
 	"fmt"
-	"sort"
-	"strconv"
 	"strings"
 	"time"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
-	"go.mongodb.org/mongo-driver/bson"
 )
 
 func TestConnection(mongoURI string) (string) {
@@ -38,80 +35,3 @@ func TestConnection(mongoURI string) (string) {
 	}
 	return ""
 }
-
-func Leaderboard(mongoURI string, guildID int) (string, []map[string]interface{}) {
-	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
-	if err != nil {
-		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
-		return "Error occurred creating MongoDB client! " + strings.Title(err.Error()), nil
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	err = client.Connect(ctx)
-	if err != nil {
-		fmt.Printf("Error occurred while connecting to database! %s\n", err)
-		return "Error occurred while connecting to database! " + strings.Title(err.Error()), nil
-	}
-
-	// Disconnect from database
-	defer client.Disconnect(ctx)
-
-	// Get leaderboard
-	leaderboardCollection := client.Database(strconv.Itoa(guildID)).Collection("Users")
-	leaderboardResult, err := leaderboardCollection.Find(
-		ctx,
-		bson.D{
-			{Key: "guild_id", Value: guildID},
-		},
-	)
-	if err != nil {
-		fmt.Printf("Error occurred while selecting from database! %s\n", err)
-		return "Error occurred while selecting from database! " + strings.Title(err.Error()), nil
-	}
-
-	// Create an array for leaderboard
-	var leaderboardArray []bson.M
-	for leaderboardResult.Next(ctx) {
-		var result bson.M
-		err := leaderboardResult.Decode(&result)
-		if err != nil {
-			fmt.Printf("Error occurred while decoding result! %s\n", err)
-			return "Error occurred while decoding result! " + strings.Title(err.Error()), nil
-		}
-		// Append result to leaderboard array
-		leaderboardArray = append(leaderboardArray, result)
-	}
-
-	// Return a dict containing {user_id: user_name, balance: balance} for each user
-	ret := make(map[string]map[string]interface{})
-	for _, result := range leaderboardArray {
-		ret[strconv.Itoa(int(result["user_id"].(int64)))] = map[string]interface{}{
-			"Name": result["user_name"].(string),
-			"Balance": result["balance"].(int64),
-		}
-	}
-
-	// Sort the slice in decreasing order of "Balance"
-	sort.Slice(leaderboardArray, func(i, j int) bool {
-		return leaderboardArray[i]["balance"].(int64) > leaderboardArray[j]["balance"].(int64)
-	})
-
-	// Create a new map with "Rank" field added
-	for i, result := range leaderboardArray {
-		ret[strconv.Itoa(int(result["user_id"].(int64)))]["Rank"] = i + 1
-	}
-
-	// Convert map to slice
-	retSlice := make([]map[string]interface{}, 0, len(ret))
-	for _, v := range ret {
-		retSlice = append(retSlice, v)
-	}
-
-	// Sort the slice in decreasing order of "Rank"
-	sort.Slice(retSlice, func(i, j int) bool {
-		return retSlice[i]["Rank"].(int) < retSlice[j]["Rank"].(int)
-	})
-	
-	return "", retSlice
-}
\ No newline at end of file
@@ -0,0 +1,836 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"github.com/bwmarrin/discordgo"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/bson"
+	commands "mary-bot/commands"
+)
+
+// One person's half of a trade
+// Everything offered is held by the trade (taken out of the user's inventory) until it closes
+type TradeSide struct {
+	UserID    int            `bson:"user_id"`
+	Coins     int64          `bson:"coins"`
+	Items     map[string]int `bson:"items"`
+	Wear      map[string]int `bson:"wear,omitempty"` // Uses taken off one of each item offered, which goes along with them
+	Confirmed bool           `bson:"confirmed"`
+	Delivered bool           `bson:"delivered"` // Whether this side's offer has been handed over since the trade closed
+}
+
+type TradeLogEntry struct {
+	At     time.Time `bson:"at"`
+	UserID int       `bson:"user_id"` // 0 when Mary did it, e.g. expiring the trade
+	Action string    `bson:"action"`
+}
+
+// A trade between two users
+// Trades for every server are kept in the Trades collection of the global database so expired ones can be found in one go
+type Trade struct {
+	TradeID   int             `bson:"trade_id"`
+	GuildID   int             `bson:"guild_id"`
+	ChannelID string          `bson:"channel_id"` // Where the trade was opened, for telling users it expired
+	Initiator TradeSide       `bson:"initiator"`
+	Partner   TradeSide       `bson:"partner"`
+	Status    string          `bson:"status"` // open, closing, completed, cancelled or expired
+	Outcome   string          `bson:"outcome"` // What a closing trade will end up as
+	Version   int             `bson:"version"` // Goes up on every change, so a confirmation only counts for what the user saw
+	CreatedAt time.Time       `bson:"created_at"`
+	UpdatedAt time.Time       `bson:"updated_at"`
+	ExpiresAt time.Time       `bson:"expires_at"`
+	ClosedAt  time.Time       `bson:"closed_at"`
+	Log       []TradeLogEntry `bson:"log"`
+}
+
+// Trades expire after this long without any changes
+const tradeTimeout = 10 * time.Minute
+
+// Confirming straight after a change isn't allowed, so nobody can swap an item out just before the other person confirms
+const tradeConfirmDelay = 3 * time.Second
+
+// Not a command
+// Gets the custom IDs of a trade's confirm and cancel buttons
+// Every message about a trade has the same buttons, so one wait per button covers all of them
+func tradeButtonIDs(tradeID int) (string, string) {
+	return fmt.Sprintf("trade_confirm_%d", tradeID), fmt.Sprintf("trade_cancel_%d", tradeID)
+}
+
+// Not a command
+// Gets the field name and both halves of a trade from one user's point of view
+func tradeSides(trade Trade, userID int) (string, TradeSide, TradeSide) {
+	if trade.Initiator.UserID == userID {
+		return "initiator", trade.Initiator, trade.Partner
+	}
+	return "partner", trade.Partner, trade.Initiator
+}
+
+// Not a command
+// Describes what one side of a trade is offering
+func describeTradeSide(side TradeSide) (string) {
+	offers := []string{}
+	if side.Coins > 0 {
+		offers = append(offers, fmt.Sprintf("💰 %d coins", side.Coins))
+	}
+	names := []string{}
+	for name, quantity := range side.Items {
+		if quantity > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		offers = append(offers, fmt.Sprintf("%s %dX %s%s", itemEmoji(name), side.Items[name], strings.Title(name), describeWear(name, side.Wear[name])))
+	}
+	if len(offers) == 0 {
+		return "Nothing yet"
+	}
+	return strings.Join(offers, "\n")
+}
+
+// Not a command
+// Checks if one side of a trade is offering anything
+func tradeSideEmpty(side TradeSide) (bool) {
+	for _, quantity := range side.Items {
+		if quantity > 0 {
+			return false
+		}
+	}
+	return side.Coins == 0
+}
+
+// Not a command
+// Gets the trade a user is part of in a server, if there is one
+func getOpenTrade(ctx context.Context, tradeCollection *mongo.Collection, guildID int, userID int) (Trade, error) {
+	var trade Trade
+	err := tradeCollection.FindOne(ctx, bson.D{
+		{Key: "guild_id", Value: guildID},
+		{Key: "status", Value: "open"},
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "initiator.user_id", Value: userID}},
+			bson.D{{Key: "partner.user_id", Value: userID}},
+		}},
+	}).Decode(&trade)
+	return trade, err
+}
+
+// Not a command
+// Builds the embed that shows both sides of a trade
+func tradeEmbed(trade Trade) (*discordgo.MessageEmbed) {
+	confirmed := func(side TradeSide) (string) {
+		if side.Confirmed {
+			return " ✅"
+		}
+		return ""
+	}
+	embed := &discordgo.MessageEmbed{
+		Title: fmt.Sprintf("Trade #%d", trade.TradeID),
+		Color: 0xffc0cb,
+		Fields: []*discordgo.MessageEmbedField{
+			{
+				Name: "Trader 1" + confirmed(trade.Initiator),
+				Value: "<@" + strconv.Itoa(trade.Initiator.UserID) + ">\n" + describeTradeSide(trade.Initiator),
+				Inline: true,
+			},
+			{
+				Name: "Trader 2" + confirmed(trade.Partner),
+				Value: "<@" + strconv.Itoa(trade.Partner.UserID) + ">\n" + describeTradeSide(trade.Partner),
+				Inline: true,
+			},
+		},
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "mary trade add/remove [item/coins] [amount], then press Confirm (or mary trade confirm) or Cancel",
+		},
+	}
+	if trade.Status == "open" {
+		embed.Description = fmt.Sprintf("Expires <t:%d:R> unless something changes. Any change resets both confirmations.", trade.ExpiresAt.Unix())
+	} else {
+		embed.Description = "This trade is " + trade.Status + "."
+	}
+
+	// Show the last few things that happened
+	log := []string{}
+	start := len(trade.Log) - 5
+	if start < 0 {
+		start = 0
+	}
+	for _, entry := range trade.Log[start:] {
+		who := "Mary"
+		if entry.UserID != 0 {
+			who = "<@" + strconv.Itoa(entry.UserID) + ">"
+		}
+		log = append(log, fmt.Sprintf("<t:%d:T> %s %s", entry.At.Unix(), who, entry.Action))
+	}
+	if len(log) > 0 {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name: "Log",
+			Value: strings.Join(log, "\n"),
+		})
+	}
+	return embed
+}
+
+// Not a command
+// Builds the message that shows a trade, with buttons to confirm or cancel it while it's open
+func tradeMessage(trade Trade) (*discordgo.MessageSend) {
+	message := &discordgo.MessageSend{Embed: tradeEmbed(trade)}
+	if trade.Status != "open" {
+		return message
+	}
+	confirmID, cancelID := tradeButtonIDs(trade.TradeID)
+	message.Components = []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{Label: "Confirm", Style: discordgo.SuccessButton, CustomID: confirmID, Emoji: discordgo.ComponentEmoji{Name: "✅"}},
+				discordgo.Button{Label: "Cancel", Style: discordgo.DangerButton, CustomID: cancelID, Emoji: discordgo.ComponentEmoji{Name: "✖️"}},
+			},
+		},
+	}
+	return message
+}
+
+// mary trade @user -> opens a trade with another user
+func OpenTrade(mongoURI string, guildID int, guildName string, userID int, userName string, channelID string, partnerID int) (string, *discordgo.MessageSend) {
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error()), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error()), nil
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	// Check if user exists in database
+	res := IsPlaying(ctx, client, guildID, guildName, userID, userName)
+	if res != "" {
+		return res, nil
+	}
+
+	// Check if the other user exists in the database
+	err = client.Database(strconv.Itoa(guildID)).Collection("Users").FindOne(ctx, bson.M{"guild_id": guildID, "user_id": partnerID}).Err()
+	if err != nil {
+		return "The user you are trying to trade with is not playing the game!", nil
+	}
+
+	// Each user can only be in one trade at a time, so nothing can be offered twice
+	tradeCollection := client.Database(globalDatabase).Collection("Trades")
+	if _, err := getOpenTrade(ctx, tradeCollection, guildID, userID); err == nil {
+		return "You are already in a trade! Finish it with `mary trade confirm` or `mary trade cancel` first.", nil
+	}
+	if _, err := getOpenTrade(ctx, tradeCollection, guildID, partnerID); err == nil {
+		return "That user is already in a trade!", nil
+	}
+
+	tradeID, err := nextSequence(ctx, client, "trades")
+	if err != nil {
+		fmt.Printf("Error occurred while numbering trade! %s\n", err)
+		return "Error occurred while numbering trade! " + strings.Title(err.Error()), nil
+	}
+	now := time.Now()
+	trade := Trade{
+		TradeID:   tradeID,
+		GuildID:   guildID,
+		ChannelID: channelID,
+		Initiator: TradeSide{UserID: userID, Items: map[string]int{}},
+		Partner:   TradeSide{UserID: partnerID, Items: map[string]int{}},
+		Status:    "open",
+		CreatedAt: now,
+		UpdatedAt: now,
+		ExpiresAt: now.Add(tradeTimeout),
+		Log:       []TradeLogEntry{{At: now, UserID: userID, Action: "opened the trade"}},
+	}
+	_, err = tradeCollection.InsertOne(ctx, trade)
+	if err != nil {
+		fmt.Printf("Error occurred while inserting into database! %s\n", err)
+		return "Error occurred while inserting into database! " + strings.Title(err.Error()), nil
+	}
+
+	// The buttons on the trade work for as long as it's open
+	confirmID, cancelID := tradeButtonIDs(tradeID)
+	go awaitTradeButton(mongoURI, trade, confirmID, func(userID int) (string, *discordgo.MessageSend) {
+		return ConfirmTrade(mongoURI, guildID, userID)
+	})
+	go awaitTradeButton(mongoURI, trade, cancelID, func(userID int) (string, *discordgo.MessageSend) {
+		return CancelTrade(mongoURI, guildID, userID), nil
+	})
+	return "", tradeMessage(trade)
+}
+
+// mary trade -> shows the trade you are in
+func ShowTrade(mongoURI string, guildID int, userID int) (string, *discordgo.MessageSend) {
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error()), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error()), nil
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	trade, err := getOpenTrade(ctx, client.Database(globalDatabase).Collection("Trades"), guildID, userID)
+	if err == mongo.ErrNoDocuments {
+		return "You aren't in a trade! Start one with `mary trade @user`.", nil
+	} else if err != nil {
+		fmt.Printf("Error occurred while finding trade in database! %s\n", err)
+		return "Error occurred while finding trade in database! " + strings.Title(err.Error()), nil
+	}
+	return "", tradeMessage(trade)
+}
+
+// mary trade add/remove [item/coins] [amount] -> puts items or coins into your side of the trade, or takes them back out
+func ChangeTradeOffer(mongoURI string, guildID int, guildName string, userID int, userName string, item string, amount int, add bool) (string, *discordgo.MessageSend) {
+	if amount < 1 {
+		return "Please specify a positive amount!", nil
+	}
+	coins := strings.ToLower(item) == "coins" || strings.ToLower(item) == "coin"
+	if !coins {
+		item = knownItemName(item)
+		if item == "" {
+			return "That item doesn't exist!", nil
+		}
+	}
+
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error()), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error()), nil
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	// Check if user exists in database
+	res := IsPlaying(ctx, client, guildID, guildName, userID, userName)
+	if res != "" {
+		return res, nil
+	}
+
+	tradeCollection := client.Database(globalDatabase).Collection("Trades")
+	userCollection := client.Database(strconv.Itoa(guildID)).Collection("Users")
+	trade, err := getOpenTrade(ctx, tradeCollection, guildID, userID)
+	if err == mongo.ErrNoDocuments {
+		return "You aren't in a trade! Start one with `mary trade @user`.", nil
+	} else if err != nil {
+		fmt.Printf("Error occurred while finding trade in database! %s\n", err)
+		return "Error occurred while finding trade in database! " + strings.Title(err.Error()), nil
+	}
+	side, _, _ := tradeSides(trade, userID)
+
+	field := side + ".coins"
+	description := fmt.Sprintf("%d coins", amount)
+	if !coins {
+		field = side + ".items." + item
+		description = fmt.Sprintf("%dX %s", amount, item)
+	}
+	change := amount
+	action := "added " + description
+	if !add {
+		change = -amount
+		action = "removed " + description
+	}
+
+	// Adding takes the offer from the user before it goes into the trade, along with any wear on the items
+	wear := 0
+	if add {
+		var taken bool
+		if coins {
+			taken, err = takeCoins(ctx, userCollection, guildID, userID, int64(amount))
+		} else {
+			taken, wear, err = takeItemLot(ctx, userCollection, guildID, userID, item, amount)
+		}
+		if err != nil {
+			fmt.Printf("Error occurred while updating database! %s\n", err)
+			return "Error occurred while updating database! " + strings.Title(err.Error()), nil
+		}
+		if !taken && coins {
+			return "You don't have enough coins!", nil
+		} else if !taken {
+			return "You don't have enough of that item!", nil
+		}
+	}
+
+	// Every change resets both confirmations and gives the trade more time
+	now := time.Now()
+	filter := bson.D{
+		{Key: "trade_id", Value: trade.TradeID},
+		{Key: "status", Value: "open"},
+	}
+	if !add {
+		filter = append(filter, bson.E{Key: field, Value: bson.D{{Key: "$gte", Value: amount}}})
+	}
+	inc := bson.D{
+		{Key: field, Value: change},
+		{Key: "version", Value: 1},
+	}
+	if wear > 0 {
+		inc = append(inc, bson.E{Key: side + ".wear." + item, Value: wear})
+	}
+	var updated Trade
+	err = tradeCollection.FindOneAndUpdate(
+		ctx,
+		filter,
+		bson.D{
+			{Key: "$inc", Value: inc},
+			{Key: "$set", Value: bson.D{
+				{Key: "initiator.confirmed", Value: false},
+				{Key: "partner.confirmed", Value: false},
+				{Key: "updated_at", Value: now},
+				{Key: "expires_at", Value: now.Add(tradeTimeout)},
+			}},
+			{Key: "$push", Value: bson.D{
+				{Key: "log", Value: TradeLogEntry{At: now, UserID: userID, Action: action}},
+			}},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err != nil && err != mongo.ErrNoDocuments {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+	}
+
+	if add {
+		if err != nil {
+			// The trade closed before the offer went in, so give it back
+			if coins {
+				err = addCoins(ctx, userCollection, guildID, userID, int64(amount))
+			} else {
+				err = addItemLot(ctx, userCollection, guildID, userID, item, amount, wear)
+			}
+			if err != nil {
+				fmt.Printf("Error occurred while returning %s to user %d! %s\n", description, userID, err)
+			}
+			return "That trade has already closed!", nil
+		}
+		return "", tradeMessage(updated)
+	}
+
+	if err == mongo.ErrNoDocuments {
+		return "You haven't offered that many!", nil
+	} else if err != nil {
+		return "Error occurred while updating database! " + strings.Title(err.Error()), nil
+	}
+	// Removing only gives the offer back once it's out of the trade
+	// Good as new ones come back first, so the wear only comes back once none of the item is left in the offer
+	if !coins {
+		_, offer, _ := tradeSides(updated, userID)
+		wear = takeTradeWear(ctx, tradeCollection, updated.TradeID, side, offer, item)
+	}
+	if coins {
+		err = addCoins(ctx, userCollection, guildID, userID, int64(amount))
+	} else {
+		err = addItemLot(ctx, userCollection, guildID, userID, item, amount, wear)
+	}
+	if err != nil {
+		fmt.Printf("Error occurred while returning %s to user %d! %s\n", description, userID, err)
+		return "Error occurred while returning your offer! " + strings.Title(err.Error()), nil
+	}
+	return "", tradeMessage(updated)
+}
+
+// Not a command
+// Takes the wear off an item in one side of a trade once none of that item is left in the offer, so it goes back with the last of them
+// Returns the wear that was taken
+func takeTradeWear(ctx context.Context, tradeCollection *mongo.Collection, tradeID int, side string, offer TradeSide, item string) (int) {
+	wear := offer.Wear[item]
+	if wear <= 0 || offer.Items[item] > 0 {
+		return 0
+	}
+	result, err := tradeCollection.UpdateOne(
+		ctx,
+		bson.D{
+			{Key: "trade_id", Value: tradeID},
+			{Key: side + ".items." + item, Value: 0},
+			{Key: side + ".wear." + item, Value: wear},
+		},
+		bson.D{
+			{Key: "$unset", Value: bson.D{
+				{Key: side + ".wear." + item, Value: ""},
+			}},
+		},
+	)
+	if err != nil {
+		fmt.Printf("Error occurred while updating trade #%d! %s\n", tradeID, err)
+		return 0
+	}
+	if result.ModifiedCount == 0 {
+		return 0
+	}
+	return wear
+}
+
+// mary trade confirm -> agrees to the trade as it is, which goes through once both users confirm
+func ConfirmTrade(mongoURI string, guildID int, userID int) (string, *discordgo.MessageSend) {
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error()), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error()), nil
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	tradeCollection := client.Database(globalDatabase).Collection("Trades")
+	trade, err := getOpenTrade(ctx, tradeCollection, guildID, userID)
+	if err == mongo.ErrNoDocuments {
+		return "You aren't in a trade! Start one with `mary trade @user`.", nil
+	} else if err != nil {
+		fmt.Printf("Error occurred while finding trade in database! %s\n", err)
+		return "Error occurred while finding trade in database! " + strings.Title(err.Error()), nil
+	}
+	if tradeSideEmpty(trade.Initiator) && tradeSideEmpty(trade.Partner) {
+		return "Nothing has been offered yet! Add something with `mary trade add [item/coins] [amount]`.", nil
+	}
+	if time.Since(trade.UpdatedAt) < tradeConfirmDelay {
+		return "The trade just changed! Check it again before confirming.", tradeMessage(trade)
+	}
+	side, _, _ := tradeSides(trade, userID)
+
+	// The version check means this only confirms the trade as it was shown above
+	var updated Trade
+	err = tradeCollection.FindOneAndUpdate(
+		ctx,
+		bson.D{
+			{Key: "trade_id", Value: trade.TradeID},
+			{Key: "status", Value: "open"},
+			{Key: "version", Value: trade.Version},
+		},
+		bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: side + ".confirmed", Value: true},
+			}},
+			{Key: "$push", Value: bson.D{
+				{Key: "log", Value: TradeLogEntry{At: time.Now(), UserID: userID, Action: "confirmed"}},
+			}},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err == mongo.ErrNoDocuments {
+		return "The trade changed while you were confirming! Check it with `mary trade` and confirm again.", nil
+	} else if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+		return "Error occurred while updating database! " + strings.Title(err.Error()), nil
+	}
+
+	if !updated.Initiator.Confirmed || !updated.Partner.Confirmed {
+		_, _, other := tradeSides(updated, userID)
+		return fmt.Sprintf("You confirmed trade #%d! Waiting for <@%d> to confirm.", updated.TradeID, other.UserID), tradeMessage(updated)
+	}
+
+	// Both users have confirmed, so swap the offers
+	if !closeTrade(ctx, client, updated, "completed", userID) {
+		// If both confirmed at once, the other confirmation may have closed it already
+		err = tradeCollection.FindOne(ctx, bson.M{"trade_id": updated.TradeID}).Decode(&updated)
+		if err != nil || updated.Status == "open" {
+			return "The trade changed while you were confirming! Check it with `mary trade` and confirm again.", nil
+		}
+	}
+	return fmt.Sprintf("🤝 Trade #%d is done! <@%d> and <@%d>, check your inventories.", updated.TradeID, updated.Initiator.UserID, updated.Partner.UserID), nil
+}
+
+// mary trade cancel -> cancels the trade you are in and gives everything back
+func CancelTrade(mongoURI string, guildID int, userID int) (string) {
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error())
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	tradeCollection := client.Database(globalDatabase).Collection("Trades")
+	// Retry if the trade changes while it's being cancelled
+	for attempt := 0; attempt < 3; attempt++ {
+		trade, err := getOpenTrade(ctx, tradeCollection, guildID, userID)
+		if err == mongo.ErrNoDocuments {
+			return "You aren't in a trade!"
+		} else if err != nil {
+			fmt.Printf("Error occurred while finding trade in database! %s\n", err)
+			return "Error occurred while finding trade in database! " + strings.Title(err.Error())
+		}
+		if closeTrade(ctx, client, trade, "cancelled", userID) {
+			return fmt.Sprintf("Trade #%d was cancelled. <@%d> and <@%d>, everything you offered has been given back.", trade.TradeID, trade.Initiator.UserID, trade.Partner.UserID)
+		}
+	}
+	return "The trade kept changing while cancelling it! Please try again."
+}
+
+// Not a command
+// Closes an open trade as completed, cancelled or expired, returning false if it changed or closed since it was read
+func closeTrade(ctx context.Context, client *mongo.Client, trade Trade, outcome string, userID int) (bool) {
+	filter := bson.D{
+		{Key: "trade_id", Value: trade.TradeID},
+		{Key: "status", Value: "open"},
+		{Key: "version", Value: trade.Version},
+	}
+	if outcome == "completed" {
+		filter = append(filter,
+			bson.E{Key: "initiator.confirmed", Value: true},
+			bson.E{Key: "partner.confirmed", Value: true},
+		)
+	}
+	// Closing first means nothing can be added or removed while the offers are handed over
+	now := time.Now()
+	result, err := client.Database(globalDatabase).Collection("Trades").UpdateOne(
+		ctx,
+		filter,
+		bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "status", Value: "closing"},
+				{Key: "outcome", Value: outcome},
+				{Key: "updated_at", Value: now},
+			}},
+			{Key: "$push", Value: bson.D{
+				{Key: "log", Value: TradeLogEntry{At: now, UserID: userID, Action: outcome + " the trade"}},
+			}},
+		},
+	)
+	if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+		return false
+	}
+	if result.ModifiedCount == 0 {
+		return false
+	}
+	trade.Status = "closing"
+	trade.Outcome = outcome
+
+	// Nobody can press the trade's buttons any more
+	confirmID, cancelID := tradeButtonIDs(trade.TradeID)
+	commands.CancelWaits(commands.WaitKey{CustomID: confirmID})
+	commands.CancelWaits(commands.WaitKey{CustomID: cancelID})
+
+	finishTrade(ctx, client, trade)
+	return true
+}
+
+// Not a command
+// Handles presses of one of a trade's buttons by either trader, until the trade closes
+// The wait is renewed each time it runs out while the trade is still open, since changes keep a trade open for longer
+func awaitTradeButton(mongoURI string, trade Trade, customID string, press func(userID int) (string, *discordgo.MessageSend)) {
+	key := commands.WaitKey{CustomID: customID}
+	for {
+		wait := commands.Listen(key, tradeTimeout)
+		for {
+			event, ok := wait.Next()
+			if !ok {
+				break
+			}
+			userID, _ := strconv.Atoi(event.UserID)
+			if userID != trade.Initiator.UserID && userID != trade.Partner.UserID {
+				continue
+			}
+			// The buttons are stopped by jail the same as mary trade
+			if res := Restricted(mongoURI, trade.GuildID, userID, "trade", ""); res != "" {
+				postChannel(event.Interaction.ChannelID, "<@" + strconv.Itoa(userID) + "> " + res)
+				continue
+			}
+			res, message := press(userID)
+			if res != "" {
+				postChannel(event.Interaction.ChannelID, res)
+			}
+			if message != nil && notifySession != nil {
+				_, err := notifySession.ChannelMessageSendComplex(event.Interaction.ChannelID, message)
+				if err != nil {
+					fmt.Printf("Error occurred while sending trade #%d! %s\n", trade.TradeID, err)
+				}
+			}
+		}
+		wait.Cancel()
+
+		client, err := getSharedClient(mongoURI)
+		if err != nil {
+			fmt.Printf("Error occurred while connecting to database! %s\n", err)
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		err = client.Database(globalDatabase).Collection("Trades").FindOne(ctx, bson.M{"trade_id": trade.TradeID, "status": "open"}).Err()
+		cancel()
+		if err != nil {
+			// Closed, or it can't be checked, so the buttons stop working and the commands are left
+			return
+		}
+	}
+}
+
+// Not a command
+// Hands over the offers of a closing trade: to the other user if it completed, otherwise back to whoever offered them
+// Each side is marked as delivered before it's handed over, so running this twice never pays out twice
+func finishTrade(ctx context.Context, client *mongo.Client, trade Trade) {
+	tradeCollection := client.Database(globalDatabase).Collection("Trades")
+	userCollection := client.Database(strconv.Itoa(trade.GuildID)).Collection("Users")
+	for _, key := range []string{"initiator", "partner"} {
+		side, other := trade.Initiator, trade.Partner
+		if key == "partner" {
+			side, other = trade.Partner, trade.Initiator
+		}
+		result, err := tradeCollection.UpdateOne(
+			ctx,
+			bson.D{
+				{Key: "trade_id", Value: trade.TradeID},
+				{Key: "status", Value: "closing"},
+				{Key: key + ".delivered", Value: false},
+			},
+			bson.D{
+				{Key: "$set", Value: bson.D{
+					{Key: key + ".delivered", Value: true},
+				}},
+			},
+		)
+		if err != nil {
+			fmt.Printf("Error occurred while updating trade #%d! %s\n", trade.TradeID, err)
+			return
+		}
+		if result.ModifiedCount == 0 {
+			continue
+		}
+
+		recipient := side.UserID
+		if trade.Outcome == "completed" {
+			recipient = other.UserID
+		}
+		if side.Coins > 0 {
+			err = addCoins(ctx, userCollection, trade.GuildID, recipient, side.Coins)
+			if err != nil {
+				fmt.Printf("Error occurred while handing over %d coins from trade #%d to user %d! %s\n", side.Coins, trade.TradeID, recipient, err)
+			}
+		}
+		for item, quantity := range side.Items {
+			if quantity <= 0 {
+				continue
+			}
+			err = addItemLot(ctx, userCollection, trade.GuildID, recipient, item, quantity, side.Wear[item])
+			if err != nil {
+				fmt.Printf("Error occurred while handing over %dX %s from trade #%d to user %d! %s\n", quantity, item, trade.TradeID, recipient, err)
+			}
+		}
+	}
+
+	_, err := tradeCollection.UpdateOne(
+		ctx,
+		bson.M{"trade_id": trade.TradeID, "status": "closing"},
+		bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "status", Value: trade.Outcome},
+				{Key: "closed_at", Value: time.Now()},
+			}},
+		},
+	)
+	if err != nil {
+		fmt.Printf("Error occurred while updating trade #%d! %s\n", trade.TradeID, err)
+		return
+	}
+	fmt.Printf("Trade #%d between %d and %d in server %d %s\n", trade.TradeID, trade.Initiator.UserID, trade.Partner.UserID, trade.GuildID, trade.Outcome)
+	if trade.Outcome == "completed" {
+		recordEvent(ctx, client, GameEvent{GuildID: trade.GuildID, UserID: trade.Initiator.UserID, ChannelID: trade.ChannelID, Type: "trade", TargetID: trade.Partner.UserID})
+		recordEvent(ctx, client, GameEvent{GuildID: trade.GuildID, UserID: trade.Partner.UserID, ChannelID: trade.ChannelID, Type: "trade", TargetID: trade.Initiator.UserID})
+	}
+}
+
+// Not a command
+// Expires trades that haven't changed in a while, and finishes any that Mary stopped in the middle of closing
+func ExpireTrades(mongoURI string) {
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx)
+
+	tradeCollection := client.Database(globalDatabase).Collection("Trades")
+	cursor, err := tradeCollection.Find(ctx, bson.D{
+		{Key: "$or", Value: bson.A{
+			bson.D{
+				{Key: "status", Value: "open"},
+				{Key: "expires_at", Value: bson.D{{Key: "$lte", Value: time.Now()}}},
+			},
+			bson.D{
+				{Key: "status", Value: "closing"},
+				{Key: "updated_at", Value: bson.D{{Key: "$lte", Value: time.Now().Add(-time.Minute)}}},
+			},
+		}},
+	})
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return
+	}
+	var trades []Trade
+	err = cursor.All(ctx, &trades)
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return
+	}
+
+	for _, trade := range trades {
+		if trade.Status == "closing" {
+			finishTrade(ctx, client, trade)
+			continue
+		}
+		if closeTrade(ctx, client, trade, "expired", 0) {
+			content := fmt.Sprintf("Trade #%d expired because nothing changed for %d minutes. Everything you offered has been given back.", trade.TradeID, int(tradeTimeout.Minutes()))
+			notifyUser(trade.Initiator.UserID, trade.ChannelID, content)
+			notifyUser(trade.Partner.UserID, trade.ChannelID, content)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"github.com/bwmarrin/discordgo"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Something that happened to a user's coins or items that they can look back on
+// Stored in the Transactions collection of the server's database
+type Transaction struct {
+	GuildID int                    `bson:"guild_id"`
+	UserID  int                    `bson:"user_id"`
+	Type    string                 `bson:"type"` // e.g. crate_open
+	Item    string                 `bson:"item"`
+	Details string                 `bson:"details"` // What happened, in words
+	Data    map[string]interface{} `bson:"data,omitempty"` // The numbers behind it, e.g. the roll of a crate
+	At      time.Time              `bson:"at"`
+}
+
+const historyPageSize = 10
+
+// Names shown in the history for each type of transaction
+var transactionNames = map[string]string{
+	"crate_open": "📦 Opened a crate",
+}
+
+// Not a command
+// Saves a transaction to a user's history
+func recordTransaction(ctx context.Context, client *mongo.Client, transaction Transaction) {
+	if transaction.At.IsZero() {
+		transaction.At = time.Now()
+	}
+	_, err := client.Database(strconv.Itoa(transaction.GuildID)).Collection("Transactions").InsertOne(ctx, transaction)
+	if err != nil {
+		fmt.Printf("Error occurred while saving %s transaction for user %d! %s\n", transaction.Type, transaction.UserID, err)
+	}
+}
+
+// mary history [optional: page] -> shows your most recent transactions
+func History(mongoURI string, guildID int, userID int, userName string, page int) (string, *discordgo.MessageEmbed) {
+	if page < 1 {
+		return "Please enter a valid page number!", nil
+	}
+
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error()), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error()), nil
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	cursor, err := client.Database(strconv.Itoa(guildID)).Collection("Transactions").Find(
+		ctx,
+		bson.M{"guild_id": guildID, "user_id": userID},
+		options.Find().
+			SetSort(bson.D{{Key: "at", Value: -1}}).
+			SetSkip(int64((page - 1) * historyPageSize)).
+			SetLimit(historyPageSize),
+	)
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return "Error occurred while selecting from database! " + strings.Title(err.Error()), nil
+	}
+	var transactions []Transaction
+	err = cursor.All(ctx, &transactions)
+	if err != nil {
+		fmt.Printf("Error occurred while decoding result! %s\n", err)
+		return "Error occurred while decoding result! " + strings.Title(err.Error()), nil
+	}
+	if len(transactions) == 0 {
+		if page > 1 {
+			return "There's nothing on that page!", nil
+		}
+		return "You don't have any transactions yet!", nil
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title: userName + "'s History",
+		Color: 0xffc0cb,
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: fmt.Sprintf("Page %d • mary history [page] for older transactions", page),
+		},
+	}
+	for _, transaction := range transactions {
+		name, ok := transactionNames[transaction.Type]
+		if !ok {
+			name = transaction.Type
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name: name,
+			Value: fmt.Sprintf("<t:%d:f> %s", transaction.At.Unix(), transaction.Details),
+		})
+	}
+	return "", embed
+}
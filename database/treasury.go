@@ -0,0 +1,107 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"github.com/bwmarrin/discordgo"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Coins a server has collected from fees and taxes
+// Stored as a single document in the Treasury collection of the server's database
+type Treasury struct {
+	GuildID int              `bson:"guild_id"`
+	Balance int64            `bson:"balance"`
+	Sources map[string]int64 `bson:"sources"` // Total collected from each source, e.g. "market_fee"
+}
+
+// Not a command
+// Adds coins to a server's treasury, keeping track of where they came from
+func addToTreasury(ctx context.Context, client *mongo.Client, guildID int, amount int64, source string) {
+	if amount <= 0 {
+		return
+	}
+	_, err := client.Database(strconv.Itoa(guildID)).Collection("Treasury").UpdateOne(
+		ctx,
+		bson.D{
+			{Key: "guild_id", Value: guildID},
+		},
+		bson.D{
+			{Key: "$inc", Value: bson.D{
+				{Key: "balance", Value: amount},
+				{Key: "sources." + source, Value: amount},
+			}},
+		},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		fmt.Printf("Error occurred while adding %d coins from %s to the treasury of server %d! %s\n", amount, source, guildID, err)
+	}
+}
+
+// Names for where treasury coins came from
+var treasurySources = map[string]string{
+	"market_fee": "Listing fees",
+	"market_tax": "Sales tax",
+	"auction_tax": "Auction tax",
+	"hospital": "Hospital fees",
+	"bail": "Bail",
+}
+
+// mary treasury -> shows how many coins the server has collected from fees and taxes
+func ShowTreasury(mongoURI string, guildID int, guildName string) (string, *discordgo.MessageEmbed) {
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error()), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error()), nil
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	treasury := Treasury{GuildID: guildID}
+	err = client.Database(strconv.Itoa(guildID)).Collection("Treasury").FindOne(ctx, bson.M{"guild_id": guildID}).Decode(&treasury)
+	if err != nil && err != mongo.ErrNoDocuments {
+		fmt.Printf("Error occurred while finding treasury in database! %s\n", err)
+		return "Error occurred while finding treasury in database! " + strings.Title(err.Error()), nil
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title: guildName + "'s Treasury",
+		Description: fmt.Sprintf("💰 %d coins", treasury.Balance),
+		Color: 0xffc0cb,
+	}
+	sources := []string{}
+	for source := range treasury.Sources {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	for _, source := range sources {
+		amount := treasury.Sources[source]
+		name, ok := treasurySources[source]
+		if !ok {
+			name = source
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name: name,
+			Value: fmt.Sprintf("%d coins", amount),
+			Inline: true,
+		})
+	}
+	return "", embed
+}
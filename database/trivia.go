@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 	"github.com/bwmarrin/discordgo"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -28,13 +29,284 @@ type TriviaQuestion struct {
 }
 
 
+// TriviaCategory represents a single category from the API
+type TriviaCategory struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// The options a user can pick when starting a trivia game
+// mary trivia [category] [easy|medium|hard] [truefalse] [amount]
+type TriviaOptions struct {
+	Category   TriviaCategory // ID 0 means any category
+	Difficulty string         // Empty means any difficulty
+	Type       string         // "multiple" or "boolean"
+	Amount     int            // Amount of coins gambled, 0 means no gamble
+}
+
+// Base payout for a correct answer when nothing is gambled
+// True/false questions pay less because you have a 50/50 chance of guessing
+var triviaPayouts = map[string]map[string]int{
+	"multiple": {"easy": 50, "medium": 100, "hard": 200},
+	"boolean":  {"easy": 25, "medium": 50, "hard": 100},
+}
+
+// Multiplier for a gambled amount when the answer is correct
+var triviaMultipliers = map[string]map[string]float64{
+	"multiple": {"easy": 2, "medium": 3, "hard": 5},
+	"boolean":  {"easy": 1.5, "medium": 2, "hard": 3},
+}
+
+// Categories rarely change, so they are only fetched from the API once
+var triviaCategories []TriviaCategory
+var triviaCategoriesMutex sync.Mutex
+
+// Not a command
+// Gets the list of trivia categories from the API
+func getTriviaCategories() ([]TriviaCategory, error) {
+	triviaCategoriesMutex.Lock()
+	defer triviaCategoriesMutex.Unlock()
+	if len(triviaCategories) > 0 {
+		return triviaCategories, nil
+	}
+
+	resp, err := http.Get("https://opentdb.com/api_category.php")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var categoryResponse struct {
+		Categories []TriviaCategory `json:"trivia_categories"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&categoryResponse)
+	if err != nil {
+		return nil, err
+	}
+	triviaCategories = categoryResponse.Categories
+	return triviaCategories, nil
+}
+
+// Find a category by its ID or (part of) its name, e.g. "#9", "9", "video games" or "entertainment: film"
+func FindTriviaCategory(name string) (TriviaCategory, string) {
+	categories, err := getTriviaCategories()
+	if err != nil {
+		fmt.Printf("Failed to get trivia categories! %s\n", err)
+		return TriviaCategory{}, "Failed to get trivia categories! " + strings.Title(err.Error())
+	}
+
+	name = strings.ToLower(strings.TrimSpace(name))
+	id, err := strconv.Atoi(strings.TrimPrefix(name, "#"))
+	// Prefer an exact match on the ID, the full name, or the name without its "Entertainment: " prefix
+	for _, category := range categories {
+		fullName := strings.ToLower(category.Name)
+		shortName := fullName[strings.LastIndex(fullName, ":")+1:]
+		if (err == nil && category.ID == id) || fullName == name || strings.TrimSpace(shortName) == name {
+			return category, ""
+		}
+	}
+	// Otherwise, take the first category that contains the name
+	for _, category := range categories {
+		if strings.Contains(strings.ToLower(category.Name), name) {
+			return category, ""
+		}
+	}
+	return TriviaCategory{}, "I couldn't find that category! Use `mary trivia categories` to see them all."
+}
+
+// Parse the arguments after "mary trivia" into options
+// The order doesn't matter: numbers are the amount to gamble, difficulties and types are keywords, and anything else is the category
+// Category IDs need a # in front, e.g. "#9", so they aren't mistaken for the amount to gamble
+func ParseTriviaOptions(args []string) (TriviaOptions, string) {
+	opts := TriviaOptions{Type: "multiple"}
+	categoryWords := []string{}
+	for _, arg := range args {
+		switch strings.ToLower(arg) {
+		case "easy", "medium", "hard":
+			opts.Difficulty = strings.ToLower(arg)
+		case "truefalse", "true/false", "tf", "boolean":
+			opts.Type = "boolean"
+		case "multiple", "mc":
+			opts.Type = "multiple"
+		default:
+			amount, err := strconv.Atoi(arg)
+			if err != nil || strings.HasPrefix(arg, "#") {
+				categoryWords = append(categoryWords, arg)
+			} else if amount < 0 {
+				return opts, "Please specify a positive amount to gamble!"
+			} else {
+				opts.Amount = amount
+			}
+		}
+	}
+
+	if len(categoryWords) > 0 {
+		category, err := FindTriviaCategory(strings.Join(categoryWords, " "))
+		if err != "" {
+			return opts, err
+		}
+		opts.Category = category
+	}
+	return opts, ""
+}
+
+// mary trivia categories -> shows every category and whether it can be played in this server
+func TriviaCategoryList(mongoURI string, guildID int) (string, *discordgo.MessageEmbed) {
+	categories, err := getTriviaCategories()
+	if err != nil {
+		fmt.Printf("Failed to get trivia categories! %s\n", err)
+		return "Failed to get trivia categories! " + strings.Title(err.Error()), nil
+	}
+
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error()), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error()), nil
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	settings := getSettings(ctx, client, guildID)
+
+	// Cross out the categories that the server admins have disabled
+	lines := []string{}
+	for _, category := range categories {
+		if isTriviaCategoryAllowed(settings, category.ID) {
+			lines = append(lines, fmt.Sprintf("`#%d` %s", category.ID, category.Name))
+		} else {
+			lines = append(lines, fmt.Sprintf("~~`#%d` %s~~", category.ID, category.Name))
+		}
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       "Trivia Categories",
+		Description: strings.Join(lines, "\n"),
+		Color:       0xffc0cb,
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "mary trivia [category name or #ID] [easy|medium|hard] [truefalse] [amount]",
+		},
+	}
+	return "", embed
+}
+
+// mary trivia allow/disallow [category] (admin only) -> restricts which categories can be played in this server
+// "mary trivia allow all" lifts the restriction
+func RestrictTrivia(mongoURI string, guildID int, categoryName string, allow bool) (string) {
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error())
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	if strings.ToLower(categoryName) == "all" {
+		if !allow {
+			return "You can't disallow every category! Allow the ones you want instead."
+		}
+		err = updateSettings(ctx, client, guildID, bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "trivia_categories", Value: []int{}},
+			}},
+		})
+		if err != nil {
+			fmt.Printf("Error occurred while updating database! %s\n", err)
+			return "Error occurred while updating database! " + strings.Title(err.Error())
+		}
+		return "Every trivia category is now allowed in this server!"
+	}
+
+	category, errMsg := FindTriviaCategory(categoryName)
+	if errMsg != "" {
+		return errMsg
+	}
+
+	// The first category allowed turns the restriction on
+	// Disallowing a category when there is no restriction allows every other category
+	settings := getSettings(ctx, client, guildID)
+	allowed := []int{}
+	if len(settings.TriviaCategories) == 0 && !allow {
+		categories, err := getTriviaCategories()
+		if err != nil {
+			return "Failed to get trivia categories! " + strings.Title(err.Error())
+		}
+		for _, c := range categories {
+			allowed = append(allowed, c.ID)
+		}
+	} else {
+		allowed = settings.TriviaCategories
+	}
+
+	updated := []int{}
+	for _, id := range allowed {
+		if id != category.ID {
+			updated = append(updated, id)
+		}
+	}
+	if allow {
+		updated = append(updated, category.ID)
+	} else if len(updated) == 0 {
+		return "You can't disallow every category! Allow the ones you want instead."
+	}
+
+	err = updateSettings(ctx, client, guildID, bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "trivia_categories", Value: updated},
+		}},
+	})
+	if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+		return "Error occurred while updating database! " + strings.Title(err.Error())
+	}
+
+	if allow {
+		return category.Name + " is now allowed in this server!"
+	}
+	return category.Name + " is no longer allowed in this server!"
+}
+
+// Not a command
+// Checks if a category can be played according to the server settings
+func isTriviaCategoryAllowed(settings GuildSettings, categoryID int) (bool) {
+	if len(settings.TriviaCategories) == 0 {
+		return true
+	}
+	for _, id := range settings.TriviaCategories {
+		if id == categoryID {
+			return true
+		}
+	}
+	return false
+}
+
 // Trivia is a function that starts a trivia game session
-func Trivia(session *discordgo.Session, message *discordgo.MessageCreate, mongoURI string, guildID int, guildName string, userID int, userName string) (string, *discordgo.MessageEmbed, string, string) {
+// Returns an error message, the question embed, the letter of the correct answer and the question itself
+func Trivia(session *discordgo.Session, message *discordgo.MessageCreate, mongoURI string, guildID int, guildName string, userID int, userName string, opts TriviaOptions) (string, *discordgo.MessageEmbed, string, TriviaQuestion) {
 	// Connect to MongoDB
 	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
 	if err != nil {
 		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
-		return "Error occurred creating MongoDB client! " + strings.Title(err.Error()), nil, "", ""
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error()), nil, "", TriviaQuestion{}
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
@@ -42,7 +314,7 @@ func Trivia(session *discordgo.Session, message *discordgo.MessageCreate, mongoU
 	err = client.Connect(ctx)
 	if err != nil {
 		fmt.Printf("Error occurred connecting to database! %s\n", err)
-		return "Error occurred while connecting to database! " + strings.Title(err.Error()), nil, "", ""
+		return "Error occurred while connecting to database! " + strings.Title(err.Error()), nil, "", TriviaQuestion{}
 	}
 
 	// Disconnect from database
@@ -64,7 +336,16 @@ func Trivia(session *discordgo.Session, message *discordgo.MessageCreate, mongoU
 
 	// Wait 5 seconds before playing trivia again
 	if time.Now().Unix() - lastTrivia/1000 < 5 && commands.IsOwner(userID) == false{
-		return "<@" + strconv.Itoa(userID) + ">, you must wait 5 seconds before playing trivia again!", nil, "", ""
+		return "<@" + strconv.Itoa(userID) + ">, you must wait 5 seconds before playing trivia again!", nil, "", TriviaQuestion{}
+	}
+
+	// Check the category against the server's allowed categories
+	// If no category was picked, pick one of the allowed ones at random
+	settings := getSettings(ctx, client, guildID)
+	if opts.Category.ID != 0 && !isTriviaCategoryAllowed(settings, opts.Category.ID) {
+		return "That category isn't allowed in this server! Use `mary trivia categories` to see the ones that are.", nil, "", TriviaQuestion{}
+	} else if opts.Category.ID == 0 && len(settings.TriviaCategories) > 0 {
+		opts.Category.ID = settings.TriviaCategories[rand.Intn(len(settings.TriviaCategories))]
 	}
 
 	// If the user is not on cooldown, set the last_trivia field to now
@@ -81,11 +362,31 @@ func Trivia(session *discordgo.Session, message *discordgo.MessageCreate, mongoU
 		},
 	)
 
+	questions, errMsg := fetchTriviaQuestions(opts, 1)
+	if errMsg != "" {
+		return errMsg, nil, "", TriviaQuestion{}
+	}
+	embed, correctLetter, question := formatTriviaQuestion(questions[0])
+
+	// Return the embed
+	return "", embed, correctLetter, question
+}
+
+// Not a command
+// Gets a number of questions from the trivia API
+func fetchTriviaQuestions(opts TriviaOptions, amount int) ([]TriviaQuestion, string) {
 	// Make a request to the trivia API
-	resp, err := http.Get("https://opentdb.com/api.php?amount=1&type=multiple")
+	url := "https://opentdb.com/api.php?amount=" + strconv.Itoa(amount) + "&type=" + opts.Type
+	if opts.Category.ID != 0 {
+		url += "&category=" + strconv.Itoa(opts.Category.ID)
+	}
+	if opts.Difficulty != "" {
+		url += "&difficulty=" + opts.Difficulty
+	}
+	resp, err := http.Get(url)
 	if err != nil {
 		fmt.Printf("Failed to get trivia question! %s\n", err)
-		return "Failed to get trivia question!" + strings.Title(err.Error()), nil, "", ""
+		return nil, "Failed to get trivia question!" + strings.Title(err.Error())
 	}
 	defer resp.Body.Close()
 
@@ -97,29 +398,43 @@ func Trivia(session *discordgo.Session, message *discordgo.MessageCreate, mongoU
 	err = json.NewDecoder(resp.Body).Decode(&triviaResponse)
 	if err != nil {
 		fmt.Printf("Failed to parse trivia question! %s\n", err)
-		return "Failed to parse trivia question!" + strings.Title(err.Error()), nil, "", ""
+		return nil, "Failed to parse trivia question!" + strings.Title(err.Error())
 	}
 
-	if triviaResponse.ResponseCode != 0 || len(triviaResponse.Results) == 0 {
+	// Response code 1 means there aren't enough questions for that combination of options
+	if triviaResponse.ResponseCode == 1 {
+		return nil, "There are not enough questions for that combination of category, difficulty and type! Try something else."
+	} else if triviaResponse.ResponseCode != 0 || len(triviaResponse.Results) == 0 {
 		fmt.Printf("Failed to get trivia question! Response code: %d\n", triviaResponse.ResponseCode)
-		return "Failed to get trivia question!" + strings.Title(err.Error()), nil, "", ""
+		return nil, "Failed to get trivia question! Response code: " + strconv.Itoa(triviaResponse.ResponseCode)
 	}
+	return triviaResponse.Results, ""
+}
 
-	// Select the first question from the response and shuffle the answer choices
-	question := triviaResponse.Results[0]
+// Not a command
+// Shuffles the choices of a question and builds the embed for it
+// Returns the embed, the letter of the correct answer and the question with its HTML entities decoded
+func formatTriviaQuestion(question TriviaQuestion) (*discordgo.MessageEmbed, string, TriviaQuestion) {
+	// Shuffle the answer choices
+	// True/false questions always show True first
 	choices := append(question.Incorrect, question.Correct)
-	rand.Shuffle(len(choices), func(i, j int) {
-		choices[i], choices[j] = choices[j], choices[i]
-	})
+	if question.Type == "boolean" {
+		choices = []string{"True", "False"}
+	} else {
+		rand.Shuffle(len(choices), func(i, j int) {
+			choices[i], choices[j] = choices[j], choices[i]
+		})
+	}
 
 	// Format the choices with A, B, C, D
-	formattedChoices := []string{"A", "B", "C", "D"}
+	formattedChoices := []string{"A", "B", "C", "D"}[:len(choices)]
 	for i, choice := range choices {
 		formattedChoices[i] += ") " + choice
 	}
 
 	// Decode the HTML entities in the question and choices
 	question.Question = html.UnescapeString(question.Question)
+	question.Category = html.UnescapeString(question.Category)
 	for i, choice := range formattedChoices {
 		formattedChoices[i] = html.UnescapeString(choice)
 	}
@@ -132,73 +447,86 @@ func Trivia(session *discordgo.Session, message *discordgo.MessageCreate, mongoU
 			break
 		}
 	}
+	question.Correct = html.UnescapeString(question.Correct)
 
 	// Capitalize the first letter of the difficulty
 	question.Difficulty = strings.Title(question.Difficulty)
 
 	// Send the question as a rich embed
+	questionType := "Multiple Choice"
+	if question.Type == "boolean" {
+		questionType = "True/False"
+	}
 	embed := &discordgo.MessageEmbed{
 		Title:       question.Question,
-		Description: fmt.Sprintf("Category: %s \nDifficulty: %s", question.Category, question.Difficulty),
+		Description: fmt.Sprintf("Category: %s \nDifficulty: %s \nType: %s", question.Category, question.Difficulty, questionType),
 		Color:       0xffc0cb,
 		Fields: []*discordgo.MessageEmbedField{
 			{Name: "Choices", Value: strings.Join(formattedChoices, "\n"), Inline: false},
 		},
 	}
+	return embed, correctLetter, question
+}
 
-	// Return the embed
-	return "", embed, correctLetter, question.Difficulty
+// Check a response against the correct answer
+// Users can answer with the letter or the answer itself
+func IsCorrectAnswer(response string, correctLetter string, question TriviaQuestion) (bool) {
+	response = strings.ToLower(strings.TrimSpace(response))
+	return response == strings.ToLower(correctLetter) || response == strings.ToLower(question.Correct)
+}
+
+// Not a command
+// Checks if a message is an answer at all: one of the choice letters, or one of the choices written out
+// Trivia games use this so chatting in the channel isn't taken as a wrong answer
+func isTriviaAnswer(response string, question TriviaQuestion) (bool) {
+	response = strings.ToLower(strings.TrimSpace(response))
+	choices := append([]string{question.Correct}, question.Incorrect...)
+	if question.Type == "boolean" {
+		choices = []string{"True", "False"}
+	}
+	for i, choice := range choices {
+		if i < 4 && response == strings.ToLower(string(rune('A' + i))) {
+			return true
+		}
+		if response == strings.ToLower(html.UnescapeString(choice)) {
+			return true
+		}
+	}
+	return false
 }
 
 // Wait for the user to respond; This is the equivalent of channelMessageWait in Discord.js
 func WaitForResponse(session *discordgo.Session, channelID string, authorID string) (string, error) {
-	// Create a channel for receiving the user's response
-    responseChan := make(chan string)
-
-    // Create a message handler that listens for the user's response
-    handler := func(s *discordgo.Session, m *discordgo.MessageCreate) {
-        // Ignore messages from other users or channels
-        if m.Author.ID != authorID || m.ChannelID != channelID {
-            return
-        }
-
-        // Send the response to the response channel
-        responseChan <- m.Content
-    }
-
-    // Add the message handler to the session
-    session.AddHandler(handler)
-
-    // Wait for the user's response or for a timeout of 10 seconds
-    select {
-    case response := <-responseChan:
-        return response, nil 
-    case <-time.After(10 * time.Second):
-        return "You ran out of time!", nil // Return an error indicating a timeout
-    }
+	// Wait for the user's response or for a timeout of 10 seconds
+	// Reactions from the user are ignored
+	wait := commands.Listen(commands.WaitKey{UserID: authorID, ChannelID: channelID}, 10*time.Second)
+	defer wait.Cancel()
+	for {
+		event, ok := wait.Next()
+		if !ok {
+			return "You ran out of time!", nil // Return an error indicating a timeout
+		}
+		if event.Message != nil {
+			return event.Message.Content, nil
+		}
+	}
 }
 
 // Pay the user for their correct answer
-func PayForCorrectAnswer(session *discordgo.Session, message *discordgo.MessageCreate, difficulty string, mongoURI string, guildID int, guildName string, userID int, userName string, amount int) (string) {
+// The payout is scaled by the difficulty and type of the question, plus a bonus for the user's answer streak
+// A negative amount takes away a lost wager
+// Record the answer with RecordTriviaAnswer first so that the streak is up to date
+func PayForCorrectAnswer(session *discordgo.Session, message *discordgo.MessageCreate, difficulty string, questionType string, mongoURI string, guildID int, guildName string, userID int, userName string, amount int) (string) {
 	// Calculate the amount of coins to pay the user
+	wager := amount
+	difficulty = strings.ToLower(difficulty)
+	if _, ok := triviaPayouts[questionType]; !ok {
+		questionType = "multiple"
+	}
 	if amount == 0 {
-		switch strings.ToLower(difficulty) {
-		case "easy":
-			amount = 50
-		case "medium":
-			amount = 100
-		case "hard":
-			amount = 200
-		}
+		amount = triviaPayouts[questionType][difficulty]
 	} else if amount > 0 {
-		switch strings.ToLower(difficulty) {
-		case "easy":
-			amount *= 2
-		case "medium":
-			amount *= 3
-		case "hard":
-			amount *= 5
-		}
+		amount = int(float64(amount) * triviaMultipliers[questionType][difficulty])
 	}
 
 	// Connect to MongoDB
@@ -223,7 +551,27 @@ func PayForCorrectAnswer(session *discordgo.Session, message *discordgo.MessageC
 	serverDatabase := client.Database(strconv.Itoa(guildID))
 	userCollection := serverDatabase.Collection("Users")
 
+	// Add the streak bonus to correct answers
+	bonus := 0
+	var stats triviaStats
+	if amount > 0 {
+		err = userCollection.FindOne(ctx, bson.M{"guild_id": guildID, "user_id": userID}).Decode(&stats)
+		if err != nil {
+			fmt.Printf("Error occurred while finding user in database! %s\n", err)
+			return "Error occurred while finding user in database! " + strings.Title(err.Error())
+		}
+		bonus = triviaStreakBonus(amount, stats.Streak)
+		amount += bonus
+	}
+
 	// Update the user's balance
+	// Wagers also count towards the user's net coins from trivia
+	update := bson.D{
+		{Key: "balance", Value: amount},
+	}
+	if wager != 0 {
+		update = append(update, bson.E{Key: "trivia_net", Value: amount})
+	}
 	_, err = userCollection.UpdateOne(
 		ctx,
 		bson.D{
@@ -231,16 +579,18 @@ func PayForCorrectAnswer(session *discordgo.Session, message *discordgo.MessageC
 			{Key: "guild_id", Value: guildID},
 		},
 		bson.D{
-			{Key: "$inc", Value: bson.D{
-				{Key: "balance", Value: amount},
-			}},
+			{Key: "$inc", Value: update},
 		},
 	)
 	if err != nil {
 		fmt.Printf("Error occurred while updating user's balance! %s\n", err)
 		return "Error occurred while updating user's balance! " + strings.Title(err.Error())
 	}
+	addSeasonEarnings(ctx, userCollection, guildID, userID, int64(amount))
 	// Success
+	if bonus > 0 {
+		return "<@" + strconv.Itoa(userID) + ">, you have been paid " + strconv.Itoa(amount) + " coins, including a " + strconv.Itoa(bonus) + " coin bonus for your streak of " + strconv.Itoa(stats.Streak) + "! 🔥"
+	}
 	return "<@" + strconv.Itoa(userID) + ">, you have been paid " + strconv.Itoa(amount) + " coins!"
 }
 
@@ -0,0 +1,618 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"github.com/bwmarrin/discordgo"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	commands "mary-bot/commands"
+)
+
+// The options a host can pick when starting a multiplayer trivia game
+// mary trivia game [rounds] [entry fee] [speed] [category] [easy|medium|hard] [truefalse]
+type TriviaGameOptions struct {
+	Rounds   int
+	EntryFee int
+	Speed    bool // Speed scoring gives points to everyone who answers correctly, more for faster answers
+	Question TriviaOptions
+}
+
+// A player in a multiplayer trivia game
+type triviaPlayer struct {
+	UserID   int
+	UserName string
+	Score    int
+	Correct  int
+	Paid     bool // Whether the player paid the entry fee
+}
+
+// How long players have to join and to answer each question
+const triviaJoinTime = 30 * time.Second
+const triviaAnswerTime = 20 * time.Second
+
+// Points for a correct answer by difficulty
+var triviaGamePoints = map[string]int{"easy": 100, "medium": 200, "hard": 300}
+
+// Share of the prize pool for first, second and third place
+var triviaPrizeShares = []int{50, 30, 20}
+
+// Only one game can run in a channel at a time
+var triviaGames = map[string]bool{}
+var triviaGamesMutex sync.Mutex
+
+// Parse the arguments after "mary trivia game" into options
+// The first number is the amount of rounds and the second is the entry fee, and any more numbers are a mistake
+func ParseTriviaGameOptions(args []string) (TriviaGameOptions, string) {
+	gameOpts := TriviaGameOptions{Rounds: 5}
+	questionArgs := []string{}
+	numbers := 0
+	for _, arg := range args {
+		number, err := strconv.Atoi(arg)
+		if err == nil && numbers == 2 {
+			return gameOpts, "A trivia game only takes two numbers, the rounds and then the entry fee!"
+		} else if err == nil {
+			if number < 0 {
+				return gameOpts, "Please specify positive numbers for the rounds and entry fee!"
+			}
+			if numbers == 0 {
+				gameOpts.Rounds = number
+			} else {
+				gameOpts.EntryFee = number
+			}
+			numbers++
+		} else if strings.ToLower(arg) == "speed" {
+			gameOpts.Speed = true
+		} else {
+			questionArgs = append(questionArgs, arg)
+		}
+	}
+	if gameOpts.Rounds < 1 || gameOpts.Rounds > 20 {
+		return gameOpts, "A trivia game can have between 1 and 20 rounds!"
+	}
+
+	questionOpts, err := ParseTriviaOptions(questionArgs)
+	if err != "" {
+		return gameOpts, err
+	}
+	gameOpts.Question = questionOpts
+	return gameOpts, ""
+}
+
+// Not a command
+// Listens to every message in a channel until the timeout runs out or handle returns true
+// Messages are handled one at a time, in the order they arrive
+func listenToChannel(channelID string, timeout time.Duration, handle func(m *discordgo.MessageCreate, elapsed time.Duration) bool) {
+	wait := commands.Listen(commands.WaitKey{ChannelID: channelID}, timeout)
+	defer wait.Cancel()
+	for {
+		event, ok := wait.Next()
+		if !ok {
+			return
+		}
+		// Reactions and button presses in the channel are ignored
+		if event.Message != nil && handle(event.Message, event.Elapsed) {
+			return
+		}
+	}
+}
+
+// Not a command
+// Takes the entry fee from a player, only if they have enough coins
+func payTriviaEntryFee(ctx context.Context, userCollection *mongo.Collection, guildID int, userID int, fee int) (bool) {
+	result, err := userCollection.UpdateOne(
+		ctx,
+		bson.D{
+			{Key: "user_id", Value: userID},
+			{Key: "guild_id", Value: guildID},
+			{Key: "balance", Value: bson.D{
+				{Key: "$gte", Value: fee},
+			}},
+		},
+		bson.D{
+			{Key: "$inc", Value: bson.D{
+				{Key: "balance", Value: -fee},
+			}},
+		},
+	)
+	if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+		return false
+	}
+	if result.ModifiedCount == 1 {
+		// Entry fees count against season earnings the same as a bet, so only winning more than the fee counts
+		addSeasonEarnings(ctx, userCollection, guildID, userID, -int64(fee))
+	}
+	return result.ModifiedCount == 1
+}
+
+// Not a command
+// Sorts the players by score, highest first
+func sortTriviaPlayers(players map[int]*triviaPlayer) ([]*triviaPlayer) {
+	sorted := []*triviaPlayer{}
+	for _, player := range players {
+		sorted = append(sorted, player)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Score == sorted[j].Score {
+			return sorted[i].Correct > sorted[j].Correct
+		}
+		return sorted[i].Score > sorted[j].Score
+	})
+	return sorted
+}
+
+// Not a command
+// Builds the scoreboard embed shown between questions and at the end of the game
+func triviaScoreboard(title string, players map[int]*triviaPlayer) (*discordgo.MessageEmbed) {
+	lines := []string{}
+	for i, player := range sortTriviaPlayers(players) {
+		if i == 10 {
+			lines = append(lines, fmt.Sprintf("...and %d more", len(players)-10))
+			break
+		}
+		lines = append(lines, fmt.Sprintf("**%d.** %s: %d points (%d correct)", i+1, player.UserName, player.Score, player.Correct))
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "Nobody has any points yet!")
+	}
+	return &discordgo.MessageEmbed{
+		Title:       title,
+		Description: strings.Join(lines, "\n"),
+		Color:       0xffc0cb,
+	}
+}
+
+// mary trivia game -> runs a trivia game that everyone in the channel can answer
+// Blocks until the game is over, sending the questions and scoreboards as it goes
+// A hostID of 0 means Mary is hosting (for tournaments)
+func TriviaGame(session *discordgo.Session, channelID string, mongoURI string, guildID int, guildName string, hostID int, hostName string, gameOpts TriviaGameOptions) (string) {
+	// Make sure there isn't already a game in this channel
+	triviaGamesMutex.Lock()
+	if triviaGames[channelID] {
+		triviaGamesMutex.Unlock()
+		return "There is already a trivia game running in this channel!"
+	}
+	triviaGames[channelID] = true
+	triviaGamesMutex.Unlock()
+	defer func() {
+		triviaGamesMutex.Lock()
+		delete(triviaGames, channelID)
+		triviaGamesMutex.Unlock()
+	}()
+
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error())
+	}
+
+	// The game can run for a few minutes, so the timeout covers every round
+	gameLength := triviaJoinTime + time.Duration(gameOpts.Rounds)*(triviaAnswerTime+5*time.Second) + time.Minute
+	ctx, cancel := context.WithTimeout(context.Background(), gameLength)
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error())
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	userCollection := client.Database(strconv.Itoa(guildID)).Collection("Users")
+
+	// Check the category against the server's allowed categories
+	// If no category was picked, pick one of the allowed ones at random
+	settings := getSettings(ctx, client, guildID)
+	if gameOpts.Question.Category.ID != 0 && !isTriviaCategoryAllowed(settings, gameOpts.Question.Category.ID) {
+		return "That category isn't allowed in this server! Use `mary trivia categories` to see the ones that are."
+	} else if gameOpts.Question.Category.ID == 0 && len(settings.TriviaCategories) > 0 {
+		gameOpts.Question.Category.ID = settings.TriviaCategories[rand.Intn(len(settings.TriviaCategories))]
+	}
+
+	questions, errMsg := fetchTriviaQuestions(gameOpts.Question, gameOpts.Rounds)
+	if errMsg != "" {
+		return errMsg
+	}
+
+	// Add a player to the game, taking their entry fee
+	players := map[int]*triviaPlayer{}
+	join := func(userID int, userName string) (bool, string) {
+		if _, ok := players[userID]; ok {
+			return false, "<@" + strconv.Itoa(userID) + ">, you have already joined!"
+		}
+		res := IsPlaying(ctx, client, guildID, guildName, userID, userName)
+		if res != "" {
+			return false, res
+		}
+		// Joining is stopped by jail the same as mary trivia
+		res = Restricted(mongoURI, guildID, userID, "trivia", "")
+		if res != "" {
+			return false, "<@" + strconv.Itoa(userID) + "> " + res
+		}
+		if gameOpts.EntryFee > 0 && !payTriviaEntryFee(ctx, userCollection, guildID, userID, gameOpts.EntryFee) {
+			return false, "<@" + strconv.Itoa(userID) + ">, you don't have enough coins to pay the entry fee!"
+		}
+		players[userID] = &triviaPlayer{UserID: userID, UserName: userName, Paid: gameOpts.EntryFee > 0}
+		return true, "<@" + strconv.Itoa(userID) + "> joined the game!"
+	}
+
+	// Give everyone their entry fee back, e.g. if the game is cancelled
+	refund := func() {
+		for _, player := range players {
+			if player.Paid {
+				userCollection.UpdateOne(
+					ctx,
+					bson.D{
+						{Key: "user_id", Value: player.UserID},
+						{Key: "guild_id", Value: guildID},
+					},
+					bson.D{
+						{Key: "$inc", Value: bson.D{
+							{Key: "balance", Value: gameOpts.EntryFee},
+						}},
+					},
+				)
+				addSeasonEarnings(ctx, userCollection, guildID, player.UserID, int64(gameOpts.EntryFee))
+			}
+		}
+	}
+
+	// Games with an entry fee have a join phase, otherwise anyone can answer at any time
+	if gameOpts.EntryFee > 0 {
+		if hostID != 0 {
+			joined, res := join(hostID, hostName)
+			if !joined {
+				return res
+			}
+		}
+		session.ChannelMessageSendEmbed(channelID, &discordgo.MessageEmbed{
+			Title:       "Trivia Game",
+			Description: fmt.Sprintf("A %d round trivia game is starting! The entry fee is %d coins.\nType `join` in the next %d seconds to play.", gameOpts.Rounds, gameOpts.EntryFee, int(triviaJoinTime.Seconds())),
+			Color:       0xffc0cb,
+		})
+		listenToChannel(channelID, triviaJoinTime, func(m *discordgo.MessageCreate, elapsed time.Duration) bool {
+			if strings.ToLower(strings.TrimSpace(m.Content)) != "join" {
+				return false
+			}
+			userID, err := strconv.Atoi(m.Author.ID)
+			if err != nil {
+				return false
+			}
+			_, res := join(userID, m.Author.Username)
+			session.ChannelMessageSend(channelID, res)
+			return false
+		})
+		if len(players) < 2 {
+			refund()
+			return "Not enough players joined, so the game has been cancelled. Entry fees have been refunded."
+		}
+	} else {
+		session.ChannelMessageSendEmbed(channelID, &discordgo.MessageEmbed{
+			Title:       "Trivia Game",
+			Description: fmt.Sprintf("A %d round trivia game is starting! Anyone can answer with the letter or the answer itself.", gameOpts.Rounds),
+			Color:       0xffc0cb,
+		})
+	}
+	time.Sleep(2 * time.Second)
+
+	for round, q := range questions {
+		embed, correctLetter, question := formatTriviaQuestion(q)
+		embed.Author = &discordgo.MessageEmbedAuthor{Name: fmt.Sprintf("Question %d/%d", round+1, len(questions))}
+		if gameOpts.Speed {
+			embed.Footer = &discordgo.MessageEmbedFooter{Text: "Speed round: faster correct answers earn more points!"}
+		} else {
+			embed.Footer = &discordgo.MessageEmbedFooter{Text: "The first correct answer wins the points!"}
+		}
+		session.ChannelMessageSendEmbed(channelID, embed)
+
+		// Each player only gets one answer per question so they can't spam every letter
+		answered := map[int]bool{}
+		winners := []string{}
+		points := triviaGamePoints[strings.ToLower(question.Difficulty)]
+		listenToChannel(channelID, triviaAnswerTime, func(m *discordgo.MessageCreate, elapsed time.Duration) bool {
+			userID, err := strconv.Atoi(m.Author.ID)
+			if err != nil || answered[userID] {
+				return false
+			}
+			// Anything that isn't a letter or one of the choices is just chat, and doesn't use up their answer
+			if !isTriviaAnswer(m.Content, question) {
+				return false
+			}
+			player, ok := players[userID]
+			if !ok {
+				// Only players who paid can answer in games with an entry fee
+				if gameOpts.EntryFee > 0 {
+					return false
+				}
+				if IsPlaying(ctx, client, guildID, guildName, userID, m.Author.Username) != "" {
+					return false
+				}
+				// Players who can't use mary trivia can't answer in a game either
+				if Restricted(mongoURI, guildID, userID, "trivia", "") != "" {
+					return false
+				}
+				player = &triviaPlayer{UserID: userID, UserName: m.Author.Username}
+				players[userID] = player
+			}
+			answered[userID] = true
+
+			// Game answers count towards everyone's trivia stats
+			correct := IsCorrectAnswer(m.Content, correctLetter, question)
+			err = recordTriviaAnswer(ctx, client, guildID, userID, channelID, question, correct, elapsed, 0)
+			if err != nil {
+				fmt.Printf("Error occurred while recording trivia answer! %s\n", err)
+			}
+
+			if correct {
+				earned := points
+				if gameOpts.Speed {
+					// Between half and all of the points depending on how fast the answer was
+					remaining := 1 - elapsed.Seconds()/triviaAnswerTime.Seconds()
+					earned = points/2 + int(float64(points/2)*remaining)
+				}
+				player.Score += earned
+				player.Correct++
+				winners = append(winners, fmt.Sprintf("<@%d> (+%d)", userID, earned))
+				if !gameOpts.Speed {
+					return true
+				}
+			}
+			// In paid speed games, end the question once everyone has answered
+			return gameOpts.Speed && gameOpts.EntryFee > 0 && len(answered) == len(players)
+		})
+
+		if len(winners) == 0 {
+			session.ChannelMessageSend(channelID, "Nobody got it! The correct answer was " + correctLetter + ") " + question.Correct + ".")
+		} else {
+			session.ChannelMessageSend(channelID, "The correct answer was " + correctLetter + ") " + question.Correct + "! Points: " + strings.Join(winners, ", "))
+		}
+
+		// Show the live scoreboard between questions
+		if round < len(questions)-1 {
+			session.ChannelMessageSendEmbed(channelID, triviaScoreboard("Scoreboard", players))
+			time.Sleep(3 * time.Second)
+		}
+	}
+
+	// Split the prize pool between the top three players with points
+	standings := sortTriviaPlayers(players)
+	winners := []*triviaPlayer{}
+	for _, player := range standings {
+		if player.Score > 0 && len(winners) < len(triviaPrizeShares) {
+			winners = append(winners, player)
+		}
+	}
+	final := triviaScoreboard("Final Standings", players)
+	pool := 0
+	for _, player := range players {
+		if player.Paid {
+			pool += gameOpts.EntryFee
+		}
+	}
+	if pool > 0 && len(winners) == 0 {
+		refund()
+		final.Footer = &discordgo.MessageEmbedFooter{Text: "Nobody scored any points, so entry fees have been refunded."}
+	} else if pool > 0 {
+		totalShares := 0
+		for i := range winners {
+			totalShares += triviaPrizeShares[i]
+		}
+		paidOut := 0
+		prizes := make([]int, len(winners))
+		for i := range winners {
+			prizes[i] = pool * triviaPrizeShares[i] / totalShares
+			paidOut += prizes[i]
+		}
+		prizes[0] += pool - paidOut // Rounding leftovers go to first place
+
+		prizeLines := []string{}
+		for i, winner := range winners {
+			userCollection.UpdateOne(
+				ctx,
+				bson.D{
+					{Key: "user_id", Value: winner.UserID},
+					{Key: "guild_id", Value: guildID},
+				},
+				bson.D{
+					{Key: "$inc", Value: bson.D{
+						{Key: "balance", Value: prizes[i]},
+					}},
+				},
+			)
+			addSeasonEarnings(ctx, userCollection, guildID, winner.UserID, int64(prizes[i]))
+			prizeLines = append(prizeLines, fmt.Sprintf("<@%d> won %d coins!", winner.UserID, prizes[i]))
+		}
+		final.Fields = append(final.Fields, &discordgo.MessageEmbedField{
+			Name:  fmt.Sprintf("Prizes (pool of %d coins)", pool),
+			Value: strings.Join(prizeLines, "\n"),
+		})
+	}
+	session.ChannelMessageSendEmbed(channelID, final)
+	return ""
+}
+
+// A trivia tournament waiting to start
+// They are stored so a restart doesn't lose them, and StartTriviaTournaments starts them when it's time
+type TriviaTournament struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	GuildID   int                `bson:"guild_id"`
+	GuildName string             `bson:"guild_name"`
+	ChannelID string             `bson:"channel_id"`
+	StartsAt  time.Time          `bson:"starts_at"`
+	Reminded  bool               `bson:"reminded"` // Whether the one minute warning has been sent
+	Status    string             `bson:"status"` // scheduled, started or missed
+	Options   TriviaGameOptions  `bson:"options"`
+}
+
+// A tournament this late, e.g. because Mary was offline when it was due, is called off instead of started
+const triviaTournamentGrace = 10 * time.Minute
+
+// mary trivia tournament [minutes] [rounds] [entry fee] ... (admin only) -> announces a trivia game that starts later
+func ScheduleTriviaTournament(session *discordgo.Session, channelID string, mongoURI string, guildID int, guildName string, minutes int, gameOpts TriviaGameOptions) (string) {
+	if minutes < 1 || minutes > 24*60 {
+		return "A tournament has to start between 1 minute and 24 hours from now!"
+	}
+
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error())
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	startTime := time.Now().Add(time.Duration(minutes) * time.Minute)
+	_, err = client.Database(globalDatabase).Collection("Tournaments").InsertOne(ctx, TriviaTournament{
+		GuildID:   guildID,
+		GuildName: guildName,
+		ChannelID: channelID,
+		StartsAt:  startTime,
+		Reminded:  minutes <= 1, // There's no time for a warning
+		Status:    "scheduled",
+		Options:   gameOpts,
+	})
+	if err != nil {
+		fmt.Printf("Error occurred while inserting into database! %s\n", err)
+		return "Error occurred while inserting into database! " + strings.Title(err.Error())
+	}
+
+	scoring := "First correct answer"
+	if gameOpts.Speed {
+		scoring = "Speed"
+	}
+	announcement := &discordgo.MessageEmbed{
+		Title:       "🏆 Trivia Tournament",
+		Description: fmt.Sprintf("A %d round trivia tournament starts <t:%d:R>!", gameOpts.Rounds, startTime.Unix()),
+		Color:       0xffc0cb,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Entry Fee", Value: strconv.Itoa(gameOpts.EntryFee) + " coins", Inline: true},
+			{Name: "Scoring", Value: scoring, Inline: true},
+		},
+	}
+	if gameOpts.Question.Category.ID != 0 {
+		announcement.Fields = append(announcement.Fields, &discordgo.MessageEmbedField{Name: "Category", Value: gameOpts.Question.Category.Name, Inline: true})
+	}
+	if gameOpts.Question.Difficulty != "" {
+		announcement.Fields = append(announcement.Fields, &discordgo.MessageEmbedField{Name: "Difficulty", Value: strings.Title(gameOpts.Question.Difficulty), Inline: true})
+	}
+	session.ChannelMessageSendEmbed(channelID, announcement)
+	return ""
+}
+
+// Not a command
+// Sends the one minute warnings for tournaments and starts the ones that are due, run by the scheduler
+// Each tournament is claimed before anything is sent, so a warning or a game only ever happens once
+func StartTriviaTournaments(mongoURI string) {
+	if notifySession == nil {
+		return
+	}
+
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx)
+
+	tournamentCollection := client.Database(globalDatabase).Collection("Tournaments")
+	now := time.Now()
+	cursor, err := tournamentCollection.Find(ctx, bson.D{
+		{Key: "status", Value: "scheduled"},
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "starts_at", Value: bson.D{{Key: "$lte", Value: now}}}},
+			bson.D{
+				{Key: "reminded", Value: false},
+				{Key: "starts_at", Value: bson.D{{Key: "$lte", Value: now.Add(time.Minute)}}},
+			},
+		}},
+	})
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return
+	}
+	var tournaments []TriviaTournament
+	err = cursor.All(ctx, &tournaments)
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return
+	}
+
+	for _, tournament := range tournaments {
+		// Not due yet, so it only needs its warning
+		if tournament.StartsAt.After(now) {
+			result, err := tournamentCollection.UpdateOne(
+				ctx,
+				bson.D{{Key: "_id", Value: tournament.ID}, {Key: "status", Value: "scheduled"}, {Key: "reminded", Value: false}},
+				bson.D{{Key: "$set", Value: bson.D{{Key: "reminded", Value: true}}}},
+			)
+			if err != nil {
+				fmt.Printf("Error occurred while updating tournament! %s\n", err)
+				continue
+			}
+			if result.ModifiedCount == 1 {
+				postChannel(tournament.ChannelID, "@here The trivia tournament starts in 1 minute! Get ready!")
+			}
+			continue
+		}
+
+		status := "started"
+		if now.Sub(tournament.StartsAt) > triviaTournamentGrace {
+			status = "missed"
+		}
+		result, err := tournamentCollection.UpdateOne(
+			ctx,
+			bson.D{{Key: "_id", Value: tournament.ID}, {Key: "status", Value: "scheduled"}},
+			bson.D{{Key: "$set", Value: bson.D{{Key: "status", Value: status}}}},
+		)
+		if err != nil {
+			fmt.Printf("Error occurred while updating tournament! %s\n", err)
+			continue
+		}
+		if result.ModifiedCount == 0 {
+			continue
+		}
+		if status == "missed" {
+			postChannel(tournament.ChannelID, "The trivia tournament that was due <t:" + strconv.FormatInt(tournament.StartsAt.Unix(), 10) + ":R> couldn't be started in time, so it has been called off.")
+			continue
+		}
+		// The game takes minutes, so it runs on its own rather than holding up the scheduler
+		go func(tournament TriviaTournament) {
+			res := TriviaGame(notifySession, tournament.ChannelID, mongoURI, tournament.GuildID, tournament.GuildName, 0, "", tournament.Options)
+			if res != "" {
+				postChannel(tournament.ChannelID, res)
+			}
+		}(tournament)
+	}
+}
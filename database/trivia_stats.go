@@ -0,0 +1,348 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"github.com/bwmarrin/discordgo"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// A single answered trivia question, stored in the TriviaAnswers collection
+type TriviaAnswer struct {
+	UserID       int       `bson:"user_id"`
+	GuildID      int       `bson:"guild_id"`
+	Category     string    `bson:"category"`
+	Difficulty   string    `bson:"difficulty"`
+	Type         string    `bson:"type"`
+	Correct      bool      `bson:"correct"`
+	ResponseTime int64     `bson:"response_time"` // In milliseconds
+	Wager        int       `bson:"wager"`
+	AnsweredAt   time.Time `bson:"answered_at"`
+}
+
+// The trivia counters kept on each user's document
+type triviaStats struct {
+	UserName   string `bson:"user_name"`
+	Answered   int    `bson:"trivia_answered"`
+	Correct    int    `bson:"trivia_correct"`
+	Streak     int    `bson:"trivia_streak"`
+	BestStreak int    `bson:"trivia_best_streak"`
+	Net        int64  `bson:"trivia_net"` // Coins won or lost from trivia wagers
+}
+
+// Users need to answer this many questions before they show up on the accuracy leaderboard
+const triviaLeaderboardMinimum = 20
+
+// Every answer in a streak after the second adds 10% to the payout, up to double
+const triviaStreakBonusStart = 2
+const triviaStreakBonusPercent = 10
+const triviaStreakBonusMax = 100
+
+// Not a command
+// Works out the bonus for a correct answer with the user's current streak
+func triviaStreakBonus(amount int, streak int) (int) {
+	percent := (streak - triviaStreakBonusStart) * triviaStreakBonusPercent
+	if percent <= 0 {
+		return 0
+	} else if percent > triviaStreakBonusMax {
+		percent = triviaStreakBonusMax
+	}
+	return amount * percent / 100
+}
+
+// Not a command
+// Stores an answered question, updates the user's counters and streak, and records the answer as an event
+func recordTriviaAnswer(ctx context.Context, client *mongo.Client, guildID int, userID int, channelID string, question TriviaQuestion, correct bool, responseTime time.Duration, wager int) (error) {
+	err := updateTriviaStats(ctx, client, guildID, userID, question, correct, responseTime, wager)
+	if err != nil {
+		return err
+	}
+	recordEvent(ctx, client, GameEvent{GuildID: guildID, UserID: userID, ChannelID: channelID, Type: "trivia_answer", Correct: correct})
+	return nil
+}
+
+// Not a command
+// Stores an answered question and updates the user's counters and streak
+func updateTriviaStats(ctx context.Context, client *mongo.Client, guildID int, userID int, question TriviaQuestion, correct bool, responseTime time.Duration, wager int) (error) {
+	serverDatabase := client.Database(strconv.Itoa(guildID))
+	_, err := serverDatabase.Collection("TriviaAnswers").InsertOne(ctx, TriviaAnswer{
+		UserID:       userID,
+		GuildID:      guildID,
+		Category:     question.Category,
+		Difficulty:   strings.ToLower(question.Difficulty),
+		Type:         question.Type,
+		Correct:      correct,
+		ResponseTime: responseTime.Milliseconds(),
+		Wager:        wager,
+		AnsweredAt:   time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	userCollection := serverDatabase.Collection("Users")
+	filter := bson.D{
+		{Key: "user_id", Value: userID},
+		{Key: "guild_id", Value: guildID},
+	}
+	if !correct {
+		// A wrong answer breaks the streak
+		_, err = userCollection.UpdateOne(
+			ctx,
+			filter,
+			bson.D{
+				{Key: "$inc", Value: bson.D{
+					{Key: "trivia_answered", Value: 1},
+				}},
+				{Key: "$set", Value: bson.D{
+					{Key: "trivia_streak", Value: 0},
+				}},
+			},
+		)
+		return err
+	}
+
+	// Increase the streak, then raise the best streak if it was beaten
+	var stats triviaStats
+	err = userCollection.FindOneAndUpdate(
+		ctx,
+		filter,
+		bson.D{
+			{Key: "$inc", Value: bson.D{
+				{Key: "trivia_answered", Value: 1},
+				{Key: "trivia_correct", Value: 1},
+				{Key: "trivia_streak", Value: 1},
+			}},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&stats)
+	if err != nil {
+		return err
+	}
+	_, err = userCollection.UpdateOne(
+		ctx,
+		filter,
+		bson.D{
+			{Key: "$max", Value: bson.D{
+				{Key: "trivia_best_streak", Value: stats.Streak},
+			}},
+		},
+	)
+	return err
+}
+
+// Stores a question answered in single player trivia
+// A timeout should be recorded as an incorrect answer so that streaks can't be kept by waiting out hard questions
+func RecordTriviaAnswer(mongoURI string, guildID int, userID int, channelID string, question TriviaQuestion, correct bool, responseTime time.Duration, wager int) (string) {
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error())
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	err = recordTriviaAnswer(ctx, client, guildID, userID, channelID, question, correct, responseTime, wager)
+	if err != nil {
+		fmt.Printf("Error occurred while recording trivia answer! %s\n", err)
+		return "Error occurred while recording trivia answer! " + strings.Title(err.Error())
+	}
+	return ""
+}
+
+// mary trivia stats [@user] -> shows a user's accuracy, streaks and net coins from trivia
+func TriviaStats(mongoURI string, guildID int, guildName string, userID int, userName string) (string, *discordgo.MessageEmbed) {
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error()), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error()), nil
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	// Check if user exists in database
+	res := IsPlaying(ctx, client, guildID, guildName, userID, userName)
+	if res != "" {
+		return res, nil
+	}
+
+	serverDatabase := client.Database(strconv.Itoa(guildID))
+	var stats triviaStats
+	err = serverDatabase.Collection("Users").FindOne(ctx, bson.M{"guild_id": guildID, "user_id": userID}).Decode(&stats)
+	if err != nil {
+		fmt.Printf("Error occurred while finding user in database! %s\n", err)
+		return "Error occurred while finding user in database! " + strings.Title(err.Error()), nil
+	}
+	if stats.Answered == 0 {
+		return "<@" + strconv.Itoa(userID) + "> hasn't answered any trivia questions yet!", nil
+	}
+
+	// Group the answers by category to get the accuracy for each
+	cursor, err := serverDatabase.Collection("TriviaAnswers").Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{
+			{Key: "user_id", Value: userID},
+			{Key: "guild_id", Value: guildID},
+		}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$category"},
+			{Key: "answered", Value: bson.D{{Key: "$sum", Value: 1}}},
+			{Key: "correct", Value: bson.D{{Key: "$sum", Value: bson.D{
+				{Key: "$cond", Value: bson.A{"$correct", 1, 0}},
+			}}}},
+			{Key: "response_time", Value: bson.D{{Key: "$avg", Value: "$response_time"}}},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "answered", Value: -1}}}},
+	})
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return "Error occurred while selecting from database! " + strings.Title(err.Error()), nil
+	}
+	var categories []struct {
+		Category     string  `bson:"_id"`
+		Answered     int     `bson:"answered"`
+		Correct      int     `bson:"correct"`
+		ResponseTime float64 `bson:"response_time"`
+	}
+	err = cursor.All(ctx, &categories)
+	if err != nil {
+		fmt.Printf("Error occurred while decoding result! %s\n", err)
+		return "Error occurred while decoding result! " + strings.Title(err.Error()), nil
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title: stats.UserName + "'s Trivia Stats",
+		Color: 0xffc0cb,
+		Fields: []*discordgo.MessageEmbedField{
+			{
+				Name:   "Accuracy",
+				Value:  fmt.Sprintf("%.1f%% (%d/%d)", float64(stats.Correct)*100/float64(stats.Answered), stats.Correct, stats.Answered),
+				Inline: true,
+			},
+			{
+				Name:   "Current Streak",
+				Value:  strconv.Itoa(stats.Streak),
+				Inline: true,
+			},
+			{
+				Name:   "Best Streak",
+				Value:  strconv.Itoa(stats.BestStreak),
+				Inline: true,
+			},
+			{
+				Name:   "Net Coins From Wagers",
+				Value:  strconv.FormatInt(stats.Net, 10) + " coins",
+				Inline: true,
+			},
+		},
+	}
+
+	// Show the categories the user has played the most (embeds are limited to 25 fields)
+	lines := []string{}
+	for i, category := range categories {
+		if i == 15 {
+			break
+		}
+		lines = append(lines, fmt.Sprintf("%s: %.1f%% (%d/%d), %.1fs avg", category.Category, float64(category.Correct)*100/float64(category.Answered), category.Correct, category.Answered, category.ResponseTime/1000))
+	}
+	embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+		Name:  "Accuracy by Category",
+		Value: strings.Join(lines, "\n"),
+	})
+	return "", embed
+}
+
+// mary trivia top -> shows the users with the best trivia accuracy
+// Only users who have answered enough questions are included so that one lucky answer doesn't top the leaderboard
+func TriviaLeaderboard(mongoURI string, guildID int) (string, *discordgo.MessageEmbed) {
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error()), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error()), nil
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	userCollection := client.Database(strconv.Itoa(guildID)).Collection("Users")
+	cursor, err := userCollection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{
+			{Key: "guild_id", Value: guildID},
+			{Key: "trivia_answered", Value: bson.D{{Key: "$gte", Value: triviaLeaderboardMinimum}}},
+		}}},
+		{{Key: "$addFields", Value: bson.D{
+			{Key: "accuracy", Value: bson.D{{Key: "$divide", Value: bson.A{"$trivia_correct", "$trivia_answered"}}}},
+		}}},
+		{{Key: "$sort", Value: bson.D{
+			{Key: "accuracy", Value: -1},
+			{Key: "trivia_answered", Value: -1},
+		}}},
+		{{Key: "$limit", Value: 10}},
+	})
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return "Error occurred while selecting from database! " + strings.Title(err.Error()), nil
+	}
+	var leaders []struct {
+		UserName   string  `bson:"user_name"`
+		Answered   int     `bson:"trivia_answered"`
+		Correct    int     `bson:"trivia_correct"`
+		BestStreak int     `bson:"trivia_best_streak"`
+		Accuracy   float64 `bson:"accuracy"`
+	}
+	err = cursor.All(ctx, &leaders)
+	if err != nil {
+		fmt.Printf("Error occurred while decoding result! %s\n", err)
+		return "Error occurred while decoding result! " + strings.Title(err.Error()), nil
+	}
+	if len(leaders) == 0 {
+		return "Nobody has answered " + strconv.Itoa(triviaLeaderboardMinimum) + " trivia questions yet!", nil
+	}
+
+	lines := []string{}
+	for i, leader := range leaders {
+		lines = append(lines, fmt.Sprintf("**%d.** %s: %.1f%% (%d/%d), best streak %d", i+1, leader.UserName, leader.Accuracy*100, leader.Correct, leader.Answered, leader.BestStreak))
+	}
+	embed := &discordgo.MessageEmbed{
+		Title:       "Trivia Leaderboard",
+		Description: strings.Join(lines, "\n"),
+		Color:       0xffc0cb,
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "Answer at least " + strconv.Itoa(triviaLeaderboardMinimum) + " questions to be ranked",
+		},
+	}
+	return "", embed
+}
@@ -10,13 +10,14 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/bson"
+	"github.com/bwmarrin/discordgo"
 	commands "mary-bot/commands"
 )
 
 // Structs are defined in items.go
 
 
-func Use(mongoURI string, guildID int, guildName string, userID int, userName string, item string, pingedUserID int) (string) {
+func Use(mongoURI string, guildID int, guildName string, userID int, userName string, channelID string, item string, pingedUserID int) (string) {
 	// Connect to MongoDB
 	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
 	if err != nil {
@@ -71,55 +72,54 @@ func Use(mongoURI string, guildID int, guildName string, userID int, userName st
 		return "You do not have that item in your inventory!"
 	}
 
-	// Check if the user has waited a minute since their last use indicated by last_use
-	// If the user has not waited a minute, return an error
-	lastUse := user.LastUse
-	if time.Since(lastUse) < time.Minute && commands.IsOwner(userID) == false {
-		return "You must wait a minute between uses!"
+	// Healing items aren't wasted on someone who isn't hurt
+	if _, ok := healingItems[item]; ok && item != "chocolate" {
+		if health, _ := currentHealth(user); health >= maxHealth {
+			return "You're already at full health!"
+		}
 	}
-	
-	// Update the user's last_use to the current time
-	_, err = userCollection.UpdateOne(
-		ctx,
-		bson.D{
-			{Key: "user_id", Value: userID},
-			{Key: "guild_id", Value: guildID},
-		},
-		bson.D{
-			{Key: "$set", Value: bson.D{
-				{Key: "last_use", Value: time.Now()},
-			}},
-		},
-	)
-	if err != nil {
-		fmt.Printf("Error occurred while updating database! %s", err)
-		return "Error occurred while updating database! " + strings.Title(err.Error())
+
+	// Getting revenge on someone who robbed you skips the cooldown
+	revenge := false
+	if _, ok := crimes[item]; ok && pingedUserID != 0 {
+		revenge, err = takeRevenge(ctx, userCollection, guildID, userID, pingedUserID)
+		if err != nil {
+			fmt.Printf("Error occurred while updating database! %s\n", err)
+			return "Error occurred while updating database! " + strings.Title(err.Error())
+		}
 	}
 
-	// Only update the inventory if they're not using car - car has infinite uses
-	// Do not take away ring until you check that the pinged user isn't married
-	if item != "car" && item != "ring" {
-		// Update the user's inventory to reduce the amount of the item they have
-		user.Inventory[itemIndex].Quantity -= 1
-		if user.Inventory[itemIndex].Quantity == 0 { // If the user has no more of the item, remove it from their inventory
-			user.Inventory = append(user.Inventory[:itemIndex], user.Inventory[itemIndex+1:]...)
+	// Each item has its own cooldown, so using a gun doesn't stop you eating chocolate
+	if commands.IsOwner(userID) == false && !revenge {
+		remaining, err := startCooldown(ctx, userCollection, guildID, userID, item)
+		if err != nil {
+			fmt.Printf("Error occurred while updating database! %s\n", err)
+			return "Error occurred while updating database! " + strings.Title(err.Error())
 		}
-		_, err = userCollection.UpdateOne(
-			ctx,
-			bson.D{
-				{Key: "user_id", Value: userID},
-				{Key: "guild_id", Value: guildID},
-			},
-			bson.D{
-				{Key: "$set", Value: bson.D{
-					{Key: "inventory", Value: user.Inventory},
-				}},
-			},
-		)
+		if remaining > 0 {
+			return "Your " + item + " is still cooling down! You can use it again in " + formatCooldown(remaining) + "."
+		}
+	}
+
+	// Wear the item down, or use it up if it doesn't have durability
+	// Do not take away ring until you check that the pinged user isn't married
+	aftermath := "" // Anything else that happened, added to the end of the reply
+	if item != "ring" {
+		used, broke, err := wearItem(ctx, userCollection, guildID, userID, item)
 		if err != nil {
 			fmt.Printf("Error occurred while updating database! %s\n", err)
 			return "Error occurred while updating database! " + strings.Title(err.Error())
 		}
+		if !used {
+			return "You do not have enough of that item in your inventory to use!"
+		}
+		if broke && getItemStats(item).Durability > 0 {
+			aftermath = " Your " + item + " broke!"
+		}
+	}
+	if item != "ring" {
+		// The ring only counts as used once the proposal goes through
+		recordEvent(ctx, client, GameEvent{GuildID: guildID, UserID: userID, Type: "use", Item: item, TargetID: pingedUserID, Amount: 1})
 	}
 	
 	// Check what the item is
@@ -146,10 +146,25 @@ func Use(mongoURI string, guildID int, guildName string, userID int, userName st
 				fmt.Printf("Error occurred while updating database! %s\n", err)
 				return "Error occurred while updating database! " + strings.Title(err.Error())
 			}
+			addSeasonEarnings(ctx, userCollection, guildID, userID, 1000000)
+			recordEvent(ctx, client, GameEvent{GuildID: guildID, UserID: userID, Type: "golden_ticket", Item: item})
 			return "You found a golden ticket! You won 1000000 coins!"
 		}
-		// Otherwise, just return a normal message
-		return "You ate some chocolate. Yum!"
+		// Otherwise, it still helps a little
+		health, err := heal(ctx, userCollection, guildID, user, healingItems[item])
+		if err != nil {
+			fmt.Printf("Error occurred while updating database! %s\n", err)
+			return "Error occurred while updating database! " + strings.Title(err.Error())
+		}
+		return fmt.Sprintf("You ate some chocolate. Yum! (%d/%d HP)", health, maxHealth)
+
+	case "bandage", "painkillers", "medkit", "giftbox":
+		health, err := heal(ctx, userCollection, guildID, user, healingItems[item])
+		if err != nil {
+			fmt.Printf("Error occurred while updating database! %s\n", err)
+			return "Error occurred while updating database! " + strings.Title(err.Error())
+		}
+		return fmt.Sprintf("You used %s %s and feel much better! You're at %d/%d HP.", itemEmoji(item), strings.Title(item), health, maxHealth)
 		
 	case "car":
 		// Check if the pinged user is rich enough
@@ -161,45 +176,55 @@ func Use(mongoURI string, guildID int, guildName string, userID int, userName st
 			fmt.Printf("That user is not currently playing the game!\n")
 			return "That user is not currently playing the game!"
 		}
-		pingedUserBalance := pingedUser.Balance
-		if pingedUserBalance < 1000 {
-			return "You ran over <@" + strconv.Itoa(pingedUserID) + "> with your car, but they didn't have enough money to pay you!"
+
+		// Crimes can go wrong before anyone gets hurt
+		crimeID, caught, err := attemptCrime(ctx, client, guildID, user, pingedUser, "car")
+		if err != nil {
+			fmt.Printf("Error occurred while updating database! %s\n", err)
+			return "Error occurred while updating database! " + strings.Title(err.Error())
+		}
+		if caught != "" {
+			return caught + aftermath
 		}
 
-		// Otherwise, take 1000 coins from the pinged user and give them to the user
-		_, err = userCollection.UpdateOne(
-			ctx,
-			bson.D{
-				{Key: "user_id", Value: userID},
-				{Key: "guild_id", Value: guildID},
-			},
-			bson.D{
-				{Key: "$inc", Value: bson.D{
-					{Key: "balance", Value: 1000},
-				}},
-			},
-		)
+		// Their gear decides how much of the hit gets through
+		attack, err := resolveAttack(ctx, userCollection, guildID, user, pingedUser, "car")
 		if err != nil {
 			fmt.Printf("Error occurred while updating database! %s\n", err)
 			return "Error occurred while updating database! " + strings.Title(err.Error())
 		}
-		_, err = pingedUserCollection.UpdateOne(
-			ctx,
-			bson.D{
-				{Key: "user_id", Value: pingedUserID},
-				{Key: "guild_id", Value: guildID},
-			},
-			bson.D{
-				{Key: "$inc", Value: bson.D{
-					{Key: "balance", Value: -1000},
-				}},
-			},
-		)
+
+		// Every attack does damage the same way, to whoever came off worse
+		hit, err := damageFromAttack(ctx, userCollection, guildID, user, pingedUser, attack)
 		if err != nil {
 			fmt.Printf("Error occurred while updating database! %s\n", err)
 			return "Error occurred while updating database! " + strings.Title(err.Error())
 		}
-		return "You ran over <@" + strconv.Itoa(pingedUserID) + "> with your car and took 1000 coins from them!"
+		aftermath = describeHit(hit, userID) + aftermath
+		if attack.Blocked || attack.Countered {
+			if attack.Blocked {
+				recordEvent(ctx, client, GameEvent{GuildID: guildID, UserID: pingedUserID, Type: "shield_block", Item: attack.Blocker, TargetID: userID})
+			}
+			return "You tried to run over <@" + strconv.Itoa(pingedUserID) + "> with your car, but " + describeDefense(attack) + "!" + aftermath
+		}
+		takenAmount := revengeLoot(scaleByAttack(1000, attack), revenge)
+		if pingedUser.Balance < takenAmount {
+			return "You ran over <@" + strconv.Itoa(pingedUserID) + "> with your car, but they didn't have enough money to pay you!" + aftermath
+		}
+
+		// Otherwise, take the coins from the pinged user and give them to the user
+		taken, err := stealCoins(ctx, userCollection, guildID, pingedUserID, userID, takenAmount)
+		if err != nil {
+			fmt.Printf("Error occurred while updating database! %s\n", err)
+			return "Error occurred while updating database! " + strings.Title(err.Error())
+		}
+		if !taken {
+			return "You ran over <@" + strconv.Itoa(pingedUserID) + "> with your car, but they didn't have enough money to pay you!" + aftermath
+		}
+		addCrimeLoot(ctx, client, guildID, crimeID, takenAmount)
+		addSeasonEarnings(ctx, userCollection, guildID, userID, takenAmount)
+		reportRobbery(ctx, userCollection, guildID, guildName, channelID, user, pingedUser, "ran you over", takenAmount, revenge)
+		return "You ran over <@" + strconv.Itoa(pingedUserID) + "> with your car and took " + strconv.Itoa(int(takenAmount)) + " coins from them!" + softened(attack) + aftermath
 
 	case "gun": 
 		// Check if the pinged user exists in the database
@@ -211,80 +236,63 @@ func Use(mongoURI string, guildID int, guildName string, userID int, userName st
 			fmt.Printf("That user is not currently playing the game!\n")
 			return "That user is not currently playing the game!"
 		}
-		pingedUserBalance := pingedUser.Balance
 
-		// Check if the pinged user has a shield
-		pingedUserInventory := pingedUser.Inventory
-		shieldIndex := -1
-		for index, item := range pingedUserInventory {
-			if item.Name == "shield" {
-				shieldIndex = index
-				break
-			}
+		// Crimes can go wrong before anyone gets hurt
+		crimeID, caught, err := attemptCrime(ctx, client, guildID, user, pingedUser, "gun")
+		if err != nil {
+			fmt.Printf("Error occurred while updating database! %s\n", err)
+			return "Error occurred while updating database! " + strings.Title(err.Error())
+		}
+		if caught != "" {
+			return caught + aftermath
 		}
 
-		if shieldIndex != -1 {
-			// Reduce the pinged user's shield quantity by 1
-			pingedUser.Inventory[shieldIndex].Quantity -= 1
+		// Their gear can block the bullet, soften it or fight back
+		attack, err := resolveAttack(ctx, userCollection, guildID, user, pingedUser, "gun")
+		if err != nil {
+			fmt.Printf("Error occurred while updating database! %s\n", err)
+			return "Error occurred while updating database! " + strings.Title(err.Error())
+		}
 
-			// If the pinged user has no more shields, remove the shield from their inventory
-			if pingedUser.Inventory[shieldIndex].Quantity == 0 {
-				pingedUser.Inventory = append(pingedUser.Inventory[:shieldIndex], pingedUser.Inventory[shieldIndex+1:]...)
-			}
-			_, err = pingedUserCollection.UpdateOne(
-				ctx,
-				bson.D{
-					{Key: "user_id", Value: pingedUserID},
-					{Key: "guild_id", Value: guildID},
-				},
-				bson.D{
-					{Key: "$set", Value: bson.D{
-						{Key: "inventory", Value: pingedUserInventory},
-					}},
-				},
-			)
+		// Every attack does damage the same way, to whoever came off worse
+		hit, err := damageFromAttack(ctx, userCollection, guildID, user, pingedUser, attack)
+		if err != nil {
+			fmt.Printf("Error occurred while updating database! %s\n", err)
+			return "Error occurred while updating database! " + strings.Title(err.Error())
+		}
+		aftermath = describeHit(hit, userID) + aftermath
+		if attack.Countered {
+			lostAmount := int64(float64(user.Balance) * (rand.Float64() * 0.1 + 0.1)) // Random percentage between 10% and 20% for you to lose
+			err = addCoins(ctx, userCollection, guildID, userID, -lostAmount)
 			if err != nil {
 				fmt.Printf("Error occurred while updating database! %s\n", err)
 				return "Error occurred while updating database! " + strings.Title(err.Error())
 			}
-			return "You shot <@" + strconv.Itoa(pingedUserID) + "> with your gun, but they had a shield and it blocked the bullet!"
+			addSeasonEarnings(ctx, userCollection, guildID, userID, -lostAmount)
+			return "You tried to hold up <@" + strconv.Itoa(pingedUserID) + "> at gunpoint, but " + describeDefense(attack) + "! You lost " + strconv.Itoa(int(lostAmount)) + " coins!" + aftermath
+		}
+		if attack.Blocked {
+			// The person who was shot is the one who survived
+			recordEvent(ctx, client, GameEvent{GuildID: guildID, UserID: pingedUserID, Type: "shield_block", Item: attack.Blocker, TargetID: userID})
+			return "You shot <@" + strconv.Itoa(pingedUserID) + "> with your gun, but " + describeDefense(attack) + "!" + aftermath
 		}
 
-		// Otherwise, get the pinged user balance and rob them for a random percentage amount
-		robbedAmount := int64(float64(pingedUserBalance) * (rand.Float64() * 0.5 + 0.1)) // Random percentage between 10% and 60%
-		_, err = pingedUserCollection.UpdateOne(
-			ctx,
-			bson.D{
-				{Key: "user_id", Value: pingedUserID},
-				{Key: "guild_id", Value: guildID},
-			},
-			bson.D{
-				{Key: "$inc", Value: bson.D{ // Remember that $dec is not a thing
-					{Key: "balance", Value: -robbedAmount}, // Decrement the balance by the robbed amount
-				}},
-			},
-		)
+		// Otherwise, rob them for a random percentage amount
+		robbedAmount := revengeLoot(scaleByAttack(int64(float64(pingedUser.Balance) * (rand.Float64() * 0.5 + 0.1)), attack), revenge) // Random percentage between 10% and 60%
+		taken, err := stealCoins(ctx, userCollection, guildID, pingedUserID, userID, robbedAmount)
 		if err != nil {
 			fmt.Printf("Error occurred while updating database! %s\n", err)
 			return "Error occurred while updating database! " + strings.Title(err.Error())
 		}
+		if !taken {
+			return "You held up <@" + strconv.Itoa(pingedUserID) + "> at gunpoint, but they didn't have any coins on them!" + aftermath
+		}
+		addCrimeLoot(ctx, client, guildID, crimeID, robbedAmount)
+		addSeasonEarnings(ctx, userCollection, guildID, userID, robbedAmount)
+		reportRobbery(ctx, userCollection, guildID, guildName, channelID, user, pingedUser, "held you up at gunpoint", robbedAmount, revenge)
+		return "You held up <@" + strconv.Itoa(pingedUserID) + "> at gunpoint and robbed " + strconv.Itoa(int(robbedAmount)) + " coins from them!" + softened(attack) + aftermath
 
-		// Update the user's balance
-		_, err = userCollection.UpdateOne(
-			ctx,
-			bson.D{
-				{Key: "user_id", Value: userID},
-				{Key: "guild_id", Value: guildID},
-			},
-			bson.D{
-				{Key: "$inc", Value: bson.D{ 
-					{Key: "balance", Value: robbedAmount}, // Increment the balance by the robbed amount
-				}},
-			},
-		)
-		return "You held up <@" + strconv.Itoa(pingedUserID) + "> at gunpoint and robbed " + strconv.Itoa(int(robbedAmount)) + " coins from them!"
-
-	case "bow":
+	case "bow", "crossbow":
 		// Check if the pinged user exists in the database
 		pingedUserCollection := client.Database(strconv.Itoa(guildID)).Collection("Users")
 		pingedUserFilter := bson.M{"guild_id": guildID, "user_id": pingedUserID}
@@ -294,100 +302,59 @@ func Use(mongoURI string, guildID int, guildName string, userID int, userName st
 			fmt.Printf("That user is not currently playing the game!\n")
 			return "That user is not currently playing the game!"
 		}
-		pingedUserBalance := pingedUser.Balance
 
-		// Check if the pinged user has a gun
-		pingedUserInventory := pingedUser.Inventory
-		gunIndex := -1
-		for index, item := range pingedUserInventory {
-			if item.Name == "gun" {
-				gunIndex = index
-				break
-			}
+		// Crimes can go wrong before anyone gets hurt
+		crimeID, caught, err := attemptCrime(ctx, client, guildID, user, pingedUser, item)
+		if err != nil {
+			fmt.Printf("Error occurred while updating database! %s\n", err)
+			return "Error occurred while updating database! " + strings.Title(err.Error())
+		}
+		if caught != "" {
+			return caught + aftermath
 		}
 
-		robbedAmount := int64(float64(pingedUserBalance) * (rand.Float64() * 0.1 + 0.2)) // Random percentage between 20% and 30% for you to rob
-		lostAmount := int64(float64(user.Balance) * (rand.Float64() * 0.1 + 0.1)) // Random percentage between 10% and 20% for you to lose
-
-		// If the pinged user has a gun, then they you lost a percentage of your balance
-		if gunIndex != -1 {
-			_, err = userCollection.UpdateOne(
-				ctx,
-				bson.D{
-					{Key: "user_id", Value: userID},
-					{Key: "guild_id", Value: guildID},
-				},
-				bson.D{
-					{Key: "$inc", Value: bson.D{ // Remember that $dec is not a thing
-						{Key: "balance", Value: -lostAmount},
-					}},
-				},
-			)
-			if err != nil {
-				fmt.Printf("Error occurred while updating database! %s\n", err)
-				return "Error occurred while updating database! " + strings.Title(err.Error())
-			}
-
-			// Reduce the pinged user's gun quantity by 1
-			pingedUser.Inventory[gunIndex].Quantity -= 1
+		// If the pinged user has a stronger weapon equipped, like a gun, then they shoot back and you lose a percentage of your balance
+		attack, err := resolveAttack(ctx, userCollection, guildID, user, pingedUser, item)
+		if err != nil {
+			fmt.Printf("Error occurred while updating database! %s\n", err)
+			return "Error occurred while updating database! " + strings.Title(err.Error())
+		}
 
-			// If the pinged user's gun quantity is 0, then remove it from their inventory
-			if pingedUser.Inventory[gunIndex].Quantity == 0 {
-				pingedUser.Inventory = append(pingedUser.Inventory[:gunIndex], pingedUser.Inventory[gunIndex+1:]...)
-			}
-			
-			// Update the pinged user's inventory
-			_, err = pingedUserCollection.UpdateOne(
-				ctx,
-				bson.D{
-					{Key: "user_id", Value: pingedUserID},
-					{Key: "guild_id", Value: guildID},
-				},
-				bson.D{
-					{Key: "$set", Value: bson.D{
-						{Key: "inventory", Value: pingedUser.Inventory},
-					}},
-				},
-			)
-			if err != nil {
-				fmt.Printf("Error occurred while updating database! %s\n", err)
-				return "Error occurred while updating database! " + strings.Title(err.Error())
-			}
-			return "You tried to rob <@" + strconv.Itoa(pingedUserID) + "> with a bow, but they had a gun and shot you! You lost " + strconv.Itoa(int(lostAmount)) + " coins!"
-		
-		} else {
-			_, err = pingedUserCollection.UpdateOne(
-				ctx,
-				bson.D{
-					{Key: "user_id", Value: pingedUserID},
-					{Key: "guild_id", Value: guildID},
-				},
-				bson.D{
-					{Key: "$inc", Value: bson.D{ // Remember that $dec is not a thing
-						{Key: "balance", Value: -robbedAmount}, // Decrement the balance by the robbed amount
-					}},
-				},
-			)
+		// Every attack does damage the same way, to whoever came off worse
+		hit, err := damageFromAttack(ctx, userCollection, guildID, user, pingedUser, attack)
+		if err != nil {
+			fmt.Printf("Error occurred while updating database! %s\n", err)
+			return "Error occurred while updating database! " + strings.Title(err.Error())
+		}
+		aftermath = describeHit(hit, userID) + aftermath
+		if attack.Countered {
+			lostAmount := int64(float64(user.Balance) * (rand.Float64() * 0.1 + 0.1)) // Random percentage between 10% and 20% for you to lose
+			err = addCoins(ctx, userCollection, guildID, userID, -lostAmount)
 			if err != nil {
 				fmt.Printf("Error occurred while updating database! %s\n", err)
 				return "Error occurred while updating database! " + strings.Title(err.Error())
 			}
+			addSeasonEarnings(ctx, userCollection, guildID, userID, -lostAmount)
+			return "You tried to rob <@" + strconv.Itoa(pingedUserID) + "> with a " + item + ", but " + describeDefense(attack) + "! You lost " + strconv.Itoa(int(lostAmount)) + " coins!" + aftermath
+		}
+		if attack.Blocked {
+			recordEvent(ctx, client, GameEvent{GuildID: guildID, UserID: pingedUserID, Type: "shield_block", Item: attack.Blocker, TargetID: userID})
+			return "You shot <@" + strconv.Itoa(pingedUserID) + "> with your " + item + ", but " + describeDefense(attack) + "!" + aftermath
+		}
 
-			// Update the user's balance
-			_, err = userCollection.UpdateOne(
-				ctx,
-				bson.D{
-					{Key: "user_id", Value: userID},
-					{Key: "guild_id", Value: guildID},
-				},
-				bson.D{
-					{Key: "$inc", Value: bson.D{ 
-						{Key: "balance", Value: robbedAmount}, // Increment the balance by the robbed amount
-					}},
-				},
-			)
-			return "You shot <@" + strconv.Itoa(pingedUserID) + "> and took " + strconv.Itoa(int(robbedAmount)) + " coins from them!"
+		robbedAmount := revengeLoot(scaleByAttack(int64(float64(pingedUser.Balance) * (rand.Float64() * 0.1 + 0.2)), attack), revenge) // Random percentage between 20% and 30% for you to rob
+		taken, err := stealCoins(ctx, userCollection, guildID, pingedUserID, userID, robbedAmount)
+		if err != nil {
+			fmt.Printf("Error occurred while updating database! %s\n", err)
+			return "Error occurred while updating database! " + strings.Title(err.Error())
+		}
+		if !taken {
+			return "You shot <@" + strconv.Itoa(pingedUserID) + ">, but they didn't have any coins on them!" + aftermath
 		}
+		addCrimeLoot(ctx, client, guildID, crimeID, robbedAmount)
+		addSeasonEarnings(ctx, userCollection, guildID, userID, robbedAmount)
+		reportRobbery(ctx, userCollection, guildID, guildName, channelID, user, pingedUser, "shot you with a " + item, robbedAmount, revenge)
+		return "You shot <@" + strconv.Itoa(pingedUserID) + "> and took " + strconv.Itoa(int(robbedAmount)) + " coins from them!" + softened(attack) + aftermath
 	
 	case "ring": // You check if the user is married earlier in the function
 		// Check if the pinged user exists in the database
@@ -437,7 +404,11 @@ func Use(mongoURI string, guildID int, guildName string, userID int, userName st
 			return "Error occurred while updating database! " + strings.Title(err.Error())
 		}
 
+		recordEvent(ctx, client, GameEvent{GuildID: guildID, UserID: userID, Type: "use", Item: item, TargetID: pingedUserID, Amount: 1})
 		if officiallyMarried {
+			// Both of them got married
+			recordEvent(ctx, client, GameEvent{GuildID: guildID, UserID: userID, Type: "marry", TargetID: pingedUserID})
+			recordEvent(ctx, client, GameEvent{GuildID: guildID, UserID: pingedUserID, Type: "marry", TargetID: userID})
 			return "🎉 Congratulations! You and <@" + strconv.Itoa(pingedUserID) + "> are now officially married! 🎉"
 		} else {
 			return "You proposed to <@" + strconv.Itoa(pingedUserID) + "> with a ring! They now have to accept your proposal by using their own ring!"
@@ -446,6 +417,40 @@ func Use(mongoURI string, guildID int, guildName string, userID int, userName st
 	return "" 
 }
 
+// Waits for the person who was proposed to to reply "yes" or "no"
+// Saying yes uses their own ring to accept, and saying no gives the ring back to the person who proposed
+// If they don't reply in time, they can still accept later by using their own ring
+func AwaitProposal(session *discordgo.Session, channelID string, mongoURI string, guildID int, guildName string, userID int, userName string, pingedUserID int, pingedUserName string) {
+	session.ChannelMessageSend(channelID, "<@" + strconv.Itoa(pingedUserID) + ">, do you accept? Reply `yes` or `no` within a minute!")
+
+	wait := commands.Listen(commands.WaitKey{UserID: strconv.Itoa(pingedUserID), ChannelID: channelID}, time.Minute)
+	defer wait.Cancel()
+	for {
+		event, ok := wait.Next()
+		if !ok {
+			session.ChannelMessageSend(channelID, "<@" + strconv.Itoa(pingedUserID) + "> didn't answer in time. They can still accept by using their own ring!")
+			return
+		}
+		if event.Message == nil {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(event.Message.Content)) {
+		case "yes", "y", "i do":
+			res := Use(mongoURI, guildID, guildName, pingedUserID, pingedUserName, channelID, "ring", userID)
+			session.ChannelMessageSend(channelID, res)
+			return
+		case "no", "n":
+			// Cancelling the proposal is the same as the proposer filing for divorce
+			res := Divorce(mongoURI, guildID, guildName, userID, userName, pingedUserID)
+			if strings.HasPrefix(res, "The papers have gone through") {
+				res = "<@" + strconv.Itoa(pingedUserID) + "> turned down the proposal... 💔 <@" + strconv.Itoa(userID) + ">, your ring has been returned."
+			}
+			session.ChannelMessageSend(channelID, res)
+			return
+		}
+	}
+}
+
 // Divorce is its own function because it doesn't use an item
 func Divorce(mongoURI string, guildID int, guildName string, userID int, userName string, pingedUserID int) (string) {
 	// Connect to MongoDB
@@ -14,71 +14,107 @@ import (
 )
 
 // mary rob @pingedUser
-func rob(ctx context.Context, userCollection *mongo.Collection, guildID int, userID int, pingedUserID int) (string) {
+func rob(ctx context.Context, userCollection *mongo.Collection, guildID int, guildName string, channelID string, userID int, pingedUserID int) (string) {
 	// Check if user is robbing themselves
 	if userID == pingedUserID {
 		return "You cannot rob yourself!"
 	}
 	
-	// Check if user has enough money to rob
-	userResult, err := userCollection.FindOne(
-		ctx,
-		bson.D{
-			{Key: "user_id", Value: pingedUserID},
-			{Key: "guild_id", Value: guildID},
-		},
-	).DecodeBytes()
+	// Get both users, the robber for their gear and cooldown and the pinged user for their balance and defenses
+	var user User
+	err := userCollection.FindOne(ctx, bson.M{"guild_id": guildID, "user_id": userID}).Decode(&user)
 	if err != nil {
 		fmt.Printf("Error occurred while selecting from database! %s\n", err)
 		return "Error occurred while selecting from database! " + strings.Title(err.Error())
 	}
-	userBalance := userResult.Lookup("balance").Int64()
-	pingedUserName := userResult.Lookup("user_name").StringValue()
-	if userBalance < 100 {
+	var pingedUser User
+	err = userCollection.FindOne(ctx, bson.M{"guild_id": guildID, "user_id": pingedUserID}).Decode(&pingedUser)
+	if err != nil {
+		fmt.Printf("Error occurred while selecting from database! %s\n", err)
+		return "Error occurred while selecting from database! " + strings.Title(err.Error())
+	}
+	if pingedUser.Balance < 100 {
 		return "That person is too poor to rob!"
 	}
 	
-	// Check if user has robbed in the last 5 minutes
-	userLastRob := userResult.Lookup("last_rob").Time()
-	if time.Now().Sub(userLastRob) < 5 * time.Minute {
-		return "You have already robbed someone in the last 5 minutes! Please wait " + strconv.Itoa(int(5 - time.Now().Sub(userLastRob).Minutes())) + " minutes before robbing again."
+	// Getting revenge on someone who robbed you skips the cooldown
+	revenge, err := takeRevenge(ctx, userCollection, guildID, userID, pingedUserID)
+	if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+		return "Error occurred while updating database! " + strings.Title(err.Error())
 	}
 
-	// Successful robbery
-	// Generate random number between 1-50
-	rand.Seed(time.Now().UnixNano())
-	robAmount := rand.Intn(50) + 1
-	
-	// Update user's balance
-	userCollection.FindOneAndUpdate(
-		ctx,
-		bson.D{
-			{Key: "user_id", Value: pingedUserID},
-			{Key: "guild_id", Value: guildID},
-		},
-		bson.D{
-			{Key: "$inc", Value: bson.D{
-				{Key: "balance", Value: robAmount},
-			}},
-		},
-	)
-	// Update pinged user's balance and user's last rob time
-	userCollection.FindOneAndUpdate(
+	// Check if user has robbed in the last 5 minutes
+	if !revenge && time.Now().Sub(user.LastRob) < 5 * time.Minute {
+		return "You have already robbed someone in the last 5 minutes! Please wait " + strconv.Itoa(int(5 - time.Now().Sub(user.LastRob).Minutes())) + " minutes before robbing again."
+	}
+	_, err = userCollection.UpdateOne(
 		ctx,
 		bson.D{
-			{Key: "user_id", Value: pingedUserID},
+			{Key: "user_id", Value: userID},
 			{Key: "guild_id", Value: guildID},
 		},
 		bson.D{
-			{Key: "$inc", Value: bson.D{
-				{Key: "balance", Value: -robAmount},
-			}},
 			{Key: "$set", Value: bson.D{
 				{Key: "last_rob", Value: time.Now()},
 			}},
 		},
 	)
-	return "You successfully robbed " + strconv.Itoa(robAmount) + " coins from " + pingedUserName + "!"
+	if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+		return "Error occurred while updating database! " + strings.Title(err.Error())
+	}
+
+	// Robbery is a crime, so they might get caught before they get anywhere near
+	client := userCollection.Database().Client()
+	crimeID, caught, err := attemptCrime(ctx, client, guildID, user, pingedUser, "rob")
+	if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+		return "Error occurred while updating database! " + strings.Title(err.Error())
+	}
+	if caught != "" {
+		return caught
+	}
+
+	// The robber fights with whatever weapon they have equipped, and the pinged user's gear can stop them
+	attack, err := resolveAttack(ctx, userCollection, guildID, user, pingedUser, equippedWeapon(user))
+	if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+		return "Error occurred while updating database! " + strings.Title(err.Error())
+	}
+	// Every attack does damage the same way, to whoever came off worse
+	hit, err := damageFromAttack(ctx, userCollection, guildID, user, pingedUser, attack)
+	if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+		return "Error occurred while updating database! " + strings.Title(err.Error())
+	}
+	if attack.Blocked || attack.Countered {
+		if attack.Blocked {
+			recordEvent(ctx, client, GameEvent{GuildID: guildID, UserID: pingedUserID, Type: "shield_block", Item: attack.Blocker, TargetID: userID})
+		}
+		return "You tried to rob " + pingedUser.UserName + ", but " + describeDefense(attack) + "!" + describeHit(hit, userID)
+	}
+
+	// Successful robbery
+	// Generate random number between 1-50
+	rand.Seed(time.Now().UnixNano())
+	robAmount := revengeLoot(scaleByAttack(int64(rand.Intn(50) + 1), attack), revenge)
+	taken, err := stealCoins(ctx, userCollection, guildID, pingedUserID, userID, robAmount)
+	if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+		return "Error occurred while updating database! " + strings.Title(err.Error())
+	}
+	if !taken {
+		return "That person is too poor to rob!"
+	}
+	addCrimeLoot(ctx, client, guildID, crimeID, robAmount)
+	addSeasonEarnings(ctx, userCollection, guildID, userID, robAmount)
+	recordEvent(ctx, client, GameEvent{GuildID: guildID, UserID: userID, Type: "rob", TargetID: pingedUserID, Amount: robAmount})
+	reportRobbery(ctx, userCollection, guildID, guildName, channelID, user, pingedUser, "robbed you", robAmount, revenge)
+	if revenge {
+		return "Revenge! You robbed " + strconv.Itoa(int(robAmount)) + " coins back from " + pingedUser.UserName + "!" + softened(attack) + describeHit(hit, userID)
+	}
+	return "You successfully robbed " + strconv.Itoa(int(robAmount)) + " coins from " + pingedUser.UserName + "!" + softened(attack) + describeHit(hit, userID)
 }
 
 func pay(ctx context.Context, userCollection *mongo.Collection, guildID int, userID int, pingedUserID int, amount int) (string) {
@@ -139,7 +175,7 @@ func pay(ctx context.Context, userCollection *mongo.Collection, guildID int, use
 }
 
 // All the economy commands that require pinging another user
-func UserInteraction(mongoURI string, guildID int, guildName string, userID int, userName string, pingedUserID int, operation string, amount int) (string) {
+func UserInteraction(mongoURI string, guildID int, guildName string, userID int, userName string, channelID string, pingedUserID int, operation string, amount int) (string) {
 	// Connect to MongoDB
 	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
 	if err != nil {
@@ -184,7 +220,7 @@ func UserInteraction(mongoURI string, guildID int, guildName string, userID int,
 
 	switch operation {
 		case "rob":
-			return rob(ctx, userCollection, guildID, userID, pingedUserID)
+			return rob(ctx, userCollection, guildID, guildName, channelID, userID, pingedUserID)
 		case "pay":
 			return pay(ctx, userCollection, guildID, userID, pingedUserID, amount)
 		default: 
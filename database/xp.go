@@ -0,0 +1,372 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"github.com/bwmarrin/discordgo"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Users earn XP for chatting, using commands and answering trivia, and level up as it adds up
+// Only users who are already playing earn XP, so chatting alone never adds anyone to the database
+
+// Messages only earn XP once a minute, and have to be long enough to not be spam
+const messageXPCooldown = time.Minute
+const messageXPMinLength = 5
+const messageXPMin = 15
+const messageXPMax = 25
+
+const commandXP = 5
+const commandXPCooldown = 10 * time.Second
+
+const triviaCorrectXP = 20
+const triviaWrongXP = 5
+
+// Each level adds this many coins to the daily, up to the maximum
+const dailyBonusPerLevel = 10
+const maxDailyBonus = 500
+
+// Shop items that need a level to buy, keyed by the name used in the inventory
+var itemLevelRequirements = map[string]int{
+	"shield": 3,
+	"car":    5,
+}
+
+func init() {
+	onEvent(xpForEvent)
+}
+
+// Not a command
+// Gets how much XP it takes to get from a level to the next one
+func xpToNextLevel(level int) (int64) {
+	return int64(5*level*level + 50*level + 100)
+}
+
+// Not a command
+// Works out a user's level from their total XP, along with the XP into the level and the XP the level needs
+func levelFromXP(xp int64) (int, int64, int64) {
+	level := 0
+	for xp >= xpToNextLevel(level) {
+		xp -= xpToNextLevel(level)
+		level++
+	}
+	return level, xp, xpToNextLevel(level)
+}
+
+// Not a command
+// The extra coins a user gets from their daily at a level
+func dailyLevelBonus(level int) (int) {
+	bonus := level * dailyBonusPerLevel
+	if bonus > maxDailyBonus {
+		return maxDailyBonus
+	}
+	return bonus
+}
+
+// Not a command
+// Gives XP for messages, commands and trivia answers
+func xpForEvent(ctx context.Context, client *mongo.Client, event GameEvent) {
+	var amount int64
+	cooldownField := ""
+	var cooldown time.Duration
+	switch event.Type {
+	case "message":
+		if len(strings.TrimSpace(event.Content)) < messageXPMinLength {
+			return
+		}
+		amount = int64(messageXPMin + rand.Intn(messageXPMax-messageXPMin+1))
+		cooldownField, cooldown = "last_message_xp", messageXPCooldown
+	case "command":
+		amount = commandXP
+		cooldownField, cooldown = "last_command_xp", commandXPCooldown
+	case "trivia_answer":
+		amount = triviaWrongXP
+		if event.Correct {
+			amount = triviaCorrectXP
+		}
+	default:
+		return
+	}
+
+	err := grantXP(ctx, client, event, amount, cooldownField, cooldown)
+	if err != nil {
+		fmt.Printf("Error occurred while giving XP! %s\n", err)
+	}
+}
+
+// Not a command
+// Adds XP to a user and handles any level ups
+// If there is a cooldown field, nothing happens unless the cooldown has passed since the last time
+func grantXP(ctx context.Context, client *mongo.Client, event GameEvent, amount int64, cooldownField string, cooldown time.Duration) (error) {
+	userCollection := client.Database(strconv.Itoa(event.GuildID)).Collection("Users")
+	now := time.Now()
+	filter := bson.D{
+		{Key: "user_id", Value: event.UserID},
+		{Key: "guild_id", Value: event.GuildID},
+	}
+	update := bson.D{
+		{Key: "$inc", Value: bson.D{
+			{Key: "xp", Value: amount},
+		}},
+	}
+	if cooldownField != "" {
+		// Checking the cooldown in the filter means two messages at once can't both earn XP
+		filter = append(filter, bson.E{Key: cooldownField, Value: bson.D{
+			{Key: "$not", Value: bson.D{{Key: "$gt", Value: now.Add(-cooldown)}}},
+		}})
+		update = append(update, bson.E{Key: "$set", Value: bson.D{
+			{Key: cooldownField, Value: now},
+		}})
+	}
+
+	var user User
+	err := userCollection.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		// Not playing, or still cooling down
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	level, _, _ := levelFromXP(user.XP)
+	if level <= user.Level {
+		return nil
+	}
+	// Only the update that actually raises the level announces it
+	result, err := userCollection.UpdateOne(
+		ctx,
+		bson.D{
+			{Key: "user_id", Value: event.UserID},
+			{Key: "guild_id", Value: event.GuildID},
+			{Key: "level", Value: bson.D{{Key: "$not", Value: bson.D{{Key: "$gte", Value: level}}}}}, // Users from before levels have no level stored
+		},
+		bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "level", Value: level},
+			}},
+		},
+	)
+	if err != nil || result.ModifiedCount == 0 {
+		return err
+	}
+	levelUp(ctx, client, event, user.Level, level)
+	recordEvent(ctx, client, GameEvent{GuildID: event.GuildID, UserID: event.UserID, ChannelID: event.ChannelID, Type: "level_up", Amount: int64(level)})
+	return nil
+}
+
+// Not a command
+// Gives out role rewards and announces a level up, depending on the server's settings
+func levelUp(ctx context.Context, client *mongo.Client, event GameEvent, oldLevel int, newLevel int) {
+	settings := getSettings(ctx, client, event.GuildID)
+
+	perks := []string{}
+	for level := oldLevel + 1; level <= newLevel; level++ {
+		roleID, ok := settings.LevelRoles[strconv.Itoa(level)]
+		if ok && notifySession != nil {
+			err := notifySession.GuildMemberRoleAdd(strconv.Itoa(event.GuildID), strconv.Itoa(event.UserID), roleID)
+			if err != nil {
+				fmt.Printf("Error occurred while giving level role! %s\n", err)
+			} else {
+				perks = append(perks, "the <@&"+roleID+"> role")
+			}
+		}
+		for item, required := range itemLevelRequirements {
+			if required == level {
+				perks = append(perks, "buying "+strings.Title(item)+"s")
+			}
+		}
+	}
+	if dailyLevelBonus(newLevel) > dailyLevelBonus(oldLevel) {
+		perks = append(perks, fmt.Sprintf("+%d coins on your daily", dailyLevelBonus(newLevel)))
+	}
+
+	channelID := settings.LevelAnnounce
+	switch settings.LevelAnnounce {
+	case "off":
+		return
+	case "":
+		// Announce where it happened, or in the announcement channel if it didn't happen in a channel
+		channelID = event.ChannelID
+		if channelID == "" {
+			channelID = settings.AnnounceChannel
+		}
+	}
+	if channelID == "" || notifySession == nil {
+		return
+	}
+	content := fmt.Sprintf("🎉 <@%d> reached level %d!", event.UserID, newLevel)
+	if len(perks) > 0 {
+		content += " Unlocked: " + strings.Join(perks, ", ") + "."
+	}
+	// Don't ping the role in the announcement
+	_, err := notifySession.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+		Content:         content,
+		AllowedMentions: &discordgo.MessageAllowedMentions{Users: []string{strconv.Itoa(event.UserID)}},
+	})
+	if err != nil {
+		fmt.Printf("Error occurred while announcing level up! %s\n", err)
+	}
+}
+
+// mary level [optional: @user] -> shows a user's level and progress to the next one
+func Level(mongoURI string, guildID int, guildName string, userID int, userName string) (string, *discordgo.MessageEmbed) {
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error()), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error()), nil
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	// Check if user exists in database
+	res := IsPlaying(ctx, client, guildID, guildName, userID, userName)
+	if res != "" {
+		return res, nil
+	}
+
+	var user User
+	err = client.Database(strconv.Itoa(guildID)).Collection("Users").FindOne(ctx, bson.M{"guild_id": guildID, "user_id": userID}).Decode(&user)
+	if err != nil {
+		fmt.Printf("Error occurred while finding user in database! %s\n", err)
+		return "Error occurred while finding user in database! " + strings.Title(err.Error()), nil
+	}
+	level, progress, needed := levelFromXP(user.XP)
+
+	// List what the next levels unlock
+	settings := getSettings(ctx, client, guildID)
+	unlocks := []string{}
+	for item, required := range itemLevelRequirements {
+		if required > level {
+			unlocks = append(unlocks, fmt.Sprintf("Level %d: buy %ss", required, strings.Title(item)))
+		}
+	}
+	for key, roleID := range settings.LevelRoles {
+		required, _ := strconv.Atoi(key)
+		if required > level {
+			unlocks = append(unlocks, fmt.Sprintf("Level %d: <@&%s>", required, roleID))
+		}
+	}
+	sort.Strings(unlocks)
+	if len(unlocks) == 0 {
+		unlocks = append(unlocks, "Nothing left to unlock!")
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title: userName + "'s Level",
+		Color: 0xffc0cb,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Level", Value: strconv.Itoa(level), Inline: true},
+			{Name: "Progress", Value: fmt.Sprintf("%s %d/%d XP", ProgressBar(progress, needed), progress, needed), Inline: true},
+			{Name: "Total XP", Value: strconv.FormatInt(user.XP, 10), Inline: true},
+			{Name: "Daily Bonus", Value: fmt.Sprintf("+%d coins", dailyLevelBonus(level)), Inline: true},
+			{Name: "Coming Up", Value: strings.Join(unlocks, "\n")},
+		},
+	}
+	return "", embed
+}
+
+// Not a command
+// Draws a text progress bar, e.g. ▰▰▰▱▱▱▱▱▱▱
+func ProgressBar(progress int64, total int64) (string) {
+	const length = 10
+	filled := 0
+	if total > 0 {
+		filled = int(progress * length / total)
+	}
+	return strings.Repeat("▰", filled) + strings.Repeat("▱", length-filled)
+}
+
+// mary levels announce [here/off/#channel] (admin only) -> sets where level ups are announced
+func SetLevelAnnounce(mongoURI string, guildID int, setting string) (string) {
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error())
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	err = updateSettings(ctx, client, guildID, bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "level_announce", Value: setting},
+		}},
+	})
+	if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+		return "Error occurred while updating database! " + strings.Title(err.Error())
+	}
+	switch setting {
+	case "":
+		return "Level ups will be announced in the channel where they happen."
+	case "off":
+		return "Level ups won't be announced anymore."
+	}
+	return "Level ups will be announced in <#" + setting + ">."
+}
+
+// mary levels role [level] [@role/none] (admin only) -> gives a role to users when they reach a level
+func SetLevelRole(mongoURI string, guildID int, level int, roleID string) (string) {
+	if level < 1 {
+		return "Please enter a level of at least 1!"
+	}
+
+	// Connect to MongoDB
+	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		fmt.Printf("Error occurred creating MongoDB client! %s\n", err)
+		return "Error occurred creating MongoDB client! " + strings.Title(err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Timeout for connection is 10 secs
+	defer cancel() // Fix for memory leak
+	err = client.Connect(ctx)
+	if err != nil {
+		fmt.Printf("Error occurred while connecting to database! %s\n", err)
+		return "Error occurred while connecting to database! " + strings.Title(err.Error())
+	}
+
+	// Disconnect from database
+	defer client.Disconnect(ctx) // Occurs as last line of main() function
+
+	key := "level_roles." + strconv.Itoa(level)
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: key, Value: roleID}}}}
+	if roleID == "" {
+		update = bson.D{{Key: "$unset", Value: bson.D{{Key: key, Value: ""}}}}
+	}
+	err = updateSettings(ctx, client, guildID, update)
+	if err != nil {
+		fmt.Printf("Error occurred while updating database! %s\n", err)
+		return "Error occurred while updating database! " + strings.Title(err.Error())
+	}
+	if roleID == "" {
+		return fmt.Sprintf("Level %d no longer gives a role.", level)
+	}
+	return fmt.Sprintf("Users who reach level %d will get the <@&%s> role! Make sure my role is above it.", level, roleID)
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -43,7 +44,10 @@ func main() {
 	// https://github.com/bwmarrin/discordgo/issues/1264
 	discord.Identify.Intents = discordgo.IntentMessageContent
 	discord.AddHandler(createMessage)
-	discord.Identify.Intents = discordgo.IntentsGuildMessages
+	discord.Identify.Intents = discordgo.IntentsGuildMessages | discordgo.IntentsGuildMessageReactions
+
+	// Handlers for commands that wait for a reply, reaction or button press
+	commands.StartAwaiter(discord)
 	
 	err := discord.Open()
 	if err != nil {
@@ -60,6 +64,21 @@ func main() {
 	
 	fmt.Println("Mary, online and ready!")
 
+	// Start background jobs like the stock market
+	database.SetNotifySession(discord)
+	MONGO_URI := os.Getenv("MONGO_URI")
+	if MONGO_URI == "" {
+		fmt.Println("MongoDB URI not found! Background jobs won't run.")
+	} else {
+		database.Schedule("stock market", database.MarketTickInterval(), database.TickMarket)
+		database.Schedule("global stats", database.GlobalSyncInterval, database.SyncGlobalStats)
+		database.Schedule("seasons", time.Minute, database.RolloverSeasons)
+		database.Schedule("trades", time.Minute, database.ExpireTrades)
+		database.Schedule("auctions", 15*time.Second, database.SettleAuctions)
+		database.Schedule("trivia tournaments", 15*time.Second, database.StartTriviaTournaments)
+		database.StartScheduler(MONGO_URI)
+	}
+
 	sc := make(chan os.Signal, 1)
     signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM, os.Interrupt, os.Kill)
     <-sc
@@ -104,7 +123,25 @@ func createMessage(session *discordgo.Session, message *discordgo.MessageCreate)
 	userName := message.Author.Username
 
 	command := strings.Split(message.Content, " ")
+
+	// Every message from a player counts towards their XP, commands included
+	if err1 == nil && err2 == nil && err3 == nil && !message.Author.Bot {
+		database.RecordMessage(MONGO_URI, guildID, userID, message.ChannelID, message.Content, strings.ToLower(command[0]) == "mary")
+	}
+
 	if strings.ToLower(command[0]) == "mary" {
+		// Knocked out players can only look around or go to the hospital until they come round, and jailed players can't touch the economy
+		if len(command) > 1 && err1 == nil && err2 == nil && err3 == nil {
+			subcommand := ""
+			if len(command) > 2 {
+				subcommand = command[2]
+			}
+			if res := database.Restricted(MONGO_URI, guildID, userID, command[1], subcommand); res != "" {
+				session.ChannelMessageSend(message.ChannelID, res)
+				return
+			}
+		}
+
 		switch true {
 		
 		// mary test
@@ -161,20 +198,20 @@ func createMessage(session *discordgo.Session, message *discordgo.MessageCreate)
 							Value: "Shows a random quote.",
 						},{
 							Name: "mary profile [optional: @user]",
-							Value: "Shows your profile or a specified user's profile.",
+							Value: "Shows your profile card or a specified user's profile card.",
 						},{
 							Name: "mary bal [optional: @user]",
 							Value: "Shows your balance or a specified user's balance.",
 						},{
 							Name: "mary inventory",
-							Value: "Shows your inventory.",
+							Value: "Shows your inventory, with how much durability each item has left and any cooldowns.",
 						},{ 
 							Name: "mary give @user [item name] [optional: amount]",
 							Value: "Gives an item to a specified user. The default amount is 1.",
 						},
 					},
 					Footer: &discordgo.MessageEmbedFooter{
-						Text: "Page 1/3",
+						Text: "Page 1/10",
 					},
 				}
 				session.ChannelMessageSendEmbed(message.ChannelID, embed)
@@ -187,7 +224,7 @@ func createMessage(session *discordgo.Session, message *discordgo.MessageCreate)
 				return
 			}
 
-			if pageNumber < 1 || pageNumber > 3 {
+			if pageNumber < 1 || pageNumber > 10 {
 				session.ChannelMessageSend(message.ChannelID, "Please enter a valid page number!")
 				return
 			}
@@ -231,20 +268,20 @@ func createMessage(session *discordgo.Session, message *discordgo.MessageCreate)
 							Value: "Shows a random quote.",
 						},{
 							Name: "mary profile [optional: @user]",
-							Value: "Shows your profile or a specified user's profile.",
+							Value: "Shows your profile card or a specified user's profile card.",
 						},{
 							Name: "mary bal [optional: @user]",
 							Value: "Shows your balance or a specified user's balance.",
 						},{
 							Name: "mary inventory",
-							Value: "Shows your inventory.",
+							Value: "Shows your inventory, with how much durability each item has left and any cooldowns.",
 						},{
 							Name: "mary give @user [item name] [optional: amount]",
 							Value: "Gives an item to a specified user. The default amount is 1.",
 						},
 					},
 					Footer: &discordgo.MessageEmbedFooter{
-						Text: "Page 1/3",
+						Text: "Page 1/10",
 					},
 				}
 				session.ChannelMessageSendEmbed(message.ChannelID, embed)
@@ -265,7 +302,7 @@ func createMessage(session *discordgo.Session, message *discordgo.MessageCreate)
 							Value: "Buys the specified item. The default amount is 1.",
 						},{
 							Name: "mary sell [item name] [optional: amount]",
-							Value: "Sells the specified item at half the original price.",
+							Value: "Sells the specified item for 50% of the original price, or more for rare and epic items.",
 						},{
 							Name: "mary daily",
 							Value: "Gives you 100 coins.",
@@ -273,11 +310,14 @@ func createMessage(session *discordgo.Session, message *discordgo.MessageCreate)
 							Name: "mary pay @user [amount]",
 							Value: "Pays the mentioned user the specified amount of coins.",
 						},{
-							Name: "mary top/leaderboard",
-							Value: "Shows the top 10 users with the highest balance.",
+							Name: "mary top/leaderboard [optional: coins/networth/trivia/streak/gambling/xp] [optional: page number]",
+							Value: "Shows the top 10 users with the highest balance, or another leaderboard. Net worth includes items and stocks, but not anything held in listings, buy orders, auctions or trades.",
+						},{
+							Name: "mary rank [optional: @user] [optional: coins/networth/trivia/streak/gambling/xp]",
+							Value: "Shows where you or the mentioned user are on a leaderboard.",
 						},{
-							Name: "mary trivia [optional: amount]",
-							Value: "Starts a trivia game. Pays 50, 100, or 200 coins upon win depending on the difficulty. You can also gamble for 2X, 3X, 5X your bet.",
+							Name: "mary trivia [optional: category] [optional: easy/medium/hard] [optional: truefalse] [optional: amount]",
+							Value: "Starts a trivia game. Pays 50, 100, or 200 coins upon win depending on the difficulty (half for true/false). You can also gamble for 2X, 3X, 5X your bet. Use `mary trivia categories` to see the categories.",
 						},{
 							Name: "mary gamble [amount]",
 							Value: "Gamble the specified amount of coins.",
@@ -290,49 +330,872 @@ func createMessage(session *discordgo.Session, message *discordgo.MessageCreate)
 						},
 					},
 					Footer: &discordgo.MessageEmbedFooter{
-						Text: "Page 2/3",
+						Text: "Page 2/10",
+					},
+				}
+				session.ChannelMessageSendEmbed(message.ChannelID, embed)	
+			} else if pageNumber == 3 {
+				// Create a rich embed
+				embed := &discordgo.MessageEmbed{
+					Title: "Mary's Commands",
+					Color: 0xffc0cb,
+					Thumbnail: &discordgo.MessageEmbedThumbnail{
+						URL: maryAvatar,
+					},
+					Fields: []*discordgo.MessageEmbedField{{
+						Name: "mary use [item name] @user",
+						Value: "Uses the specified item on the mentioned user. Each item has its own cooldown, and guns, bows, crossbows, cars and shields wear down with use.",
+					},{
+						Name: "mary eat [item name]",
+						Value: "You eat a chocolate. Who knows, maybe you'll get lucky?",
+					},{
+						Name: "mary runover @user",
+						Value: "Run over the mentioned user. Wears down your car, which lasts 10 uses.",
+					},{
+						Name: "mary shoot @user",
+						Value: "Shoot the mentioned user with the gun. If user has no gun, it uses the bow. Wears down your gun/bow.",
+					},{
+						Name: "mary kill @user",
+						Value: "Shoot the mentioned user with the gun. Wears down your gun.",
+					},{
+						Name: "mary marry @user",
+						Value: "Give the mentioned user a ring. If they reply yes or give you one back, congratulations! You're married!",
+					},{
+						Name: "mary divorce @user",
+						Value: "Divorce the mentioned user. You must be married to them or have proposed to them. Gives you back one ring.",
+					},
+				},
+				Footer: &discordgo.MessageEmbedFooter{
+					Text: "Page 3/10",
+				},
+				}
+				session.ChannelMessageSendEmbed(message.ChannelID, embed)
+			} else if pageNumber == 4 {
+				// Create a rich embed
+				embed := &discordgo.MessageEmbed{
+					Title: "Mary's Commands",
+					Color: 0xffc0cb,
+					Thumbnail: &discordgo.MessageEmbedThumbnail{
+						URL: maryAvatar,
+					},
+					Fields: []*discordgo.MessageEmbedField{{
+						Name: "mary trivia categories",
+						Value: "Shows every trivia category. Crossed out categories are not allowed in this server. Pick one by name or by its ID with a # in front, e.g. `mary trivia #9`.",
+					},{
+						Name: "mary trivia stats [optional: @user]",
+						Value: "Shows your trivia accuracy by category, your current and best answer streaks, and your net coins from trivia wagers.",
+					},{
+						Name: "mary trivia top",
+						Value: "Shows the users with the best trivia accuracy. Answer streaks of 3 or more earn bonus coins!",
+					},{
+						Name: "mary trivia game [optional: rounds] [optional: entry fee] [optional: speed] [optional: category/difficulty/truefalse]",
+						Value: "Starts a trivia game that everyone in the channel can answer. The first correct answer wins the points, or faster answers earn more with speed. The top 3 split the entry fees.",
+					},{
+						Name: "mary trivia tournament [minutes] [optional: rounds] [optional: entry fee] ... (admin only)",
+						Value: "Announces a trivia game that starts in the specified number of minutes.",
+					},{
+						Name: "mary trivia allow/disallow [category] (admin only)",
+						Value: "Allows or disallows a trivia category in this server. Use `mary trivia allow all` to allow every category.",
+					},
+				},
+				Footer: &discordgo.MessageEmbedFooter{
+					Text: "Page 4/10",
+				},
+				}
+				session.ChannelMessageSendEmbed(message.ChannelID, embed)
+			} else if pageNumber == 5 {
+				// Create a rich embed
+				embed := &discordgo.MessageEmbed{
+					Title: "Mary's Commands",
+					Color: 0xffc0cb,
+					Thumbnail: &discordgo.MessageEmbedThumbnail{
+						URL: maryAvatar,
+					},
+					Fields: []*discordgo.MessageEmbedField{{
+						Name: "mary stocks",
+						Value: "Shows every stock on the market and how much its price moved in the last tick.",
+					},{
+						Name: "mary stock info [ticker]",
+						Value: "Shows the price, recent change, high and low of a stock.",
+					},{
+						Name: "mary stock buy [ticker] [amount]",
+						Value: "Buys shares of a stock at the market price.",
+					},{
+						Name: "mary stock sell [ticker] [amount]",
+						Value: "Sells shares of a stock at the market price.",
+					},{
+						Name: "mary portfolio [optional: @user]",
+						Value: "Shows the stocks you own with what you paid, what they're worth now and your profit or loss.",
+					},{
+						Name: "mary order [buy/sell/stop/takeprofit] [ticker] [amount] [price] [optional: expiry]",
+						Value: "Places an order that trades when the price is reached: buy at or below, sell or take profit at or above, or stop your losses at or below the price. Orders last 7 days unless you give an expiry like 12h or 3d. You'll get a DM when it fills.",
+					},{
+						Name: "mary orders [optional: cancel [order number]]",
+						Value: "Shows your open orders, or cancels one of them.",
+					},{
+						Name: "mary maryportfolio",
+						Value: "Shows Mary's own portfolio. She trades by herself using moving average crossovers, momentum and mean reversion.",
+					},{
+						Name: "mary stock top",
+						Value: "Ranks everyone's stock returns in this server. Can you beat Mary?",
+					},
+				},
+				Footer: &discordgo.MessageEmbedFooter{
+					Text: "Page 5/10",
+				},
+				}
+				session.ChannelMessageSendEmbed(message.ChannelID, embed)
+			} else if pageNumber == 6 {
+				// Create a rich embed
+				embed := &discordgo.MessageEmbed{
+					Title: "Mary's Commands",
+					Color: 0xffc0cb,
+					Thumbnail: &discordgo.MessageEmbedThumbnail{
+						URL: maryAvatar,
+					},
+					Fields: []*discordgo.MessageEmbedField{{
+						Name: "mary global join/leave",
+						Value: "Joins or leaves the global leaderboard. Your stats from every server you play in are combined, unless a server has opted out.",
+					},{
+						Name: "mary global top [optional: coins/networth/trivia/streak/gambling] [optional: page number]",
+						Value: "Shows the global leaderboard of everyone who has joined.",
+					},{
+						Name: "mary global profile [optional: @user]",
+						Value: "Shows the servers you or the mentioned user play in and your combined stats.",
+					},{
+						Name: "mary global enable/disable (admin only)",
+						Value: "Lets this server's players show up on the global leaderboard, or keeps everyone's stats here private.",
+					},{
+						Name: "mary season [optional: weekly/monthly]",
+						Value: "Shows who has earned the most coins this week or month. The top 3 win coins and a badge when the season ends.",
+					},{
+						Name: "mary season history [optional: weekly/monthly/reset] [optional: season number]",
+						Value: "Shows the winners of past seasons, or the full results of one season.",
+					},{
+						Name: "mary season channel [#channel/none] (admin only)",
+						Value: "Sets the channel where season winners are announced. Defaults to the current channel.",
+					},{
+						Name: "mary season reset (admin only)",
+						Value: "Archives everyone's balance and resets it to 0, starting a new economy season. The richest 3 get a badge.",
+					},
+				},
+				Footer: &discordgo.MessageEmbedFooter{
+					Text: "Page 6/10",
+				},
+				}
+				session.ChannelMessageSendEmbed(message.ChannelID, embed)
+			} else if pageNumber == 7 {
+				// Create a rich embed
+				embed := &discordgo.MessageEmbed{
+					Title: "Mary's Commands",
+					Color: 0xffc0cb,
+					Thumbnail: &discordgo.MessageEmbedThumbnail{
+						URL: maryAvatar,
+					},
+					Fields: []*discordgo.MessageEmbedField{{
+						Name: "mary level [optional: @user]",
+						Value: "Shows your level, your progress to the next one and what it unlocks. Earn XP by chatting, using commands and answering trivia.",
+					},{
+						Name: "mary levels announce [here/off/#channel] (admin only)",
+						Value: "Sets where level ups are announced. `here` announces them in the channel they happened in.",
+					},{
+						Name: "mary levels role [level] [@role/none] (admin only)",
+						Value: "Gives a role to users when they reach a level.",
+					},{
+						Name: "mary achievements [optional: @user]",
+						Value: "Shows the achievements you have unlocked and your progress on the rest. They show up as badges on your profile.",
+					},{
+						Name: "mary title [optional: title/none]",
+						Value: "Lists the titles you have unlocked from achievements, or picks one to show on your profile.",
+					},{
+						Name: "mary background [galaxy/sakura/ocean/none]",
+						Value: "Puts a background you have bought from the shop on your profile card.",
+					},{
+						Name: "mary quests",
+						Value: "Shows your daily and weekly quests and your progress on them. You get new ones every day and every week.",
+					},{
+						Name: "mary quests claim",
+						Value: "Collects the coins for every quest you have finished.",
 					},
+				},
+				Footer: &discordgo.MessageEmbedFooter{
+					Text: "Page 7/10",
+				},
+				}
+				session.ChannelMessageSendEmbed(message.ChannelID, embed)
+			} else if pageNumber == 8 {
+				// Create a rich embed
+				embed := &discordgo.MessageEmbed{
+					Title: "Mary's Commands",
+					Color: 0xffc0cb,
+					Thumbnail: &discordgo.MessageEmbedThumbnail{
+						URL: maryAvatar,
+					},
+					Fields: []*discordgo.MessageEmbedField{{
+						Name: "mary trade @user",
+						Value: "Opens a trade with another user. Anything you offer is held by Mary until the trade closes.",
+					},{
+						Name: "mary trade",
+						Value: "Shows both sides of the trade you are in.",
+					},{
+						Name: "mary trade add/remove [item/coins] [amount]",
+						Value: "Puts items or coins into your side of the trade, or takes them back out. Any change resets both confirmations.",
+					},{
+						Name: "mary trade confirm",
+						Value: "Agrees to the trade as it is, the same as pressing Confirm on the trade. Once both of you confirm, everything is swapped at once.",
+					},{
+						Name: "mary trade cancel",
+						Value: "Cancels the trade and gives everything back. Trades also expire after 10 minutes without changes.",
+					},
+				},
+				Footer: &discordgo.MessageEmbedFooter{
+					Text: "Page 8/10",
+				},
+				}
+				session.ChannelMessageSendEmbed(message.ChannelID, embed)
+			} else if pageNumber == 9 {
+				// Create a rich embed
+				embed := &discordgo.MessageEmbed{
+					Title: "Mary's Commands",
+					Color: 0xffc0cb,
+					Thumbnail: &discordgo.MessageEmbedThumbnail{
+						URL: maryAvatar,
+					},
+					Fields: []*discordgo.MessageEmbedField{{
+						Name: "mary market [optional: item] [optional: page]",
+						Value: "Shows what other users are selling, cheapest first. Add an item name to only see that item.",
+					},{
+						Name: "mary market sell [item] [amount] [price for each]",
+						Value: "Lists items on the market. They're taken out of your inventory until they sell, and listing costs a small fee.",
+					},{
+						Name: "mary market buy [listing number] [optional: amount]",
+						Value: "Buys items from a listing. The seller gets the coins minus the sales tax.",
+					},{
+						Name: "mary market order [item] [amount] [price for each]",
+						Value: "Offers to buy items at your own price. Your coins are held until someone lists the item at or below your price, which sells it to you straight away.",
+					},{
+						Name: "mary market orders [optional: item] [optional: page]",
+						Value: "Shows what other users want to buy, best price first.",
+					},{
+						Name: "mary market mine",
+						Value: "Shows your open listings and buy orders.",
+					},{
+						Name: "mary market cancel [number]",
+						Value: "Takes one of your listings or buy orders down, giving back the items that haven't sold or the coins that haven't been spent.",
+					},{
+						Name: "mary market fee/tax [percent] (admin only)",
+						Value: "Sets the listing fee or sales tax. Both go to the server's treasury.",
+					},{
+						Name: "mary treasury",
+						Value: "Shows how many coins the server has collected from fees and taxes.",
+					},{
+						Name: "mary auction start [item] [amount] [reserve] [duration]",
+						Value: "Auctions items off to the highest bidder. Bids below the reserve aren't accepted, and the duration can be like 30m, 12h or 2d.",
+					},{
+						Name: "mary auction bid [auction number] [amount]",
+						Value: "Bids on an auction. Your coins are held until you're outbid or it ends, and bids near the end push the end back by 2 minutes.",
+					},{
+						Name: "mary auctions",
+						Value: "Shows the auctions running in the server. Use `mary auction [auction number]` for details.",
+					},{
+						Name: "mary auction cancel [auction number]",
+						Value: "Cancels one of your auctions, as long as nobody has bid on it yet.",
+					},
+				},
+				Footer: &discordgo.MessageEmbedFooter{
+					Text: "Page 9/10",
+				},
+				}
+				session.ChannelMessageSendEmbed(message.ChannelID, embed)
+			} else if pageNumber == 10 {
+				// Create a rich embed
+				embed := &discordgo.MessageEmbed{
+					Title: "Mary's Commands",
+					Color: 0xffc0cb,
+					Thumbnail: &discordgo.MessageEmbedThumbnail{
+						URL: maryAvatar,
+					},
+					Fields: []*discordgo.MessageEmbedField{{
+						Name: "mary recipes",
+						Value: "Shows every crafting recipe and which ones you have the items for right now.",
+					},{
+						Name: "mary craft [item] [optional: amount]",
+						Value: "Uses up items from your inventory to make another, e.g. a bow and a gun make a crossbow.",
+					},{
+						Name: "mary open [crate]",
+						Value: "Opens a crate, chest or vault from your inventory for a random reward.",
+					},{
+						Name: "mary droprates [crate]",
+						Value: "Shows the exact odds of everything that can come out of a crate, and how many opens until a rare is guaranteed.",
+					},{
+						Name: "mary history [optional: page]",
+						Value: "Shows your most recent transactions, including every crate you've opened and what you rolled.",
+					},{
+						Name: "mary equip [item]",
+						Value: "Equips a weapon, armor or accessory from your inventory. Your gear is used in every attack, robbery and defense.",
+					},{
+						Name: "mary unequip [optional: slot or item]",
+						Value: "Takes off the gear in a slot (weapon, armor or accessory), or all of it.",
+					},{
+						Name: "mary crimes [optional: @user] [optional: page]",
+						Value: "Shows someone's criminal record. Robbing, shooting and running people over can go wrong, and the better your gear and level the better your chances.",
+					},{
+						Name: "mary bail @user",
+						Value: "Pays to get someone out of jail. Players caught committing a crime are either fined or jailed, and can't use economy commands while they're inside.",
+					},{
+						Name: "mary notify [optional: on/off] [optional: here]",
+						Value: "Turns on DMs telling you who robbed you, how much they took and where, in every server. Add `here` to change just this server, and use `mary notify default here` to undo that. After being robbed you have 15 minutes to get revenge, which skips your cooldown and has a better chance of working.",
+					},{
+						Name: "mary hospital",
+						Value: "Heals you to full health for a fee. Attacks do damage, and at 0 HP you're knocked out for 30 minutes unless you go to the hospital. Bandages, painkillers, medkits and chocolate restore HP.",
+					},
+				},
+				Footer: &discordgo.MessageEmbedFooter{
+					Text: "Page 10/10",
+				},
+				}
+				session.ChannelMessageSendEmbed(message.ChannelID, embed)
+			}
+		}
+		
+		// mary stocks -> shows every stock on the market
+		case strings.ToLower(command[1]) == "stocks" || (strings.ToLower(command[1]) == "stock" && len(command) == 3 && strings.ToLower(command[2]) == "list"):
+			err, res := database.StockList(MONGO_URI)
+			if err != "" {
+				session.ChannelMessageSend(message.ChannelID, err)
+				break
+			}
+			session.ChannelMessageSendEmbed(message.ChannelID, res)
+
+		// mary stock top -> ranks stock returns in the server against Mary's
+		case strings.ToLower(command[1]) == "stock" && len(command) == 3 && (strings.ToLower(command[2]) == "top" || strings.ToLower(command[2]) == "leaderboard"):
+			err, res := database.StockLeaderboard(MONGO_URI, guildID)
+			if err != "" {
+				session.ChannelMessageSend(message.ChannelID, err)
+				break
+			}
+			session.ChannelMessageSendEmbed(message.ChannelID, res)
+
+		// mary maryportfolio -> shows Mary's own portfolio and trades
+		case strings.ToLower(command[1]) == "maryportfolio":
+			err, res := database.ShowMaryPortfolio(MONGO_URI)
+			if err != "" {
+				session.ChannelMessageSend(message.ChannelID, err)
+				break
+			}
+			session.ChannelMessageSendEmbed(message.ChannelID, res)
+
+		// mary stock info/buy/sell [ticker] [amount]
+		case strings.ToLower(command[1]) == "stock":
+			if len(command) < 4 {
+				session.ChannelMessageSend(message.ChannelID, "Please use `mary stock info [ticker]`, `mary stock buy [ticker] [amount]` or `mary stock sell [ticker] [amount]`!")
+				break
+			}
+			if strings.ToLower(command[2]) == "info" {
+				err, res := database.StockInfo(MONGO_URI, command[3])
+				if err != "" {
+					session.ChannelMessageSend(message.ChannelID, err)
+					break
+				}
+				session.ChannelMessageSendEmbed(message.ChannelID, res)
+				break
+			}
+
+			// Buying or selling defaults to one share
+			amount := 1
+			if len(command) > 4 {
+				num, err := strconv.Atoi(command[4])
+				if err != nil {
+					session.ChannelMessageSend(message.ChannelID, "Please enter a valid number of shares!")
+					break
+				}
+				amount = num
+			}
+			switch strings.ToLower(command[2]) {
+			case "buy":
+				res := database.BuyStock(MONGO_URI, guildID, guildName, userID, userName, command[3], amount)
+				session.ChannelMessageSend(message.ChannelID, res)
+			case "sell":
+				res := database.SellStock(MONGO_URI, guildID, guildName, userID, userName, command[3], amount)
+				session.ChannelMessageSend(message.ChannelID, res)
+			default:
+				session.ChannelMessageSend(message.ChannelID, "Please use `mary stock info [ticker]`, `mary stock buy [ticker] [amount]` or `mary stock sell [ticker] [amount]`!")
+			}
+
+		// mary order [buy/sell/stop/takeprofit] [ticker] [amount] [price] [optional: expiry]
+		case strings.ToLower(command[1]) == "order":
+			if len(command) < 6 {
+				session.ChannelMessageSend(message.ChannelID, "Please use `mary order [buy/sell/stop/takeprofit] [ticker] [amount] [price] [optional: expiry]`!")
+				break
+			}
+			amount, err := strconv.Atoi(command[4])
+			if err != nil {
+				session.ChannelMessageSend(message.ChannelID, "Please enter a valid number of shares!")
+				break
+			}
+			price, err := strconv.ParseFloat(command[5], 64)
+			if err != nil {
+				session.ChannelMessageSend(message.ChannelID, "Please enter a valid price!")
+				break
+			}
+			var expiry time.Duration
+			if len(command) > 6 {
+				var errMsg string
+				expiry, errMsg = database.ParseOrderExpiry(command[6])
+				if errMsg != "" {
+					session.ChannelMessageSend(message.ChannelID, errMsg)
+					break
+				}
+			}
+			res := database.PlaceOrder(MONGO_URI, guildID, guildName, userID, userName, message.ChannelID, command[2], command[3], amount, price, expiry)
+			session.ChannelMessageSend(message.ChannelID, res)
+
+		// mary orders [optional: cancel [order number]] -> lists or cancels your open orders
+		case strings.ToLower(command[1]) == "orders":
+			if len(command) > 3 && strings.ToLower(command[2]) == "cancel" {
+				orderID, err := strconv.Atoi(strings.TrimPrefix(command[3], "#"))
+				if err != nil {
+					session.ChannelMessageSend(message.ChannelID, "Please enter a valid order number!")
+					break
+				}
+				res := database.CancelOrder(MONGO_URI, guildID, userID, orderID)
+				session.ChannelMessageSend(message.ChannelID, res)
+				break
+			}
+			err, res := database.ListOrders(MONGO_URI, guildID, userID, userName)
+			if err != "" {
+				session.ChannelMessageSend(message.ChannelID, err)
+				break
+			}
+			session.ChannelMessageSendEmbed(message.ChannelID, res)
+
+		// mary portfolio [@user] -> shows a user's stocks and how they're doing
+		case strings.ToLower(command[1]) == "portfolio":
+			portfolioUserID := userID
+			portfolioUserName := userName
+			if len(message.Mentions) > 0 {
+				portfolioUserID, _ = strconv.Atoi(message.Mentions[0].ID)
+				portfolioUserName = message.Mentions[0].Username
+			}
+			err, res := database.Portfolio(MONGO_URI, guildID, guildName, portfolioUserID, portfolioUserName)
+			if err != "" {
+				session.ChannelMessageSend(message.ChannelID, err)
+				break
+			}
+			session.ChannelMessageSendEmbed(message.ChannelID, res)
+
+		// mary trade [@user/add/remove/confirm/cancel] -> trades items and coins with another user
+		case strings.ToLower(command[1]) == "trade":
+			var err string
+			var res *discordgo.MessageSend
+			if len(command) == 2 {
+				err, res = database.ShowTrade(MONGO_URI, guildID, userID)
+			} else {
+				switch strings.ToLower(command[2]) {
+				case "add", "remove":
+					if len(command) < 4 {
+						session.ChannelMessageSend(message.ChannelID, "Please use `mary trade " + strings.ToLower(command[2]) + " [item/coins] [amount]`!")
+						return
+					}
+					amount := 1
+					if len(command) > 4 {
+						num, convErr := strconv.Atoi(command[4])
+						if convErr != nil {
+							session.ChannelMessageSend(message.ChannelID, "Please specify a valid amount!")
+							return
+						}
+						amount = num
+					}
+					err, res = database.ChangeTradeOffer(MONGO_URI, guildID, guildName, userID, userName, command[3], amount, strings.ToLower(command[2]) == "add")
+				case "confirm", "accept":
+					err, res = database.ConfirmTrade(MONGO_URI, guildID, userID)
+				case "cancel", "decline":
+					err = database.CancelTrade(MONGO_URI, guildID, userID)
+				default:
+					partner := strings.Trim(command[2], "<@!>")
+					partnerID, convErr := strconv.Atoi(partner)
+					if convErr != nil {
+						session.ChannelMessageSend(message.ChannelID, "Please specify a valid user to trade with!")
+						return
+					}
+					if partnerID == userID {
+						session.ChannelMessageSend(message.ChannelID, "You can't trade with yourself!")
+						return
+					}
+					err, res = database.OpenTrade(MONGO_URI, guildID, guildName, userID, userName, message.ChannelID, partnerID)
+					if err == "" {
+						err = "<@" + partner + ">, <@" + strconv.Itoa(userID) + "> wants to trade with you! Add your offer with `mary trade add [item/coins] [amount]`."
+					}
+				}
+			}
+			if err != "" {
+				session.ChannelMessageSend(message.ChannelID, err)
+			}
+			if res != nil {
+				session.ChannelMessageSendComplex(message.ChannelID, res)
+			}
+
+		// mary market [sell/buy/order/orders/cancel/mine/fee/tax/item] -> buys and sells items with other users
+		case strings.ToLower(command[1]) == "market":
+			sub := ""
+			if len(command) > 2 {
+				sub = strings.ToLower(command[2])
+			}
+			switch sub {
+			case "sell", "list":
+				if len(command) != 6 {
+					session.ChannelMessageSend(message.ChannelID, "Please use `mary market sell [item] [amount] [price for each]`!")
+					break
+				}
+				amount, err := strconv.Atoi(command[4])
+				if err != nil {
+					session.ChannelMessageSend(message.ChannelID, "Please enter a valid amount!")
+					break
+				}
+				price, err := strconv.ParseInt(command[5], 10, 64)
+				if err != nil {
+					session.ChannelMessageSend(message.ChannelID, "Please enter a valid price!")
+					break
+				}
+				res := database.ListItem(MONGO_URI, guildID, guildName, userID, userName, message.ChannelID, command[3], amount, price)
+				session.ChannelMessageSend(message.ChannelID, res)
+			case "order":
+				if len(command) != 6 {
+					session.ChannelMessageSend(message.ChannelID, "Please use `mary market order [item] [amount] [price for each]`!")
+					break
+				}
+				amount, err := strconv.Atoi(command[4])
+				if err != nil {
+					session.ChannelMessageSend(message.ChannelID, "Please enter a valid amount!")
+					break
+				}
+				price, err := strconv.ParseInt(command[5], 10, 64)
+				if err != nil {
+					session.ChannelMessageSend(message.ChannelID, "Please enter a valid price!")
+					break
+				}
+				res := database.PlaceItemOrder(MONGO_URI, guildID, guildName, userID, userName, message.ChannelID, command[3], amount, price)
+				session.ChannelMessageSend(message.ChannelID, res)
+			case "orders":
+				// The item and page can be given in either order, like browsing listings
+				item := ""
+				page := 1
+				for _, arg := range command[3:] {
+					if num, err := strconv.Atoi(arg); err == nil {
+						page = num
+					} else {
+						item = arg
+					}
+				}
+				err, res := database.BrowseItemOrders(MONGO_URI, guildID, guildName, item, page)
+				if err != "" {
+					session.ChannelMessageSend(message.ChannelID, err)
+					break
+				}
+				session.ChannelMessageSendEmbed(message.ChannelID, res)
+			case "buy", "cancel":
+				if len(command) < 4 {
+					session.ChannelMessageSend(message.ChannelID, "Please use `mary market " + sub + " [number]`!")
+					break
+				}
+				listingID, err := strconv.Atoi(strings.TrimPrefix(command[3], "#"))
+				if err != nil {
+					session.ChannelMessageSend(message.ChannelID, "Please enter a valid number!")
+					break
+				}
+				if sub == "cancel" {
+					res := database.CancelListing(MONGO_URI, guildID, userID, listingID)
+					session.ChannelMessageSend(message.ChannelID, res)
+					break
+				}
+				amount := 1
+				if len(command) > 4 {
+					amount, err = strconv.Atoi(command[4])
+					if err != nil {
+						session.ChannelMessageSend(message.ChannelID, "Please enter a valid amount!")
+						break
+					}
+				}
+				res := database.BuyListing(MONGO_URI, guildID, guildName, userID, userName, listingID, amount)
+				session.ChannelMessageSend(message.ChannelID, res)
+			case "mine":
+				err, res := database.MyListings(MONGO_URI, guildID, userID, userName)
+				if err != "" {
+					session.ChannelMessageSend(message.ChannelID, err)
+					break
+				}
+				session.ChannelMessageSendEmbed(message.ChannelID, res)
+			case "fee", "tax":
+				if !commands.IsAdmin(session, message, userID) {
+					session.ChannelMessageSend(message.ChannelID, "Apologies, this command is not available to you.")
+					break
+				}
+				if len(command) != 4 {
+					session.ChannelMessageSend(message.ChannelID, "Please use `mary market " + sub + " [percent]`!")
+					break
+				}
+				percent, err := strconv.ParseFloat(strings.TrimSuffix(command[3], "%"), 64)
+				if err != nil {
+					session.ChannelMessageSend(message.ChannelID, "Please enter a valid percentage!")
+					break
+				}
+				res := database.SetMarketRate(MONGO_URI, guildID, sub, percent)
+				session.ChannelMessageSend(message.ChannelID, res)
+			default:
+				// The item and page can be given in either order, e.g. mary market 2 or mary market gun 2
+				item := ""
+				page := 1
+				for _, arg := range command[2:] {
+					if num, err := strconv.Atoi(arg); err == nil {
+						page = num
+					} else {
+						item = arg
+					}
+				}
+				err, res := database.BrowseMarket(MONGO_URI, guildID, guildName, item, page)
+				if err != "" {
+					session.ChannelMessageSend(message.ChannelID, err)
+					break
+				}
+				session.ChannelMessageSendEmbed(message.ChannelID, res)
+			}
+
+		// mary auction [start/bid/cancel/auction number] -> auctions items off to the highest bidder
+		case strings.ToLower(command[1]) == "auction":
+			sub := ""
+			if len(command) > 2 {
+				sub = strings.ToLower(command[2])
+			}
+			switch sub {
+			case "start":
+				if len(command) != 7 {
+					session.ChannelMessageSend(message.ChannelID, "Please use `mary auction start [item] [amount] [reserve] [duration]`!")
+					break
+				}
+				amount, err := strconv.Atoi(command[4])
+				if err != nil {
+					session.ChannelMessageSend(message.ChannelID, "Please enter a valid amount!")
+					break
+				}
+				reserve, err := strconv.ParseInt(command[5], 10, 64)
+				if err != nil {
+					session.ChannelMessageSend(message.ChannelID, "Please enter a valid reserve!")
+					break
+				}
+				duration, errMsg := database.ParseAuctionDuration(command[6])
+				if errMsg != "" {
+					session.ChannelMessageSend(message.ChannelID, errMsg)
+					break
+				}
+				errMsg, res := database.StartAuction(MONGO_URI, guildID, guildName, userID, userName, message.ChannelID, command[3], amount, reserve, duration)
+				if errMsg != "" {
+					session.ChannelMessageSend(message.ChannelID, errMsg)
+					break
+				}
+				session.ChannelMessageSendEmbed(message.ChannelID, res)
+			case "bid":
+				if len(command) != 5 {
+					session.ChannelMessageSend(message.ChannelID, "Please use `mary auction bid [auction number] [amount]`!")
+					break
+				}
+				auctionID, err := strconv.Atoi(strings.TrimPrefix(command[3], "#"))
+				if err != nil {
+					session.ChannelMessageSend(message.ChannelID, "Please enter a valid auction number!")
+					break
+				}
+				amount, err := strconv.ParseInt(command[4], 10, 64)
+				if err != nil {
+					session.ChannelMessageSend(message.ChannelID, "Please enter a valid bid!")
+					break
+				}
+				res := database.BidAuction(MONGO_URI, guildID, guildName, userID, userName, auctionID, amount)
+				session.ChannelMessageSend(message.ChannelID, res)
+			case "cancel":
+				if len(command) != 4 {
+					session.ChannelMessageSend(message.ChannelID, "Please use `mary auction cancel [auction number]`!")
+					break
+				}
+				auctionID, err := strconv.Atoi(strings.TrimPrefix(command[3], "#"))
+				if err != nil {
+					session.ChannelMessageSend(message.ChannelID, "Please enter a valid auction number!")
+					break
+				}
+				res := database.CancelAuction(MONGO_URI, guildID, userID, auctionID)
+				session.ChannelMessageSend(message.ChannelID, res)
+			case "":
+				err, res := database.ListAuctions(MONGO_URI, guildID, guildName)
+				if err != "" {
+					session.ChannelMessageSend(message.ChannelID, err)
+					break
+				}
+				session.ChannelMessageSendEmbed(message.ChannelID, res)
+			default:
+				auctionID, err := strconv.Atoi(strings.TrimPrefix(command[2], "#"))
+				if err != nil {
+					session.ChannelMessageSend(message.ChannelID, "Please use `mary auction start`, `mary auction bid`, `mary auction cancel` or `mary auction [auction number]`!")
+					break
+				}
+				errMsg, res := database.ShowAuction(MONGO_URI, guildID, auctionID)
+				if errMsg != "" {
+					session.ChannelMessageSend(message.ChannelID, errMsg)
+					break
+				}
+				session.ChannelMessageSendEmbed(message.ChannelID, res)
+			}
+
+		// mary auctions -> shows the auctions running in the server
+		case strings.ToLower(command[1]) == "auctions":
+			err, res := database.ListAuctions(MONGO_URI, guildID, guildName)
+			if err != "" {
+				session.ChannelMessageSend(message.ChannelID, err)
+				break
+			}
+			session.ChannelMessageSendEmbed(message.ChannelID, res)
+
+		// mary craft [item] [optional: amount] -> uses up items from your inventory to make another
+		case strings.ToLower(command[1]) == "craft":
+			if len(command) < 3 {
+				session.ChannelMessageSend(message.ChannelID, "Please specify an item to craft! Check `mary recipes` for what you can make.")
+				break
+			}
+			amount := 1
+			item := strings.Join(command[2:], "")
+			if num, err := strconv.Atoi(command[len(command)-1]); err == nil && len(command) > 3 {
+				amount = num
+				item = strings.Join(command[2:len(command)-1], "")
+			}
+			res := database.Craft(MONGO_URI, guildID, guildName, userID, userName, message.ChannelID, item, amount)
+			session.ChannelMessageSend(message.ChannelID, res)
+
+		// mary recipes -> shows every recipe and which ones you can craft right now
+		case strings.ToLower(command[1]) == "recipes":
+			err, res := database.Recipes(MONGO_URI, guildID, guildName, userID, userName)
+			if err != "" {
+				session.ChannelMessageSend(message.ChannelID, err)
+				break
+			}
+			session.ChannelMessageSendEmbed(message.ChannelID, res)
+
+		// mary hospital -> pays to be healed to full health straight away, even if you're knocked out
+		case strings.ToLower(command[1]) == "hospital":
+			res := database.Hospital(MONGO_URI, guildID, guildName, userID, userName)
+			session.ChannelMessageSend(message.ChannelID, res)
+
+		// mary bail @user -> pays to get someone else out of jail
+		case strings.ToLower(command[1]) == "bail":
+			if len(message.Mentions) == 0 {
+				session.ChannelMessageSend(message.ChannelID, "Please mention who you want to bail out of jail!")
+				break
+			}
+			pingedUserID, _ := strconv.Atoi(message.Mentions[0].ID)
+			res := database.Bail(MONGO_URI, guildID, guildName, userID, userName, pingedUserID)
+			session.ChannelMessageSend(message.ChannelID, res)
+
+		// mary notify [on/off/default] [optional: here] -> turns DMs about being robbed on or off everywhere, or just in this server
+		case strings.ToLower(command[1]) == "notify":
+			setting := ""
+			if len(command) > 2 {
+				setting = strings.ToLower(command[2])
+			}
+			here := len(command) > 3 && strings.ToLower(command[3]) == "here"
+			res := database.SetRobberyNotifications(MONGO_URI, guildID, guildName, userID, userName, setting, here)
+			session.ChannelMessageSend(message.ChannelID, res)
+
+		// mary crimes [optional: @user] [optional: page] -> shows the crimes someone has committed
+		case strings.ToLower(command[1]) == "crimes":
+			crimesUserID := userID
+			crimesUserName := userName
+			if len(message.Mentions) > 0 {
+				crimesUserID, _ = strconv.Atoi(message.Mentions[0].ID)
+				crimesUserName = message.Mentions[0].Username
+			}
+			page := 1
+			if num, convErr := strconv.Atoi(command[len(command)-1]); convErr == nil && len(command) > 2 {
+				page = num
+			}
+			err, res := database.Crimes(MONGO_URI, guildID, guildName, crimesUserID, crimesUserName, page)
+			if err != "" {
+				session.ChannelMessageSend(message.ChannelID, err)
+				break
+			}
+			session.ChannelMessageSendEmbed(message.ChannelID, res)
+
+		// mary equip [item] -> puts on a piece of gear from your inventory
+		case strings.ToLower(command[1]) == "equip":
+			if len(command) < 3 {
+				session.ChannelMessageSend(message.ChannelID, "Please specify an item to equip! Weapons, armor and accessories can be equipped.")
+				break
+			}
+			res := database.Equip(MONGO_URI, guildID, guildName, userID, userName, strings.Join(command[2:], ""))
+			session.ChannelMessageSend(message.ChannelID, res)
+
+		// mary unequip [optional: slot or item] -> takes off a piece of gear, or all of it
+		case strings.ToLower(command[1]) == "unequip":
+			res := database.Unequip(MONGO_URI, guildID, guildName, userID, userName, strings.Join(command[2:], ""))
+			session.ChannelMessageSend(message.ChannelID, res)
+
+		// mary open [crate] -> opens a crate from your inventory
+		case strings.ToLower(command[1]) == "open":
+			if len(command) < 3 {
+				session.ChannelMessageSend(message.ChannelID, "Please specify a crate to open! Check `mary droprates [crate]` for what's inside each one.")
+				break
+			}
+			err, frames := database.OpenCrate(MONGO_URI, guildID, guildName, userID, userName, message.ChannelID, strings.Join(command[2:], ""))
+			if err != "" {
+				session.ChannelMessageSend(message.ChannelID, err)
+				break
+			}
+			// Show each step of the opening by editing the same message
+			sent, sendErr := session.ChannelMessageSendEmbed(message.ChannelID, frames[0])
+			for _, frame := range frames[1:] {
+				time.Sleep(1 * time.Second)
+				if sendErr != nil {
+					sent, sendErr = session.ChannelMessageSendEmbed(message.ChannelID, frame)
+					continue
 				}
-				session.ChannelMessageSendEmbed(message.ChannelID, embed)	
-			} else if pageNumber == 3 {
-				// Create a rich embed
-				embed := &discordgo.MessageEmbed{
-					Title: "Mary's Commands",
-					Color: 0xffc0cb,
-					Thumbnail: &discordgo.MessageEmbedThumbnail{
-						URL: maryAvatar,
-					},
-					Fields: []*discordgo.MessageEmbedField{{
-						Name: "mary use [item name] @user",
-						Value: "Uses the specified item on the mentioned user. You can only use one item at a time.",
-					},{
-						Name: "mary eat [item name]",
-						Value: "You eat a chocolate. Who knows, maybe you'll get lucky?",
-					},{
-						Name: "mary runover @user",
-						Value: "Run over the mentioned user. Does not use up car item.",
-					},{
-						Name: "mary shoot @user",
-						Value: "Shoot the mentioned user with the gun. If user has no gun, it uses the bow. Consumes one gun/bow item.",
-					},{
-						Name: "mary kill @user",
-						Value: "Shoot the mentioned user with the gun. Consumes one gun item.",
-					},{
-						Name: "mary marry @user",
-						Value: "Give the mentioned user a ring. If they give you one back, congratulations! You're married!",
-					},{
-						Name: "mary divorce @user",
-						Value: "Divorce the mentioned user. You must be married to them or have proposed to them. Gives you back one ring.",
-					},
-				},
-				Footer: &discordgo.MessageEmbedFooter{
-					Text: "Page 3/3",
-				},
+				session.ChannelMessageEditEmbed(message.ChannelID, sent.ID, frame)
+			}
+
+		// mary droprates [crate] -> shows the exact odds of everything that can come out of a crate
+		case strings.ToLower(command[1]) == "droprates":
+			if len(command) < 3 {
+				session.ChannelMessageSend(message.ChannelID, "Please specify a crate! e.g. `mary droprates crate`")
+				break
+			}
+			err, res := database.DropRates(strings.Join(command[2:], ""))
+			if err != "" {
+				session.ChannelMessageSend(message.ChannelID, err)
+				break
+			}
+			session.ChannelMessageSendEmbed(message.ChannelID, res)
+
+		// mary history [optional: page] -> shows your most recent transactions
+		case strings.ToLower(command[1]) == "history":
+			page := 1
+			if len(command) > 2 {
+				num, convErr := strconv.Atoi(command[2])
+				if convErr != nil {
+					session.ChannelMessageSend(message.ChannelID, "Please enter a valid page number!")
+					break
 				}
-				session.ChannelMessageSendEmbed(message.ChannelID, embed)
+				page = num
 			}
-		}
-		
+			err, res := database.History(MONGO_URI, guildID, userID, userName, page)
+			if err != "" {
+				session.ChannelMessageSend(message.ChannelID, err)
+				break
+			}
+			session.ChannelMessageSendEmbed(message.ChannelID, res)
+
+		// mary treasury -> shows the coins the server has collected from market fees and taxes
+		case strings.ToLower(command[1]) == "treasury":
+			err, res := database.ShowTreasury(MONGO_URI, guildID, guildName)
+			if err != "" {
+				session.ChannelMessageSend(message.ChannelID, err)
+				break
+			}
+			session.ChannelMessageSendEmbed(message.ChannelID, res)
+
 		// mary profile -> shows your profile
 		case strings.ToLower(command[1]) == "profile":
 			// Declare variables so that they can be used outside of the if statement
@@ -343,6 +1206,8 @@ func createMessage(session *discordgo.Session, message *discordgo.MessageCreate)
 			var timeLeft int
 			var avatarURL string
 			var spouse string
+			profileUserID := userID
+			profileUser := message.Author
 
 			// If user mentions another user, get their profile
 			if len(message.Mentions) > 0 {
@@ -350,6 +1215,8 @@ func createMessage(session *discordgo.Session, message *discordgo.MessageCreate)
 				mentionedUser := message.Mentions[0]
 				mentionedUserID, _:= strconv.Atoi(mentionedUser.ID)
 				mentionedUserName := mentionedUser.Username
+				profileUserID = mentionedUserID
+				profileUser = mentionedUser
 
 				// Get mentioned user's profile
 				user, bal, serverName, timeLeft, spouse = database.GetProfile(MONGO_URI, guildID, guildName, mentionedUserID, mentionedUserName)
@@ -388,6 +1255,57 @@ func createMessage(session *discordgo.Session, message *discordgo.MessageCreate)
 			minutesLeft := int(hoursLeft % 60)
 			secondsLeft := int(minutesLeft % 60)
 
+			// Get level, badges, streak and background
+			extras := database.GetProfileExtras(MONGO_URI, guildID, profileUserID)
+
+			// Try drawing the profile card first
+			// Rendering gives up after a few seconds so the profile never hangs, and the embed is sent instead
+			card := commands.ProfileCard{
+				UserName: user,
+				Title: extras.Title,
+				AvatarURL: profileUser.AvatarURL("256"),
+				Balance: bal,
+				Spouse: spouse,
+				Level: extras.Level,
+				LevelXP: extras.LevelXP,
+				LevelNeeded: extras.LevelNeeded,
+				TriviaStreak: extras.TriviaStreak,
+				BestStreak: extras.BestStreak,
+				Badges: extras.BadgeNames,
+				Background: extras.Background,
+				Health: extras.Health,
+			}
+			png, err := commands.RenderProfileCard(card, 3*time.Second)
+			if err == nil {
+				_, err = session.ChannelMessageSendComplex(message.ChannelID, &discordgo.MessageSend{
+					Files: []*discordgo.File{
+						{
+							Name: "profile.png",
+							ContentType: "image/png",
+							Reader: bytes.NewReader(png),
+						},
+					},
+				})
+				if err == nil {
+					break
+				}
+			}
+			fmt.Printf("Error occurred while sending profile card! %s\n", err)
+
+			if extras.Title != "" {
+				user += " " + extras.Title
+			}
+			badges := extras.Badges
+			if len(extras.SeasonBadges) > 0 {
+				if badges != "" {
+					badges += "\n"
+				}
+				badges += strings.Join(extras.SeasonBadges, "\n")
+			}
+			if badges == "" {
+				badges = "None yet!"
+			}
+
 			// Create embed
 			embed := &discordgo.MessageEmbed{
 				Title: "Profile",
@@ -421,6 +1339,21 @@ func createMessage(session *discordgo.Session, message *discordgo.MessageCreate)
 						Value: strconv.Itoa(hoursLeft) + "h " + strconv.Itoa(minutesLeft) + "m " + strconv.Itoa(secondsLeft) + "s",
 						Inline: true,
 					},
+					{
+						Name: "Level " + strconv.Itoa(extras.Level),
+						Value: database.ProgressBar(extras.LevelXP, extras.LevelNeeded) + " " + strconv.FormatInt(extras.LevelXP, 10) + "/" + strconv.FormatInt(extras.LevelNeeded, 10) + " XP",
+						Inline: true,
+					},
+					{
+						Name: "Health",
+						Value: extras.Health,
+						Inline: true,
+					},
+					{
+						Name: "Badges",
+						Value: badges,
+						Inline: false,
+					},
 				},
 			}
 			// Send embed
@@ -647,7 +1580,7 @@ func createMessage(session *discordgo.Session, message *discordgo.MessageCreate)
 			if err != nil {
 				fmt.Printf("Error converting pinged user ID! %s\n", err)
 			}
-			res := database.UserInteraction(MONGO_URI, guildID, guildName, userID, userName, pingedUser, "rob", 0)
+			res := database.UserInteraction(MONGO_URI, guildID, guildName, userID, userName, message.ChannelID, pingedUser, "rob", 0)
 			session.ChannelMessageSend(message.ChannelID, res)
 
 		// mary pay @user amount -> gives user amount of coins
@@ -671,74 +1604,381 @@ func createMessage(session *discordgo.Session, message *discordgo.MessageCreate)
 			if err != nil {
 				fmt.Printf("Error converting amount! %s\n", err)
 			}
-			res := database.UserInteraction(MONGO_URI, guildID, guildName, userID, userName, pingedUser, "pay", amount)
+			res := database.UserInteraction(MONGO_URI, guildID, guildName, userID, userName, message.ChannelID, pingedUser, "pay", amount)
 			session.ChannelMessageSend(message.ChannelID, res)
 
-		// mary top/leaderboard -> shows users with the most coins in descending order
+		// mary top/leaderboard [optional: metric] [optional: page] -> shows a page of the users with the highest score
 		case strings.ToLower(command[1]) == "leaderboard" || strings.ToLower(command[1]) == "top":
-			err, res := database.Leaderboard(MONGO_URI, guildID)
+			// The metric and page can be given in either order, e.g. mary top 2 or mary top networth 2
+			metric := ""
+			page := 1
+			for _, arg := range command[2:] {
+				if num, err := strconv.Atoi(arg); err == nil {
+					page = num
+				} else {
+					metric = arg
+				}
+			}
+			guildIconURL := ""
+			if guild != nil {
+				guildIconURL = guild.IconURL()
+			}
+			err, res := database.Leaderboard(MONGO_URI, guildID, guildIconURL, metric, page)
 			if err != "" { // Different error than usual
 				session.ChannelMessageSend(message.ChannelID, err)
+				break
 			}
-				
-			// Get profile picture of the server
-			mary, err3 := discordgo.New("Bot " + os.Getenv("TOKEN"))
-			if err3 != nil {
-				session.ChannelMessageSend(message.ChannelID, "Error retrieving server profile picture!")
+			session.ChannelMessageSendEmbed(message.ChannelID, res)
+
+		// mary global [join/leave/profile/top/enable/disable] -> the leaderboard and profile across every server
+		case strings.ToLower(command[1]) == "global":
+			if len(command) < 3 {
+				session.ChannelMessageSend(message.ChannelID, "Please use `mary global join`, `mary global leave`, `mary global profile [optional: @user]` or `mary global top [optional: metric] [optional: page number]`!")
+				break
 			}
-			guild, err4 := mary.Guild(message.Message.GuildID)
-			if err4 != nil {
-				session.ChannelMessageSend(message.ChannelID, "Error retrieving server profile picture!")
+			switch strings.ToLower(command[2]) {
+			case "join", "leave":
+				res := database.SetGlobalOptIn(MONGO_URI, guildID, guildName, userID, userName, strings.ToLower(command[2]) == "join")
+				session.ChannelMessageSend(message.ChannelID, res)
+			case "enable", "disable": // Admin only
+				if !commands.IsAdmin(session, message, userID) {
+					session.ChannelMessageSend(message.ChannelID, "Apologies, this command is not available to you.")
+					break
+				}
+				res := database.SetGuildGlobalOptOut(MONGO_URI, guildID, strings.ToLower(command[2]) == "disable")
+				session.ChannelMessageSend(message.ChannelID, res)
+			case "profile":
+				profileUserID := userID
+				profileUserName := userName
+				if len(message.Mentions) > 0 {
+					profileUserID, _ = strconv.Atoi(message.Mentions[0].ID)
+					profileUserName = message.Mentions[0].Username
+				}
+				err, res := database.GlobalProfile(MONGO_URI, profileUserID, profileUserName)
+				if err != "" {
+					session.ChannelMessageSend(message.ChannelID, err)
+					break
+				}
+				session.ChannelMessageSendEmbed(message.ChannelID, res)
+			case "top", "leaderboard":
+				metric := ""
+				page := 1
+				for _, arg := range command[3:] {
+					if num, err := strconv.Atoi(arg); err == nil {
+						page = num
+					} else {
+						metric = arg
+					}
+				}
+				err, res := database.GlobalLeaderboard(MONGO_URI, metric, page)
+				if err != "" {
+					session.ChannelMessageSend(message.ChannelID, err)
+					break
+				}
+				session.ChannelMessageSendEmbed(message.ChannelID, res)
+			default:
+				session.ChannelMessageSend(message.ChannelID, "Please use `mary global join`, `mary global leave`, `mary global profile [optional: @user]` or `mary global top [optional: metric] [optional: page number]`!")
 			}
-			guildIconURL := guild.IconURL()
 
-			// Create rich embed 
-			embed := &discordgo.MessageEmbed{
-				Title: "Leaderboard",
-				Color: 0xffc0cb,
-				Thumbnail: &discordgo.MessageEmbedThumbnail{
-					URL: guildIconURL,
-				},
+		// mary season [weekly/monthly/history/channel/reset] -> leaderboards of what users earned this week or month
+		case strings.ToLower(command[1]) == "season" || strings.ToLower(command[1]) == "seasons":
+			sub := ""
+			if len(command) > 2 {
+				sub = strings.ToLower(command[2])
+			}
+			switch sub {
+			case "history", "archive":
+				period := ""
+				number := 0
+				for _, arg := range command[3:] {
+					if num, err := strconv.Atoi(arg); err == nil {
+						number = num
+					} else {
+						period = arg
+					}
+				}
+				err, res := database.SeasonHistory(MONGO_URI, guildID, period, number)
+				if err != "" {
+					session.ChannelMessageSend(message.ChannelID, err)
+					break
+				}
+				session.ChannelMessageSendEmbed(message.ChannelID, res)
+			case "channel": // Admin only
+				if !commands.IsAdmin(session, message, userID) {
+					session.ChannelMessageSend(message.ChannelID, "Apologies, this command is not available to you.")
+					break
+				}
+				channelID := ""
+				if len(command) > 3 {
+					channelID = strings.TrimSuffix(strings.TrimPrefix(command[3], "<#"), ">")
+					if _, err := strconv.Atoi(channelID); err != nil && strings.ToLower(command[3]) != "none" {
+						session.ChannelMessageSend(message.ChannelID, "Please mention a channel, or use `none` to stop announcements!")
+						break
+					} else if err != nil {
+						channelID = ""
+					}
+				} else {
+					channelID = message.ChannelID
+				}
+				res := database.SetAnnounceChannel(MONGO_URI, guildID, channelID)
+				session.ChannelMessageSend(message.ChannelID, res)
+			case "reset": // Admin only
+				if !commands.IsAdmin(session, message, userID) {
+					session.ChannelMessageSend(message.ChannelID, "Apologies, this command is not available to you.")
+					break
+				}
+				session.ChannelMessageSend(message.ChannelID, "This will archive everyone's balance and set it back to 0. The top 3 will get a badge. Type `confirm` within 30 seconds to reset the economy.")
+				reply, ok := commands.Await(commands.WaitKey{UserID: message.Author.ID, ChannelID: message.ChannelID}, 30*time.Second)
+				if !ok || reply.Message == nil || strings.ToLower(strings.TrimSpace(reply.Message.Content)) != "confirm" {
+					session.ChannelMessageSend(message.ChannelID, "The economy reset was cancelled.")
+					break
+				}
+				err, res := database.ResetEconomy(MONGO_URI, guildID)
+				if err != "" {
+					session.ChannelMessageSend(message.ChannelID, err)
+					break
+				}
+				session.ChannelMessageSend(message.ChannelID, "The economy has been reset! Everyone starts again from 0 coins.")
+				session.ChannelMessageSendEmbed(message.ChannelID, res)
+			default:
+				err, res := database.SeasonStandings(MONGO_URI, guildID, guildName, userID, userName, sub)
+				if err != "" {
+					session.ChannelMessageSend(message.ChannelID, err)
+					break
+				}
+				session.ChannelMessageSendEmbed(message.ChannelID, res)
 			}
 
-			// Use the values from res dict to create fields
-			for _, data := range res {
-				fields := []*discordgo.MessageEmbedField{
-					{
-						Name: "Rank",
-						Value: strconv.Itoa(int(data["Rank"].(int))),
-						Inline: true,
-					},{
-						Name: "Name",
-						Value: data["Name"].(string),
-						Inline: true,
-					},{
-						Name: "Balance",
-						Value: strconv.FormatInt(data["Balance"].(int64), 10),
-						Inline: true,
-					},
+		// mary level [optional: @user] -> shows a user's level and what the next levels unlock
+		case (strings.ToLower(command[1]) == "level" || strings.ToLower(command[1]) == "xp") && len(command) <= 3:
+			levelUserID := userID
+			levelUserName := userName
+			if len(message.Mentions) > 0 {
+				levelUserID, _ = strconv.Atoi(message.Mentions[0].ID)
+				levelUserName = message.Mentions[0].Username
+			}
+			err, res := database.Level(MONGO_URI, guildID, guildName, levelUserID, levelUserName)
+			if err != "" {
+				session.ChannelMessageSend(message.ChannelID, err)
+				break
+			}
+			session.ChannelMessageSendEmbed(message.ChannelID, res)
+
+		// mary levels [announce/role] (admin only) -> sets up level up announcements and role rewards
+		case strings.ToLower(command[1]) == "levels":
+			if !commands.IsAdmin(session, message, userID) {
+				session.ChannelMessageSend(message.ChannelID, "Apologies, this command is not available to you.")
+				break
+			}
+			sub := ""
+			if len(command) > 2 {
+				sub = strings.ToLower(command[2])
+			}
+			switch {
+			case sub == "announce" && len(command) == 4:
+				// here -> the channel the level up happened in, off -> no announcements, #channel -> always that channel
+				setting := strings.ToLower(command[3])
+				switch setting {
+				case "here":
+					setting = ""
+				case "off":
+				default:
+					setting = strings.TrimSuffix(strings.TrimPrefix(command[3], "<#"), ">")
+					if _, err := strconv.Atoi(setting); err != nil {
+						session.ChannelMessageSend(message.ChannelID, "Please use `here`, `off` or mention a channel!")
+						return
+					}
+				}
+				res := database.SetLevelAnnounce(MONGO_URI, guildID, setting)
+				session.ChannelMessageSend(message.ChannelID, res)
+			case sub == "role" && len(command) == 5:
+				level, err := strconv.Atoi(command[3])
+				if err != nil {
+					session.ChannelMessageSend(message.ChannelID, "Please enter a valid level!")
+					break
+				}
+				roleID := ""
+				if strings.ToLower(command[4]) != "none" {
+					roleID = strings.TrimSuffix(strings.TrimPrefix(command[4], "<@&"), ">")
+					if _, err := strconv.Atoi(roleID); err != nil {
+						session.ChannelMessageSend(message.ChannelID, "Please mention a role, or use `none` to remove the reward!")
+						break
+					}
 				}
-				embed.Fields = append(embed.Fields, fields...)
+				res := database.SetLevelRole(MONGO_URI, guildID, level, roleID)
+				session.ChannelMessageSend(message.ChannelID, res)
+			default:
+				session.ChannelMessageSend(message.ChannelID, "Please use `mary levels announce [here/off/#channel]` or `mary levels role [level] [@role/none]`!")
 			}
-			session.ChannelMessageSendEmbed(message.ChannelID, embed)
+
+		// mary achievements [optional: @user] -> shows unlocked achievements and progress on the rest
+		case strings.ToLower(command[1]) == "achievements" || strings.ToLower(command[1]) == "badges":
+			achievementsUserID := userID
+			achievementsUserName := userName
+			if len(message.Mentions) > 0 {
+				achievementsUserID, _ = strconv.Atoi(message.Mentions[0].ID)
+				achievementsUserName = message.Mentions[0].Username
+			}
+			err, res := database.Achievements(MONGO_URI, guildID, guildName, achievementsUserID, achievementsUserName)
+			if err != "" {
+				session.ChannelMessageSend(message.ChannelID, err)
+				break
+			}
+			session.ChannelMessageSendEmbed(message.ChannelID, res)
+
+		// mary title [optional: title/none] -> lists your titles, or picks the one shown on your profile
+		case strings.ToLower(command[1]) == "title":
+			title := strings.Join(command[2:], " ")
+			res := database.SetTitle(MONGO_URI, guildID, guildName, userID, userName, title)
+			session.ChannelMessageSend(message.ChannelID, res)
+
+		// mary background [name/none] -> puts a background you have bought on your profile card
+		case strings.ToLower(command[1]) == "background":
+			if len(command) == 2 {
+				session.ChannelMessageSend(message.ChannelID, "Please choose a background of " + strings.Join(database.ProfileBackgrounds, ", ") + " or none!")
+				break
+			}
+			res := database.SetBackground(MONGO_URI, guildID, guildName, userID, userName, command[2])
+			session.ChannelMessageSend(message.ChannelID, res)
+
+		// mary quests [optional: claim] -> shows your daily and weekly quests, or collects the rewards for finished ones
+		case strings.ToLower(command[1]) == "quests" || strings.ToLower(command[1]) == "quest":
+			if len(command) > 2 && strings.ToLower(command[2]) == "claim" {
+				res := database.ClaimQuests(MONGO_URI, guildID, guildName, userID, userName)
+				session.ChannelMessageSend(message.ChannelID, res)
+				break
+			}
+			err, res := database.Quests(MONGO_URI, guildID, guildName, userID, userName)
+			if err != "" {
+				session.ChannelMessageSend(message.ChannelID, err)
+				break
+			}
+			session.ChannelMessageSendEmbed(message.ChannelID, res)
+
+		// mary rank [optional: @user] [optional: metric] -> shows where a user is on a leaderboard
+		case strings.ToLower(command[1]) == "rank":
+			rankUserID := userID
+			rankUserName := userName
+			if len(message.Mentions) > 0 {
+				rankUserID, _ = strconv.Atoi(message.Mentions[0].ID)
+				rankUserName = message.Mentions[0].Username
+			}
+			metric := ""
+			for _, arg := range command[2:] {
+				if !strings.HasPrefix(arg, "<@") {
+					metric = arg
+				}
+			}
+			res := database.Rank(MONGO_URI, guildID, guildName, rankUserID, rankUserName, metric)
+			session.ChannelMessageSend(message.ChannelID, res)
 
 		// mary trivia -> starts a trivia game
 		case strings.ToLower(command[1]) == "trivia" || strings.ToLower(command[1]) == "triv" || strings.ToLower(command[1]) == "quiz":
-			gambleAmount := 0
-			if len(command) == 3 {
-				// Check if user specified a valid amount to gamble
-				if valid.IsInt(command[2]) == false {
-					session.ChannelMessageSend(message.ChannelID, "Please specify a valid amount to gamble!")
-					return
-				} else {
-					res, err := strconv.Atoi(command[2])
-					if err != nil {
-						fmt.Printf("Error converting gamble amount! %s\n", err)
-					}
-					gambleAmount = res
-					session.ChannelMessageSend(message.ChannelID, "Gambling " + command[2] + " coins. Checking balance...")
-					time.Sleep(1 * time.Second)
+			// mary trivia categories -> shows every category
+			if len(command) == 3 && strings.ToLower(command[2]) == "categories" {
+				err, res := database.TriviaCategoryList(MONGO_URI, guildID)
+				if err != "" {
+					session.ChannelMessageSend(message.ChannelID, err)
+					break
+				}
+				session.ChannelMessageSendEmbed(message.ChannelID, res)
+				break
+			}
+
+			// mary trivia stats [@user] -> shows trivia accuracy, streaks and net coins
+			if len(command) > 2 && strings.ToLower(command[2]) == "stats" {
+				statsUserID := userID
+				statsUserName := userName
+				if len(message.Mentions) > 0 {
+					statsUserID, _ = strconv.Atoi(message.Mentions[0].ID)
+					statsUserName = message.Mentions[0].Username
+				}
+				err, res := database.TriviaStats(MONGO_URI, guildID, guildName, statsUserID, statsUserName)
+				if err != "" {
+					session.ChannelMessageSend(message.ChannelID, err)
+					break
+				}
+				session.ChannelMessageSendEmbed(message.ChannelID, res)
+				break
+			}
+
+			// mary trivia top/leaderboard -> shows the users with the best trivia accuracy
+			if len(command) == 3 && (strings.ToLower(command[2]) == "top" || strings.ToLower(command[2]) == "leaderboard") {
+				err, res := database.TriviaLeaderboard(MONGO_URI, guildID)
+				if err != "" {
+					session.ChannelMessageSend(message.ChannelID, err)
+					break
+				}
+				session.ChannelMessageSendEmbed(message.ChannelID, res)
+				break
+			}
+
+			// mary trivia game [rounds] [entry fee] [speed] [category] [difficulty] [truefalse] -> starts a trivia game anyone in the channel can answer
+			if len(command) > 2 && strings.ToLower(command[2]) == "game" {
+				gameOpts, optsErr := database.ParseTriviaGameOptions(command[3:])
+				if optsErr != "" {
+					session.ChannelMessageSend(message.ChannelID, optsErr)
+					break
+				}
+				res := database.TriviaGame(session, message.ChannelID, MONGO_URI, guildID, guildName, userID, userName, gameOpts)
+				if res != "" {
+					session.ChannelMessageSend(message.ChannelID, res)
+				}
+				break
+			}
+
+			// mary trivia tournament [minutes] [rounds] [entry fee] ... (admin only) -> announces a trivia game that starts later
+			if len(command) > 2 && strings.ToLower(command[2]) == "tournament" {
+				if !commands.IsAdmin(session, message, userID) {
+					session.ChannelMessageSend(message.ChannelID, "Apologies, this command is not available to you.")
+					break
+				}
+				if len(command) == 3 {
+					session.ChannelMessageSend(message.ChannelID, "Please specify how many minutes until the tournament starts!")
+					break
 				}
+				minutes, err := strconv.Atoi(command[3])
+				if err != nil {
+					session.ChannelMessageSend(message.ChannelID, "Please specify a valid number of minutes!")
+					break
+				}
+				gameOpts, optsErr := database.ParseTriviaGameOptions(command[4:])
+				if optsErr != "" {
+					session.ChannelMessageSend(message.ChannelID, optsErr)
+					break
+				}
+				res := database.ScheduleTriviaTournament(session, message.ChannelID, MONGO_URI, guildID, guildName, minutes, gameOpts)
+				if res != "" {
+					session.ChannelMessageSend(message.ChannelID, res)
+				}
+				break
+			}
+
+			// mary trivia allow/disallow [category] (admin only) -> restricts the categories for this server
+			if len(command) > 2 && (strings.ToLower(command[2]) == "allow" || strings.ToLower(command[2]) == "disallow") {
+				if !commands.IsAdmin(session, message, userID) {
+					session.ChannelMessageSend(message.ChannelID, "Apologies, this command is not available to you.")
+					break
+				}
+				if len(command) == 3 {
+					session.ChannelMessageSend(message.ChannelID, "Please specify a category!")
+					break
+				}
+				res := database.RestrictTrivia(MONGO_URI, guildID, strings.Join(command[3:], " "), strings.ToLower(command[2]) == "allow")
+				session.ChannelMessageSend(message.ChannelID, res)
+				break
+			}
+
+			// mary trivia [category] [easy|medium|hard] [truefalse] [amount]
+			opts, optsErr := database.ParseTriviaOptions(command[2:])
+			if optsErr != "" {
+				session.ChannelMessageSend(message.ChannelID, optsErr)
+				return
+			}
+			gambleAmount := opts.Amount
+			if gambleAmount != 0 {
+				session.ChannelMessageSend(message.ChannelID, "Gambling " + strconv.Itoa(gambleAmount) + " coins. Checking balance...")
+				time.Sleep(1 * time.Second)
 			}
 			// Check if user has enough coins to gamble
 			// The reason we check it here is so that if the user hasn't been added to the database yet, they will be added
@@ -748,41 +1988,47 @@ func createMessage(session *discordgo.Session, message *discordgo.MessageCreate)
 				return
 			}
 
-			err, res, correctAnswer, difficulty := database.Trivia(session, message, MONGO_URI, guildID, guildName, userID, userName)
+			err, res, correctAnswer, question := database.Trivia(session, message, MONGO_URI, guildID, guildName, userID, userName, opts)
 			if err != "" {
 				session.ChannelMessageSend(message.ChannelID, err)
 			} else {
 				session.ChannelMessageSendEmbed(message.ChannelID, res)
 
 				// Wait for user to respond
+				askedAt := time.Now()
 				msg, err := database.WaitForResponse(session, message.ChannelID, message.Author.ID)
 				if err != nil {
 					session.ChannelMessageSend(message.ChannelID, "Error waiting for response!")
 				}
+				responseTime := time.Since(askedAt)
 				if msg == "You ran out of time!" {
+					// Running out of time counts as a wrong answer in the user's stats
+					database.RecordTriviaAnswer(MONGO_URI, guildID, userID, message.ChannelID, question, false, responseTime, gambleAmount)
 					session.ChannelMessageSend(message.ChannelID, msg)
 					return
 				}
 
+				// Record the answer before paying so that the streak bonus is up to date
+				correct := database.IsCorrectAnswer(msg, correctAnswer, question)
+				recordErr := database.RecordTriviaAnswer(MONGO_URI, guildID, userID, message.ChannelID, question, correct, responseTime, gambleAmount)
+				if recordErr != "" {
+					session.ChannelMessageSend(message.ChannelID, recordErr)
+				}
+
 				// Check if user's response is correct
-				if strings.ToLower(msg) == strings.ToLower(correctAnswer) {
+				if correct {
 					session.ChannelMessageSend(message.ChannelID, "Correct!")
-					// Give user coins based on difficulty
+					// Give user coins based on difficulty and question type
 					// If the user gambled coins, pay them differently 
-					res := ""
-					if gambleAmount != 0 {
-						res = database.PayForCorrectAnswer(session, message, difficulty, MONGO_URI, guildID, guildName, userID, userName, gambleAmount)
-					} else {
-						res = database.PayForCorrectAnswer(session, message, difficulty, MONGO_URI, guildID, guildName, userID, userName, 0)
-					}
+					res := database.PayForCorrectAnswer(session, message, question.Difficulty, question.Type, MONGO_URI, guildID, guildName, userID, userName, gambleAmount)
 					session.ChannelMessageSend(message.ChannelID, res)
 				} else {
-					session.ChannelMessageSend(message.ChannelID, "Incorrect! The correct answer is " + correctAnswer + ".")
+					session.ChannelMessageSend(message.ChannelID, "Incorrect! The correct answer is " + correctAnswer + ") " + question.Correct + ".")
 					// If the user gambled coins, take them away
 					if gambleAmount != 0 {
-						res := database.PayForCorrectAnswer(session, message, difficulty, MONGO_URI, guildID, guildName, userID, userName, -gambleAmount)
+						res := database.PayForCorrectAnswer(session, message, question.Difficulty, question.Type, MONGO_URI, guildID, guildName, userID, userName, -gambleAmount)
 						_ = res // Unused variable
-						session.ChannelMessageSend(message.ChannelID, "<@" + strconv.Itoa(userID) + ">, you lose. -" + command[2] + " coins.")
+						session.ChannelMessageSend(message.ChannelID, "<@" + strconv.Itoa(userID) + ">, you lose. -" + strconv.Itoa(gambleAmount) + " coins.")
 				}
 			}
 		}
@@ -847,8 +2093,16 @@ func createMessage(session *discordgo.Session, message *discordgo.MessageCreate)
 			words := strings.Fields(message.Content)
 			item := strings.ToLower(words[2])
 			switch item {
+			case "galaxy", "sakura", "ocean": { // mary use galaxy/sakura/ocean
+				res := database.SetBackground(MONGO_URI, guildID, guildName, userID, userName, item)
+				session.ChannelMessageSend(message.ChannelID, res)
+			}
 			case "chocolate": { // mary use chocolate 
-				res := database.Use(MONGO_URI, guildID, guildName, userID, userName, "chocolate", 0)
+				res := database.Use(MONGO_URI, guildID, guildName, userID, userName, message.ChannelID, "chocolate", 0)
+				session.ChannelMessageSend(message.ChannelID, res)
+			}
+			case "bandage", "painkillers", "medkit", "giftbox": { // mary use bandage/painkillers/medkit/giftbox
+				res := database.Use(MONGO_URI, guildID, guildName, userID, userName, message.ChannelID, item, 0)
 				session.ChannelMessageSend(message.ChannelID, res)
 			}
 			case "car": { // mary use car @target
@@ -872,7 +2126,7 @@ func createMessage(session *discordgo.Session, message *discordgo.MessageCreate)
 					session.ChannelMessageSend(message.ChannelID, "You can't run yourself over!")
 					break
 				}
-				res := database.Use(MONGO_URI, guildID, guildName, userID, userName, "car", pingedUserID)
+				res := database.Use(MONGO_URI, guildID, guildName, userID, userName, message.ChannelID, "car", pingedUserID)
 				session.ChannelMessageSend(message.ChannelID, res)
 			}
 			case "gun": { // mary use gun @target
@@ -896,10 +2150,10 @@ func createMessage(session *discordgo.Session, message *discordgo.MessageCreate)
 					session.ChannelMessageSend(message.ChannelID, "You can't rob yourself!")
 					break
 				}
-				res := database.Use(MONGO_URI, guildID, guildName, userID, userName, "gun", pingedUserID)
+				res := database.Use(MONGO_URI, guildID, guildName, userID, userName, message.ChannelID, "gun", pingedUserID)
 				session.ChannelMessageSend(message.ChannelID, res)
 			} 
-			case "bow": { // mary use bow @target [optional: amount]
+			case "bow", "crossbow": { // mary use bow/crossbow @target [optional: amount]
 				// Check if the user has specified a target
 				if len(words) < 4 {
 					session.ChannelMessageSend(message.ChannelID, "Please specify a target!")
@@ -915,12 +2169,12 @@ func createMessage(session *discordgo.Session, message *discordgo.MessageCreate)
 					session.ChannelMessageSend(message.ChannelID, "Please specify a valid target!")
 					break
 				}
-				// Make sure the user doesn't use the bow on themselves
+				// Make sure the user doesn't use the bow or crossbow on themselves
 				if pingedUserID == userID {
 					session.ChannelMessageSend(message.ChannelID, "You can't rob yourself!")
 					break
 				}
-				res := database.Use(MONGO_URI, guildID, guildName, userID, userName, "bow", pingedUserID)
+				res := database.Use(MONGO_URI, guildID, guildName, userID, userName, message.ChannelID, item, pingedUserID)
 				session.ChannelMessageSend(message.ChannelID, res)
 			}
 			case "ring": { // mary use ring @target
@@ -943,8 +2197,11 @@ func createMessage(session *discordgo.Session, message *discordgo.MessageCreate)
 					session.ChannelMessageSend(message.ChannelID, "You can't marry yourself!")
 					break
 				}
-				res := database.Use(MONGO_URI, guildID, guildName, userID, userName, "ring", pingedUserID)
+				res := database.Use(MONGO_URI, guildID, guildName, userID, userName, message.ChannelID, "ring", pingedUserID)
 				session.ChannelMessageSend(message.ChannelID, res)
+				if strings.HasPrefix(res, "You proposed to") && len(message.Mentions) > 0 {
+					database.AwaitProposal(session, message.ChannelID, MONGO_URI, guildID, guildName, userID, userName, pingedUserID, message.Mentions[0].Username)
+				}
 			}
 		}
 
@@ -958,7 +2215,7 @@ func createMessage(session *discordgo.Session, message *discordgo.MessageCreate)
 			item := strings.ToLower(words[2])
 			switch item {
 			case "chocolate": { // mary eat chocolate
-				res := database.Use(MONGO_URI, guildID, guildName, userID, userName, "chocolate", 0)
+				res := database.Use(MONGO_URI, guildID, guildName, userID, userName, message.ChannelID, "chocolate", 0)
 				session.ChannelMessageSend(message.ChannelID, res)
 				}
 			default: {
@@ -984,7 +2241,7 @@ func createMessage(session *discordgo.Session, message *discordgo.MessageCreate)
 					session.ChannelMessageSend(message.ChannelID, "You can't rob yourself!")
 					break
 				}
-				res := database.Use(MONGO_URI, guildID, guildName, userID, userName, "car", pingedUserID)
+				res := database.Use(MONGO_URI, guildID, guildName, userID, userName, message.ChannelID, "car", pingedUserID)
 				session.ChannelMessageSend(message.ChannelID, res)
 		}
 
@@ -1010,9 +2267,9 @@ func createMessage(session *discordgo.Session, message *discordgo.MessageCreate)
 				session.ChannelMessageSend(message.ChannelID, "You can't rob yourself!")
 				break
 			}
-			res := database.Use(MONGO_URI, guildID, guildName, userID, userName, "gun", pingedUserID)
+			res := database.Use(MONGO_URI, guildID, guildName, userID, userName, message.ChannelID, "gun", pingedUserID)
 			if res == "You do not have that item in your inventory!" || res == "You do not have enough of that item in your inventory to use!" {
-				res = database.Use(MONGO_URI, guildID, guildName, userID, userName, "bow", pingedUserID)
+				res = database.Use(MONGO_URI, guildID, guildName, userID, userName, message.ChannelID, "bow", pingedUserID)
 			}
 			session.ChannelMessageSend(message.ChannelID, res)
 		}
@@ -1038,7 +2295,7 @@ func createMessage(session *discordgo.Session, message *discordgo.MessageCreate)
 				session.ChannelMessageSend(message.ChannelID, "You can't rob yourself!")
 				break
 			}
-			res := database.Use(MONGO_URI, guildID, guildName, userID, userName, "gun", pingedUserID)
+			res := database.Use(MONGO_URI, guildID, guildName, userID, userName, message.ChannelID, "gun", pingedUserID)
 			session.ChannelMessageSend(message.ChannelID, res)
 		}
 
@@ -1062,8 +2319,11 @@ func createMessage(session *discordgo.Session, message *discordgo.MessageCreate)
 				session.ChannelMessageSend(message.ChannelID, "You can't marry yourself!")
 				break
 			}
-			res := database.Use(MONGO_URI, guildID, guildName, userID, userName, "ring", pingedUserID)
+			res := database.Use(MONGO_URI, guildID, guildName, userID, userName, message.ChannelID, "ring", pingedUserID)
 			session.ChannelMessageSend(message.ChannelID, res)
+			if strings.HasPrefix(res, "You proposed to") && len(message.Mentions) > 0 {
+				database.AwaitProposal(session, message.ChannelID, MONGO_URI, guildID, guildName, userID, userName, pingedUserID, message.Mentions[0].Username)
+			}
 		}
 
 		case strings.ToLower(command[1]) == "divorce": {